@@ -0,0 +1,171 @@
+// Command backtest runs agents' decision cycles over historical bars without
+// touching the database and writes the equity curve, trades and statistics.
+//
+//	go run ./cmd/backtest -bars data/bars -models deepseek-chat,gpt-4o-mini -from 2025-01-02 -to 2025-02-01
+//	go run ./cmd/backtest -bars data/THYAO.csv -news data/news.json -every 1h -out-json result.json -out-equity equity.csv -out-trades trades.csv
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/backtest"
+	"github.com/1batu/market-ai/internal/config"
+	"github.com/1batu/market-ai/internal/simulation"
+	"github.com/1batu/market-ai/pkg/logger"
+)
+
+func main() {
+	barsFlag := flag.String("bars", "", "bar CSV file or directory of <SYMBOL>.csv files")
+	newsFlag := flag.String("news", "", "news articles as a JSON array (optional)")
+	fromFlag := flag.String("from", "", "first decision time (RFC3339 or YYYY-MM-DD, default first bar)")
+	toFlag := flag.String("to", "", "last decision time (RFC3339 or YYYY-MM-DD, default last bar)")
+	every := flag.Duration("every", 0, "decision interval (default every bar)")
+	balance := flag.Float64("balance", backtest.DefaultInitialBalance, "starting cash per agent")
+	modelsFlag := flag.String("models", "", "comma separated models; one agent per model")
+	strategy := flag.String("strategy", "balanced", "agent strategy")
+	promptSet := flag.String("prompt-set", "", "prompt set (default: the one selected for the strategy)")
+	outJSON := flag.String("out-json", "", "write the full result as JSON")
+	outEquity := flag.String("out-equity", "", "write the equity curve as CSV")
+	outTrades := flag.String("out-trades", "", "write the trade list as CSV")
+	flag.Parse()
+
+	if *barsFlag == "" || *modelsFlag == "" {
+		fmt.Fprintln(os.Stderr, "-bars and -models are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	logger.Init(cfg.Log.Level)
+
+	var from, to time.Time
+	if *fromFlag != "" {
+		if from, err = simulation.ParseTime(*fromFlag); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -from")
+		}
+	}
+	if *toFlag != "" {
+		if to, err = simulation.ParseTime(*toFlag); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -to")
+		}
+	}
+
+	// Bars of the week before -from give the first decisions a previous close and candles
+	var barsFrom time.Time
+	if !from.IsZero() {
+		barsFrom = from.AddDate(0, 0, -7)
+	}
+	bars, err := simulation.LoadCSV(*barsFlag, barsFrom, to)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load bars")
+	}
+	var news *backtest.NewsStore
+	if *newsFlag != "" {
+		if news, err = backtest.LoadNews(*newsFlag); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load news")
+		}
+	}
+
+	prompts, err := ai.NewPromptRegistry(cfg.AI.PromptDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load prompt templates")
+	}
+	clients := backtestClients(cfg.AI)
+	var agents []backtest.Agent
+	for _, m := range strings.Split(*modelsFlag, ",") {
+		m = strings.TrimSpace(m)
+		client, ok := clients[m]
+		if !ok {
+			log.Fatal().Str("model", m).Msg("Unknown model")
+		}
+		agents = append(agents, backtest.Agent{
+			Name:     m,
+			Strategy: *strategy,
+			Decider:  &backtest.AIDecider{Client: client, Prompts: prompts, PromptSet: *promptSet, TokenBudget: cfg.AI.PromptTokenBudget},
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	market := backtest.NewMarket(bars)
+	log.Info().
+		Int("bars", len(bars)).
+		Int("symbols", len(market.Symbols())).
+		Str("bar_interval", market.Interval().String()).
+		Int("news", news.Len()).
+		Int("agents", len(agents)).
+		Msg("Backtest started")
+
+	cfgRun := backtest.Config{Start: from, End: to, Every: *every, InitialBalance: *balance}
+	result, err := backtest.NewEngine(cfgRun, market, news, agents).Run(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Backtest failed")
+	}
+
+	writeFile(*outJSON, result.WriteJSON)
+	writeFile(*outEquity, result.WriteEquityCSV)
+	writeFile(*outTrades, result.WriteTradesCSV)
+
+	fmt.Printf("%s -> %s (%s bars)\n", result.Start.Format(time.RFC3339), result.End.Format(time.RFC3339), result.Interval)
+	for _, s := range result.Summaries {
+		fmt.Printf("%-24s equity %12.2f  return %7.2f%%  max DD %6.2f%%  sharpe %5.2f  trades %4d  win %5.1f%%  rejected %d  errors %d\n",
+			s.Agent, s.FinalEquity, s.ReturnPercent, s.MaxDrawdownPercent, s.SharpeRatio, s.Trades, s.WinRate, s.Rejected, s.Errors)
+	}
+}
+
+// backtestClients creates a client for every configured model, like the
+// replay command: premium models are not filtered
+func backtestClients(cfg config.AIConfig) map[string]ai.Client {
+	clients := map[string]ai.Client{}
+	for _, c := range []ai.Client{
+		ai.NewOpenAIClient(cfg.OpenAIKey, cfg.GPTModel),
+		ai.NewOpenAIClient(cfg.OpenAIKey, cfg.GPT4MiniModel),
+		ai.NewAnthropicClient(cfg.AnthropicKey, cfg.ClaudeModel),
+		ai.NewDeepSeekClient(cfg.DeepSeekKey, cfg.DeepSeekModel),
+		ai.NewGroqClient(cfg.GroqKey, cfg.GroqModel),
+		ai.NewMistralClient(cfg.MistralKey, cfg.MistralModel),
+		ai.NewXAIClient(cfg.XAIKey, cfg.XAIModel),
+	} {
+		clients[c.GetModelName()] = c
+	}
+	if c, err := ai.NewGoogleClient(cfg.GoogleKey, cfg.GoogleModel); err == nil {
+		clients[c.GetModelName()] = c
+	}
+	if cfg.EnsembleMembers != "" {
+		if ens, _ := ai.EnsembleFromSpec(cfg.EnsembleMembers, cfg.EnsembleJudge, clients); ens != nil {
+			clients[ens.GetModelName()] = ens
+		}
+	}
+	return clients
+}
+
+// writeFile writes an output when its path is set
+func writeFile(path string, write func(io.Writer) error) {
+	if path == "" {
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("Failed to create output")
+	}
+	if err := write(f); err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("Failed to write output")
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal().Err(err).Str("path", path).Msg("Failed to write output")
+	}
+}
@@ -0,0 +1,182 @@
+// Command replay re-runs stored agent decisions on their audited prompts with
+// the same or another model and prints how the new decisions differ.
+//
+//	go run ./cmd/replay -decision <id> [-model deepseek-chat] [-prompt-set default-v1-tr]
+//	go run ./cmd/replay -from 2025-01-02T10:00:00Z -to 2025-01-02T18:00:00Z [-agent <id>] [-model ...] [-limit 50]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/config"
+	"github.com/1batu/market-ai/internal/database"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/1batu/market-ai/pkg/logger"
+)
+
+func main() {
+	decisionFlag := flag.String("decision", "", "decision ID to replay")
+	agentFlag := flag.String("agent", "", "only replay decisions of this agent (range mode)")
+	fromFlag := flag.String("from", "", "range start (RFC3339 or YYYY-MM-DD)")
+	toFlag := flag.String("to", "", "range end (RFC3339 or YYYY-MM-DD, default now)")
+	model := flag.String("model", "", "model to replay with (default: the original model)")
+	promptSet := flag.String("prompt-set", "", "replace the system prompt with this prompt set")
+	limit := flag.Int("limit", 20, "maximum decisions to replay in range mode")
+	asJSON := flag.Bool("json", false, "print the full result as JSON")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	logger.Init(cfg.Log.Level)
+
+	db, err := database.NewPostgresPool(cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to PostgreSQL")
+	}
+	defer db.Close()
+
+	prompts, err := ai.NewPromptRegistry(cfg.AI.PromptDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load prompt templates")
+	}
+	replay := services.NewReplayService(db, replayClients(cfg.AI), prompts)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts := models.ReplayOptions{Model: *model, PromptSet: *promptSet}
+
+	if *decisionFlag != "" {
+		id, err := uuid.Parse(*decisionFlag)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid decision ID")
+		}
+		r, err := replay.Replay(ctx, id, opts)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Replay failed")
+		}
+		if *asJSON {
+			printJSON(r)
+			return
+		}
+		printReplay(*r)
+		return
+	}
+
+	if *fromFlag == "" {
+		fmt.Fprintln(os.Stderr, "either -decision or -from is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	req := models.ReplayRangeRequest{ReplayOptions: opts, Limit: *limit}
+	if req.From, err = parseTime(*fromFlag); err != nil {
+		log.Fatal().Err(err).Msg("Invalid -from")
+	}
+	if *toFlag != "" {
+		if req.To, err = parseTime(*toFlag); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -to")
+		}
+	}
+	if *agentFlag != "" {
+		id, err := uuid.Parse(*agentFlag)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid agent ID")
+		}
+		req.AgentID = &id
+	}
+
+	report, err := replay.ReplayRange(ctx, req)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Replay failed")
+	}
+	if *asJSON {
+		printJSON(report)
+		return
+	}
+	for _, r := range report.Results {
+		printReplay(r)
+	}
+	fmt.Printf("\n%d replayed, %d same action (%.1f%%), %d failed\n", report.Total, report.Matching, report.MatchRate, report.Failed)
+}
+
+// replayClients creates a client for every configured model. Unlike the
+// server, premium models are not filtered: replaying with one is an explicit choice.
+func replayClients(cfg config.AIConfig) map[string]ai.Client {
+	clients := map[string]ai.Client{}
+	for _, c := range []ai.Client{
+		ai.NewOpenAIClient(cfg.OpenAIKey, cfg.GPTModel),
+		ai.NewOpenAIClient(cfg.OpenAIKey, cfg.GPT4MiniModel),
+		ai.NewAnthropicClient(cfg.AnthropicKey, cfg.ClaudeModel),
+		ai.NewDeepSeekClient(cfg.DeepSeekKey, cfg.DeepSeekModel),
+		ai.NewGroqClient(cfg.GroqKey, cfg.GroqModel),
+		ai.NewMistralClient(cfg.MistralKey, cfg.MistralModel),
+		ai.NewXAIClient(cfg.XAIKey, cfg.XAIModel),
+	} {
+		clients[c.GetModelName()] = c
+	}
+	if c, err := ai.NewGoogleClient(cfg.GoogleKey, cfg.GoogleModel); err == nil {
+		clients[c.GetModelName()] = c
+	}
+	if cfg.EnsembleMembers != "" {
+		if ens, _ := ai.EnsembleFromSpec(cfg.EnsembleMembers, cfg.EnsembleJudge, clients); ens != nil {
+			clients[ens.GetModelName()] = ens
+		}
+	}
+	return clients
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+func printReplay(r models.DecisionReplay) {
+	fmt.Printf("%s  %s -> %s", r.DecisionID, describe(r.Original), r.Model)
+	switch {
+	case r.Error != "":
+		fmt.Printf("  ERROR: %s\n", r.Error)
+	case r.Diff.SameAction && len(r.Diff.Changes) == 0:
+		fmt.Printf("  %s  identical\n", describe(*r.Replayed))
+	default:
+		verdict := "DIFFERENT"
+		if r.Diff.SameAction {
+			verdict = "same action"
+		}
+		fmt.Printf("  %s  %s (%dms)\n", describe(*r.Replayed), verdict, r.LatencyMs)
+		for _, c := range r.Diff.Changes {
+			fmt.Printf("    - %s\n", c)
+		}
+	}
+}
+
+func describe(d models.ReplayedDecision) string {
+	parts := []string{d.Action}
+	if d.StockSymbol != "" {
+		parts = append(parts, d.StockSymbol)
+	}
+	if d.Quantity > 0 {
+		parts = append(parts, fmt.Sprintf("x%d", d.Quantity))
+	}
+	return fmt.Sprintf("%s (%.0f%%)", strings.Join(parts, " "), d.Confidence)
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
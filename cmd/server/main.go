@@ -12,6 +12,7 @@ import (
 	"github.com/1batu/market-ai/internal/ai"
 	"github.com/1batu/market-ai/internal/api"
 	"github.com/1batu/market-ai/internal/api/handlers"
+	"github.com/1batu/market-ai/internal/badges"
 	"github.com/1batu/market-ai/internal/config"
 	"github.com/1batu/market-ai/internal/database"
 	"github.com/1batu/market-ai/internal/datasources/fusion"
@@ -19,10 +20,13 @@ import (
 	tw "github.com/1batu/market-ai/internal/datasources/twitter"
 	"github.com/1batu/market-ai/internal/datasources/yahoo"
 	"github.com/1batu/market-ai/internal/middleware"
+	"github.com/1batu/market-ai/internal/ranking"
 	"github.com/1batu/market-ai/internal/services"
+	"github.com/1batu/market-ai/internal/simulation"
 	"github.com/1batu/market-ai/internal/websocket"
 	"github.com/1batu/market-ai/pkg/logger"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
 
@@ -99,11 +103,59 @@ func main() {
 		updateInterval,
 		cacheTTL,
 	)
-	go newsAggregator.Start(ctx)
-	log.Info().Dur("interval", updateInterval).Msg("News aggregator started")
+	// Geçmiş oynatmada canlı haber çekilmez; arşivdeki haberler orijinal zamanlarında yayınlanır
+	replaying := cfg.Simulator.Enabled && cfg.Simulator.Replay != ""
+	if !replaying {
+		go newsAggregator.Start(ctx)
+		log.Info().Dur("interval", updateInterval).Msg("News aggregator started")
+	}
+
+	// === MUM OLUŞTURUCU (fiyat güncellemelerinden market_data'ya 1m/5m/15m/1h/1d mumlar) ===
+	candleBuilder := services.NewCandleBuilder(db, 10*time.Second)
+	go candleBuilder.Start(ctx)
+
+	// === PİYASA SİMÜLATÖRÜ (SIMULATOR_ENABLED=true ise; simüle fiyatlar ya da geçmiş oynatma) ===
+	var replayClock *simulation.VirtualClock
+	var orderFlow *simulation.OrderFlow
+	scenarioRunner := services.NewScenarioRunner(db, hub, newsAggregator, cfg.Simulator.ScenarioDir)
+	if cfg.Simulator.Enabled {
+		simulator, clock, err := newMarketSimulator(db, hub, cfg.Simulator)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid simulator configuration")
+		}
+		// market_data oynatılırken mumlar zaten tabloda
+		if cfg.Simulator.Replay != "market_data" {
+			simulator.SetCandleBuilder(candleBuilder)
+		}
+		// Ajanların işlemleri simüle fiyatı iter (oynatmada fiyatlar kayıttan gelir)
+		if cfg.Simulator.Replay == "" && cfg.Simulator.ImpactCoefficient > 0 {
+			orderFlow = simulation.NewOrderFlow()
+			simulator.SetOrderFlow(orderFlow)
+		}
+		if overlay := simulator.Overlay(); overlay != nil {
+			scenarioRunner.SetOverlay(overlay)
+		}
+		if clock != nil {
+			scenarioRunner.SetClock(clock)
+		}
+		replayClock = clock
+		go simulator.Start(ctx)
+	}
+	go scenarioRunner.Start(ctx)
 
 	// === TİCARET MOTORU & RİSK YÖNETİCİSİ ===
 	tradingEngine := services.NewTradingEngine(db)
+	if orderFlow != nil {
+		tradingEngine.SetOrderFlow(orderFlow)
+	}
+	// Rozet kuralları (gömülü varsayılanlar ya da BADGE_RULES_FILE)
+	badgeRules, err := badges.Load(cfg.Leaderboard.BadgeRules)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load badge rules")
+	}
+	badgeEngine := services.NewBadgeEngine(db, hub, badgeRules)
+	tradingEngine.SetBadgeEngine(badgeEngine)
+	go badgeEngine.Start(ctx)
 	riskManager := services.NewRiskManager(db, 5.0, 20.0, 70.0)
 
 	// === AJAN MOTORU (karar aralıkları) ===
@@ -125,6 +177,29 @@ func main() {
 		maxDec,
 	)
 
+	// === PROMPT ŞABLONLARI ===
+	promptRegistry, err := ai.NewPromptRegistry(cfg.AI.PromptDir)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load prompt templates")
+	}
+	if cfg.AI.PromptDefaultSet != "" {
+		if err := promptRegistry.SetDefault(cfg.AI.PromptDefaultSet); err != nil {
+			log.Warn().Err(err).Msg("Ignoring PROMPT_DEFAULT_SET")
+		}
+	}
+	for strategy, set := range ai.ParsePromptStrategies(cfg.AI.PromptStrategies) {
+		if err := promptRegistry.AssignStrategy(strategy, set); err != nil {
+			log.Warn().Err(err).Str("strategy", strategy).Msg("Ignoring prompt strategy mapping")
+		}
+	}
+	agentEngine.SetPromptRegistry(promptRegistry)
+	agentEngine.SetPromptTokenBudget(cfg.AI.PromptTokenBudget)
+	log.Info().Strs("prompt_sets", promptRegistry.Names()).Msg("Prompt templates loaded")
+
+	// Prompt A/B deneyleri (çalışan deney varsa varyant ataması yapar)
+	experimentSvc := services.NewExperimentService(db, promptRegistry)
+	agentEngine.SetExperimentService(experimentSvc)
+
 	// === YZ İSTEMCİLERİ ===
 	openaiClient := ai.NewOpenAIClient(cfg.AI.OpenAIKey, cfg.AI.GPTModel)
 	gpt4MiniClient := ai.NewOpenAIClient(cfg.AI.OpenAIKey, cfg.AI.GPT4MiniModel)
@@ -148,8 +223,41 @@ func main() {
 		cfg.AI.XAIModel:    true,
 	}
 
+	// Topluluk (komite) istemcisi: üyeler model adlarıyla yapılandırılmış istemcilerden seçilir
+	clientsByModel := map[string]ai.Client{}
+	for _, c := range []ai.Client{openaiClient, gpt4MiniClient, claudeClient, deepseekClient, groqClient, mistralClient, xaiClient} {
+		if cfg.AI.EnablePremiumModels || !premiumModels[c.GetModelName()] {
+			clientsByModel[c.GetModelName()] = c
+		}
+	}
+	if geminiClient != nil {
+		clientsByModel[geminiClient.GetModelName()] = geminiClient
+	}
+	var ensembleClient *ai.EnsembleClient
+	if cfg.AI.EnsembleMembers != "" {
+		var missing []string
+		ensembleClient, missing = ai.EnsembleFromSpec(cfg.AI.EnsembleMembers, cfg.AI.EnsembleJudge, clientsByModel)
+		if len(missing) > 0 {
+			log.Warn().Strs("models", missing).Msg("Ensemble members/judge not available, skipping")
+		}
+		if ensembleClient != nil {
+			log.Info().Str("model", ensembleClient.GetModelName()).Msg("Ensemble client configured")
+		}
+	}
+
+	// Karar tekrarı: kayıtlı promptlar aynı ya da farklı bir modelde yeniden çalıştırılır
+	replayClients := map[string]ai.Client{}
+	for name, c := range clientsByModel {
+		replayClients[name] = c
+	}
+	if ensembleClient != nil {
+		replayClients[ensembleClient.GetModelName()] = ensembleClient
+	}
+	replaySvc := services.NewReplayService(db, replayClients, promptRegistry)
+	replaySvc.SetContext(ctx)
+
 	// Tüm bilinen ajanları isim alt dizelerine göre kaydet
-	rows, qerr := db.Query(ctx, "SELECT id, name FROM agents WHERE status = 'active'")
+	rows, qerr := db.Query(ctx, "SELECT id, name FROM agents WHERE status IN ('active', 'shadow')")
 	if qerr == nil {
 		defer rows.Close()
 		for rows.Next() {
@@ -159,6 +267,10 @@ func main() {
 				continue
 			}
 			switch {
+			case strings.Contains(strings.ToLower(name), "ensemble") || strings.Contains(strings.ToLower(name), "committee"):
+				if ensembleClient != nil {
+					agentEngine.RegisterAgent(id, ensembleClient)
+				}
 			case strings.Contains(strings.ToLower(name), "gpt-4o mini") || strings.Contains(strings.ToLower(name), "gpt-4o-mini"):
 				if gpt4MiniClient != nil {
 					agentEngine.RegisterAgent(id, gpt4MiniClient)
@@ -196,6 +308,24 @@ func main() {
 		log.Warn().Err(qerr).Msg("Failed to query agents for registration")
 	}
 
+	// === AJAN HAFIZASI (dersler 10 dk, yansıma 6 saatte bir) ===
+	memorySvc := services.NewMemoryService(db, hub, 10*time.Minute, 6*time.Hour)
+	memorySvc.SetClientLookup(agentEngine.Client)
+	agentEngine.SetMemoryService(memorySvc)
+	go memorySvc.Start(ctx)
+
+	// === İŞLEM ÖNERİLERİ (onay modundaki ajanlar) ===
+	proposalSvc := services.NewProposalService(db, hub, tradingEngine, riskManager, time.Duration(cfg.AI.ProposalTTL)*time.Second)
+	agentEngine.SetProposalService(proposalSvc)
+	if replayClock != nil {
+		agentEngine.SetClock(replayClock)
+	}
+	go proposalSvc.Start(ctx)
+
+	// === KARAR DEĞERLENDİRME (1h/1d/5d ufuklarında sonuç ve kalibrasyon) ===
+	evaluator := services.NewDecisionEvaluator(db)
+	go evaluator.Start(ctx)
+
 	// Ajan motorunu başlat
 	go agentEngine.Start(ctx)
 	log.Info().Msg("Agent engine started (30-60 sec decision cycle)")
@@ -242,6 +372,10 @@ func main() {
 	}
 
 	fusionService := fusion.New(db, yahooClient, webScraper, twitterClient, tweetAnalyzer)
+	// Simülatör yokken Yahoo fiyatları mumlara işlenir
+	if !cfg.Simulator.Enabled {
+		fusionService.SetPriceObserver(candleBuilder.ObservePrices)
+	}
 	marketCtxHandler := handlers.NewMarketContextHandler(fusionService)
 	debugHandler := handlers.NewDebugDataHandler(yahooClient, webScraper, twitterClient, tweetAnalyzer)
 	metricsHandler := handlers.NewMetricsHandler(db)
@@ -250,20 +384,39 @@ func main() {
 	go stockUniverseSvc.Start(ctx)
 	universeHandler := handlers.NewUniverseHandler(db, stockUniverseSvc)
 	// Prompt bağlamı için füzyon + sembolleri ajan motoruna enjekte et
-	agentEngine.SetFusionService(fusionService)
-	agentEngine.SetContextSymbols(symbols)
+	// (geçmiş oynatmada canlı bağlam geleceği sızdıracağından verilmez)
+	if !replaying {
+		agentEngine.SetFusionService(fusionService)
+		agentEngine.SetContextSymbols(symbols)
+	}
 
 	// === HTTP İŞLEYİCİLERİ ===
 	healthHandler := handlers.NewHealthHandler(db, redisClient)
 	agentHandler := handlers.NewAgentHandler(db)
 	stockHandler := handlers.NewStockHandler(db)
 	tradeHandler := handlers.NewTradeHandler(db, tradingEngine)
-	leaderboardHandler := handlers.NewLeaderboardHandler(db)
+	// Lider tabloları (gömülü varsayılanlar ya da LEADERBOARD_BOARDS_FILE)
+	boards, err := ranking.Load(cfg.Leaderboard.Boards)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load leaderboard boards")
+	}
+	boardRanker := services.NewBoardRanker(db, boards)
+	leaderboardHandler := handlers.NewLeaderboardHandler(db, boardRanker)
 	roiHistoryHandler := handlers.NewROIHistoryHandler(db)
 	newsHandler := handlers.NewNewsHandler(newsAggregator)
 	authHandler := handlers.NewAuthHandler(cfg)
-
-	api.SetupRoutes(app, healthHandler, agentHandler, stockHandler, tradeHandler, leaderboardHandler, roiHistoryHandler, marketCtxHandler, debugHandler, metricsHandler, universeHandler, newsHandler, authHandler, hub)
+	experimentHandler := handlers.NewExperimentHandler(experimentSvc)
+	proposalHandler := handlers.NewProposalHandler(proposalSvc)
+	decisionHandler := handlers.NewDecisionHandler(db, replaySvc)
+	scenarioHandler := handlers.NewScenarioHandler(scenarioRunner)
+	// Yarışma sezonları (başlangıç/bitiş dakikada bir denetlenir)
+	seasonSvc := services.NewSeasonService(db, hub, time.Minute)
+	seasonSvc.SetBoards(boardRanker)
+	go seasonSvc.Start(ctx)
+	seasonHandler := handlers.NewSeasonHandler(seasonSvc)
+	badgeHandler := handlers.NewBadgeHandler(badgeEngine)
+
+	api.SetupRoutes(app, healthHandler, agentHandler, stockHandler, tradeHandler, leaderboardHandler, roiHistoryHandler, marketCtxHandler, debugHandler, metricsHandler, universeHandler, newsHandler, authHandler, experimentHandler, proposalHandler, decisionHandler, scenarioHandler, seasonHandler, badgeHandler, hub)
 
 	go func() {
 		addr := fmt.Sprintf(":%s", cfg.Server.Port)
@@ -279,8 +432,24 @@ func main() {
 		lbInterval = 60
 	}
 	leaderboardSvc := services.NewLeaderboardService(db, hub, lbInterval*time.Second)
+	// Karşılaştırma serileri; gerçek endeks yalnızca gerçek fiyatlarla anlamlı
+	benchmarkSvc := services.NewBenchmarkService(db, cfg.Leaderboard.DepositRate)
+	if !cfg.Simulator.Enabled {
+		benchmarkSvc.SetIndexSource(yahooClient, cfg.Leaderboard.BenchmarkIndex)
+	}
+	leaderboardSvc.SetBenchmarks(benchmarkSvc)
+	leaderboardSvc.SetBoards(boardRanker)
+	leaderboardSvc.SetBadges(badgeEngine)
 	go leaderboardSvc.Start(ctx)
 
+	// Gün sonu özeti (BIST kapanışı 18:15 İstanbul; açılışta eksik günler tamamlanır)
+	dailyStatsSvc := services.NewDailyStatsService(db)
+	go dailyStatsSvc.Start(ctx)
+
+	// Günlük ikili karşılaşmalar ve Elo puanları (tamamlanmış günler saatlik taranır)
+	matchupSvc := services.NewMatchupService(db, hub, time.Hour)
+	go matchupSvc.Start(ctx)
+
 	// === PİYASA VERİSİ TOPLAYICI & DUYGU TAKİPCİSİ (v0.5) ===
 	mdc := services.NewMarketDataCollector(
 		fusionService,
@@ -311,3 +480,60 @@ func main() {
 
 	log.Info().Msg("Server exited")
 }
+
+// newMarketSimulator yapılandırmaya göre fiyat süreci ya da geçmiş oynatma
+// simülatörü oluşturur; oynatmada ajanların kullanacağı sanal saati de döndürür
+func newMarketSimulator(db *pgxpool.Pool, hub *websocket.Hub, cfg config.SimulatorConfig) (*services.MarketSimulator, *simulation.VirtualClock, error) {
+	if cfg.Replay == "" {
+		simCfg, err := simulation.ConfigFrom(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		market, err := simulation.NewMarket(simCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return services.NewMarketSimulator(db, hub, market, time.Duration(cfg.TickSeconds)*time.Second, cfg.Speed), nil, nil
+	}
+
+	if cfg.ReplayFrom == "" {
+		return nil, nil, fmt.Errorf("SIM_REPLAY_FROM is required for historical replay")
+	}
+	from, err := simulation.ParseTime(cfg.ReplayFrom)
+	if err != nil {
+		return nil, nil, err
+	}
+	var to time.Time
+	if cfg.ReplayTo != "" {
+		if to, err = simulation.ParseTime(cfg.ReplayTo); err != nil {
+			return nil, nil, err
+		}
+	}
+	timeframe := cfg.ReplayTimeframe
+	if timeframe == "" {
+		timeframe = "1m"
+	}
+	interval, ok := simulation.Timeframes[timeframe]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown replay timeframe %q", timeframe)
+	}
+
+	var source simulation.BarSource
+	switch cfg.Replay {
+	case "market_data":
+		source = services.NewMarketDataSource(db, timeframe, from, to)
+	case "csv":
+		bars, err := simulation.LoadCSV(cfg.ReplayCSV, from, to)
+		if err != nil {
+			return nil, nil, err
+		}
+		source = simulation.NewSliceSource(bars)
+	default:
+		return nil, nil, fmt.Errorf("unknown replay source %q (want market_data or csv)", cfg.Replay)
+	}
+
+	clock := simulation.NewVirtualClock(from)
+	replay := simulation.NewReplayer(source, clock, interval, cfg.Speed, time.Duration(cfg.ReplayMaxGap)*time.Second)
+	log.Info().Str("source", cfg.Replay).Str("timeframe", timeframe).Float64("speed", cfg.Speed).Time("from", from).Msg("Historical replay configured")
+	return services.NewReplaySimulator(db, hub, replay), clock, nil
+}
@@ -0,0 +1,134 @@
+// Command sweep optimises a rule-based strategy: it backtests every parameter
+// set of a grid on rolling in-sample/out-of-sample windows, ranks the sets by
+// out-of-sample Sharpe and reports overfitting indicators. No database is used.
+//
+//	go run ./cmd/sweep -config sweeps/sma_cross.yaml -bars data/bars
+//	go run ./cmd/sweep -config sweeps/momentum.yaml -bars data/bars -base '{"position_pct": 4}' -out-json sweep.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/backtest"
+	"github.com/1batu/market-ai/internal/config"
+	"github.com/1batu/market-ai/internal/simulation"
+	"github.com/1batu/market-ai/pkg/logger"
+)
+
+func main() {
+	configFlag := flag.String("config", "", "sweep configuration (YAML)")
+	barsFlag := flag.String("bars", "", "bar CSV file or directory of <SYMBOL>.csv files")
+	newsFlag := flag.String("news", "", "news articles as a JSON array (optional)")
+	fromFlag := flag.String("from", "", "first bar (RFC3339 or YYYY-MM-DD)")
+	toFlag := flag.String("to", "", "end of the bars (RFC3339 or YYYY-MM-DD)")
+	baseFlag := flag.String("base", "", "fixed parameters as JSON, e.g. an agent's agent_strategies.parameters")
+	workers := flag.Int("workers", 0, "parallel backtests (default: config or number of CPUs)")
+	top := flag.Int("top", 10, "parameter sets to print")
+	outJSON := flag.String("out-json", "", "write the full report as JSON")
+	flag.Parse()
+
+	if *configFlag == "" || *barsFlag == "" {
+		fmt.Fprintln(os.Stderr, "-config and -bars are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	logger.Init(cfg.Log.Level)
+
+	data, err := os.ReadFile(*configFlag)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to read sweep configuration")
+	}
+	sweep, err := backtest.ParseSweepConfig(data)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid sweep configuration")
+	}
+	if *baseFlag != "" {
+		base, err := backtest.ParseParameters([]byte(*baseFlag))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid -base")
+		}
+		for k, v := range sweep.Base {
+			if _, ok := base[k]; !ok {
+				base[k] = v
+			}
+		}
+		sweep.Base = base
+	}
+	if *workers > 0 {
+		sweep.Workers = *workers
+	}
+
+	var from, to time.Time
+	if *fromFlag != "" {
+		if from, err = simulation.ParseTime(*fromFlag); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -from")
+		}
+	}
+	if *toFlag != "" {
+		if to, err = simulation.ParseTime(*toFlag); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -to")
+		}
+	}
+	bars, err := simulation.LoadCSV(*barsFlag, from, to)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load bars")
+	}
+	var news *backtest.NewsStore
+	if *newsFlag != "" {
+		if news, err = backtest.LoadNews(*newsFlag); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load news")
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	started := time.Now()
+	report, err := backtest.RunSweep(ctx, *sweep, backtest.NewMarket(bars), news)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Sweep failed")
+	}
+	log.Info().Int("backtests", report.Backtests).Dur("took", time.Since(started)).Msg("Sweep finished")
+
+	if *outJSON != "" {
+		f, err := os.Create(*outJSON)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create output")
+		}
+		if err := report.WriteJSON(f); err != nil {
+			log.Fatal().Err(err).Msg("Failed to write output")
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to write output")
+		}
+	}
+
+	fmt.Printf("%s: %d parameter sets x %d windows\n\n", report.Strategy, len(report.Results), len(report.Windows))
+	fmt.Printf("%4s  %-40s %9s %9s %8s %8s %7s %6s\n", "rank", "parameters", "OOS SR", "IS SR", "OOS ret", "OOS DD", "decay", "trades")
+	for i, r := range report.Results {
+		if i == *top {
+			break
+		}
+		fmt.Printf("%4d  %-40s %9.2f %9.2f %7.2f%% %7.2f%% %7.2f %6d\n",
+			r.Rank, r.Parameters.String(), r.OutOfSampleSharpe, r.InSampleSharpe, r.OutOfSampleReturn, r.OutOfSampleMaxDrawdown, r.SharpeDecay, r.OutOfSampleTrades)
+	}
+	o := report.Overfitting
+	fmt.Printf("\nPBO %.2f  rank correlation %.2f  mean Sharpe decay %.2f  walk-forward efficiency %.2f\n",
+		o.PBO, o.RankCorrelation, o.MeanSharpeDecay, o.WalkForwardEfficiency)
+	for _, s := range o.Selections {
+		fmt.Printf("  %s..%s  %-40s IS SR %6.2f  OOS SR %6.2f  OOS rank %d\n",
+			s.InStart.Format("2006-01-02"), s.OutEnd.Format("2006-01-02"), s.Parameters.String(), s.InSampleSharpe, s.OutOfSampleSharpe, s.OutOfSampleRank)
+	}
+}
@@ -19,33 +19,44 @@ interface LeaderboardEntry {
   badges: string[];
 }
 
+const BENCHMARK_LABELS: Record<string, string> = {
+  bist100: 'BIST 100',
+  equal_weight: 'Eşit Ağırlık',
+  cash: 'Nakit',
+};
+
 export default function Leaderboard() {
   const [entries, setEntries] = useState<LeaderboardEntry[]>([]);
   const [roiHistory, setRoiHistory] = useState<Record<string, { time: string; roi: number }[]>>({});
+  const [benchmarkHistory, setBenchmarkHistory] = useState<Record<string, { time: string; roi: number }[]>>({});
   const [lastHistoryFetch, setLastHistoryFetch] = useState<number>(0);
-  // Fetch ROI history for all agents (typed)
+  // Fetch ROI history: agent lines keyed by agent ID, benchmark lines keyed by name
   const fetchROIHistory = () => {
+    type RawPoint = { time: string; roi: number };
+    const toSeries = (raw: unknown): Record<string, RawPoint[]> => {
+      const series: Record<string, RawPoint[]> = {};
+      if (!raw || typeof raw !== 'object') return series;
+      Object.entries(raw as Record<string, unknown>).forEach(([key, points]) => {
+        if (Array.isArray(points)) {
+          series[key] = points
+            .filter((p): p is RawPoint =>
+              p &&
+              typeof p === 'object' &&
+              typeof (p as RawPoint).time === 'string' &&
+              typeof (p as RawPoint).roi === 'number'
+            )
+            .map(p => ({ time: p.time, roi: p.roi }))
+            .sort((a, b) => new Date(a.time).getTime() - new Date(b.time).getTime());
+        }
+      });
+      return series;
+    };
     fetch(`${process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080/api'}/v1/leaderboard/roi-history?limit=120`)
       .then(r => r.json())
-      .then((d: { success?: boolean; data?: unknown }) => {
-        if (d.success && d.data && typeof d.data === 'object' && d.data !== null) {
-          type RawPoint = { time: string; roi: number };
-          const transformed: Record<string, RawPoint[]> = {};
-          Object.entries(d.data as Record<string, unknown>).forEach(([agentId, points]) => {
-            if (Array.isArray(points)) {
-              const validPoints = points
-                .filter((p): p is RawPoint =>
-                  p &&
-                  typeof p === 'object' &&
-                  typeof (p as RawPoint).time === 'string' &&
-                  typeof (p as RawPoint).roi === 'number'
-                )
-                .map(p => ({ time: p.time, roi: p.roi }))
-                .sort((a, b) => new Date(a.time).getTime() - new Date(b.time).getTime());
-              transformed[agentId] = validPoints;
-            }
-          });
-          setRoiHistory(transformed);
+      .then((d: { success?: boolean; data?: { agents?: unknown; benchmarks?: unknown } }) => {
+        if (d.success && d.data && typeof d.data === 'object') {
+          setRoiHistory(toSeries(d.data.agents));
+          setBenchmarkHistory(toSeries(d.data.benchmarks));
           setLastHistoryFetch(Date.now());
         }
       })
@@ -218,6 +229,24 @@ export default function Leaderboard() {
         {entries.length === 0 && (
           <div className="text-center text-sm text-gray-500 dark:text-gray-400 py-6">Henüz veri yok. Ajanlar başlatılıyor...</div>
         )}
+        {Object.keys(benchmarkHistory).length > 0 && (
+          <div className="grid grid-cols-3 gap-4 pt-2 text-sm">
+            {Object.entries(benchmarkHistory).map(([name, points]) => {
+              const last = points.length > 0 ? points[points.length - 1].roi : 0;
+              return (
+                <div key={name}>
+                  <div className="text-xs text-gray-500 dark:text-gray-400">{BENCHMARK_LABELS[name] || name}</div>
+                  <div className={last >= 0 ? 'text-green-600 dark:text-green-400 font-medium' : 'text-red-600 dark:text-red-400 font-medium'}>
+                    {last >= 0 ? '+' : ''}{last.toFixed(2)}%
+                  </div>
+                  <div className="mt-1">
+                    <Sparkline points={points} positive={last >= 0} />
+                  </div>
+                </div>
+              );
+            })}
+          </div>
+        )}
       </div>
     </div>
   );
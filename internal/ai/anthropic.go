@@ -34,6 +34,7 @@ type AnthropicMessage struct {
 
 // AnthropicMessageResponse is the response from Anthropic API
 type AnthropicMessageResponse struct {
+	Model   string `json:"model"`
 	Content []struct {
 		Type string `json:"type"`
 		Text string `json:"text"`
@@ -52,7 +53,7 @@ func NewAnthropicClient(apiKey, model string) *AnthropicClient {
 }
 
 // GetTradingDecision gets a trading decision from Anthropic
-func (c *AnthropicClient) GetTradingDecision(ctx context.Context, prompt string) (*models.AIDecision, error) {
+func (c *AnthropicClient) GetTradingDecision(ctx context.Context, systemPrompt, prompt string) (*models.AIDecision, error) {
 	if c.apiKey == "" {
 		return nil, fmt.Errorf("anthropic API key not configured")
 	}
@@ -60,8 +61,8 @@ func (c *AnthropicClient) GetTradingDecision(ctx context.Context, prompt string)
 	// Build request
 	reqBody := AnthropicMessageRequest{
 		Model:     c.model,
-		MaxTokens: 1500,
-		System:    GetSystemPrompt(),
+		MaxTokens: MaxResponseTokens,
+		System:    systemPrompt,
 		Messages: []interface{}{
 			AnthropicMessage{
 				Role:    "user",
@@ -110,6 +111,8 @@ func (c *AnthropicClient) GetTradingDecision(ctx context.Context, prompt string)
 	if err := json.Unmarshal([]byte(apiResp.Content[0].Text), &decision); err != nil {
 		return nil, fmt.Errorf("failed to parse decision response: %w", err)
 	}
+	decision.RawResponse = apiResp.Content[0].Text
+	decision.ModelVersion = apiResp.Model
 
 	return &decision, nil
 }
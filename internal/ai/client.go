@@ -2,6 +2,7 @@ package ai
 
 import (
 	"context"
+	"time"
 
 	"github.com/1batu/market-ai/internal/models"
 )
@@ -9,12 +10,36 @@ import (
 // Client defines the interface for AI trading decision makers
 type Client interface {
 	// GetTradingDecision asks AI to make a trading decision
-	GetTradingDecision(ctx context.Context, prompt string) (*models.AIDecision, error)
+	GetTradingDecision(ctx context.Context, systemPrompt, prompt string) (*models.AIDecision, error)
 
 	// GetModelName returns the model name
 	GetModelName() string
 }
 
+// ProviderOf returns the provider behind a client, e.g. "openai" or "anthropic"
+func ProviderOf(c Client) string {
+	switch c.(type) {
+	case *OpenAIClient:
+		return "openai"
+	case *AnthropicClient:
+		return "anthropic"
+	case *GoogleClient:
+		return "google"
+	case *DeepSeekClient:
+		return "deepseek"
+	case *GroqClient:
+		return "groq"
+	case *MistralClient:
+		return "mistral"
+	case *XAIClient:
+		return "xai"
+	case *EnsembleClient:
+		return "ensemble"
+	default:
+		return "unknown"
+	}
+}
+
 // DecisionRequest contains all data needed for an AI trading decision
 type DecisionRequest struct {
 	AgentID        string
@@ -33,4 +58,12 @@ type DecisionRequest struct {
 	MCSentiments map[string]*models.StockSentiment
 	MCTopTweets  []models.Tweet
 	MCNotes      string
+
+	// Agent memory: most relevant past lessons and the latest standing note
+	Memories     []models.AgentMemory
+	StandingNote string
+
+	// Market time of the decision during a historical replay; zero = wall clock.
+	// News and memory ages in the prompt are relative to it.
+	Now time.Time
 }
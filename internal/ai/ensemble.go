@@ -0,0 +1,283 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+// EnsembleMember is one model of a committee with its voting weight
+type EnsembleMember struct {
+	Client Client
+	Weight float64
+}
+
+// EnsembleClient fans the same prompt out to several member clients and
+// combines their decisions, either by confidence-weighted voting or by letting
+// a judge model read every member's decision and rationale.
+type EnsembleClient struct {
+	members []EnsembleMember
+	judge   Client // optional; nil = voting
+}
+
+// NewEnsembleClient creates a committee client. Members with a non-positive
+// weight get weight 1.
+func NewEnsembleClient(members []EnsembleMember, judge Client) *EnsembleClient {
+	ms := make([]EnsembleMember, 0, len(members))
+	for _, m := range members {
+		if m.Client == nil {
+			continue
+		}
+		if m.Weight <= 0 {
+			m.Weight = 1
+		}
+		ms = append(ms, m)
+	}
+	return &EnsembleClient{members: ms, judge: judge}
+}
+
+// Members returns the number of member clients
+func (c *EnsembleClient) Members() int { return len(c.members) }
+
+// GetModelName lists the member models, e.g. "ensemble(gpt-4o-mini,deepseek-chat)"
+func (c *EnsembleClient) GetModelName() string {
+	names := make([]string, len(c.members))
+	for i, m := range c.members {
+		names[i] = m.Client.GetModelName()
+	}
+	name := "ensemble(" + strings.Join(names, ",") + ")"
+	if c.judge != nil {
+		name += "+judge:" + c.judge.GetModelName()
+	}
+	return name
+}
+
+// GetTradingDecision asks every member concurrently and aggregates the answers.
+// The returned decision carries every member's vote in Votes.
+func (c *EnsembleClient) GetTradingDecision(ctx context.Context, systemPrompt, prompt string) (*models.AIDecision, error) {
+	if len(c.members) == 0 {
+		return nil, errors.New("ensemble has no members")
+	}
+
+	decisions := make([]*models.AIDecision, len(c.members))
+	errs := make([]error, len(c.members))
+	var wg sync.WaitGroup
+	for i, m := range c.members {
+		wg.Add(1)
+		go func(i int, client Client) {
+			defer wg.Done()
+			decisions[i], errs[i] = client.GetTradingDecision(ctx, systemPrompt, prompt)
+		}(i, m.Client)
+	}
+	wg.Wait()
+
+	votes := make([]models.CommitteeVote, len(c.members))
+	answered := 0
+	for i, m := range c.members {
+		votes[i] = models.CommitteeVote{Model: m.Client.GetModelName(), Weight: m.Weight}
+		if errs[i] == nil && decisions[i] == nil {
+			errs[i] = errors.New("empty decision")
+		}
+		if errs[i] != nil {
+			votes[i].Error = errs[i].Error()
+			continue
+		}
+		d := decisions[i]
+		votes[i].Action = d.Action
+		votes[i].StockSymbol = d.StockSymbol
+		votes[i].Quantity = d.Quantity
+		votes[i].Confidence = d.Confidence
+		votes[i].Summary = d.ReasoningSummary
+		answered++
+	}
+	if answered == 0 {
+		return nil, fmt.Errorf("all %d ensemble members failed: %w", len(c.members), errors.Join(errs...))
+	}
+
+	if c.judge != nil {
+		judged, err := c.judge.GetTradingDecision(ctx, systemPrompt, prompt+"\n\n"+committeeSection(votes, decisions))
+		if err == nil && judged != nil {
+			for i := range votes {
+				votes[i].Elected = votes[i].Error == "" && voteKey(votes[i].Action, votes[i].StockSymbol) == voteKey(judged.Action, judged.StockSymbol)
+			}
+			judged.Votes = votes
+			judged.ThinkingSteps = append(judged.ThinkingSteps, models.ThinkingStep{
+				Step:        "Committee Judge",
+				Observation: fmt.Sprintf("%s decided %s after reading %d/%d member votes", c.judge.GetModelName(), voteKey(judged.Action, judged.StockSymbol), answered, len(votes)),
+			})
+			return judged, nil
+		}
+		// the judge failing should not cost the decision: fall back to voting
+	}
+
+	out := tallyVotes(votes, decisions)
+	out.RawResponse = memberTranscript(votes, decisions)
+	out.ModelVersion = c.GetModelName()
+	return out, nil
+}
+
+// tallyVotes elects the action (and symbol) with the highest sum of
+// weight × confidence. The committee's confidence is that score divided by the
+// total weight of all members, so dissent and failed members lower it.
+// The elected member with the highest confidence supplies the details
+// (prices, orders, thinking steps); BUY/SELL quantities are weight-averaged.
+func tallyVotes(votes []models.CommitteeVote, decisions []*models.AIDecision) *models.AIDecision {
+	scores := make(map[string]float64)
+	var totalWeight float64
+	for _, v := range votes {
+		totalWeight += v.Weight
+		if v.Error == "" {
+			scores[voteKey(v.Action, v.StockSymbol)] += v.Weight * v.Confidence
+		}
+	}
+
+	keys := make([]string, 0, len(scores))
+	for k := range scores {
+		keys = append(keys, k)
+	}
+	// Highest score wins; ties go to HOLD, then alphabetical for determinism
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		if (keys[i] == models.ActionHold) != (keys[j] == models.ActionHold) {
+			return keys[i] == models.ActionHold
+		}
+		return keys[i] < keys[j]
+	})
+	winner := keys[0]
+
+	lead := -1
+	var qtyWeight, qtySum float64
+	elected := 0
+	for i := range votes {
+		if votes[i].Error != "" || voteKey(votes[i].Action, votes[i].StockSymbol) != winner {
+			continue
+		}
+		votes[i].Elected = true
+		elected++
+		if lead < 0 || votes[i].Confidence > votes[lead].Confidence {
+			lead = i
+		}
+		qtySum += votes[i].Weight * float64(votes[i].Quantity)
+		qtyWeight += votes[i].Weight
+	}
+
+	out := *decisions[lead]
+	out.Votes = votes
+	out.Confidence = 0
+	if totalWeight > 0 {
+		out.Confidence = scores[winner] / totalWeight
+	}
+	if (out.Action == models.ActionBuy || out.Action == models.ActionSell) && qtyWeight > 0 {
+		if q := int(qtySum / qtyWeight); q > 0 {
+			out.Quantity = q
+		}
+	}
+
+	tally := fmt.Sprintf("Committee: %d/%d votes for %s (confidence-weighted support %.1f%%)",
+		elected, len(votes), voteKey(out.Action, out.StockSymbol), out.Confidence)
+	out.ThinkingSteps = append(append([]models.ThinkingStep(nil), out.ThinkingSteps...), models.ThinkingStep{Step: "Committee Vote", Observation: tally})
+	out.ReasoningFull = tally + "\n\n" + out.ReasoningFull
+	return &out
+}
+
+// committeeSection renders the members' decisions for the judge model
+func committeeSection(votes []models.CommitteeVote, decisions []*models.AIDecision) string {
+	var b strings.Builder
+	b.WriteString("=== COMMITTEE VOTES ===\n")
+	b.WriteString("You are the judge of a committee of trading models. Read their decisions below and give the committee's final decision in the same JSON format.\n")
+	for i, v := range votes {
+		if v.Error != "" {
+			fmt.Fprintf(&b, "- %s: no answer\n", v.Model)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s (weight %s): %s", v.Model, strconv.FormatFloat(v.Weight, 'f', -1, 64), voteKey(v.Action, v.StockSymbol))
+		if v.Quantity > 0 {
+			fmt.Fprintf(&b, " x%d", v.Quantity)
+		}
+		fmt.Fprintf(&b, ", confidence %.0f%%\n  Rationale: %s\n", v.Confidence, truncate(decisions[i].ReasoningSummary, 300))
+		if full := decisions[i].ReasoningFull; full != "" {
+			fmt.Fprintf(&b, "  Analysis: %s\n", truncate(full, 600))
+		}
+	}
+	return b.String()
+}
+
+// memberTranscript joins every member's raw answer for the decision audit trail
+func memberTranscript(votes []models.CommitteeVote, decisions []*models.AIDecision) string {
+	var b strings.Builder
+	for i, v := range votes {
+		fmt.Fprintf(&b, "--- %s ---\n", v.Model)
+		if v.Error != "" {
+			b.WriteString("error: " + v.Error + "\n")
+			continue
+		}
+		b.WriteString(decisions[i].RawResponse + "\n")
+	}
+	return b.String()
+}
+
+// voteKey groups votes: BUY/SELL per symbol, other actions by action alone
+func voteKey(action, symbol string) string {
+	action = strings.ToUpper(action)
+	if action == models.ActionBuy || action == models.ActionSell {
+		return action + " " + strings.ToUpper(symbol)
+	}
+	return action
+}
+
+// EnsembleFromSpec builds a committee from a "model[:weight],..." member spec
+// and an optional judge model, picking clients by model name. It returns nil if
+// no member is available, plus the models that were not found.
+func EnsembleFromSpec(spec, judgeModel string, clients map[string]Client) (*EnsembleClient, []string) {
+	var missing []string
+	names, weights := ParseEnsembleMembers(spec)
+	var members []EnsembleMember
+	for i, name := range names {
+		if c, ok := clients[name]; ok {
+			members = append(members, EnsembleMember{Client: c, Weight: weights[i]})
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	var judge Client
+	if judgeModel != "" {
+		if c, ok := clients[judgeModel]; ok {
+			judge = c
+		} else {
+			missing = append(missing, judgeModel)
+		}
+	}
+	if len(members) == 0 {
+		return nil, missing
+	}
+	return NewEnsembleClient(members, judge), missing
+}
+
+// ParseEnsembleMembers parses "model[:weight],..." into model names and weights
+func ParseEnsembleMembers(spec string) ([]string, []float64) {
+	var names []string
+	var weights []float64
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weight := part, 1.0
+		if i := strings.LastIndex(part, ":"); i > 0 {
+			if w, err := strconv.ParseFloat(strings.TrimSpace(part[i+1:]), 64); err == nil {
+				name, weight = strings.TrimSpace(part[:i]), w
+			}
+		}
+		names = append(names, name)
+		weights = append(weights, weight)
+	}
+	return names, weights
+}
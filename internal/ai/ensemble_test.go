@@ -0,0 +1,154 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+type fakeClient struct {
+	model    string
+	decision *models.AIDecision
+	err      error
+	prompt   string
+}
+
+func (f *fakeClient) GetTradingDecision(_ context.Context, _, prompt string) (*models.AIDecision, error) {
+	f.prompt = prompt
+	if f.err != nil {
+		return nil, f.err
+	}
+	d := *f.decision
+	return &d, nil
+}
+
+func (f *fakeClient) GetModelName() string { return f.model }
+
+func vote(model, action, symbol string, qty int, conf float64) *fakeClient {
+	return &fakeClient{model: model, decision: &models.AIDecision{
+		Action: action, StockSymbol: symbol, Quantity: qty, Confidence: conf,
+		ReasoningSummary: model + " says " + action,
+		RawResponse:      `{"action":"` + action + `"}`,
+	}}
+}
+
+func TestEnsembleVoting(t *testing.T) {
+	ens := NewEnsembleClient([]EnsembleMember{
+		{Client: vote("a", "BUY", "THYAO", 10, 80)},
+		{Client: vote("b", "BUY", "thyao", 20, 90), Weight: 2},
+		{Client: vote("c", "HOLD", "", 0, 95)},
+		{Client: &fakeClient{model: "d", err: errors.New("timeout")}},
+	}, nil)
+
+	d, err := ens.GetTradingDecision(context.Background(), "sys", "prompt")
+	if err != nil {
+		t.Fatalf("GetTradingDecision() error = %v", err)
+	}
+	// BUY THYAO: 80 + 2*90 = 260 vs HOLD 95; total weight 5
+	if d.Action != "BUY" || d.ReasoningSummary != "b says BUY" {
+		t.Errorf("elected %s from %q, want BUY led by b", d.Action, d.ReasoningSummary)
+	}
+	if d.Confidence != 52 {
+		t.Errorf("confidence = %v, want 52", d.Confidence)
+	}
+	if d.Quantity != 16 { // (10 + 2*20) / 3
+		t.Errorf("quantity = %d, want 16", d.Quantity)
+	}
+	if len(d.Votes) != 4 || !d.Votes[0].Elected || d.Votes[2].Elected || d.Votes[3].Error != "timeout" {
+		t.Errorf("votes = %+v", d.Votes)
+	}
+	if !strings.Contains(d.ReasoningFull, "Committee: 2/4 votes for BUY THYAO") {
+		t.Errorf("reasoning_full = %q", d.ReasoningFull)
+	}
+	if !strings.Contains(d.RawResponse, "--- b ---\n{\"action\":\"BUY\"}") || !strings.Contains(d.RawResponse, "--- d ---\nerror: timeout") {
+		t.Errorf("raw transcript = %q", d.RawResponse)
+	}
+	if ens.GetModelName() != "ensemble(a,b,c,d)" {
+		t.Errorf("GetModelName() = %s", ens.GetModelName())
+	}
+}
+
+func TestEnsembleTieGoesToHold(t *testing.T) {
+	ens := NewEnsembleClient([]EnsembleMember{
+		{Client: vote("a", "SELL", "ASELS", 5, 80)},
+		{Client: vote("b", "HOLD", "", 0, 80)},
+	}, nil)
+	d, err := ens.GetTradingDecision(context.Background(), "sys", "prompt")
+	if err != nil || d.Action != "HOLD" {
+		t.Errorf("tie elected %v, %v; want HOLD", d, err)
+	}
+}
+
+func TestEnsembleJudge(t *testing.T) {
+	judge := vote("judge", "SELL", "ASELS", 3, 75)
+	ens := NewEnsembleClient([]EnsembleMember{
+		{Client: vote("a", "SELL", "ASELS", 5, 70)},
+		{Client: vote("b", "HOLD", "", 0, 60)},
+	}, judge)
+
+	d, err := ens.GetTradingDecision(context.Background(), "sys", "prompt")
+	if err != nil {
+		t.Fatalf("GetTradingDecision() error = %v", err)
+	}
+	if d.Action != "SELL" || d.Quantity != 3 || d.Confidence != 75 {
+		t.Errorf("judge decision not used: %+v", d)
+	}
+	for _, want := range []string{"=== COMMITTEE VOTES ===", "- a (weight 1): SELL ASELS x5, confidence 70%", "Rationale: b says HOLD"} {
+		if !strings.Contains(judge.prompt, want) {
+			t.Errorf("judge prompt missing %q", want)
+		}
+	}
+	if !d.Votes[0].Elected || d.Votes[1].Elected {
+		t.Errorf("votes = %+v", d.Votes)
+	}
+
+	// a failing judge falls back to voting
+	judge.err = errors.New("down")
+	if d, err := ens.GetTradingDecision(context.Background(), "sys", "prompt"); err != nil || d.Action != "SELL" || d.Confidence != 35 {
+		t.Errorf("fallback = %+v, %v", d, err)
+	}
+}
+
+func TestEnsembleAllFail(t *testing.T) {
+	ens := NewEnsembleClient([]EnsembleMember{{Client: &fakeClient{model: "a", err: errors.New("x")}}}, nil)
+	if _, err := ens.GetTradingDecision(context.Background(), "sys", "prompt"); err == nil {
+		t.Error("want error when every member fails")
+	}
+}
+
+func TestParseEnsembleMembers(t *testing.T) {
+	names, weights := ParseEnsembleMembers(" gpt-4o-mini:2, deepseek-chat ,,llama:x")
+	if strings.Join(names, "|") != "gpt-4o-mini|deepseek-chat|llama:x" || weights[0] != 2 || weights[1] != 1 || weights[2] != 1 {
+		t.Errorf("ParseEnsembleMembers() = %v %v", names, weights)
+	}
+}
+
+func TestProviderOf(t *testing.T) {
+	if got := ProviderOf(NewOpenAIClient("", "gpt-4o-mini")); got != "openai" {
+		t.Errorf("ProviderOf(openai) = %s", got)
+	}
+	if got := ProviderOf(NewEnsembleClient(nil, nil)); got != "ensemble" {
+		t.Errorf("ProviderOf(ensemble) = %s", got)
+	}
+	if got := ProviderOf(&fakeClient{model: "x"}); got != "unknown" {
+		t.Errorf("ProviderOf(fake) = %s", got)
+	}
+}
+
+func TestEnsembleFromSpec(t *testing.T) {
+	clients := map[string]Client{"a": vote("a", "HOLD", "", 0, 50), "b": vote("b", "HOLD", "", 0, 50)}
+
+	ens, missing := EnsembleFromSpec("a:2,b,c", "judge", clients)
+	if ens == nil || ens.Members() != 2 || ens.judge != nil {
+		t.Fatalf("ensemble = %+v", ens)
+	}
+	if strings.Join(missing, ",") != "c,judge" {
+		t.Errorf("missing = %v", missing)
+	}
+	if ens, _ := EnsembleFromSpec("x,y", "", clients); ens != nil {
+		t.Errorf("ensemble without members = %+v", ens)
+	}
+}
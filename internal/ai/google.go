@@ -32,13 +32,13 @@ func NewGoogleClient(apiKey, model string) (*GoogleClient, error) {
 }
 
 // GetTradingDecision queries Gemini for a decision
-func (gc *GoogleClient) GetTradingDecision(ctx context.Context, prompt string) (*models.AIDecision, error) {
+func (gc *GoogleClient) GetTradingDecision(ctx context.Context, systemPrompt, prompt string) (*models.AIDecision, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	model := gc.client.GenerativeModel(gc.model)
 	model.SetTemperature(0.7)
-	model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(GetSystemPrompt())}}
+	model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(systemPrompt)}}
 
 	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
@@ -53,6 +53,8 @@ func (gc *GoogleClient) GetTradingDecision(ctx context.Context, prompt string) (
 	if err := json.Unmarshal([]byte(raw), &decision); err != nil {
 		return nil, fmt.Errorf("failed to parse gemini JSON: %w", err)
 	}
+	decision.RawResponse = raw
+	decision.ModelVersion = gc.model
 	return &decision, nil
 }
 
@@ -23,17 +23,17 @@ func NewMistralClient(apiKey, model string) *MistralClient {
 	return &MistralClient{client: openai.NewClientWithConfig(cfg), model: model}
 }
 
-func (c *MistralClient) GetTradingDecision(ctx context.Context, prompt string) (*models.AIDecision, error) {
+func (c *MistralClient) GetTradingDecision(ctx context.Context, systemPrompt, prompt string) (*models.AIDecision, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 	resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
 		Model: c.model,
 		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleSystem, Content: GetSystemPrompt()},
+			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
 			{Role: openai.ChatMessageRoleUser, Content: prompt},
 		},
 		Temperature:    0.7,
-		MaxTokens:      1500,
+		MaxTokens:      MaxResponseTokens,
 		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
 	})
 	if err != nil {
@@ -46,6 +46,8 @@ func (c *MistralClient) GetTradingDecision(ctx context.Context, prompt string) (
 	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &decision); err != nil {
 		return nil, fmt.Errorf("failed to parse mistral response: %w", err)
 	}
+	decision.RawResponse = resp.Choices[0].Message.Content
+	decision.ModelVersion = resp.Model
 	return &decision, nil
 }
 
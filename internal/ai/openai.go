@@ -24,13 +24,13 @@ func NewOpenAIClient(apiKey, model string) *OpenAIClient {
 }
 
 // GetTradingDecision gets a trading decision from OpenAI
-func (c *OpenAIClient) GetTradingDecision(ctx context.Context, prompt string) (*models.AIDecision, error) {
+func (c *OpenAIClient) GetTradingDecision(ctx context.Context, systemPrompt, prompt string) (*models.AIDecision, error) {
 	resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
 		Model: c.model,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
-				Content: GetSystemPrompt(),
+				Content: systemPrompt,
 			},
 			{
 				Role:    openai.ChatMessageRoleUser,
@@ -38,7 +38,7 @@ func (c *OpenAIClient) GetTradingDecision(ctx context.Context, prompt string) (*
 			},
 		},
 		Temperature: 0.7,
-		MaxTokens:   1500,
+		MaxTokens:   MaxResponseTokens,
 		ResponseFormat: &openai.ChatCompletionResponseFormat{
 			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
 		},
@@ -56,6 +56,9 @@ func (c *OpenAIClient) GetTradingDecision(ctx context.Context, prompt string) (*
 		return nil, fmt.Errorf("failed to parse openai response: %w", err)
 	}
 
+	decision.RawResponse = resp.Choices[0].Message.Content
+	decision.ModelVersion = resp.Model
+
 	return &decision, nil
 }
 
@@ -1,247 +1,561 @@
 package ai
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
 	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
+	"unicode/utf8"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+//go:embed prompts/*.tmpl
+var embeddedPrompts embed.FS
+
+// DefaultPromptSet is the prompt set used when neither the agent nor its strategy selects one
+const DefaultPromptSet = "default-v1"
+
+const (
+	systemTemplateSuffix   = ".system.tmpl"
+	decisionTemplateSuffix = ".decision.tmpl"
 )
 
-// GetSystemPrompt returns the system prompt for AI trading
-func GetSystemPrompt() string {
-	return `You are a professional BIST (Borsa Istanbul) trader with 10+ years of experience.
-Your goal is to maximize portfolio returns while managing risk effectively.
-
-CRITICAL: You must respond ONLY with valid JSON in this exact format:
-{
-  "action": "BUY|SELL|HOLD",
-  "stock_symbol": "THYAO",
-  "quantity": 50,
-  "target_price": 250.00,
-  "stop_loss": 240.00,
-  "reasoning_summary": "Brief one-line explanation",
-  "reasoning_full": "Detailed multi-step analysis",
-  "confidence": 85,
-  "risk_level": "low|medium|high",
-  "thinking_steps": [
-    {
-      "step": "Market Analysis",
-      "observation": "Price trending up with high volume"
-    },
-    {
-      "step": "Technical Indicators",
-      "observation": "RSI at 45, MACD positive crossover"
-    },
-    {
-      "step": "Decision",
-      "observation": "Strong buy signal with good risk/reward"
-    }
-  ]
+// PromptSet is a named, versioned pair of system and decision prompt templates.
+// Hash identifies the exact template revision so decisions can be grouped by prompt.
+type PromptSet struct {
+	Name string
+	Hash string
+
+	system   *template.Template
+	decision *template.Template
 }
 
-Rules:
-- NEVER invest more than 5% of balance in a single trade
-- Always set stop loss (max 3% loss per trade)
-- Only trade when confidence > 70%
-- Consider portfolio diversification
-- If uncertain, choose HOLD
-- News context is CRITICAL - major news can cause 10%+ moves
-- Always factor in news sentiment when making decisions`
+// RenderSystem renders the system prompt for a decision request
+func (ps *PromptSet) RenderSystem(req *DecisionRequest) (string, error) {
+	return ps.render(ps.system, req)
 }
 
-// BuildDecisionPrompt builds the prompt for AI decision making
-func BuildDecisionPrompt(req *DecisionRequest) string {
-	var sb strings.Builder
-
-	// Agent Info
-	sb.WriteString("=== AGENT STATUS ===\n")
-	sb.WriteString(fmt.Sprintf("Name: %s\n", req.AgentName))
-	sb.WriteString(fmt.Sprintf("Available Balance: %.2f TL\n", req.CurrentBalance))
-	sb.WriteString(fmt.Sprintf("Strategy: %s\n\n", req.Strategy))
-
-	// Portfolio
-	sb.WriteString("=== CURRENT PORTFOLIO ===\n")
-	if len(req.Portfolio) == 0 {
-		sb.WriteString("No positions\n")
-	} else {
-		for _, p := range req.Portfolio {
-			sb.WriteString(fmt.Sprintf("- %s: %d lots @ %.2f TL avg (Current Value: %.2f TL, P/L: %.2f TL)\n",
-				p.StockSymbol, p.Quantity, p.AvgBuyPrice, p.CurrentValue, p.ProfitLoss))
-		}
-	}
-	sb.WriteString("\n")
-
-	// Available Stocks
-	sb.WriteString("=== AVAILABLE STOCKS ===\n")
-	for _, s := range req.Stocks {
-		direction := "↑"
-		if s.ChangePercent < 0 {
-			direction = "↓"
-		}
-		sb.WriteString(fmt.Sprintf("- %s (%s): %.2f TL (%s%.2f%%) | Volume: %d\n",
-			s.Symbol, s.Name, s.CurrentPrice, direction, s.ChangePercent, s.Volume))
-	}
-	sb.WriteString("\n")
-
-	// === AI QUANTITY CONTROL (Position Sizing Guidance) ===
-	maxTradeAmount := req.CurrentBalance * 0.05
-	sb.WriteString("=== 💰 YOUR TRADING AUTHORITY ===\n")
-	sb.WriteString(fmt.Sprintf("Current Balance: %.2f TL\n", req.CurrentBalance))
-	sb.WriteString(fmt.Sprintf("Max Per Trade: %.2f TL (5%% rule)\n\n", maxTradeAmount))
-	sb.WriteString("YOU have FULL CONTROL over:\n")
-	sb.WriteString("1. Which stock to trade\n")
-	sb.WriteString("2. How many LOTS to buy/sell (MUST be an exact integer)\n")
-	sb.WriteString("3. When to trade (timing)\n\n")
-
-	sb.WriteString("=== 📊 DYNAMIC UNIVERSE SNAPSHOT ===\n")
-	if len(req.Stocks) == 0 {
-		sb.WriteString("No active stocks available.\n\n")
-	} else {
-		for _, stock := range req.Stocks {
-			if stock.CurrentPrice <= 0 {
-				continue
-			}
-			maxLots := int(maxTradeAmount / stock.CurrentPrice)
-			sb.WriteString(fmt.Sprintf("- %s (%s): Price: %.2f TL | Max lots: %d (≈ %.2f TL)\n",
-				stock.Symbol, stock.Name, stock.CurrentPrice, maxLots, float64(maxLots)*stock.CurrentPrice))
-		}
-		sb.WriteString("\n")
-	}
-
-	sb.WriteString("=== ⚠️ QUANTITY CALCULATION RULES ===\n")
-	sb.WriteString("When deciding quantity, consider:\n")
-	sb.WriteString("1. Stock price (higher price = fewer lots)\n")
-	sb.WriteString("2. Your conviction level (higher confidence = more lots)\n")
-	sb.WriteString("3. Risk management (don't exceed 5% per trade)\n")
-	sb.WriteString("4. Portfolio diversification (avoid over-concentration)\n")
-	sb.WriteString("5. Market volatility (more volatile = smaller position)\n\n")
-
-	// Market Data (Recent candles)
-	if len(req.MarketData) > 0 {
-		sb.WriteString("=== RECENT MARKET DATA (Last 5 candles) ===\n")
-		for i, md := range req.MarketData {
-			if i >= 5 {
-				break
-			}
-			sb.WriteString(fmt.Sprintf("%s - O:%.2f H:%.2f L:%.2f C:%.2f V:%d\n",
-				md.Timestamp.Format("15:04"), md.OpenPrice, md.HighPrice, md.LowPrice, md.ClosePrice, md.Volume))
-		}
-		sb.WriteString("\n")
-	}
-
-	// *** NEWS SECTION - Most important for decision making ***
-	sb.WriteString("=== 📰 LATEST ECONOMIC NEWS (Last 3 Hours) ===\n")
-	if len(req.News) == 0 {
-		sb.WriteString("No significant news in the last 3 hours.\n")
-	} else {
-		sb.WriteString(fmt.Sprintf("Total: %d news articles (showing top 10)\n\n", req.NewsCount))
-
-		// Show max 10 news
-		newsCount := len(req.News)
-		if newsCount > 10 {
-			newsCount = 10
-		}
-
-		for i := 0; i < newsCount; i++ {
-			article := req.News[i]
-			timeAgo := time.Since(article.PublishedAt)
-
-			// Format time ago
-			timeStr := formatDuration(timeAgo)
-
-			sb.WriteString(fmt.Sprintf("%d. [%s] [%s] %s\n",
-				i+1,
-				timeStr,
-				article.Source,
-				article.Title,
-			))
-
-			// Add description if available
-			if article.Description != "" && len(article.Description) > 0 {
-				desc := article.Description
-				if len(desc) > 150 {
-					desc = desc[:150] + "..."
-				}
-				sb.WriteString(fmt.Sprintf("   📝 %s\n", desc))
-			}
+// RenderDecision renders the user (decision) prompt for a decision request
+func (ps *PromptSet) RenderDecision(req *DecisionRequest) (string, error) {
+	return ps.render(ps.decision, req)
+}
 
-			// Show related stocks if available
-			if len(article.RelatedStocks) > 0 {
-				sb.WriteString(fmt.Sprintf("   🎯 Related: %s\n", strings.Join(article.RelatedStocks, ", ")))
-			}
+func (ps *PromptSet) render(t *template.Template, req *DecisionRequest) (string, error) {
+	if req == nil {
+		req = &DecisionRequest{}
+	}
+	return ps.execute(t, newPromptData(req))
+}
+
+func (ps *PromptSet) execute(t *template.Template, d *promptData) (string, error) {
+	if !d.Now.IsZero() {
+		clone, err := t.Clone()
+		if err != nil {
+			return "", fmt.Errorf("render prompt %s/%s: %w", ps.Name, t.Name(), err)
+		}
+		t = clone.Funcs(clockFuncs(d.Now))
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("render prompt %s/%s: %w", ps.Name, t.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// PromptRegistry holds the available prompt sets and the rules used to pick one per agent
+type PromptRegistry struct {
+	mu         sync.RWMutex
+	sets       map[string]*PromptSet
+	defaultSet string
+	strategies map[string]string // strategy -> prompt set
+}
+
+// NewPromptRegistry loads the embedded prompt sets and, if dir is not empty, the
+// sets found in dir. Sets loaded from dir replace embedded sets with the same name.
+func NewPromptRegistry(dir string) (*PromptRegistry, error) {
+	embedded, err := fs.Sub(embeddedPrompts, "prompts")
+	if err != nil {
+		return nil, err
+	}
+	sets, err := loadPromptSets(embedded)
+	if err != nil {
+		return nil, fmt.Errorf("load embedded prompts: %w", err)
+	}
+
+	if dir != "" {
+		overrides, err := loadPromptSets(os.DirFS(dir))
+		if err != nil {
+			return nil, fmt.Errorf("load prompts from %s: %w", dir, err)
+		}
+		for name, ps := range overrides {
+			sets[name] = ps
+		}
+	}
+
+	return &PromptRegistry{
+		sets:       sets,
+		defaultSet: DefaultPromptSet,
+		strategies: make(map[string]string),
+	}, nil
+}
+
+var (
+	defaultPromptsOnce sync.Once
+	defaultPrompts     *PromptRegistry
+)
+
+// DefaultPrompts returns a registry containing only the embedded prompt sets
+func DefaultPrompts() *PromptRegistry {
+	defaultPromptsOnce.Do(func() {
+		r, err := NewPromptRegistry("")
+		if err != nil {
+			panic(err) // embedded templates are part of the binary; failing to parse them is a build bug
+		}
+		defaultPrompts = r
+	})
+	return defaultPrompts
+}
+
+// Get returns a prompt set by name
+func (r *PromptRegistry) Get(name string) (*PromptSet, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ps, ok := r.sets[name]
+	return ps, ok
+}
+
+// Names returns the names of all loaded prompt sets, sorted
+func (r *PromptRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.sets))
+	for name := range r.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDefault changes the fallback prompt set
+func (r *PromptRegistry) SetDefault(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.sets[name]; !ok {
+		return fmt.Errorf("unknown prompt set %q", name)
+	}
+	r.defaultSet = name
+	return nil
+}
+
+// AssignStrategy makes agents running the given strategy use the named prompt set
+func (r *PromptRegistry) AssignStrategy(strategy, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.sets[name]; !ok {
+		return fmt.Errorf("unknown prompt set %q", name)
+	}
+	r.strategies[strings.ToLower(strategy)] = name
+	return nil
+}
+
+// Select picks the prompt set for an agent: the agent's own set wins, then the
+// set assigned to its strategy, then the registry default.
+func (r *PromptRegistry) Select(agentSet, strategy string) *PromptSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if ps, ok := r.sets[agentSet]; ok {
+		return ps
+	}
+	if name, ok := r.strategies[strings.ToLower(strategy)]; ok {
+		return r.sets[name]
+	}
+	return r.sets[r.defaultSet]
+}
+
+// loadPromptSets reads every <name>.system.tmpl / <name>.decision.tmpl pair in fsys
+func loadPromptSets(fsys fs.FS) (map[string]*PromptSet, error) {
+	systemFiles, err := fs.Glob(fsys, "*"+systemTemplateSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make(map[string]*PromptSet, len(systemFiles))
+	for _, sysFile := range systemFiles {
+		name := strings.TrimSuffix(sysFile, systemTemplateSuffix)
+
+		sysSrc, err := fs.ReadFile(fsys, sysFile)
+		if err != nil {
+			return nil, err
+		}
+		decSrc, err := fs.ReadFile(fsys, name+decisionTemplateSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("prompt set %s has no decision template: %w", name, err)
+		}
+
+		sysTmpl, err := template.New(sysFile).Funcs(promptFuncs).Parse(string(sysSrc))
+		if err != nil {
+			return nil, err
+		}
+		decTmpl, err := template.New(name + decisionTemplateSuffix).Funcs(promptFuncs).Parse(string(decSrc))
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(append(append(sysSrc, 0), decSrc...))
+		sets[name] = &PromptSet{
+			Name:     name,
+			Hash:     hex.EncodeToString(sum[:])[:12],
+			system:   sysTmpl,
+			decision: decTmpl,
+		}
+	}
+	return sets, nil
+}
+
+// ParsePromptStrategies parses "strategy=set,strategy=set" into a map
+func ParsePromptStrategies(s string) map[string]string {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || strings.TrimSpace(k) == "" || strings.TrimSpace(v) == "" {
+			continue
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+// Initial caps applied before any token budgeting
+const (
+	maxPromptNews       = 10
+	maxPromptTweets     = 3
+	maxPromptPrices     = 5
+	maxPromptSentiments = 5
+	maxPromptTrades     = 3
+	maxPromptCandles    = 15
+	maxPromptMemories   = 5
+	newsDescLimit       = 153
+	shortNewsDescLimit  = 80
+	maxOmittedSymbols   = 10
+)
+
+// promptData is the value templates are executed against: the request plus
+// pre-computed, size-limited views of its lists. The Omitted* fields summarise
+// what token budgeting dropped so templates can mention it.
+type promptData struct {
+	*DecisionRequest
+	MaxTradeAmount float64
+	TopStocks      []models.Stock       // held stocks first, then biggest movers
+	TopNews        []models.NewsArticle // news about held stocks first
+	HeldNewsCount  int                  // leading TopNews entries about held stocks
+	DescLimit      int                  // max runes of a news description, 0 = hide
+	TopPrices      []*models.StockPrice
+	TopSentiments  []*models.StockSentiment
+	TopTweets      []models.Tweet
+	LastTrades     []models.Trade
+	LastCandles    []models.MarketData
+	TopMemories    []models.AgentMemory
+
+	OmittedNews        int
+	OmittedNewsSymbols []string
+	OmittedStocks      int
+	OmittedAdvancers   int
+	OmittedDecliners   int
+	OmittedTweets      int
+	HeldStockCount     int
 
-			sb.WriteString("\n")
+	allStocks []models.Stock
+	allNews   []models.NewsArticle
+}
+
+func newPromptData(req *DecisionRequest) *promptData {
+	held := make(map[string]bool, len(req.Portfolio))
+	for _, p := range req.Portfolio {
+		held[p.StockSymbol] = true
+	}
+
+	d := &promptData{
+		DecisionRequest: req,
+		MaxTradeAmount:  req.CurrentBalance * 0.05,
+		DescLimit:       newsDescLimit,
+		TopPrices:       firstN(req.MCPrices, maxPromptPrices),
+		TopTweets:       firstN(req.MCTopTweets, maxPromptTweets),
+		LastTrades:      firstN(req.RecentTrades, maxPromptTrades),
+		LastCandles:     firstN(req.MarketData, maxPromptCandles),
+		TopMemories:     firstN(req.Memories, maxPromptMemories),
+	}
+
+	// Stocks: held positions first, then by absolute move
+	d.allStocks = append([]models.Stock(nil), req.Stocks...)
+	sort.SliceStable(d.allStocks, func(i, j int) bool {
+		hi, hj := held[d.allStocks[i].Symbol], held[d.allStocks[j].Symbol]
+		if hi != hj {
+			return hi
+		}
+		return math.Abs(d.allStocks[i].ChangePercent) > math.Abs(d.allStocks[j].ChangePercent)
+	})
+	for _, s := range d.allStocks {
+		if held[s.Symbol] {
+			d.HeldStockCount++
+		}
+	}
+	d.TopStocks = d.allStocks
+
+	// News: articles mentioning held stocks first, otherwise keep feed order
+	for _, a := range req.News {
+		if mentionsAny(a.RelatedStocks, held) {
+			d.allNews = append(d.allNews, a)
+		}
+	}
+	d.HeldNewsCount = len(d.allNews)
+	for _, a := range req.News {
+		if !mentionsAny(a.RelatedStocks, held) {
+			d.allNews = append(d.allNews, a)
 		}
 	}
+	d.TopNews = firstN(d.allNews, maxPromptNews)
+	if d.HeldNewsCount > len(d.TopNews) {
+		d.HeldNewsCount = len(d.TopNews)
+	}
 
-	sb.WriteString("=== ⚠️ IMPORTANT - Consider News Impact ===\n")
-	sb.WriteString("- How might these news affect BIST stocks?\n")
-	sb.WriteString("- Are there any company-specific announcements?\n")
-	sb.WriteString("- What's the overall market sentiment from news?\n")
-	sb.WriteString("- Any economic indicators or policy changes?\n")
-	sb.WriteString("- Breaking news might cause significant price movements!\n\n")
+	symbols := make([]string, 0, len(req.MCSentiments))
+	for sym := range req.MCSentiments {
+		symbols = append(symbols, sym)
+	}
+	sort.Strings(symbols)
+	for _, sym := range firstN(symbols, maxPromptSentiments) {
+		agg := *req.MCSentiments[sym]
+		agg.Symbol = sym
+		d.TopSentiments = append(d.TopSentiments, &agg)
+	}
+	return d
+}
 
-	// Recent Trades
-	if len(req.RecentTrades) > 0 {
-		sb.WriteString("=== YOUR RECENT TRADES ===\n")
-		for i, t := range req.RecentTrades {
-			if i >= 3 {
-				break
+func mentionsAny(symbols []string, set map[string]bool) bool {
+	for _, s := range symbols {
+		if set[s] {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeOmitted refreshes the overflow summaries after lists were trimmed
+func (d *promptData) summarizeOmitted() {
+	d.OmittedNews = len(d.allNews) - len(d.TopNews)
+	d.OmittedNewsSymbols = d.OmittedNewsSymbols[:0]
+	seen := map[string]bool{}
+	for _, a := range d.allNews[len(d.TopNews):] {
+		for _, s := range a.RelatedStocks {
+			if !seen[s] {
+				seen[s] = true
+				d.OmittedNewsSymbols = append(d.OmittedNewsSymbols, s)
 			}
+		}
+	}
+	sort.Strings(d.OmittedNewsSymbols)
+	d.OmittedNewsSymbols = firstN(d.OmittedNewsSymbols, maxOmittedSymbols)
+
+	d.OmittedStocks, d.OmittedAdvancers, d.OmittedDecliners = 0, 0, 0
+	for _, s := range d.allStocks[len(d.TopStocks):] {
+		d.OmittedStocks++
+		switch {
+		case s.ChangePercent > 0:
+			d.OmittedAdvancers++
+		case s.ChangePercent < 0:
+			d.OmittedDecliners++
+		}
+	}
+
+	d.OmittedTweets = len(firstN(d.MCTopTweets, maxPromptTweets)) - len(d.TopTweets)
+}
+
+// compressionStep removes one unit of low-priority content; it reports false
+// when there is nothing left to remove at this step.
+type compressionStep struct {
+	section string
+	shrink  func(d *promptData) bool
+}
+
+// compressionSteps are applied in order until the prompt fits. The order is the
+// reverse of section priority: portfolio > held-stock news > top movers > general news.
+// The portfolio itself is never trimmed.
+var compressionSteps = []compressionStep{
+	{"news_descriptions", func(d *promptData) bool {
+		if d.DescLimit > shortNewsDescLimit {
+			d.DescLimit = shortNewsDescLimit
+			return true
+		}
+		return false
+	}},
+	{"general_news", func(d *promptData) bool {
+		if len(d.TopNews) > d.HeldNewsCount {
+			d.TopNews = d.TopNews[:len(d.TopNews)-1]
+			return true
+		}
+		return false
+	}},
+	{"tweets", func(d *promptData) bool {
+		if len(d.TopTweets) > 0 {
+			d.TopTweets = d.TopTweets[:len(d.TopTweets)-1]
+			return true
+		}
+		return false
+	}},
+	{"memories", func(d *promptData) bool {
+		if len(d.TopMemories) > 1 {
+			d.TopMemories = d.TopMemories[:len(d.TopMemories)-1]
+			return true
+		}
+		return false
+	}},
+	{"sentiments", func(d *promptData) bool {
+		if len(d.TopSentiments) > 0 {
+			d.TopSentiments = d.TopSentiments[:len(d.TopSentiments)-1]
+			return true
+		}
+		return false
+	}},
+	{"context_prices", func(d *promptData) bool {
+		if len(d.TopPrices) > 0 {
+			d.TopPrices = d.TopPrices[:len(d.TopPrices)-1]
+			return true
+		}
+		return false
+	}},
+	{"candles", func(d *promptData) bool {
+		if len(d.LastCandles) > 0 {
+			d.LastCandles = d.LastCandles[:len(d.LastCandles)-1]
+			return true
+		}
+		return false
+	}},
+	{"recent_trades", func(d *promptData) bool {
+		if len(d.LastTrades) > 1 {
+			d.LastTrades = d.LastTrades[:len(d.LastTrades)-1]
+			return true
+		}
+		return false
+	}},
+	{"stocks", func(d *promptData) bool {
+		if len(d.TopStocks) > d.HeldStockCount {
+			d.TopStocks = d.TopStocks[:len(d.TopStocks)-1]
+			return true
+		}
+		return false
+	}},
+	{"news_descriptions_removed", func(d *promptData) bool {
+		if d.DescLimit > 0 {
+			d.DescLimit = 0
+			return true
+		}
+		return false
+	}},
+	{"held_news", func(d *promptData) bool {
+		if len(d.TopNews) > 0 {
+			d.TopNews = d.TopNews[:len(d.TopNews)-1]
+			d.HeldNewsCount = len(d.TopNews)
+			return true
+		}
+		return false
+	}},
+}
+
+// RenderedPrompt is a decision prompt rendered under a token budget
+type RenderedPrompt struct {
+	Text       string
+	Tokens     int      // estimated tokens of Text for the target model
+	Compressed []string // sections that were trimmed to fit, in order
+}
 
-			// === MARKET CONTEXT (v0.5) ===
-			if len(req.MCPrices) > 0 || len(req.MCSentiments) > 0 || len(req.MCTopTweets) > 0 {
-				sb.WriteString("\n=== 📊 MARKET CONTEXT (Fused: Yahoo + Scraper + Twitter) ===\n")
-				if len(req.MCPrices) > 0 {
-					sb.WriteString("Prices (delayed ~15m):\n")
-					max := 5
-					if len(req.MCPrices) < max {
-						max = len(req.MCPrices)
-					}
-					for i := 0; i < max; i++ {
-						p := req.MCPrices[i]
-						sb.WriteString(fmt.Sprintf("- %s: %.2f TL (O:%.2f H:%.2f L:%.2f V:%d)\n", p.Symbol, p.Price, p.Open, p.High, p.Low, p.Volume))
-					}
-				}
-				if len(req.MCSentiments) > 0 {
-					sb.WriteString("Sentiment summary (tweets):\n")
-					shown := 0
-					for sym, agg := range req.MCSentiments {
-						sb.WriteString(fmt.Sprintf("- %s: avg=%.2f pos=%d neu=%d neg=%d\n", sym, agg.AvgSentiment, agg.PositiveCount, agg.NeutralCount, agg.NegativeCount))
-						shown++
-						if shown >= 5 {
-							break
-						}
-					}
-				}
-				if len(req.MCTopTweets) > 0 {
-					sb.WriteString("Top tweets:\n")
-					max := 3
-					if len(req.MCTopTweets) < max {
-						max = len(req.MCTopTweets)
-					}
-					for i := 0; i < max; i++ {
-						t := req.MCTopTweets[i]
-						sb.WriteString(fmt.Sprintf("- @%s (impact %.2f): %s\n", t.Author, t.ImpactScore, truncate(t.Text, 140)))
-					}
-				}
-				if req.MCNotes != "" {
-					sb.WriteString(req.MCNotes + "\n")
-				}
-				sb.WriteString("\n")
+// BuildDecision renders the decision prompt so that it fits in maxTokens for the
+// given model, dropping low-priority content and summarising what was left out.
+// maxTokens <= 0 disables budgeting. If the prompt still does not fit after every
+// compression step, the most compressed version is returned.
+func (ps *PromptSet) BuildDecision(req *DecisionRequest, model string, maxTokens int) (*RenderedPrompt, error) {
+	if req == nil {
+		req = &DecisionRequest{}
+	}
+	d := newPromptData(req)
+	text, err := ps.execute(ps.decision, d)
+	if err != nil {
+		return nil, err
+	}
+	out := &RenderedPrompt{Text: text, Tokens: EstimateTokens(model, text)}
+	if maxTokens <= 0 {
+		return out, nil
+	}
+
+	for _, step := range compressionSteps {
+		trimmed := false
+		for out.Tokens > maxTokens && step.shrink(d) {
+			trimmed = true
+			d.summarizeOmitted()
+			if out.Text, err = ps.execute(ps.decision, d); err != nil {
+				return nil, err
 			}
-			sb.WriteString(fmt.Sprintf("- %s %s %d lots @ %.2f TL (%s)\n",
-				t.CreatedAt.Format("15:04"), t.TradeType, t.Quantity, t.Price, t.Reasoning))
+			out.Tokens = EstimateTokens(model, out.Text)
+		}
+		if trimmed && (len(out.Compressed) == 0 || out.Compressed[len(out.Compressed)-1] != step.section) {
+			out.Compressed = append(out.Compressed, step.section)
+		}
+		if out.Tokens <= maxTokens {
+			break
 		}
-		sb.WriteString("\n")
 	}
+	return out, nil
+}
 
-	sb.WriteString("=== QUESTION ===\n")
-	sb.WriteString("Based on the above information, what trading decision should you make RIGHT NOW?\n")
-	sb.WriteString("Consider: technical analysis, risk management, portfolio balance, market trends, AND NEWS IMPACT.\n")
-	sb.WriteString("Also consider market context (multi-source sentiment and recent signals).\n")
-	sb.WriteString("Remember to respond ONLY with valid JSON in the specified format.\n")
+func firstN[T any](s []T, n int) []T {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
 
-	return sb.String()
+var promptFuncs = template.FuncMap{
+	"arrow": func(change float64) string {
+		if change < 0 {
+			return "↓"
+		}
+		return "↑"
+	},
+	"maxLots": func(budget, price float64) int {
+		if price <= 0 {
+			return 0
+		}
+		return int(budget / price)
+	},
+	"lotsValue": func(budget, price float64) float64 {
+		if price <= 0 {
+			return 0
+		}
+		return float64(int(budget/price)) * price
+	},
+	"ago":      func(t time.Time) string { return formatDuration(time.Since(t)) },
+	"agoTR":    func(t time.Time) string { return formatDurationTR(time.Since(t)) },
+	"truncate": truncate,
+	"join":     strings.Join,
+	"inc":      func(i int) int { return i + 1 },
+}
+
+// clockFuncs replaces the age helpers so they measure from the request's
+// market time instead of the wall clock
+func clockFuncs(now time.Time) template.FuncMap {
+	return template.FuncMap{
+		"ago":   func(t time.Time) string { return formatDuration(now.Sub(t)) },
+		"agoTR": func(t time.Time) string { return formatDurationTR(now.Sub(t)) },
+	}
 }
 
 // formatDuration formats time duration in human-readable format
@@ -261,12 +575,31 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dd ago", days)
 }
 
+// formatDurationTR formatDuration'ın Türkçe karşılığı
+func formatDurationTR(d time.Duration) string {
+	if d < time.Minute {
+		return "az önce"
+	}
+	if d < time.Hour {
+		return fmt.Sprintf("%d dk önce", int(d.Minutes()))
+	}
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%d sa önce", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d gün önce", int(d.Hours()/24))
+}
+
+// truncate shortens s to at most n runes, never splitting a UTF-8 character
 func truncate(s string, n int) string {
-	if len(s) <= n {
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
 		return s
 	}
+	r := []rune(s)
 	if n <= 3 {
-		return s[:n]
+		return string(r[:n])
 	}
-	return s[:n-3] + "..."
+	return string(r[:n-3]) + "..."
 }
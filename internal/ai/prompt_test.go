@@ -0,0 +1,260 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+func sampleRequest() *DecisionRequest {
+	now := time.Now()
+	return &DecisionRequest{
+		AgentName:      "Test Agent",
+		CurrentBalance: 100000,
+		Strategy:       "balanced",
+		Portfolio: []models.Portfolio{
+			{StockSymbol: "THYAO", Quantity: 10, AvgBuyPrice: 250, CurrentValue: 2600, ProfitLoss: 100},
+		},
+		Stocks: []models.Stock{
+			{Symbol: "THYAO", Name: "Türk Hava Yolları", CurrentPrice: 260, ChangePercent: 1.5, Volume: 1000},
+			{Symbol: "ASELS", Name: "Aselsan", CurrentPrice: 50, ChangePercent: -0.5, Volume: 500},
+		},
+		News: []models.NewsArticle{
+			{Title: "THY yolcu sayısını açıkladı", Source: "Bloomberg HT", PublishedAt: now.Add(-30 * time.Minute), RelatedStocks: []string{"THYAO"}},
+		},
+		NewsCount: 1,
+		RecentTrades: []models.Trade{
+			{StockSymbol: "THYAO", TradeType: "BUY", Quantity: 10, Price: 250, CreatedAt: now},
+			{StockSymbol: "ASELS", TradeType: "BUY", Quantity: 5, Price: 48, CreatedAt: now},
+		},
+		MCPrices: []*models.StockPrice{{Symbol: "THYAO", Price: 260}},
+	}
+}
+
+func TestDefaultPromptsRender(t *testing.T) {
+	reg := DefaultPrompts()
+	ps := reg.Select("", "")
+	if ps == nil || ps.Name != DefaultPromptSet {
+		t.Fatalf("Select() default = %v, want %s", ps, DefaultPromptSet)
+	}
+
+	out, err := ps.RenderDecision(sampleRequest())
+	if err != nil {
+		t.Fatalf("RenderDecision() error = %v", err)
+	}
+	for _, want := range []string{"=== AGENT STATUS ===", "- THYAO: 10 lots @ 250.00 TL avg", "Max lots: 100", "30m ago", "=== QUESTION ==="} {
+		if !strings.Contains(out, want) {
+			t.Errorf("decision prompt missing %q", want)
+		}
+	}
+	// market context must be rendered once, not once per recent trade
+	if n := strings.Count(out, "=== 📊 MARKET CONTEXT"); n != 1 {
+		t.Errorf("market context rendered %d times, want 1", n)
+	}
+
+	sys, err := ps.RenderSystem(nil)
+	if err != nil || !strings.Contains(sys, `"action": "BUY|SELL|HOLD"`) {
+		t.Errorf("RenderSystem() = %q, %v", sys, err)
+	}
+}
+
+func TestPromptAgesFollowRequestClock(t *testing.T) {
+	req := sampleRequest()
+	at := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	req.Now = at
+	req.News[0].PublishedAt = at.Add(-3 * time.Hour)
+
+	out, err := DefaultPrompts().Select("", "").RenderDecision(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "[3h ago]") {
+		t.Errorf("news age should be measured from the replay time:\n%s", out)
+	}
+}
+
+func TestTurkishPromptSet(t *testing.T) {
+	ps, ok := DefaultPrompts().Get("default-v1-tr")
+	if !ok {
+		t.Fatal("default-v1-tr prompt set not loaded")
+	}
+	out, err := ps.RenderDecision(sampleRequest())
+	if err != nil {
+		t.Fatalf("RenderDecision() error = %v", err)
+	}
+	if !strings.Contains(out, "=== MEVCUT PORTFÖY ===") || !strings.Contains(out, "30 dk önce") {
+		t.Errorf("turkish prompt not rendered in Turkish:\n%s", out)
+	}
+	if def, _ := DefaultPrompts().Get(DefaultPromptSet); def.Hash == ps.Hash {
+		t.Error("different prompt sets must have different hashes")
+	}
+}
+
+func TestPromptRegistrySelectAndOverride(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("terse-v2.system.tmpl", "Be brief.")
+	write("terse-v2.decision.tmpl", "Agent {{.AgentName}} has {{printf \"%.0f\" .CurrentBalance}} TL.")
+
+	reg, err := NewPromptRegistry(dir)
+	if err != nil {
+		t.Fatalf("NewPromptRegistry() error = %v", err)
+	}
+	if err := reg.AssignStrategy("Aggressive", "terse-v2"); err != nil {
+		t.Fatalf("AssignStrategy() error = %v", err)
+	}
+	if err := reg.AssignStrategy("balanced", "missing"); err == nil {
+		t.Error("AssignStrategy() with unknown set should fail")
+	}
+
+	if got := reg.Select("", "aggressive").Name; got != "terse-v2" {
+		t.Errorf("strategy selection = %s, want terse-v2", got)
+	}
+	if got := reg.Select("default-v1-tr", "aggressive").Name; got != "default-v1-tr" {
+		t.Errorf("agent selection = %s, want default-v1-tr", got)
+	}
+	if got := reg.Select("unknown", "balanced").Name; got != DefaultPromptSet {
+		t.Errorf("fallback selection = %s, want %s", got, DefaultPromptSet)
+	}
+
+	out, err := reg.Select("terse-v2", "").RenderDecision(sampleRequest())
+	if err != nil || out != "Agent Test Agent has 100000 TL." {
+		t.Errorf("RenderDecision() = %q, %v", out, err)
+	}
+}
+
+func TestParsePromptStrategies(t *testing.T) {
+	got := ParsePromptStrategies(" aggressive=default-v1-tr, bad, =x,conservative = default-v1 ")
+	if len(got) != 2 || got["aggressive"] != "default-v1-tr" || got["conservative"] != "default-v1" {
+		t.Errorf("ParsePromptStrategies() = %v", got)
+	}
+}
+
+func TestTruncateKeepsRunes(t *testing.T) {
+	s := "Türkiye'de şirketlerin çeyreklik kârları açıklandı"
+	for n := 0; n < 60; n++ {
+		got := truncate(s, n)
+		if !utf8.ValidString(got) {
+			t.Fatalf("truncate(%d) produced invalid UTF-8: %q", n, got)
+		}
+		if c := utf8.RuneCountInString(got); c > n {
+			t.Fatalf("truncate(%d) = %d runes", n, c)
+		}
+	}
+	if got := truncate("ğüşiöç", 5); got != "ğü..." {
+		t.Errorf("truncate() = %q, want %q", got, "ğü...")
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	ascii := strings.Repeat("a", 400)
+	if got := EstimateTokens("gpt-4o", ascii); got != 100 {
+		t.Errorf("EstimateTokens(gpt-4o, 400 ascii) = %d, want 100", got)
+	}
+	if tr := EstimateTokens("gpt-4o", strings.Repeat("ş", 400)); tr <= 100 {
+		t.Errorf("turkish text should cost more tokens than ascii, got %d", tr)
+	}
+	if EstimateTokens("claude-3-5-sonnet", ascii) <= EstimateTokens("gpt-4o", ascii) {
+		t.Error("claude profile should estimate more tokens than gpt for the same text")
+	}
+	if got := PromptTokenLimit("gpt-4", 6000, 1000); got != 8192-MaxResponseTokens-1000 {
+		t.Errorf("PromptTokenLimit() = %d, want context-window cap", got)
+	}
+	if got := PromptTokenLimit("gpt-4o", 6000, 1000); got != 6000 {
+		t.Errorf("PromptTokenLimit() = %d, want configured budget", got)
+	}
+}
+
+func TestBuildDecisionBudget(t *testing.T) {
+	req := sampleRequest()
+	for i := 0; i < 20; i++ {
+		sym := fmt.Sprintf("S%02d", i)
+		req.Stocks = append(req.Stocks, models.Stock{Symbol: sym, Name: "Şirket", CurrentPrice: 10, ChangePercent: float64(i - 10)})
+		req.News = append(req.News, models.NewsArticle{
+			Title:         "Genel piyasa haberi " + sym,
+			Source:        "AA",
+			Description:   strings.Repeat("Borsa İstanbul'da işlem hacmi yükseldi. ", 10),
+			RelatedStocks: []string{sym},
+		})
+	}
+	req.NewsCount = len(req.News)
+	ps := DefaultPrompts().Select("", "")
+
+	full, err := ps.BuildDecision(req, "gpt-4o", 0)
+	if err != nil {
+		t.Fatalf("BuildDecision() error = %v", err)
+	}
+	if len(full.Compressed) != 0 {
+		t.Errorf("unbudgeted prompt compressed: %v", full.Compressed)
+	}
+
+	budget := full.Tokens / 2
+	got, err := ps.BuildDecision(req, "gpt-4o", budget)
+	if err != nil {
+		t.Fatalf("BuildDecision() error = %v", err)
+	}
+	if got.Tokens > budget {
+		t.Errorf("tokens = %d, want <= %d", got.Tokens, budget)
+	}
+	if got.Tokens != EstimateTokens("gpt-4o", got.Text) {
+		t.Error("reported tokens do not match the rendered text")
+	}
+	if len(got.Compressed) == 0 || got.Compressed[0] != "news_descriptions" {
+		t.Errorf("compressed sections = %v", got.Compressed)
+	}
+	for _, want := range []string{
+		"- THYAO: 10 lots @ 250.00 TL avg", // portfolio is never trimmed
+		"THY yolcu sayısını açıkladı",      // held-stock news outranks general news
+		"S00 (Şirket)",                     // biggest mover kept
+		"older/less relevant articles omitted",
+	} {
+		if !strings.Contains(got.Text, want) {
+			t.Errorf("budgeted prompt missing %q", want)
+		}
+	}
+	if strings.Contains(got.Text, "Genel piyasa haberi S08") {
+		t.Error("general news should be dropped before held-stock news")
+	}
+
+	// an impossible budget compresses everything but still keeps the portfolio
+	tiny, err := ps.BuildDecision(req, "gpt-4o", 1)
+	if err != nil {
+		t.Fatalf("BuildDecision() error = %v", err)
+	}
+	if !strings.Contains(tiny.Text, "- THYAO: 10 lots") || strings.Contains(tiny.Text, "S00 (Şirket)") {
+		t.Errorf("maximally compressed prompt:\n%s", tiny.Text)
+	}
+}
+
+func TestMemoryBlock(t *testing.T) {
+	req := sampleRequest()
+	out, _ := DefaultPrompts().Select("", "").RenderDecision(req)
+	if strings.Contains(out, "LESSONS FROM YOUR PAST TRADES") {
+		t.Error("memory block rendered without memories")
+	}
+
+	req.StandingNote = "Stop chasing rallies."
+	req.Memories = []models.AgentMemory{{Content: "Buying THYAO at 250.00 lost 3.0%.", CreatedAt: time.Now().Add(-2 * time.Hour)}}
+	out, err := DefaultPrompts().Select("", "").RenderDecision(req)
+	if err != nil {
+		t.Fatalf("RenderDecision() error = %v", err)
+	}
+	for _, want := range []string{"=== 🧠 LESSONS FROM YOUR PAST TRADES ===", "Standing note: Stop chasing rallies.", "- [2h ago] Buying THYAO at 250.00 lost 3.0%."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("decision prompt missing %q", want)
+		}
+	}
+	if strings.Index(out, "LESSONS FROM YOUR PAST TRADES") > strings.Index(out, "=== QUESTION ===") {
+		t.Error("memory block must come before the question")
+	}
+}
@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+// reflectionSystemPrompt asks for a standing note in the regular decision JSON
+// format so every Client can be reused without a separate completion API.
+const reflectionSystemPrompt = `You are reviewing your own recent trading mistakes on Borsa Istanbul (BIST).
+Write a short standing note (at most 3 sentences) with concrete rules you will follow in future decisions to avoid repeating these mistakes.
+
+Respond ONLY with valid JSON in this exact format:
+{
+  "action": "HOLD",
+  "stock_symbol": "",
+  "quantity": 0,
+  "reasoning_summary": "<the standing note>",
+  "reasoning_full": "<your analysis of the mistakes>",
+  "confidence": 0,
+  "risk_level": "low",
+  "thinking_steps": []
+}`
+
+// BuildReflectionPrompt returns the system and user prompt asking an agent to
+// summarise its recent losing lessons into a standing note
+func BuildReflectionPrompt(agentName string, lessons []models.AgentMemory) (string, string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Agent: %s\n\n=== YOUR RECENT MISTAKES ===\n", agentName)
+	for _, m := range lessons {
+		fmt.Fprintf(&b, "- [%s] %s\n", m.CreatedAt.Format("2006-01-02 15:04"), m.Situation)
+		if m.ProfitLoss != nil {
+			fmt.Fprintf(&b, "  Outcome: %.2f TL\n", *m.ProfitLoss)
+		}
+		fmt.Fprintf(&b, "  Lesson: %s\n", truncate(m.Content, 300))
+	}
+	b.WriteString("\nWhat patterns do you see, and what rules will you follow from now on?")
+	return reflectionSystemPrompt, b.String()
+}
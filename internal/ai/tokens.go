@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxResponseTokens is the completion limit every client requests; it is
+// reserved out of the context window when sizing prompts.
+const MaxResponseTokens = 1500
+
+// tokenProfile approximates a tokenizer: how many ASCII characters make up a
+// token on average, and the model's context window.
+type tokenProfile struct {
+	charsPerToken float64
+	contextWindow int
+}
+
+// Ordered by specificity: the first matching prefix wins
+var tokenProfiles = []struct {
+	match   string
+	profile tokenProfile
+}{
+	{"gpt-4o", tokenProfile{4.0, 128000}},
+	{"gpt-4-turbo", tokenProfile{4.0, 128000}},
+	{"gpt-4", tokenProfile{4.0, 8192}},
+	{"gpt-3.5", tokenProfile{4.0, 16385}},
+	{"claude", tokenProfile{3.5, 200000}},
+	{"gemini", tokenProfile{4.0, 1000000}},
+	{"deepseek", tokenProfile{3.6, 64000}},
+	{"llama", tokenProfile{3.8, 128000}},
+	{"mixtral", tokenProfile{3.7, 64000}},
+	{"mistral", tokenProfile{3.7, 32000}},
+	{"grok", tokenProfile{4.0, 131072}},
+}
+
+var defaultTokenProfile = tokenProfile{3.5, 8192}
+
+func profileFor(model string) tokenProfile {
+	model = strings.ToLower(model)
+	for _, p := range tokenProfiles {
+		if strings.Contains(model, p.match) {
+			return p.profile
+		}
+	}
+	return defaultTokenProfile
+}
+
+// EstimateTokens approximates the number of tokens text uses for the given model.
+// Non-ASCII runes (Turkish letters, emoji) usually split into several byte-level
+// tokens, so they are weighted by their UTF-8 length.
+func EstimateTokens(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	var weight float64
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			weight++
+		} else {
+			weight += float64(utf8.RuneLen(r))
+		}
+	}
+	tokens := int(weight/profileFor(model).charsPerToken + 0.999)
+	if tokens < 1 {
+		tokens = 1
+	}
+	return tokens
+}
+
+// ContextWindow returns the model's context window in tokens
+func ContextWindow(model string) int {
+	return profileFor(model).contextWindow
+}
+
+// PromptTokenLimit returns how many tokens the decision prompt may use: the
+// configured budget (0 = no budget), capped by what fits in the model's
+// context window next to the system prompt and the response.
+func PromptTokenLimit(model string, budget, systemTokens int) int {
+	limit := ContextWindow(model) - MaxResponseTokens - systemTokens
+	if budget > 0 && budget < limit {
+		limit = budget
+	}
+	if limit < 1 {
+		return 1 // nothing fits; compress as far as possible
+	}
+	return limit
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"time"
+
 	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -18,7 +22,7 @@ func NewAgentHandler(db *pgxpool.Pool) *AgentHandler {
 func (h *AgentHandler) GetAll(c *fiber.Ctx) error {
 	query := `
 		SELECT a.id, a.name, a.model, a.status, a.initial_balance, a.current_balance,
-		       a.created_at, a.updated_at,
+		       COALESCE(a.approval_mode, FALSE), a.created_at, a.updated_at,
 		       COALESCE(m.total_profit_loss, 0) as profit_loss,
 		       COALESCE(m.roi, 0) as roi
 		FROM agents a
@@ -47,7 +51,7 @@ func (h *AgentHandler) GetAll(c *fiber.Ctx) error {
 		if err := rows.Scan(
 			&agent.ID, &agent.Name, &agent.Model, &agent.Status,
 			&agent.InitialBalance, &agent.CurrentBalance,
-			&agent.CreatedAt, &agent.UpdatedAt,
+			&agent.ApprovalMode, &agent.CreatedAt, &agent.UpdatedAt,
 			&agent.ProfitLoss, &agent.ROI,
 		); err != nil {
 			continue
@@ -72,14 +76,15 @@ func (h *AgentHandler) GetByID(c *fiber.Ctx) error {
 
 	var agent models.Agent
 	query := `
-		SELECT id, name, model, status, initial_balance, current_balance, created_at, updated_at
+		SELECT id, name, model, status, initial_balance, current_balance, prompt_set,
+		       COALESCE(approval_mode, FALSE), created_at, updated_at
 		FROM agents WHERE id = $1
 	`
 
 	err = h.db.QueryRow(c.Context(), query, id).Scan(
 		&agent.ID, &agent.Name, &agent.Model, &agent.Status,
-		&agent.InitialBalance, &agent.CurrentBalance,
-		&agent.CreatedAt, &agent.UpdatedAt,
+		&agent.InitialBalance, &agent.CurrentBalance, &agent.PromptSet,
+		&agent.ApprovalMode, &agent.CreatedAt, &agent.UpdatedAt,
 	)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(models.Response{
@@ -94,6 +99,47 @@ func (h *AgentHandler) GetByID(c *fiber.Ctx) error {
 	})
 }
 
+// SetApprovalMode turns human approval of the agent's trades on or off
+// PUT /api/v1/agents/:id/approval-mode
+func (h *AgentHandler) SetApprovalMode(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Message: "Invalid agent ID",
+		})
+	}
+
+	var req models.ApprovalModeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	tag, err := h.db.Exec(c.Context(),
+		"UPDATE agents SET approval_mode = $1, updated_at = NOW() WHERE id = $2", req.Enabled, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Success: false,
+			Message: "Failed to update approval mode",
+		})
+	}
+	if tag.RowsAffected() == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{
+			Success: false,
+			Message: "Agent not found",
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Approval mode updated",
+		Data:    req,
+	})
+}
+
 func (h *AgentHandler) GetMetrics(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -176,3 +222,175 @@ func (h *AgentHandler) GetPortfolio(c *fiber.Ctx) error {
 		Data:    holdings,
 	})
 }
+
+// GetMemories returns an agent's stored lessons and reflection notes, newest first
+// GET /api/v1/agents/:id/memories?kind=lesson|reflection
+func (h *AgentHandler) GetMemories(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Message: "Invalid agent ID",
+		})
+	}
+
+	query := `
+		SELECT id, agent_id, decision_id, kind, stock_symbol, COALESCE(situation, ''), content,
+		       profit_loss, COALESCE(importance, 0), COALESCE(source, 'rule'), created_at
+		FROM agent_memories
+		WHERE agent_id = $1 AND ($2 = '' OR kind = $2)
+		ORDER BY created_at DESC
+		LIMIT 100
+	`
+
+	rows, err := h.db.Query(c.Context(), query, id, c.Query("kind"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Success: false,
+			Message: "Failed to fetch memories",
+		})
+	}
+	defer rows.Close()
+
+	memories := []models.AgentMemory{}
+	for rows.Next() {
+		var m models.AgentMemory
+		if err := rows.Scan(
+			&m.ID, &m.AgentID, &m.DecisionID, &m.Kind, &m.StockSymbol, &m.Situation,
+			&m.Content, &m.ProfitLoss, &m.Importance, &m.Source, &m.CreatedAt,
+		); err != nil {
+			continue
+		}
+		memories = append(memories, m)
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    memories,
+	})
+}
+
+// GetCalibration returns the agent's calibration curve (stated confidence vs.
+// realised hit rate) and Brier score at a horizon
+// GET /api/v1/agents/:id/calibration?horizon=1d
+func (h *AgentHandler) GetCalibration(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Message: "Invalid agent ID",
+		})
+	}
+
+	horizon := c.Query("horizon", services.PrimaryHorizon)
+	cals, err := services.Calibrations(c.Context(), h.db, horizon, &id)
+	if err != nil {
+		return calibrationError(c, err)
+	}
+
+	// No scored decisions yet: an empty curve
+	cal := models.Calibration{AgentID: id, Horizon: horizon, Bins: []models.CalibrationBin{}}
+	if len(cals) > 0 {
+		cal = cals[0]
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    cal,
+	})
+}
+
+// calibrationError maps calibration errors to HTTP responses
+func calibrationError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrUnknownHorizon) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to compute calibration"})
+}
+
+// GetMatchups returns the agent's head-to-head record against every opponent
+// GET /api/v1/agents/:id/matchups
+func (h *AgentHandler) GetMatchups(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Message: "Invalid agent ID",
+		})
+	}
+
+	matchups, err := services.AgentMatchups(c.Context(), h.db, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Success: false,
+			Message: "Failed to fetch matchups",
+		})
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    matchups,
+	})
+}
+
+// GetDailyStats returns the agent's end-of-day rollups, newest first
+// GET /api/v1/agents/:id/daily-stats?from=2025-01-01&to=2025-01-31 (default: last 30 days)
+func (h *AgentHandler) GetDailyStats(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Message: "Invalid agent ID",
+		})
+	}
+
+	to := time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.DateOnly, v); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid to date (YYYY-MM-DD)"})
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.DateOnly, v); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid from date (YYYY-MM-DD)"})
+		}
+	}
+	if from.After(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "from must not be after to"})
+	}
+
+	stats, err := services.DailyStats(c.Context(), h.db, id, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Success: false,
+			Message: "Failed to fetch daily stats",
+		})
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    stats,
+	})
+}
+
+// GetBadges returns the badges the agent has been awarded, newest first
+// GET /api/v1/agents/:id/badges
+func (h *AgentHandler) GetBadges(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Message: "Invalid agent ID",
+		})
+	}
+
+	badges, err := services.AgentBadges(c.Context(), h.db, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Success: false,
+			Message: "Failed to fetch badges",
+		})
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    badges,
+	})
+}
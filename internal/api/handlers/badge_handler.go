@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// BadgeHandler serves the badge rule catalogue
+type BadgeHandler struct {
+	engine *services.BadgeEngine
+}
+
+// NewBadgeHandler creates a new badge handler
+func NewBadgeHandler(engine *services.BadgeEngine) *BadgeHandler {
+	return &BadgeHandler{engine: engine}
+}
+
+// List returns the loaded badge rules
+// GET /api/v1/badges
+func (h *BadgeHandler) List(c *fiber.Ctx) error {
+	return c.JSON(models.Response{Success: true, Data: h.engine.Rules()})
+}
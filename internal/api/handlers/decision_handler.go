@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DecisionHandler serves the audit trail of agent decisions and their replays
+type DecisionHandler struct {
+	db     *pgxpool.Pool
+	replay *services.ReplayService
+}
+
+// NewDecisionHandler creates a new decision handler
+func NewDecisionHandler(db *pgxpool.Pool, replay *services.ReplayService) *DecisionHandler {
+	return &DecisionHandler{db: db, replay: replay}
+}
+
+// GetTrail returns everything needed to explain a decision: the stored decision,
+// the market snapshot it saw, the exact prompts and raw model response, latency,
+// provider/model, reasoning steps and resulting trades
+// GET /api/v1/decisions/:id
+func (h *DecisionHandler) GetTrail(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid decision ID"})
+	}
+
+	trail, err := services.DecisionTrail(c.Context(), h.db, id)
+	if errors.Is(err, services.ErrDecisionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Decision not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch decision"})
+	}
+	return c.JSON(models.Response{Success: true, Data: trail})
+}
+
+// Replay re-runs a decision on its stored prompt with the same or another model
+// (body: {"model": "...", "prompt_set": "..."}, both optional) and diffs the result
+// POST /api/v1/decisions/:id/replay
+func (h *DecisionHandler) Replay(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid decision ID"})
+	}
+	var opts models.ReplayOptions
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&opts); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid request body"})
+		}
+	}
+
+	r, err := h.replay.Replay(c.Context(), id, opts)
+	if err != nil {
+		return replayError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: r})
+}
+
+// ListReplays returns the stored replays of a decision
+// GET /api/v1/decisions/:id/replays
+func (h *DecisionHandler) ListReplays(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid decision ID"})
+	}
+	replays, err := h.replay.List(c.Context(), id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch replays"})
+	}
+	return c.JSON(models.Response{Success: true, Data: replays})
+}
+
+// ReplayRange starts replaying the audited decisions of a time range, optionally
+// of one agent, in the background and returns 202 with the batch ID
+// (body: {"from": RFC3339, "to": RFC3339, "agent_id": "...", "model": "...", "prompt_set": "...", "limit": 20})
+// POST /api/v1/replays
+func (h *DecisionHandler) ReplayRange(c *fiber.Ctx) error {
+	var req models.ReplayRangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid request body"})
+	}
+	if req.From.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "from is required"})
+	}
+
+	report, err := h.replay.StartRange(c.Context(), req)
+	if err != nil {
+		return replayError(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(models.Response{Success: true, Message: "Replay started", Data: report})
+}
+
+// GetBatch returns a range replay's status and the results so far
+// GET /api/v1/replays/:id
+func (h *DecisionHandler) GetBatch(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid batch ID"})
+	}
+	report, err := h.replay.Batch(c.Context(), id)
+	if err != nil {
+		return replayError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: report})
+}
+
+// replayError maps replay service errors to HTTP responses
+func replayError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDecisionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Decision not found"})
+	case errors.Is(err, services.ErrReplayBatchNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Replay batch not found"})
+	case errors.Is(err, services.ErrNoDecisionAudit):
+		return c.Status(fiber.StatusConflict).JSON(models.Response{Success: false, Message: err.Error()})
+	case errors.Is(err, services.ErrReplayModelUnavailable), errors.Is(err, services.ErrUnknownPromptSet):
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: err.Error()})
+	}
+}
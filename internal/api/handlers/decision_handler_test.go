@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDecisionHandler_InvalidID(t *testing.T) {
+	app := fiber.New()
+	h := NewDecisionHandler(nil, nil)
+	app.Get("/decisions/:id", h.GetTrail)
+	app.Get("/replays/:id", h.GetBatch)
+
+	for _, path := range []string{"/decisions/not-a-uuid", "/replays/not-a-uuid"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", path, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
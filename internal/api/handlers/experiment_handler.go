@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ExperimentHandler handles prompt A/B experiment requests
+type ExperimentHandler struct {
+	service *services.ExperimentService
+}
+
+// NewExperimentHandler creates a new experiment handler
+func NewExperimentHandler(svc *services.ExperimentService) *ExperimentHandler {
+	return &ExperimentHandler{service: svc}
+}
+
+// List returns all experiments
+// GET /api/v1/experiments
+func (h *ExperimentHandler) List(c *fiber.Ctx) error {
+	experiments, err := h.service.List(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch experiments"})
+	}
+	return c.JSON(models.Response{Success: true, Data: experiments})
+}
+
+// Create creates and starts a new experiment
+// POST /api/v1/experiments
+func (h *ExperimentHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateExperimentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid request body"})
+	}
+
+	exp, err := h.service.Create(c.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExperiment) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to create experiment"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(models.Response{Success: true, Message: "Experiment started", Data: exp})
+}
+
+// GetByID returns a single experiment
+// GET /api/v1/experiments/:id
+func (h *ExperimentHandler) GetByID(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid experiment ID"})
+	}
+
+	exp, err := h.service.Get(c.Context(), id)
+	if err != nil {
+		return experimentError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: exp})
+}
+
+// Stop stops a running experiment
+// POST /api/v1/experiments/:id/stop
+func (h *ExperimentHandler) Stop(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid experiment ID"})
+	}
+
+	if err := h.service.Stop(c.Context(), id); err != nil {
+		if errors.Is(err, services.ErrExperimentNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Experiment not found or not running"})
+		}
+		return experimentError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Message: "Experiment stopped"})
+}
+
+// GetReport compares experiment variants with significance statistics
+// GET /api/v1/experiments/:id/report
+func (h *ExperimentHandler) GetReport(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid experiment ID"})
+	}
+
+	report, err := h.service.Report(c.Context(), id)
+	if err != nil {
+		return experimentError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: report})
+}
+
+func experimentError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrExperimentNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Experiment not found"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to load experiment"})
+}
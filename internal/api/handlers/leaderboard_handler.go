@@ -1,19 +1,38 @@
 package handlers
 
 import (
+	"slices"
+
 	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type LeaderboardHandler struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	boards *services.BoardRanker
 }
 
-func NewLeaderboardHandler(db *pgxpool.Pool) *LeaderboardHandler { return &LeaderboardHandler{db: db} }
+func NewLeaderboardHandler(db *pgxpool.Pool, boards *services.BoardRanker) *LeaderboardHandler {
+	return &LeaderboardHandler{db: db, boards: boards}
+}
 
-// GetLeaderboard returns current leaderboard entries
+// GetLeaderboard returns current leaderboard entries, or a named board's
+// standings with scores and components
+// GET /api/v1/leaderboard?board=risk_adjusted
 func (h *LeaderboardHandler) GetLeaderboard(c *fiber.Ctx) error {
+	if board := c.Query("board"); board != "" {
+		if _, ok := h.boards.Board(board); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Unknown board"})
+		}
+		entries, err := services.BoardLeaderboard(c.Context(), h.db, board)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch leaderboard"})
+		}
+		return c.JSON(models.Response{Success: true, Data: entries})
+	}
+
 	const q = `
         SELECT
             lr.rank_overall,
@@ -53,3 +72,56 @@ func (h *LeaderboardHandler) GetLeaderboard(c *fiber.Ctx) error {
 
 	return c.JSON(models.Response{Success: true, Data: entries})
 }
+
+// GetBoards lists the configured boards: weights, eligibility and calibration
+// horizon. The first one is the default order of GET /leaderboard.
+// GET /api/v1/leaderboard/boards
+func (h *LeaderboardHandler) GetBoards(c *fiber.Ctx) error {
+	return c.JSON(models.Response{Success: true, Data: h.boards.Boards()})
+}
+
+// GetShadowLeaderboard ranks shadow (paper) agents by their shadow ledger
+// GET /api/v1/leaderboard/shadow
+func (h *LeaderboardHandler) GetShadowLeaderboard(c *fiber.Ctx) error {
+	entries, err := services.ShadowLeaderboard(c.Context(), h.db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch shadow leaderboard"})
+	}
+	return c.JSON(models.Response{Success: true, Data: entries})
+}
+
+// GetCalibration compares every agent's stated confidence with its realised hit
+// rate (calibration curve and Brier score) at a horizon
+// GET /api/v1/leaderboard/calibration?horizon=1d
+func (h *LeaderboardHandler) GetCalibration(c *fiber.Ctx) error {
+	cals, err := services.Calibrations(c.Context(), h.db, c.Query("horizon", services.PrimaryHorizon), nil)
+	if err != nil {
+		return calibrationError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: cals})
+}
+
+// GetBenchmarks lists every active agent's alpha, beta, tracking error and
+// information ratio against the benchmarks (optionally one of them)
+// GET /api/v1/leaderboard/benchmarks?benchmark=bist100
+func (h *LeaderboardHandler) GetBenchmarks(c *fiber.Ctx) error {
+	benchmark := c.Query("benchmark")
+	if benchmark != "" && !slices.Contains(services.Benchmarks, benchmark) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Unknown benchmark"})
+	}
+	comparisons, err := services.BenchmarkComparisons(c.Context(), h.db, benchmark)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch benchmark comparisons"})
+	}
+	return c.JSON(models.Response{Success: true, Data: comparisons})
+}
+
+// GetElo ranks active agents by their Elo rating from daily head-to-head matchups
+// GET /api/v1/leaderboard/elo
+func (h *LeaderboardHandler) GetElo(c *fiber.Ctx) error {
+	ratings, err := services.EloLeaderboard(c.Context(), h.db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch Elo ratings"})
+	}
+	return c.JSON(models.Response{Success: true, Data: ratings})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ProposalHandler handles trade proposals of agents in approval mode
+type ProposalHandler struct {
+	service *services.ProposalService
+}
+
+// NewProposalHandler creates a new proposal handler
+func NewProposalHandler(svc *services.ProposalService) *ProposalHandler {
+	return &ProposalHandler{service: svc}
+}
+
+// List returns proposals, optionally filtered by status and agent
+// GET /api/v1/proposals?status=pending&agent_id=...&limit=50
+func (h *ProposalHandler) List(c *fiber.Ctx) error {
+	var agentID *uuid.UUID
+	if raw := c.Query("agent_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid agent ID"})
+		}
+		agentID = &id
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	proposals, err := h.service.List(c.Context(), c.Query("status"), agentID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch proposals"})
+	}
+	return c.JSON(models.Response{Success: true, Data: proposals})
+}
+
+// GetByID returns a single proposal
+// GET /api/v1/proposals/:id
+func (h *ProposalHandler) GetByID(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid proposal ID"})
+	}
+
+	p, err := h.service.Get(c.Context(), id)
+	if err != nil {
+		return proposalError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: p})
+}
+
+// Approve executes a pending proposal and records the approver
+// POST /api/v1/proposals/:id/approve
+func (h *ProposalHandler) Approve(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid proposal ID"})
+	}
+	var req models.ProposalReviewRequest
+	_ = c.BodyParser(&req) // body is optional
+
+	p, err := h.service.Approve(c.Context(), id, approverIdentity(c), req.Note)
+	if err != nil {
+		return proposalError(c, err)
+	}
+	if p.Status == models.ProposalFailed {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.Response{Success: false, Message: "Proposal approved but execution failed", Data: p})
+	}
+	return c.JSON(models.Response{Success: true, Message: "Proposal approved and executed", Data: p})
+}
+
+// Reject rejects a pending proposal and records the reviewer
+// POST /api/v1/proposals/:id/reject
+func (h *ProposalHandler) Reject(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid proposal ID"})
+	}
+	var req models.ProposalReviewRequest
+	_ = c.BodyParser(&req) // body is optional
+
+	p, err := h.service.Reject(c.Context(), id, approverIdentity(c), req.Note)
+	if err != nil {
+		return proposalError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Message: "Proposal rejected", Data: p})
+}
+
+// approverIdentity names the authenticated caller: the JWT username, or the
+// API key (masked) when the request used one
+func approverIdentity(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok && username != "" {
+		return "user:" + username
+	}
+	if key, ok := c.Locals("api_key").(string); ok && key != "" {
+		if len(key) > 4 {
+			key = key[len(key)-4:]
+		}
+		return fmt.Sprintf("api_key:...%s", key)
+	}
+	return "unknown"
+}
+
+func proposalError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrProposalNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Proposal not found"})
+	case errors.Is(err, services.ErrProposalNotPending):
+		return c.Status(fiber.StatusConflict).JSON(models.Response{Success: false, Message: "Proposal is no longer pending (already reviewed or expired)"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to process proposal"})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestApproverIdentity(t *testing.T) {
+	cases := []struct {
+		name   string
+		locals map[string]string
+		want   string
+	}{
+		{"jwt user", map[string]string{"username": "alice"}, "user:alice"},
+		{"api key is masked", map[string]string{"api_key": "secret-key-1234"}, "api_key:...1234"},
+		{"anonymous", nil, "unknown"},
+	}
+	for _, tc := range cases {
+		app := fiber.New()
+		var got string
+		app.Get("/", func(c *fiber.Ctx) error {
+			for k, v := range tc.locals {
+				c.Locals(k, v)
+			}
+			got = approverIdentity(c)
+			return nil
+		})
+		if _, err := app.Test(httptest.NewRequest("GET", "/", nil)); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: approverIdentity() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestProposalHandler_InvalidID(t *testing.T) {
+	app := fiber.New()
+	h := NewProposalHandler(nil)
+	app.Post("/proposals/:id/approve", h.Approve)
+	app.Post("/proposals/:id/reject", h.Reject)
+
+	for _, path := range []string{"/proposals/not-a-uuid/approve", "/proposals/not-a-uuid/reject"} {
+		resp, err := app.Test(httptest.NewRequest("POST", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("POST %s = %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
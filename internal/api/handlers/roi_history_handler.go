@@ -1,9 +1,8 @@
 package handlers
 
 import (
-	"time"
-
 	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,7 +13,19 @@ type ROIHistoryHandler struct{ db *pgxpool.Pool }
 func NewROIHistoryHandler(db *pgxpool.Pool) *ROIHistoryHandler { return &ROIHistoryHandler{db: db} }
 
 // GetAllAgentsROIHistory returns ROI time series for all active agents (recent N snapshots)
+// and the benchmark lines (bist100, equal_weight, cash)
 func (h *ROIHistoryHandler) GetAllAgentsROIHistory(c *fiber.Ctx) error {
+	return h.roiHistory(c, "active", "live")
+}
+
+// GetShadowROIHistory returns ROI time series of shadow agents' shadow ledgers
+// (no benchmark lines)
+// GET /api/v1/leaderboard/shadow/roi-history
+func (h *ROIHistoryHandler) GetShadowROIHistory(c *fiber.Ctx) error {
+	return h.roiHistory(c, "shadow", "shadow")
+}
+
+func (h *ROIHistoryHandler) roiHistory(c *fiber.Ctx, status, book string) error {
 	limit := c.QueryInt("limit", 50)
 	if limit < 1 {
 		limit = 50
@@ -27,29 +38,35 @@ func (h *ROIHistoryHandler) GetAllAgentsROIHistory(c *fiber.Ctx) error {
         SELECT aps.agent_id, aps.snapshot_time, aps.roi_percent
         FROM agent_performance_snapshots aps
         JOIN agents a ON aps.agent_id = a.id
-        WHERE a.status = 'active'
+        WHERE a.status = $2 AND aps.book = $3
         ORDER BY aps.snapshot_time DESC
         LIMIT $1`
 
-	rows, err := h.db.Query(c.Context(), query, limit)
+	rows, err := h.db.Query(c.Context(), query, limit, status, book)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch ROI history"})
 	}
 	defer rows.Close()
 
-	type Point struct {
-		Time time.Time `json:"time"`
-		ROI  float64   `json:"roi"`
-	}
-	data := map[uuid.UUID][]Point{}
+	data := models.ROIHistory{Agents: map[string][]models.ROIPoint{}, Benchmarks: map[string][]models.ROIPoint{}}
 	for rows.Next() {
 		var agentID uuid.UUID
-		var ts time.Time
-		var roi float64
-		if err := rows.Scan(&agentID, &ts, &roi); err != nil {
+		var p models.ROIPoint
+		if err := rows.Scan(&agentID, &p.Time, &p.ROI); err != nil {
 			continue
 		}
-		data[agentID] = append(data[agentID], Point{Time: ts, ROI: roi})
+		data.Agents[agentID.String()] = append(data.Agents[agentID.String()], p)
+	}
+	rows.Close()
+
+	if book == services.BookLive {
+		benchmarks, err := services.BenchmarkROIHistory(c.Context(), h.db, limit)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch benchmark history"})
+		}
+		for name, points := range benchmarks {
+			data.Benchmarks[name] = points
+		}
 	}
 
 	return c.JSON(models.Response{Success: true, Data: data})
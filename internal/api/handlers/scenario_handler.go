@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/1batu/market-ai/internal/simulation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ScenarioHandler handles stress-test scenario requests
+type ScenarioHandler struct {
+	runner *services.ScenarioRunner
+}
+
+// NewScenarioHandler creates a new scenario handler
+func NewScenarioHandler(runner *services.ScenarioRunner) *ScenarioHandler {
+	return &ScenarioHandler{runner: runner}
+}
+
+// List returns the scenarios in the scenario directory
+// GET /api/v1/scenarios
+func (h *ScenarioHandler) List(c *fiber.Ctx) error {
+	scenarios, err := h.runner.Available()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to list scenarios"})
+	}
+	return c.JSON(models.Response{Success: true, Data: scenarios})
+}
+
+// Start starts a scenario. The body is either JSON ({"name": "<file>"} or
+// {"yaml": "..."}) or a raw YAML document with a YAML content type.
+// POST /api/v1/scenarios/runs
+func (h *ScenarioHandler) Start(c *fiber.Ctx) error {
+	var req models.StartScenarioRequest
+	if strings.Contains(string(c.Request().Header.ContentType()), "yaml") {
+		req.YAML = string(c.Body())
+	} else if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid request body"})
+	}
+
+	var sc *simulation.Scenario
+	var err error
+	switch {
+	case req.YAML != "":
+		sc, err = simulation.ParseScenario([]byte(req.YAML))
+	case req.Name != "":
+		sc, err = h.runner.Load(req.Name)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "name or yaml is required"})
+	}
+	if err != nil {
+		return scenarioError(c, err)
+	}
+
+	run, err := h.runner.StartRun(c.Context(), sc)
+	if err != nil {
+		return scenarioError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(models.Response{Success: true, Message: "Scenario started", Data: run})
+}
+
+// ListRuns returns the most recent scenario runs
+// GET /api/v1/scenarios/runs
+func (h *ScenarioHandler) ListRuns(c *fiber.Ctx) error {
+	runs, err := h.runner.List(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch scenario runs"})
+	}
+	return c.JSON(models.Response{Success: true, Data: runs})
+}
+
+// GetRun returns a single scenario run with its applied events
+// GET /api/v1/scenarios/runs/:id
+func (h *ScenarioHandler) GetRun(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid run ID"})
+	}
+
+	run, err := h.runner.Get(c.Context(), id)
+	if err != nil {
+		return scenarioError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: run})
+}
+
+// Stop stops the running scenario
+// POST /api/v1/scenarios/runs/:id/stop
+func (h *ScenarioHandler) Stop(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid run ID"})
+	}
+
+	if err := h.runner.StopRun(c.Context(), id); err != nil {
+		if errors.Is(err, services.ErrScenarioNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Scenario run not found or not running"})
+		}
+		return scenarioError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Message: "Scenario stopped"})
+}
+
+// GetReport shows how each agent reacted during a run
+// GET /api/v1/scenarios/runs/:id/report
+func (h *ScenarioHandler) GetReport(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid run ID"})
+	}
+
+	report, err := h.runner.Report(c.Context(), id)
+	if err != nil {
+		return scenarioError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: report})
+}
+
+func scenarioError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrScenarioNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Scenario not found"})
+	case errors.Is(err, simulation.ErrInvalidScenario):
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: err.Error()})
+	case errors.Is(err, services.ErrScenarioRunning):
+		return c.Status(fiber.StatusConflict).JSON(models.Response{Success: false, Message: err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to process scenario"})
+}
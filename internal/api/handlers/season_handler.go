@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SeasonHandler handles competition season requests
+type SeasonHandler struct {
+	service *services.SeasonService
+}
+
+// NewSeasonHandler creates a new season handler
+func NewSeasonHandler(svc *services.SeasonService) *SeasonHandler {
+	return &SeasonHandler{service: svc}
+}
+
+// List returns all seasons, newest first
+// GET /api/v1/seasons
+func (h *SeasonHandler) List(c *fiber.Ctx) error {
+	seasons, err := h.service.List(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to fetch seasons"})
+	}
+	return c.JSON(models.Response{Success: true, Data: seasons})
+}
+
+// Create schedules a season; it starts right away unless starts_at is in the future.
+// Starting a season ends the running one and resets the participants' balances.
+// POST /api/v1/seasons
+func (h *SeasonHandler) Create(c *fiber.Ctx) error {
+	var req models.CreateSeasonRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid request body"})
+	}
+
+	season, err := h.service.Create(c.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSeason) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to create season"})
+	}
+	message := "Season scheduled"
+	if season.Status == models.SeasonActive {
+		message = "Season started"
+	}
+	return c.Status(fiber.StatusCreated).JSON(models.Response{Success: true, Message: message, Data: season})
+}
+
+// GetCurrent returns the running season with its live standings
+// GET /api/v1/seasons/current
+func (h *SeasonHandler) GetCurrent(c *fiber.Ctx) error {
+	detail, err := h.service.Current(c.Context())
+	if err != nil {
+		if errors.Is(err, services.ErrSeasonNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "No active season"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to load season"})
+	}
+	return c.JSON(models.Response{Success: true, Data: detail})
+}
+
+// GetByID returns a season with its standings (final standings and badges once completed)
+// GET /api/v1/seasons/:id
+func (h *SeasonHandler) GetByID(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid season ID"})
+	}
+
+	detail, err := h.service.Detail(c.Context(), id)
+	if err != nil {
+		return seasonError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Data: detail})
+}
+
+// End archives the running season before its end date
+// POST /api/v1/seasons/:id/end
+func (h *SeasonHandler) End(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{Success: false, Message: "Invalid season ID"})
+	}
+
+	if err := h.service.End(c.Context(), id); err != nil {
+		if errors.Is(err, services.ErrSeasonNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Season not found or not active"})
+		}
+		return seasonError(c, err)
+	}
+	return c.JSON(models.Response{Success: true, Message: "Season ended"})
+}
+
+func seasonError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrSeasonNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.Response{Success: false, Message: "Season not found"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(models.Response{Success: false, Message: "Failed to load season"})
+}
@@ -21,6 +21,12 @@ func SetupRoutes(
 	universeHandler *handlers.UniverseHandler,
 	newsHandler *handlers.NewsHandler,
 	authHandler *handlers.AuthHandler,
+	experimentHandler *handlers.ExperimentHandler,
+	proposalHandler *handlers.ProposalHandler,
+	decisionHandler *handlers.DecisionHandler,
+	scenarioHandler *handlers.ScenarioHandler,
+	seasonHandler *handlers.SeasonHandler,
+	badgeHandler *handlers.BadgeHandler,
 	hub *websocket.Hub,
 ) {
 	app.Get("/health", healthHandler.Check)
@@ -38,6 +44,12 @@ func SetupRoutes(
 	agents.Get("/:id", agentHandler.GetByID)
 	agents.Get("/:id/metrics", agentHandler.GetMetrics)
 	agents.Get("/:id/portfolio", agentHandler.GetPortfolio)
+	agents.Get("/:id/memories", agentHandler.GetMemories)
+	agents.Get("/:id/calibration", agentHandler.GetCalibration)
+	agents.Get("/:id/matchups", agentHandler.GetMatchups)
+	agents.Get("/:id/daily-stats", agentHandler.GetDailyStats)
+	agents.Get("/:id/badges", agentHandler.GetBadges)
+	agents.Put("/:id/approval-mode", middleware.APIKeyOrJWTProtected(), agentHandler.SetApprovalMode) // Protected (API key or JWT)
 
 	stocks := v1.Group("/stocks")
 	stocks.Get("/", stockHandler.GetAll)
@@ -50,7 +62,24 @@ func SetupRoutes(
 
 	// Leaderboard
 	v1.Get("/leaderboard", leaderboardHandler.GetLeaderboard)
+	v1.Get("/leaderboard/boards", leaderboardHandler.GetBoards)
 	v1.Get("/leaderboard/roi-history", roiHistoryHandler.GetAllAgentsROIHistory)
+	v1.Get("/leaderboard/shadow", leaderboardHandler.GetShadowLeaderboard)
+	v1.Get("/leaderboard/calibration", leaderboardHandler.GetCalibration)
+	v1.Get("/leaderboard/benchmarks", leaderboardHandler.GetBenchmarks)
+	v1.Get("/leaderboard/elo", leaderboardHandler.GetElo)
+	v1.Get("/leaderboard/shadow/roi-history", roiHistoryHandler.GetShadowROIHistory)
+
+	// Badge rules
+	v1.Get("/badges", badgeHandler.List)
+
+	// Competition seasons
+	seasons := v1.Group("/seasons")
+	seasons.Get("/", seasonHandler.List)
+	seasons.Post("/", middleware.APIKeyOrJWTProtected(), seasonHandler.Create) // Protected (API key or JWT)
+	seasons.Get("/current", seasonHandler.GetCurrent)
+	seasons.Get("/:id", seasonHandler.GetByID)
+	seasons.Post("/:id/end", middleware.APIKeyOrJWTProtected(), seasonHandler.End) // Protected (API key or JWT)
 
 	// Market context (v0.5)
 	v1.Get("/market/context", marketCtxHandler.GetContext)
@@ -70,6 +99,37 @@ func SetupRoutes(
 	news.Post("/fetch", middleware.APIKeyOrJWTProtected(), newsHandler.TriggerNewsFetch) // Protected (API key or JWT)
 	news.Get("/latest", newsHandler.GetLatestNews)                                       // Public
 
+	// Prompt A/B experiments
+	experiments := v1.Group("/experiments")
+	experiments.Get("/", experimentHandler.List)
+	experiments.Post("/", middleware.APIKeyOrJWTProtected(), experimentHandler.Create) // Protected (API key or JWT)
+	experiments.Get("/:id", experimentHandler.GetByID)
+	experiments.Get("/:id/report", experimentHandler.GetReport)
+	experiments.Post("/:id/stop", middleware.APIKeyOrJWTProtected(), experimentHandler.Stop) // Protected (API key or JWT)
+
+	// Decision audit trail and replay
+	v1.Get("/decisions/:id", middleware.APIKeyOrJWTProtected(), decisionHandler.GetTrail)            // Protected (API key or JWT)
+	v1.Get("/decisions/:id/replays", middleware.APIKeyOrJWTProtected(), decisionHandler.ListReplays) // Protected (API key or JWT)
+	v1.Post("/decisions/:id/replay", middleware.APIKeyOrJWTProtected(), decisionHandler.Replay)      // Protected (API key or JWT)
+	v1.Post("/replays", middleware.APIKeyOrJWTProtected(), decisionHandler.ReplayRange)              // Protected (API key or JWT)
+	v1.Get("/replays/:id", middleware.APIKeyOrJWTProtected(), decisionHandler.GetBatch)              // Protected (API key or JWT)
+
+	// Stress-test scenarios (price shocks, volatility regimes, synthetic news)
+	scenarios := v1.Group("/scenarios")
+	scenarios.Get("/", scenarioHandler.List)
+	scenarios.Get("/runs", scenarioHandler.ListRuns)
+	scenarios.Post("/runs", middleware.APIKeyOrJWTProtected(), scenarioHandler.Start) // Protected (API key or JWT)
+	scenarios.Get("/runs/:id", scenarioHandler.GetRun)
+	scenarios.Get("/runs/:id/report", scenarioHandler.GetReport)
+	scenarios.Post("/runs/:id/stop", middleware.APIKeyOrJWTProtected(), scenarioHandler.Stop) // Protected (API key or JWT)
+
+	// Trade proposals (agents in approval mode)
+	proposals := v1.Group("/proposals")
+	proposals.Get("/", proposalHandler.List)
+	proposals.Get("/:id", proposalHandler.GetByID)
+	proposals.Post("/:id/approve", middleware.APIKeyOrJWTProtected(), proposalHandler.Approve) // Protected (API key or JWT)
+	proposals.Post("/:id/reject", middleware.APIKeyOrJWTProtected(), proposalHandler.Reject)   // Protected (API key or JWT)
+
 	// Debug endpoints (per-source)
 	dbg := v1.Group("/debug")
 	dbg.Get("/yahoo", debugHandler.GetYahoo)
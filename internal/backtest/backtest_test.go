@@ -0,0 +1,181 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
+	"github.com/google/uuid"
+)
+
+// scriptDecider returns its decisions in order and remembers what it saw
+type scriptDecider struct {
+	decisions []*models.AIDecision
+	seen      []*ai.DecisionRequest
+}
+
+func (d *scriptDecider) Name() string { return "script" }
+
+func (d *scriptDecider) Decide(_ context.Context, req *ai.DecisionRequest) (*models.AIDecision, error) {
+	d.seen = append(d.seen, req)
+	next := d.decisions[0]
+	d.decisions = d.decisions[1:]
+	return next, nil
+}
+
+func dailyBars(symbol string, day time.Time, closes ...float64) []simulation.Bar {
+	bars := make([]simulation.Bar, len(closes))
+	for i, c := range closes {
+		bars[i] = simulation.Bar{Symbol: symbol, Time: day.AddDate(0, 0, i), Open: c, High: c, Low: c, Close: c, Volume: 1000}
+	}
+	return bars
+}
+
+func TestEngineRunsDecisionsOnClosedBars(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	bars := append(dailyBars("THYAO", day, 100, 110, 120, 90), dailyBars("AKBNK", day, 40, 40, 40, 40)...)
+	decider := &scriptDecider{decisions: []*models.AIDecision{
+		{Action: models.ActionBuy, StockSymbol: "THYAO", Quantity: 40, Confidence: 80},
+		{Action: models.ActionSell, StockSymbol: "THYAO", Quantity: 20, Confidence: 80},
+		{Action: models.ActionBuy, StockSymbol: "THYAO", Quantity: 1000, Confidence: 80},
+		{Action: models.ActionHold, Confidence: 80},
+	}}
+	news := NewNewsStore([]models.NewsArticle{{Title: "late", PublishedAt: day.Add(36 * time.Hour)}})
+
+	res, err := NewEngine(Config{}, NewMarket(bars), news, []Agent{{Name: "bot", Decider: decider}}).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, req := range decider.seen {
+		if want := []float64{100, 110, 120, 90}[i]; req.Stocks[1].Symbol != "THYAO" || req.Stocks[1].CurrentPrice != want {
+			t.Errorf("decision %d saw %+v, want THYAO at %.0f", i, req.Stocks, want)
+		}
+		if wantNews := i == 1; (req.NewsCount > 0) != wantNews {
+			t.Errorf("decision %d at %s saw %d articles", i, req.Now, req.NewsCount)
+		}
+	}
+	if got := decider.seen[1].Stocks[1].ChangePercent; math.Abs(got-10) > 1e-9 {
+		t.Errorf("change percent = %v, want 10", got)
+	}
+
+	if len(res.Trades) != 2 || res.Trades[1].TradeType != models.ActionSell {
+		t.Fatalf("trades = %+v", res.Trades)
+	}
+	if got := res.Trades[1].RealizedPL; math.Abs(got-197.8) > 1e-6 {
+		t.Errorf("realized P/L = %v, want 197.8", got)
+	}
+
+	s := res.Summaries[0]
+	if math.Abs(s.FinalEquity-99993.8) > 1e-6 {
+		t.Errorf("final equity = %v, want 99993.8", s.FinalEquity)
+	}
+	if s.Decisions != 4 || s.Rejected != 1 || s.Holds != 1 || s.WinRate != 100 {
+		t.Errorf("summary = %+v", s)
+	}
+	if len(res.Equity) != 4 {
+		t.Errorf("equity points = %d, want one per bar close", len(res.Equity))
+	}
+
+	var buf bytes.Buffer
+	if err := res.WriteTradesCSV(&buf); err != nil || strings.Count(buf.String(), "\n") != 3 {
+		t.Errorf("trades CSV = %q, %v", buf.String(), err)
+	}
+}
+
+func TestEngineRebalanceUsesRiskCap(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	decider := &scriptDecider{decisions: []*models.AIDecision{
+		{Action: models.ActionRebalance, TargetWeights: map[string]float64{"THYAO": 10}, Confidence: 90},
+	}}
+	res, err := NewEngine(Config{}, NewMarket(dailyBars("THYAO", day, 100)), nil, []Agent{{Decider: decider}}).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10% of 100k is wanted but a single buy is capped at 5% of cash
+	if len(res.Trades) != 1 || res.Trades[0].Quantity != 50 {
+		t.Errorf("trades = %+v, want one capped buy of 50 lots", res.Trades)
+	}
+}
+
+func TestBookAtomicOrdersRollBack(t *testing.T) {
+	b := NewBook(uuid.New(), 1000)
+	prices := map[string]float64{"A": 100, "B": 100}
+	results, err := b.ExecuteOrders([]models.TradeRequest{
+		{StockSymbol: "A", TradeType: models.ActionBuy, Quantity: 5},
+		{StockSymbol: "B", TradeType: models.ActionBuy, Quantity: 5},
+	}, models.ExecutionAtomic, prices, time.Now())
+	if err == nil || results[0].Error != "rolled back" {
+		t.Fatalf("results = %+v, err = %v", results, err)
+	}
+	if b.Cash != 1000 || len(b.Holdings()) != 0 || len(b.Trades()) != 0 {
+		t.Errorf("book not restored: cash %v, holdings %v", b.Cash, b.Holdings())
+	}
+
+	if _, err := b.ExecuteOrders([]models.TradeRequest{
+		{StockSymbol: "B", TradeType: models.ActionBuy, Quantity: 5},
+		{StockSymbol: "A", TradeType: models.ActionBuy, Quantity: 5},
+	}, models.ExecutionOrdered, prices, time.Now()); err != nil || b.Holdings()["B"] != 5 || b.Holdings()["A"] != 0 {
+		t.Errorf("ordered mode: holdings %v, err %v", b.Holdings(), err)
+	}
+}
+
+func TestMarketCandlesOnlyClosed(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var bars []simulation.Bar
+	for i := 0; i < 40; i++ {
+		p := float64(100 + i)
+		bars = append(bars, simulation.Bar{Symbol: "THYAO", Time: start.Add(time.Duration(i) * time.Minute), Open: p, High: p + 1, Low: p - 1, Close: p + 0.5, Volume: 10})
+	}
+	m := NewMarket(bars)
+	if m.Interval() != time.Minute {
+		t.Fatalf("interval = %s", m.Interval())
+	}
+
+	candles := m.Candles([]string{"THYAO"}, "15m", 5, start.Add(31*time.Minute))
+	if len(candles) != 2 {
+		t.Fatalf("candles = %+v, want the 10:00 and 10:15 candles", candles)
+	}
+	c := candles[0]
+	if c.OpenPrice != 100 || c.ClosePrice != 114.5 || c.HighPrice != 115 || c.LowPrice != 99 || c.Volume != 150 {
+		t.Errorf("first candle = %+v", c)
+	}
+	if p, _ := m.Price("THYAO", start.Add(31*time.Minute)); p != 130.5 {
+		t.Errorf("price = %v, want the 10:30 bar close", p)
+	}
+}
+
+func TestNewsAsOf(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := NewNewsStore([]models.NewsArticle{
+		{Title: "old", PublishedAt: at.Add(-25 * time.Hour)},
+		{Title: "recent", PublishedAt: at.Add(-time.Hour)},
+		{Title: "future", PublishedAt: at.Add(time.Hour)},
+	})
+	if got := s.AsOf(at); len(got) != 1 || got[0].Title != "recent" {
+		t.Errorf("AsOf = %+v", got)
+	}
+}
+
+func TestDrawdownAndSharpe(t *testing.T) {
+	day := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+	var equity []EquityPoint
+	for i, v := range []float64{100, 110, 99, 104} {
+		equity = append(equity, EquityPoint{Time: day.AddDate(0, 0, i), Equity: v})
+	}
+	if dd := maxDrawdown(100, equity); math.Abs(dd-10) > 1e-9 {
+		t.Errorf("max drawdown = %v, want 10", dd)
+	}
+	if s := sharpe(100, equity); s <= 0 {
+		t.Errorf("sharpe = %v, want positive for a rising curve", s)
+	}
+	if s := sharpe(100, equity[:1]); s != 0 {
+		t.Errorf("sharpe of one return = %v, want 0", s)
+	}
+}
@@ -0,0 +1,216 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/google/uuid"
+)
+
+// position is one holding of a book
+type position struct {
+	quantity      int
+	totalInvested float64
+}
+
+// Trade is a filled order of a backtest. RealizedPL is set on sells: the
+// proceeds after commission minus the average cost of the sold lots.
+type Trade struct {
+	Agent string `json:"agent"`
+	models.Trade
+	RealizedPL float64 `json:"realized_pl"`
+}
+
+// Book is the in-memory account of one agent: cash, positions and trades.
+// Orders are filled with services.FillOrder, the live engine's fill rules,
+// and positions keep average cost the way the portfolio table does.
+type Book struct {
+	AgentID   uuid.UUID
+	Cash      float64
+	positions map[string]*position
+	trades    []Trade
+}
+
+// NewBook opens a book with the given cash
+func NewBook(agentID uuid.UUID, cash float64) *Book {
+	return &Book{AgentID: agentID, Cash: cash, positions: map[string]*position{}}
+}
+
+// Holdings returns the lots held per symbol
+func (b *Book) Holdings() map[string]int {
+	h := make(map[string]int, len(b.positions))
+	for sym, p := range b.positions {
+		h[sym] = p.quantity
+	}
+	return h
+}
+
+// Value returns the market value of the positions at prices
+func (b *Book) Value(prices map[string]float64) float64 {
+	var v float64
+	for sym, p := range b.positions {
+		v += float64(p.quantity) * prices[sym]
+	}
+	return v
+}
+
+// Portfolio returns the positions valued at prices, sorted by symbol
+func (b *Book) Portfolio(prices map[string]float64) []models.Portfolio {
+	out := make([]models.Portfolio, 0, len(b.positions))
+	for sym, p := range b.positions {
+		value := float64(p.quantity) * prices[sym]
+		out = append(out, models.Portfolio{
+			AgentID:       b.AgentID,
+			StockSymbol:   sym,
+			Quantity:      p.quantity,
+			AvgBuyPrice:   p.totalInvested / float64(p.quantity),
+			TotalInvested: p.totalInvested,
+			CurrentValue:  value,
+			ProfitLoss:    value - p.totalInvested,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StockSymbol < out[j].StockSymbol })
+	return out
+}
+
+// Trades returns every trade of the book, oldest first
+func (b *Book) Trades() []Trade { return b.trades }
+
+// RecentTrades returns the last n trades, newest first
+func (b *Book) RecentTrades(n int) []models.Trade {
+	var out []models.Trade
+	for i := len(b.trades) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, b.trades[i].Trade)
+	}
+	return out
+}
+
+// Execute fills one order at price
+func (b *Book) Execute(req models.TradeRequest, price float64, at time.Time) (*models.Trade, error) {
+	if price <= 0 {
+		return nil, fmt.Errorf("stock not found: %s", req.StockSymbol)
+	}
+	p := b.positions[req.StockSymbol]
+	held := 0
+	if p != nil {
+		held = p.quantity
+	}
+	fill, err := services.FillOrder(b.Cash, held, req, price)
+	if err != nil {
+		return nil, err
+	}
+
+	var realized float64
+	b.Cash += fill.CashDelta
+	switch req.TradeType {
+	case models.ActionBuy:
+		if p == nil {
+			p = &position{}
+			b.positions[req.StockSymbol] = p
+		}
+		p.quantity += req.Quantity
+		p.totalInvested += fill.Amount
+	case models.ActionSell:
+		realized = fill.CashDelta - p.totalInvested*float64(req.Quantity)/float64(p.quantity)
+		if p.quantity == req.Quantity {
+			delete(b.positions, req.StockSymbol)
+		} else {
+			p.totalInvested = p.totalInvested * float64(p.quantity-req.Quantity) / float64(p.quantity)
+			p.quantity -= req.Quantity
+		}
+	}
+
+	trade := Trade{Trade: models.Trade{
+		ID:          uuid.New(),
+		AgentID:     b.AgentID,
+		StockSymbol: req.StockSymbol,
+		TradeType:   req.TradeType,
+		Quantity:    req.Quantity,
+		Price:       price,
+		TotalAmount: fill.Amount,
+		Commission:  fill.Commission,
+		Reasoning:   req.Reasoning,
+		DecisionID:  req.DecisionID,
+		CreatedAt:   at,
+	}, RealizedPL: realized}
+	b.trades = append(b.trades, trade)
+	return &trade.Trade, nil
+}
+
+// ExecuteOrders mirrors TradingEngine.ExecuteOrders: sells go first; in
+// atomic mode a failing order restores the book and marks the others rolled
+// back or skipped, in ordered mode every order stands on its own.
+func (b *Book) ExecuteOrders(orders []models.TradeRequest, mode string, prices map[string]float64, at time.Time) ([]models.OrderResult, error) {
+	if len(orders) == 0 {
+		return nil, errors.New("no orders")
+	}
+	orders = services.SellsFirst(orders)
+	results := make([]models.OrderResult, len(orders))
+	for i, o := range orders {
+		results[i].Order = o
+	}
+
+	if mode == models.ExecutionOrdered {
+		executed := 0
+		for i, o := range orders {
+			trade, err := b.Execute(o, prices[o.StockSymbol], at)
+			if err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].Trade = trade
+			executed++
+		}
+		if executed == 0 {
+			return results, errors.New("no orders executed")
+		}
+		return results, nil
+	}
+
+	saved := b.snapshot()
+	for i, o := range orders {
+		trade, err := b.Execute(o, prices[o.StockSymbol], at)
+		if err != nil {
+			b.restore(saved)
+			results[i].Error = err.Error()
+			for j := range results[:i] {
+				results[j].Trade = nil
+				results[j].Error = "rolled back"
+			}
+			for j := i + 1; j < len(results); j++ {
+				results[j].Error = "skipped"
+			}
+			return results, fmt.Errorf("order %d (%s %d %s): %w", i+1, o.TradeType, o.Quantity, o.StockSymbol, err)
+		}
+		results[i].Trade = trade
+	}
+	return results, nil
+}
+
+// bookState is a copy of a book for rolling back an atomic order set
+type bookState struct {
+	cash      float64
+	positions map[string]position
+	trades    int
+}
+
+func (b *Book) snapshot() bookState {
+	s := bookState{cash: b.Cash, positions: make(map[string]position, len(b.positions)), trades: len(b.trades)}
+	for sym, p := range b.positions {
+		s.positions[sym] = *p
+	}
+	return s
+}
+
+func (b *Book) restore(s bookState) {
+	b.Cash = s.cash
+	b.positions = make(map[string]*position, len(s.positions))
+	for sym, p := range s.positions {
+		b.positions[sym] = &p
+	}
+	b.trades = b.trades[:s.trades]
+}
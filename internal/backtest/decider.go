@@ -0,0 +1,43 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+)
+
+// Decider makes one trading decision from the data an agent sees
+type Decider interface {
+	Decide(ctx context.Context, req *ai.DecisionRequest) (*models.AIDecision, error)
+	Name() string
+}
+
+// AIDecider asks a model for decisions with the prompts the live engine
+// renders: the prompt set selected for the agent and strategy, and the
+// decision prompt fitted to the model's token budget
+type AIDecider struct {
+	Client      ai.Client
+	Prompts     *ai.PromptRegistry
+	PromptSet   string // empty = the registry's choice for the strategy
+	TokenBudget int    // decision prompt budget (see PROMPT_TOKEN_BUDGET)
+}
+
+// Name returns the model name
+func (d *AIDecider) Name() string { return d.Client.GetModelName() }
+
+// Decide renders the prompts for req and returns the model's decision
+func (d *AIDecider) Decide(ctx context.Context, req *ai.DecisionRequest) (*models.AIDecision, error) {
+	promptSet := d.Prompts.Select(d.PromptSet, req.Strategy)
+	systemPrompt, err := promptSet.RenderSystem(req)
+	if err != nil {
+		return nil, fmt.Errorf("render system prompt: %w", err)
+	}
+	model := d.Client.GetModelName()
+	rendered, err := promptSet.BuildDecision(req, model, ai.PromptTokenLimit(model, d.TokenBudget, ai.EstimateTokens(model, systemPrompt)))
+	if err != nil {
+		return nil, fmt.Errorf("render decision prompt: %w", err)
+	}
+	return d.Client.GetTradingDecision(ctx, systemPrompt, rendered.Text)
+}
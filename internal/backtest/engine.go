@@ -0,0 +1,259 @@
+// Package backtest runs agent decision cycles over historical bars without a
+// database. Prices, news and each agent's account live in memory; decisions
+// go through the live RiskManager checks and orders are filled with the live
+// engine's fill rules, so a backtest trades the way the agent would have.
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+	"github.com/google/uuid"
+)
+
+// Defaults match a new agent and the server's risk settings
+const (
+	DefaultInitialBalance = 100000.0
+	defaultStrategy       = "balanced"
+	recentTrades          = 5
+)
+
+// ErrNoBars is returned when no bar closes inside the backtest range
+var ErrNoBars = errors.New("no bars in range")
+
+// Agent is one backtested agent
+type Agent struct {
+	ID       uuid.UUID
+	Name     string
+	Strategy string // default "balanced"
+	Decider  Decider
+}
+
+// Config controls a backtest run
+type Config struct {
+	Start          time.Time     // first decision time (zero = first bar close)
+	End            time.Time     // last decision time, inclusive (zero = last bar close)
+	Every          time.Duration // decision interval (0 = every bar)
+	InitialBalance float64       // starting cash per agent (0 = DefaultInitialBalance)
+	Risk           *services.RiskManager
+}
+
+// Engine runs a backtest
+type Engine struct {
+	cfg    Config
+	market *Market
+	news   *NewsStore
+	agents []Agent
+}
+
+// NewEngine creates a backtest over market and news; news may be nil. Without
+// a risk manager the server's limits are used (5% per trade, 20% portfolio,
+// 70% confidence).
+func NewEngine(cfg Config, market *Market, news *NewsStore, agents []Agent) *Engine {
+	if cfg.InitialBalance <= 0 {
+		cfg.InitialBalance = DefaultInitialBalance
+	}
+	if cfg.Risk == nil {
+		cfg.Risk = services.NewRiskManager(nil, 5.0, 20.0, 70.0)
+	}
+	for i := range agents {
+		if agents[i].ID == uuid.Nil {
+			agents[i].ID = uuid.New()
+		}
+		if agents[i].Name == "" {
+			agents[i].Name = agents[i].Decider.Name()
+		}
+		if agents[i].Strategy == "" {
+			agents[i].Strategy = defaultStrategy
+		}
+	}
+	return &Engine{cfg: cfg, market: market, news: news, agents: agents}
+}
+
+// agentRun is the state of one agent during a run
+type agentRun struct {
+	agent     Agent
+	book      *Book
+	equity    []EquityPoint
+	decisions []DecisionRecord
+}
+
+// Run steps through every bar close in the range, lets each agent decide when
+// its decision interval has passed and marks every book to market after each
+// close. Agents decide concurrently; their books are independent.
+func (e *Engine) Run(ctx context.Context) (*Result, error) {
+	var times []time.Time
+	for _, t := range e.market.CloseTimes() {
+		if (e.cfg.Start.IsZero() || !t.Before(e.cfg.Start)) && (e.cfg.End.IsZero() || !t.After(e.cfg.End)) {
+			times = append(times, t)
+		}
+	}
+	if len(times) == 0 {
+		return nil, ErrNoBars
+	}
+
+	runs := make([]*agentRun, len(e.agents))
+	for i, a := range e.agents {
+		runs[i] = &agentRun{agent: a, book: NewBook(a.ID, e.cfg.InitialBalance)}
+	}
+
+	var lastDecision time.Time
+	for _, t := range times {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		prices := e.market.Prices(t)
+
+		if lastDecision.IsZero() || t.Sub(lastDecision) >= e.cfg.Every {
+			lastDecision = t
+			var wg sync.WaitGroup
+			for _, r := range runs {
+				wg.Add(1)
+				go func(r *agentRun) {
+					defer wg.Done()
+					r.decisions = append(r.decisions, e.decide(ctx, r, t, prices))
+				}(r)
+			}
+			wg.Wait()
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, r := range runs {
+			value := r.book.Value(prices)
+			r.equity = append(r.equity, EquityPoint{
+				Time: t, AgentID: r.agent.ID, Agent: r.agent.Name,
+				Cash: r.book.Cash, PortfolioValue: value, Equity: r.book.Cash + value,
+			})
+		}
+	}
+
+	return e.result(times[0], times[len(times)-1], runs), nil
+}
+
+// decide runs one decision cycle of an agent at t
+func (e *Engine) decide(ctx context.Context, r *agentRun, t time.Time, prices map[string]float64) DecisionRecord {
+	rec := DecisionRecord{Time: t, AgentID: r.agent.ID, Agent: r.agent.Name, DecisionID: uuid.New()}
+	decision, err := r.agent.Decider.Decide(ctx, e.request(r, t, prices))
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	rec.Action, rec.StockSymbol, rec.Quantity, rec.Confidence = decision.Action, decision.StockSymbol, decision.Quantity, decision.Confidence
+	rec.Reasoning = decision.ReasoningSummary
+
+	var trades []*models.Trade
+	switch decision.Action {
+	case models.ActionHold:
+		return rec
+	case models.ActionMulti, models.ActionRebalance:
+		trades, err = e.executeOrders(r, rec.DecisionID, decision, t, prices)
+	default:
+		trades, err = e.executeTrade(r, rec.DecisionID, decision, t, prices)
+	}
+	if err != nil {
+		rec.Rejected = err.Error()
+	}
+	rec.Executed = len(trades)
+	return rec
+}
+
+// request builds what the agent sees at t, like AgentEngine.gatherDecisionData
+func (e *Engine) request(r *agentRun, t time.Time, prices map[string]float64) *ai.DecisionRequest {
+	req := &ai.DecisionRequest{
+		AgentID:        r.agent.ID.String(),
+		AgentName:      r.agent.Name,
+		CurrentBalance: r.book.Cash,
+		Portfolio:      r.book.Portfolio(prices),
+		Stocks:         e.market.Stocks(t),
+		RecentTrades:   r.book.RecentTrades(recentTrades),
+		Strategy:       r.agent.Strategy,
+		News:           e.news.AsOf(t),
+		Now:            t,
+	}
+	req.NewsCount = len(req.News)
+	req.MarketData = e.market.Candles(services.CandleSymbols(req), services.PromptCandleTimeframe, services.PromptCandlesPerSymbol, t)
+	return req
+}
+
+// executeTrade validates and fills a single BUY/SELL decision
+func (e *Engine) executeTrade(r *agentRun, decisionID uuid.UUID, decision *models.AIDecision, t time.Time, prices map[string]float64) ([]*models.Trade, error) {
+	price, ok := prices[decision.StockSymbol]
+	if !ok {
+		return nil, fmt.Errorf("stock not found: %s", decision.StockSymbol)
+	}
+	if err := e.cfg.Risk.CheckTrade(r.book.Cash, r.book.Value(prices), price, decision); err != nil {
+		return nil, err
+	}
+	trade, err := r.book.Execute(models.TradeRequest{
+		AgentID:     r.agent.ID,
+		StockSymbol: decision.StockSymbol,
+		TradeType:   decision.Action,
+		Quantity:    decision.Quantity,
+		Reasoning:   decision.ReasoningSummary,
+		DecisionID:  &decisionID,
+	}, price, t)
+	if err != nil {
+		return nil, err
+	}
+	return []*models.Trade{trade}, nil
+}
+
+// executeOrders validates and fills a MULTI or REBALANCE decision the way
+// AgentEngine.executeOrders does: rebalances are planned from target weights
+// with the per-trade cap and always execute atomically
+func (e *Engine) executeOrders(r *agentRun, decisionID uuid.UUID, decision *models.AIDecision, t time.Time, prices map[string]float64) ([]*models.Trade, error) {
+	orders := decision.TradeRequests(r.agent.ID)
+	mode := decision.ExecutionMode
+	if decision.Action == models.ActionRebalance {
+		for sym := range decision.TargetWeights {
+			if _, ok := prices[sym]; !ok {
+				return nil, fmt.Errorf("stock not found: %s", sym)
+			}
+		}
+		planned, err := services.RebalanceOrders(r.book.Cash, r.book.Holdings(), prices, decision.TargetWeights, e.cfg.Risk.MaxBuyAmount(r.book.Cash))
+		if err != nil {
+			return nil, err
+		}
+		orders = planned
+		mode = models.ExecutionAtomic
+
+		reasoning := decision.Rationale
+		if reasoning == "" {
+			reasoning = decision.ReasoningSummary
+		}
+		for i := range orders {
+			orders[i].AgentID = r.agent.ID
+			orders[i].Reasoning = reasoning
+		}
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	if mode != models.ExecutionOrdered {
+		mode = models.ExecutionAtomic
+	}
+	for i := range orders {
+		orders[i].DecisionID = &decisionID
+	}
+
+	if err := e.cfg.Risk.CheckOrders(r.book.Cash, r.book.Value(prices), r.book.Holdings(), prices, orders, decision.Confidence); err != nil {
+		return nil, err
+	}
+
+	results, err := r.book.ExecuteOrders(orders, mode, prices, t)
+	var trades []*models.Trade
+	for _, res := range results {
+		if res.Trade != nil {
+			trades = append(trades, res.Trade)
+		}
+	}
+	return trades, err
+}
@@ -0,0 +1,177 @@
+package backtest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
+)
+
+// maxStocks mirrors the live engine's stock list (ORDER BY symbol LIMIT 20)
+const maxStocks = 20
+
+// Market is the in-memory price store of a backtest. It only ever shows bars
+// that have closed at the asked time, like the replay mode does against the
+// database, so a decision at t never sees a price from after t.
+type Market struct {
+	bars     map[string][]simulation.Bar // per symbol, oldest first
+	symbols  []string
+	interval time.Duration // bar size
+}
+
+// NewMarket indexes bars by symbol. The bar size is the smallest gap between
+// two bars of a symbol (one day when every symbol has a single bar).
+func NewMarket(bars []simulation.Bar) *Market {
+	m := &Market{bars: map[string][]simulation.Bar{}}
+	for _, b := range bars {
+		m.bars[b.Symbol] = append(m.bars[b.Symbol], b)
+	}
+	for sym, bs := range m.bars {
+		sort.SliceStable(bs, func(i, j int) bool { return bs[i].Time.Before(bs[j].Time) })
+		for i := 1; i < len(bs); i++ {
+			if gap := bs[i].Time.Sub(bs[i-1].Time); gap > 0 && (m.interval == 0 || gap < m.interval) {
+				m.interval = gap
+			}
+		}
+		m.symbols = append(m.symbols, sym)
+	}
+	sort.Strings(m.symbols)
+	if m.interval == 0 {
+		m.interval = 24 * time.Hour
+	}
+	return m
+}
+
+// Interval is the bar size
+func (m *Market) Interval() time.Duration { return m.interval }
+
+// Symbols returns every symbol with bars, sorted
+func (m *Market) Symbols() []string { return m.symbols }
+
+// CloseTimes returns the distinct times at which bars close, ascending
+func (m *Market) CloseTimes() []time.Time {
+	seen := map[time.Time]bool{}
+	var times []time.Time
+	for _, bs := range m.bars {
+		for _, b := range bs {
+			t := b.Time.Add(m.interval)
+			if !seen[t] {
+				seen[t] = true
+				times = append(times, t)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+// closed returns the bars of symbol that have closed by t
+func (m *Market) closed(symbol string, t time.Time) []simulation.Bar {
+	bs := m.bars[symbol]
+	n := sort.Search(len(bs), func(i int) bool { return bs[i].Time.Add(m.interval).After(t) })
+	return bs[:n]
+}
+
+// Price returns the close of the last bar of symbol closed by t
+func (m *Market) Price(symbol string, t time.Time) (float64, bool) {
+	bs := m.closed(symbol, t)
+	if len(bs) == 0 {
+		return 0, false
+	}
+	return bs[len(bs)-1].Close, true
+}
+
+// Prices returns the price at t of every symbol that has one
+func (m *Market) Prices(t time.Time) map[string]float64 {
+	prices := make(map[string]float64, len(m.symbols))
+	for _, sym := range m.symbols {
+		if p, ok := m.Price(sym, t); ok {
+			prices[sym] = p
+		}
+	}
+	return prices
+}
+
+// Stocks builds the stock list the agent sees at t: the current price, the
+// change against the previous day's last close and the day's volume so far
+func (m *Market) Stocks(t time.Time) []models.Stock {
+	var stocks []models.Stock
+	for _, sym := range m.symbols {
+		bs := m.closed(sym, t)
+		if len(bs) == 0 {
+			continue
+		}
+		last := bs[len(bs)-1]
+		s := models.Stock{Symbol: sym, Name: sym, CurrentPrice: last.Close, LastUpdated: last.Time.Add(m.interval), IsActive: true}
+		day := last.Time.Truncate(24 * time.Hour)
+		for i := len(bs) - 1; i >= 0; i-- {
+			if bs[i].Time.Before(day) {
+				s.PreviousClose = bs[i].Close
+				break
+			}
+			s.Volume += bs[i].Volume
+		}
+		if s.PreviousClose > 0 {
+			s.ChangePercent = (s.CurrentPrice - s.PreviousClose) / s.PreviousClose * 100
+		}
+		stocks = append(stocks, s)
+		if len(stocks) == maxStocks {
+			break
+		}
+	}
+	return stocks
+}
+
+// Candles returns the last perSymbol candles of the timeframe closed by t for
+// each symbol, in symbols order and oldest first within a symbol. Bars are
+// aggregated into the timeframe; no candles smaller than a bar are made.
+func (m *Market) Candles(symbols []string, timeframe string, perSymbol int, t time.Time) []models.MarketData {
+	size := simulation.Timeframes[timeframe]
+	if size == 0 || size < m.interval {
+		return nil
+	}
+	var out []models.MarketData
+	for _, sym := range symbols {
+		var candles []models.MarketData
+		bs := m.closed(sym, t)
+		from := t.Add(-size * time.Duration(perSymbol+1)).Truncate(size)
+		first := sort.Search(len(bs), func(i int) bool { return !bs[i].Time.Before(from) })
+		for _, b := range bs[first:] {
+			start := b.Time.Truncate(size)
+			if start.Add(size).After(t) {
+				continue // the candle's period has not passed yet
+			}
+			if n := len(candles); n > 0 && candles[n-1].Timestamp.Equal(start) {
+				c := &candles[n-1]
+				c.ClosePrice = b.Close
+				c.HighPrice = max(c.HighPrice, b.High)
+				c.LowPrice = min(c.LowPrice, b.Low)
+				c.Volume += b.Volume
+				continue
+			}
+			candles = append(candles, models.MarketData{
+				Symbol: sym, OpenPrice: b.Open, HighPrice: b.High, LowPrice: b.Low, ClosePrice: b.Close,
+				Volume: b.Volume, Timestamp: start, Timeframe: timeframe,
+			})
+		}
+		if len(candles) > perSymbol {
+			candles = candles[len(candles)-perSymbol:]
+		}
+		out = append(out, candles...)
+	}
+	return out
+}
+
+// Closes returns the closes of the last n bars of symbol closed by t, oldest first
+func (m *Market) Closes(symbol string, t time.Time, n int) []float64 {
+	bs := m.closed(symbol, t)
+	if len(bs) > n {
+		bs = bs[len(bs)-n:]
+	}
+	closes := make([]float64, len(bs))
+	for i, b := range bs {
+		closes[i] = b.Close
+	}
+	return closes
+}
@@ -0,0 +1,71 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+// News window and size the live engine gives an agent (GetNewsAsOf)
+const (
+	newsWindow = 24 * time.Hour
+	newsLimit  = 20
+)
+
+// NewsStore is the in-memory news store of a backtest
+type NewsStore struct {
+	articles []models.NewsArticle // newest first
+}
+
+// NewNewsStore indexes articles by publication time
+func NewNewsStore(articles []models.NewsArticle) *NewsStore {
+	s := &NewsStore{articles: append([]models.NewsArticle(nil), articles...)}
+	sort.SliceStable(s.articles, func(i, j int) bool { return s.articles[i].PublishedAt.After(s.articles[j].PublishedAt) })
+	return s
+}
+
+// LoadNews reads a JSON array of news articles (the market_events export
+// format: title, description, source, related_stocks, published_at, ...)
+func LoadNews(path string) (*NewsStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var articles []models.NewsArticle
+	if err := json.Unmarshal(data, &articles); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewNewsStore(articles), nil
+}
+
+// Len returns the number of stored articles
+func (s *NewsStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.articles)
+}
+
+// AsOf returns the articles published in the 24 hours up to t, newest first,
+// the same window the live engine uses during a replay
+func (s *NewsStore) AsOf(t time.Time) []models.NewsArticle {
+	articles := []models.NewsArticle{}
+	if s == nil {
+		return articles
+	}
+	from := t.Add(-newsWindow)
+	for _, a := range s.articles {
+		if a.PublishedAt.After(t) {
+			continue
+		}
+		if !a.PublishedAt.After(from) || len(articles) == newsLimit {
+			break
+		}
+		articles = append(articles, a)
+	}
+	return articles
+}
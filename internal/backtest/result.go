@@ -0,0 +1,185 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/performance"
+	"github.com/google/uuid"
+)
+
+// EquityPoint is an agent's marked-to-market account after a bar close
+type EquityPoint struct {
+	Time           time.Time `json:"time"`
+	AgentID        uuid.UUID `json:"agent_id"`
+	Agent          string    `json:"agent"`
+	Cash           float64   `json:"cash"`
+	PortfolioValue float64   `json:"portfolio_value"`
+	Equity         float64   `json:"equity"`
+}
+
+// DecisionRecord is one decision cycle of an agent. Error is set when the
+// decider failed, Rejected when the risk checks or fills refused the orders.
+type DecisionRecord struct {
+	Time        time.Time `json:"time"`
+	AgentID     uuid.UUID `json:"agent_id"`
+	Agent       string    `json:"agent"`
+	DecisionID  uuid.UUID `json:"decision_id"`
+	Action      string    `json:"action,omitempty"`
+	StockSymbol string    `json:"stock_symbol,omitempty"`
+	Quantity    int       `json:"quantity,omitempty"`
+	Confidence  float64   `json:"confidence,omitempty"`
+	Reasoning   string    `json:"reasoning,omitempty"`
+	Executed    int       `json:"executed"`
+	Rejected    string    `json:"rejected,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Summary holds an agent's backtest statistics. WinRate is the percentage of
+// sells that realized a profit; SharpeRatio is annualized from daily returns.
+type Summary struct {
+	AgentID            uuid.UUID `json:"agent_id"`
+	Agent              string    `json:"agent"`
+	Decider            string    `json:"decider"`
+	InitialEquity      float64   `json:"initial_equity"`
+	FinalEquity        float64   `json:"final_equity"`
+	ReturnPercent      float64   `json:"return_percent"`
+	MaxDrawdownPercent float64   `json:"max_drawdown_percent"`
+	SharpeRatio        float64   `json:"sharpe_ratio"`
+	Decisions          int       `json:"decisions"`
+	Holds              int       `json:"holds"`
+	Rejected           int       `json:"rejected"`
+	Errors             int       `json:"errors"`
+	Trades             int       `json:"trades"`
+	WinRate            float64   `json:"win_rate"`
+	RealizedPL         float64   `json:"realized_pl"`
+	Commission         float64   `json:"commission"`
+}
+
+// Result is the output of a backtest
+type Result struct {
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Interval  string           `json:"bar_interval"`
+	Summaries []Summary        `json:"summaries"`
+	Equity    []EquityPoint    `json:"equity"`
+	Trades    []Trade          `json:"trades"`
+	Decisions []DecisionRecord `json:"decisions"`
+}
+
+func (e *Engine) result(start, end time.Time, runs []*agentRun) *Result {
+	res := &Result{Start: start, End: end, Interval: e.market.Interval().String()}
+	for _, r := range runs {
+		trades := r.book.Trades()
+		for i := range trades {
+			trades[i].Agent = r.agent.Name
+		}
+		res.Summaries = append(res.Summaries, summarize(r.agent, r.agent.Decider.Name(), e.cfg.InitialBalance, r.equity, trades, r.decisions))
+		res.Equity = append(res.Equity, r.equity...)
+		res.Trades = append(res.Trades, trades...)
+		res.Decisions = append(res.Decisions, r.decisions...)
+	}
+	sort.SliceStable(res.Equity, func(i, j int) bool { return res.Equity[i].Time.Before(res.Equity[j].Time) })
+	sort.SliceStable(res.Trades, func(i, j int) bool { return res.Trades[i].CreatedAt.Before(res.Trades[j].CreatedAt) })
+	sort.SliceStable(res.Decisions, func(i, j int) bool { return res.Decisions[i].Time.Before(res.Decisions[j].Time) })
+	return res
+}
+
+// summarize computes an agent's statistics
+func summarize(a Agent, decider string, initial float64, equity []EquityPoint, trades []Trade, decisions []DecisionRecord) Summary {
+	s := Summary{AgentID: a.ID, Agent: a.Name, Decider: decider, InitialEquity: initial, FinalEquity: initial, Decisions: len(decisions), Trades: len(trades)}
+	if len(equity) > 0 {
+		s.FinalEquity = equity[len(equity)-1].Equity
+	}
+	if initial > 0 {
+		s.ReturnPercent = (s.FinalEquity - initial) / initial * 100
+	}
+	s.MaxDrawdownPercent = maxDrawdown(initial, equity)
+	s.SharpeRatio = sharpe(initial, equity)
+
+	for _, d := range decisions {
+		switch {
+		case d.Error != "":
+			s.Errors++
+		case d.Rejected != "":
+			s.Rejected++
+		case d.Action == models.ActionHold:
+			s.Holds++
+		}
+	}
+
+	sells, wins := 0, 0
+	for _, t := range trades {
+		s.Commission += t.Commission
+		if t.TradeType == models.ActionSell {
+			sells++
+			s.RealizedPL += t.RealizedPL
+			if t.RealizedPL > 0 {
+				wins++
+			}
+		}
+	}
+	if sells > 0 {
+		s.WinRate = float64(wins) / float64(sells) * 100
+	}
+	return s
+}
+
+// maxDrawdown returns the largest peak-to-trough fall of the equity in percent
+func maxDrawdown(initial float64, equity []EquityPoint) float64 {
+	dd, _ := performance.MaxDrawdown(initial, points(equity))
+	return dd
+}
+
+// sharpe returns the annualized Sharpe ratio (zero risk-free rate) of the
+// daily returns of the equity curve, using each day's last point
+func sharpe(initial float64, equity []EquityPoint) float64 {
+	return performance.Sharpe(performance.DailyReturns(initial, points(equity)))
+}
+
+func points(equity []EquityPoint) []performance.Point {
+	out := make([]performance.Point, len(equity))
+	for i, p := range equity {
+		out[i] = performance.Point{Time: p.Time, Equity: p.Equity, PortfolioValue: p.PortfolioValue}
+	}
+	return out
+}
+
+// WriteJSON writes the whole result as indented JSON
+func (r *Result) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteEquityCSV writes the equity curve as CSV
+func (r *Result) WriteEquityCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "agent", "cash", "portfolio_value", "equity"})
+	for _, p := range r.Equity {
+		_ = cw.Write([]string{p.Time.Format(time.RFC3339), p.Agent, money(p.Cash), money(p.PortfolioValue), money(p.Equity)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTradesCSV writes the trade list as CSV
+func (r *Result) WriteTradesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"time", "agent", "symbol", "type", "quantity", "price", "total_amount", "commission", "realized_pl", "reasoning"})
+	for _, t := range r.Trades {
+		_ = cw.Write([]string{
+			t.CreatedAt.Format(time.RFC3339), t.Agent, t.StockSymbol, t.TradeType, strconv.Itoa(t.Quantity),
+			money(t.Price), money(t.TotalAmount), money(t.Commission), money(t.RealizedPL), t.Reasoning,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func money(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
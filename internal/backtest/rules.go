@@ -0,0 +1,272 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/services"
+)
+
+// ruleConfidence is the confidence of rule decisions; rules either fire or not
+const ruleConfidence = 100
+
+// Parameters are the numeric settings of a rule-based strategy, stored as
+// agent_strategies.parameters
+type Parameters map[string]float64
+
+// ParseParameters reads agent_strategies.parameters; numbers are kept, booleans
+// become 1/0 and other values are ignored
+func ParseParameters(raw []byte) (Parameters, error) {
+	p := Parameters{}
+	if len(raw) == 0 {
+		return p, nil
+	}
+	var values map[string]any
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	for k, v := range values {
+		switch v := v.(type) {
+		case float64:
+			p[k] = v
+		case bool:
+			if v {
+				p[k] = 1
+			} else {
+				p[k] = 0
+			}
+		}
+	}
+	return p, nil
+}
+
+// String formats the parameters as "a=1,b=2" in key order
+func (p Parameters) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.FormatFloat(p[k], 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// with returns defaults overridden by p
+func (p Parameters) with(defaults Parameters) Parameters {
+	out := make(Parameters, len(defaults)+len(p))
+	for k, v := range defaults {
+		out[k] = v
+	}
+	for k, v := range p {
+		out[k] = v
+	}
+	return out
+}
+
+// RuleSpec is a rule-based strategy. Signal gets the last History closes of a
+// symbol (oldest first) and returns a positive strength to enter, a negative
+// value to exit and zero to do nothing.
+type RuleSpec struct {
+	Defaults Parameters
+	History  func(p Parameters) int
+	Signal   func(p Parameters, closes []float64) float64
+	Validate func(p Parameters) error
+}
+
+// Every strategy also reads these: position_pct is the share of cash put into
+// one buy (the risk manager caps a trade at 5%), max_positions the number of
+// stocks held at once
+var commonDefaults = Parameters{"position_pct": 5, "max_positions": 3}
+
+// RuleStrategies are the rule-based strategies, keyed by agent_strategies.strategy_type
+var RuleStrategies = map[string]RuleSpec{
+	// momentum: enter when the return over lookback bars exceeds threshold %, exit below -threshold %
+	"momentum": {
+		Defaults: Parameters{"lookback": 20, "threshold": 2},
+		History:  func(p Parameters) int { return int(p["lookback"]) + 1 },
+		Signal: func(p Parameters, c []float64) float64 {
+			ret := (c[len(c)-1]/c[0] - 1) * 100
+			switch {
+			case ret > p["threshold"]:
+				return ret
+			case ret < -p["threshold"]:
+				return -1
+			}
+			return 0
+		},
+		Validate: func(p Parameters) error {
+			if p["lookback"] < 1 || p["threshold"] < 0 {
+				return fmt.Errorf("lookback must be >= 1 and threshold >= 0")
+			}
+			return nil
+		},
+	},
+	// sma_cross: enter when the fast moving average crosses above the slow one, exit when it crosses below
+	"sma_cross": {
+		Defaults: Parameters{"fast": 10, "slow": 30},
+		History:  func(p Parameters) int { return int(p["slow"]) + 1 },
+		Signal: func(p Parameters, c []float64) float64 {
+			fast, slow := int(p["fast"]), int(p["slow"])
+			prev, last := c[:len(c)-1], c[1:]
+			before := mean(prev[len(prev)-fast:]) - mean(prev[len(prev)-slow:])
+			now := mean(last[len(last)-fast:]) - mean(last[len(last)-slow:])
+			switch {
+			case before <= 0 && now > 0:
+				return now / mean(last[len(last)-slow:]) * 100
+			case before >= 0 && now < 0:
+				return -1
+			}
+			return 0
+		},
+		Validate: func(p Parameters) error {
+			if p["fast"] < 1 || p["slow"] <= p["fast"] {
+				return fmt.Errorf("need 1 <= fast < slow")
+			}
+			return nil
+		},
+	},
+	// mean_reversion: enter when the close is entry_z standard deviations below its
+	// lookback mean, exit once it is back above exit_z
+	"mean_reversion": {
+		Defaults: Parameters{"lookback": 20, "entry_z": 2, "exit_z": 0},
+		History:  func(p Parameters) int { return int(p["lookback"]) },
+		Signal: func(p Parameters, c []float64) float64 {
+			m := mean(c)
+			var variance float64
+			for _, v := range c {
+				variance += (v - m) * (v - m)
+			}
+			std := math.Sqrt(variance / float64(len(c)))
+			if std == 0 {
+				return 0
+			}
+			z := (c[len(c)-1] - m) / std
+			switch {
+			case z < -p["entry_z"]:
+				return -z
+			case z > p["exit_z"]:
+				return -1
+			}
+			return 0
+		},
+		Validate: func(p Parameters) error {
+			if p["lookback"] < 2 || p["entry_z"] <= 0 {
+				return fmt.Errorf("lookback must be >= 2 and entry_z > 0")
+			}
+			return nil
+		},
+	},
+}
+
+func mean(v []float64) float64 {
+	var s float64
+	for _, x := range v {
+		s += x
+	}
+	return s / float64(len(v))
+}
+
+// RuleDecider trades a rule-based strategy over every symbol of the market.
+// Exits sell the whole position; entries are sized so each buy stays within
+// position_pct of the cash left after the previous orders, which is how the
+// risk manager checks a multi-order decision.
+type RuleDecider struct {
+	strategy string
+	spec     RuleSpec
+	params   Parameters
+	market   *Market
+}
+
+// NewRuleDecider creates a decider for a strategy of RuleStrategies; missing
+// parameters take the strategy's defaults
+func NewRuleDecider(strategy string, params Parameters, market *Market) (*RuleDecider, error) {
+	spec, ok := RuleStrategies[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule strategy %q", strategy)
+	}
+	p := params.with(spec.Defaults.with(commonDefaults))
+	if p["position_pct"] <= 0 || p["max_positions"] < 1 {
+		return nil, fmt.Errorf("%s: position_pct must be > 0 and max_positions >= 1", strategy)
+	}
+	if err := spec.Validate(p); err != nil {
+		return nil, fmt.Errorf("%s: %w", strategy, err)
+	}
+	return &RuleDecider{strategy: strategy, spec: spec, params: p, market: market}, nil
+}
+
+// Name returns the strategy and its parameters
+func (d *RuleDecider) Name() string { return d.strategy + "(" + d.params.String() + ")" }
+
+// Parameters returns the effective parameters, defaults included
+func (d *RuleDecider) Parameters() Parameters { return d.params }
+
+// Decide evaluates the rule for every symbol at req.Now
+func (d *RuleDecider) Decide(_ context.Context, req *ai.DecisionRequest) (*models.AIDecision, error) {
+	held := map[string]int{}
+	for _, p := range req.Portfolio {
+		held[p.StockSymbol] = p.Quantity
+	}
+
+	type entry struct {
+		symbol   string
+		strength float64
+		price    float64
+	}
+	var orders []models.Order
+	var entries []entry
+	cash := req.CurrentBalance
+	n := d.spec.History(d.params)
+	for _, sym := range d.market.Symbols() {
+		closes := d.market.Closes(sym, req.Now, n)
+		if len(closes) < n {
+			continue
+		}
+		signal := d.spec.Signal(d.params, closes)
+		price := closes[len(closes)-1]
+		switch {
+		case held[sym] > 0 && signal < 0:
+			orders = append(orders, models.Order{Action: models.ActionSell, StockSymbol: sym, Quantity: held[sym]})
+			cash += float64(held[sym]) * price * (1 - services.CommissionRate)
+			delete(held, sym)
+		case held[sym] == 0 && signal > 0:
+			entries = append(entries, entry{sym, signal, price})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].strength > entries[j].strength })
+	slots := int(d.params["max_positions"]) - len(held)
+	for _, e := range entries {
+		if slots <= 0 {
+			break
+		}
+		qty := int(cash * d.params["position_pct"] / 100 / e.price)
+		if qty <= 0 {
+			continue
+		}
+		orders = append(orders, models.Order{Action: models.ActionBuy, StockSymbol: e.symbol, Quantity: qty})
+		cash -= float64(qty) * e.price * (1 + services.CommissionRate)
+		slots--
+	}
+
+	decision := &models.AIDecision{Action: models.ActionHold, Confidence: ruleConfidence, ReasoningSummary: d.strategy + ": no signal"}
+	switch len(orders) {
+	case 0:
+	case 1:
+		decision.Action, decision.StockSymbol, decision.Quantity = orders[0].Action, orders[0].StockSymbol, orders[0].Quantity
+		decision.ReasoningSummary = fmt.Sprintf("%s: %s %s", d.strategy, orders[0].Action, orders[0].StockSymbol)
+	default:
+		decision.Action, decision.Orders, decision.ExecutionMode = models.ActionMulti, orders, models.ExecutionAtomic
+		decision.ReasoningSummary = fmt.Sprintf("%s: %d orders", d.strategy, len(orders))
+	}
+	return decision, nil
+}
@@ -0,0 +1,457 @@
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/1batu/market-ai/internal/simulation"
+	"go.yaml.in/yaml/v3"
+)
+
+// maxSweepSets bounds the grid so a typo cannot start millions of backtests
+const maxSweepSets = 5000
+
+// ErrInvalidSweep wraps every sweep configuration error
+var ErrInvalidSweep = errors.New("invalid sweep")
+
+// Grid lists the values to try for each parameter
+type Grid map[string][]float64
+
+// Combinations returns every parameter set of the grid in a stable order
+func (g Grid) Combinations() []Parameters {
+	keys := make([]string, 0, len(g))
+	for k := range g {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sets := []Parameters{{}}
+	for _, k := range keys {
+		next := make([]Parameters, 0, len(sets)*len(g[k]))
+		for _, s := range sets {
+			for _, v := range g[k] {
+				p := make(Parameters, len(s)+1)
+				for sk, sv := range s {
+					p[sk] = sv
+				}
+				p[k] = v
+				next = append(next, p)
+			}
+		}
+		sets = next
+	}
+	return sets
+}
+
+// SweepConfig describes a parameter sweep of a rule-based strategy with
+// walk-forward validation: every parameter set is backtested on rolling
+// in-sample windows and on the out-of-sample window that follows each one.
+//
+//	strategy: sma_cross
+//	base: {position_pct: 4}
+//	grid:
+//	  fast: [5, 10, 20]
+//	  slow: [30, 50]
+//	in_sample_days: 60
+//	out_of_sample_days: 20
+type SweepConfig struct {
+	Strategy        string              `yaml:"strategy" json:"strategy"`
+	Base            Parameters          `yaml:"base,omitempty" json:"base,omitempty"` // fixed parameters, e.g. an agent's agent_strategies.parameters
+	Grid            Grid                `yaml:"grid" json:"grid"`
+	InSampleDays    int                 `yaml:"in_sample_days" json:"in_sample_days"`
+	OutOfSampleDays int                 `yaml:"out_of_sample_days" json:"out_of_sample_days"`
+	StepDays        int                 `yaml:"step_days,omitempty" json:"step_days,omitempty"` // 0 = out_of_sample_days
+	Every           simulation.Duration `yaml:"every,omitempty" json:"every,omitempty"`         // decision interval (0 = every bar)
+	InitialBalance  float64             `yaml:"initial_balance,omitempty" json:"initial_balance,omitempty"`
+	Workers         int                 `yaml:"workers,omitempty" json:"-"` // parallel backtests (0 = number of CPUs)
+}
+
+// ParseSweepConfig reads and validates a sweep configuration
+func ParseSweepConfig(data []byte) (*SweepConfig, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var c SweepConfig
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSweep, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks the configuration and fills defaults
+func (c *SweepConfig) Validate() error {
+	spec, ok := RuleStrategies[c.Strategy]
+	if !ok {
+		return fmt.Errorf("%w: unknown rule strategy %q", ErrInvalidSweep, c.Strategy)
+	}
+	if c.InSampleDays <= 0 || c.OutOfSampleDays <= 0 || c.StepDays < 0 {
+		return fmt.Errorf("%w: in_sample_days and out_of_sample_days must be > 0", ErrInvalidSweep)
+	}
+	if c.StepDays == 0 {
+		c.StepDays = c.OutOfSampleDays
+	}
+	sets := 1
+	for k, values := range c.Grid {
+		if len(values) == 0 {
+			return fmt.Errorf("%w: grid %q has no values", ErrInvalidSweep, k)
+		}
+		if _, known := spec.Defaults[k]; !known {
+			if _, common := commonDefaults[k]; !common {
+				return fmt.Errorf("%w: %s has no parameter %q", ErrInvalidSweep, c.Strategy, k)
+			}
+		}
+		if sets *= len(values); sets > maxSweepSets {
+			return fmt.Errorf("%w: grid has more than %d parameter sets", ErrInvalidSweep, maxSweepSets)
+		}
+	}
+	return nil
+}
+
+// Window is one walk-forward step: in-sample [InStart, InEnd), out-of-sample [InEnd, OutEnd)
+type Window struct {
+	InStart time.Time `json:"in_start"`
+	InEnd   time.Time `json:"in_end"`
+	OutEnd  time.Time `json:"out_end"`
+}
+
+// Windows returns the walk-forward windows that fit between start and end
+func (c *SweepConfig) Windows(start, end time.Time) []Window {
+	in := time.Duration(c.InSampleDays) * 24 * time.Hour
+	out := time.Duration(c.OutOfSampleDays) * 24 * time.Hour
+	step := time.Duration(c.StepDays) * 24 * time.Hour
+	var windows []Window
+	for s := start; !s.Add(in + out).After(end); s = s.Add(step) {
+		windows = append(windows, Window{InStart: s, InEnd: s.Add(in), OutEnd: s.Add(in + out)})
+	}
+	return windows
+}
+
+// PeriodStats are the statistics of one backtest of a sweep
+type PeriodStats struct {
+	Sharpe      float64 `json:"sharpe"`
+	Return      float64 `json:"return_percent"`
+	MaxDrawdown float64 `json:"max_drawdown_percent"`
+	Trades      int     `json:"trades"`
+}
+
+// WindowStats are a parameter set's in-sample and out-of-sample results in a window
+type WindowStats struct {
+	InSample    PeriodStats `json:"in_sample"`
+	OutOfSample PeriodStats `json:"out_of_sample"`
+}
+
+// SweepResult is one parameter set, averaged over the windows. SharpeDecay
+// (in-sample minus out-of-sample Sharpe) grows when a set only fits the past.
+type SweepResult struct {
+	Rank                   int           `json:"rank"`
+	Parameters             Parameters    `json:"parameters"`
+	InSampleSharpe         float64       `json:"in_sample_sharpe"`
+	OutOfSampleSharpe      float64       `json:"out_of_sample_sharpe"`
+	InSampleReturn         float64       `json:"in_sample_return_percent"`
+	OutOfSampleReturn      float64       `json:"out_of_sample_return_percent"`
+	OutOfSampleMaxDrawdown float64       `json:"out_of_sample_max_drawdown_percent"`
+	OutOfSampleTrades      int           `json:"out_of_sample_trades"`
+	PositiveWindows        int           `json:"positive_windows"`
+	SharpeDecay            float64       `json:"sharpe_decay"`
+	Windows                []WindowStats `json:"windows"`
+}
+
+// WindowSelection is the set a walk-forward run would have picked in a
+// window (best in-sample Sharpe) and how it then did out of sample.
+// OutOfSampleRank is its rank among all sets out of sample (1 = best).
+type WindowSelection struct {
+	Window
+	Parameters        Parameters `json:"parameters"`
+	InSampleSharpe    float64    `json:"in_sample_sharpe"`
+	OutOfSampleSharpe float64    `json:"out_of_sample_sharpe"`
+	OutOfSampleReturn float64    `json:"out_of_sample_return_percent"`
+	OutOfSampleRank   int        `json:"out_of_sample_rank"`
+}
+
+// Overfitting summarizes how well in-sample results predict out-of-sample ones.
+//   - PBO: share of windows where the in-sample best set ends up in the bottom
+//     half out of sample (probability of backtest overfitting; 0.5 = no skill)
+//   - RankCorrelation: mean Spearman correlation of in- and out-of-sample
+//     Sharpe across sets (near 0 or negative = the ranking does not carry over)
+//   - MeanSharpeDecay: mean in-sample minus out-of-sample Sharpe of all sets
+//   - WalkForwardEfficiency: daily out-of-sample return of the selected sets
+//     over their daily in-sample return (well below 1 = overfit)
+type Overfitting struct {
+	PBO                   float64           `json:"pbo"`
+	RankCorrelation       float64           `json:"rank_correlation"`
+	MeanSharpeDecay       float64           `json:"mean_sharpe_decay"`
+	WalkForwardEfficiency float64           `json:"walk_forward_efficiency"`
+	Selections            []WindowSelection `json:"selections"`
+}
+
+// SweepReport is the result of a sweep, sets ranked by out-of-sample Sharpe
+type SweepReport struct {
+	Strategy    string        `json:"strategy"`
+	Base        Parameters    `json:"base,omitempty"`
+	Windows     []Window      `json:"windows"`
+	Backtests   int           `json:"backtests"`
+	Results     []SweepResult `json:"results"`
+	Overfitting Overfitting   `json:"overfitting"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *SweepReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// sweepJob is one backtest of a sweep
+type sweepJob struct {
+	set, window int
+	outOfSample bool
+}
+
+// RunSweep backtests every parameter set of the grid on every walk-forward
+// window, in parallel, and ranks the sets by mean out-of-sample Sharpe. Each
+// backtest starts with a fresh book; indicators still see the bars before the
+// window, never after it.
+func RunSweep(ctx context.Context, cfg SweepConfig, market *Market, news *NewsStore) (*SweepReport, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	closes := market.CloseTimes()
+	if len(closes) == 0 {
+		return nil, ErrNoBars
+	}
+	windows := cfg.Windows(closes[0].Add(-market.Interval()), closes[len(closes)-1])
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("%w: the bars do not cover one in-sample plus out-of-sample window", ErrInvalidSweep)
+	}
+
+	sets := cfg.Grid.Combinations()
+	for i, p := range sets {
+		sets[i] = p.with(cfg.Base)
+		if _, err := NewRuleDecider(cfg.Strategy, sets[i], market); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSweep, err)
+		}
+	}
+
+	stats := make([][]WindowStats, len(sets))
+	for i := range stats {
+		stats[i] = make([]WindowStats, len(windows))
+	}
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan sweepJob)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				ps, err := runPeriod(ctx, cfg, market, news, sets[j.set], windows[j.window], j.outOfSample)
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+				if j.outOfSample {
+					stats[j.set][j.window].OutOfSample = ps
+				} else {
+					stats[j.set][j.window].InSample = ps
+				}
+			}
+		}()
+	}
+feed:
+	for s := range sets {
+		for w := range windows {
+			for _, oos := range []bool{false, true} {
+				select {
+				case jobs <- sweepJob{set: s, window: w, outOfSample: oos}:
+				case <-ctx.Done():
+					break feed
+				}
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &SweepReport{Strategy: cfg.Strategy, Base: cfg.Base, Windows: windows, Backtests: len(sets) * len(windows) * 2}
+	report.Results = rankSets(sets, stats)
+	report.Overfitting = overfitting(cfg, sets, windows, stats)
+	return report, nil
+}
+
+// runPeriod backtests one parameter set on the in-sample or out-of-sample part of a window
+func runPeriod(ctx context.Context, cfg SweepConfig, market *Market, news *NewsStore, params Parameters, w Window, outOfSample bool) (PeriodStats, error) {
+	decider, err := NewRuleDecider(cfg.Strategy, params, market)
+	if err != nil {
+		return PeriodStats{}, err
+	}
+	start, end := w.InStart, w.InEnd
+	if outOfSample {
+		start, end = w.InEnd, w.OutEnd
+	}
+	run := Config{Start: start, End: end.Add(-time.Nanosecond), Every: time.Duration(cfg.Every), InitialBalance: cfg.InitialBalance}
+	res, err := NewEngine(run, market, news, []Agent{{Name: decider.Name(), Strategy: cfg.Strategy, Decider: decider}}).Run(ctx)
+	if errors.Is(err, ErrNoBars) {
+		return PeriodStats{}, nil
+	}
+	if err != nil {
+		return PeriodStats{}, err
+	}
+	s := res.Summaries[0]
+	return PeriodStats{Sharpe: s.SharpeRatio, Return: s.ReturnPercent, MaxDrawdown: s.MaxDrawdownPercent, Trades: s.Trades}, nil
+}
+
+// rankSets averages each set over the windows and sorts by out-of-sample Sharpe
+func rankSets(sets []Parameters, stats [][]WindowStats) []SweepResult {
+	results := make([]SweepResult, len(sets))
+	for i, p := range sets {
+		r := SweepResult{Parameters: p, Windows: stats[i]}
+		for _, w := range stats[i] {
+			r.InSampleSharpe += w.InSample.Sharpe
+			r.OutOfSampleSharpe += w.OutOfSample.Sharpe
+			r.InSampleReturn += w.InSample.Return
+			r.OutOfSampleReturn += w.OutOfSample.Return
+			r.OutOfSampleMaxDrawdown = math.Max(r.OutOfSampleMaxDrawdown, w.OutOfSample.MaxDrawdown)
+			r.OutOfSampleTrades += w.OutOfSample.Trades
+			if w.OutOfSample.Return > 0 {
+				r.PositiveWindows++
+			}
+		}
+		if n := float64(len(stats[i])); n > 0 {
+			r.InSampleSharpe /= n
+			r.OutOfSampleSharpe /= n
+			r.InSampleReturn /= n
+			r.OutOfSampleReturn /= n
+		}
+		r.SharpeDecay = r.InSampleSharpe - r.OutOfSampleSharpe
+		results[i] = r
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].OutOfSampleSharpe != results[j].OutOfSampleSharpe {
+			return results[i].OutOfSampleSharpe > results[j].OutOfSampleSharpe
+		}
+		return results[i].OutOfSampleReturn > results[j].OutOfSampleReturn
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+	return results
+}
+
+// overfitting compares the in-sample and out-of-sample rankings window by window
+func overfitting(cfg SweepConfig, sets []Parameters, windows []Window, stats [][]WindowStats) Overfitting {
+	var o Overfitting
+	var below, correlated int
+	var inDaily, outDaily float64
+	for w, win := range windows {
+		is := make([]float64, len(sets))
+		oos := make([]float64, len(sets))
+		best := 0
+		for s := range sets {
+			is[s], oos[s] = stats[s][w].InSample.Sharpe, stats[s][w].OutOfSample.Sharpe
+			if is[s] > is[best] {
+				best = s
+			}
+		}
+		rank := 1
+		for s := range sets {
+			if oos[s] > oos[best] {
+				rank++
+			}
+		}
+		if len(sets) > 1 && float64(rank) > float64(len(sets)+1)/2 {
+			below++
+		}
+		if rho, ok := spearman(is, oos); ok {
+			o.RankCorrelation += rho
+			correlated++
+		}
+		o.Selections = append(o.Selections, WindowSelection{
+			Window: win, Parameters: sets[best],
+			InSampleSharpe: is[best], OutOfSampleSharpe: oos[best],
+			OutOfSampleReturn: stats[best][w].OutOfSample.Return, OutOfSampleRank: rank,
+		})
+		inDaily += stats[best][w].InSample.Return / float64(cfg.InSampleDays)
+		outDaily += stats[best][w].OutOfSample.Return / float64(cfg.OutOfSampleDays)
+	}
+	if len(windows) > 0 {
+		o.PBO = float64(below) / float64(len(windows))
+	}
+	if correlated > 0 {
+		o.RankCorrelation /= float64(correlated)
+	}
+	if inDaily > 0 {
+		o.WalkForwardEfficiency = outDaily / inDaily
+	}
+	for s := range sets {
+		for w := range windows {
+			o.MeanSharpeDecay += stats[s][w].InSample.Sharpe - stats[s][w].OutOfSample.Sharpe
+		}
+	}
+	if n := len(sets) * len(windows); n > 0 {
+		o.MeanSharpeDecay /= float64(n)
+	}
+	return o
+}
+
+// spearman returns the rank correlation of a and b; false when either is constant
+func spearman(a, b []float64) (float64, bool) {
+	ra, rb := ranks(a), ranks(b)
+	ma, mb := mean(ra), mean(rb)
+	var cov, va, vb float64
+	for i := range ra {
+		cov += (ra[i] - ma) * (rb[i] - mb)
+		va += (ra[i] - ma) * (ra[i] - ma)
+		vb += (rb[i] - mb) * (rb[i] - mb)
+	}
+	if va == 0 || vb == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(va*vb), true
+}
+
+// ranks returns the 1-based ranks of v, ties sharing their average rank
+func ranks(v []float64) []float64 {
+	idx := make([]int, len(v))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return v[idx[i]] < v[idx[j]] })
+	r := make([]float64, len(v))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && v[idx[j+1]] == v[idx[i]] {
+			j++
+		}
+		for k := i; k <= j; k++ {
+			r[idx[k]] = float64(i+j)/2 + 1
+		}
+		i = j + 1
+	}
+	return r
+}
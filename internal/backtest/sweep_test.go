@@ -0,0 +1,138 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
+)
+
+func TestGridCombinations(t *testing.T) {
+	sets := Grid{"slow": {30, 50}, "fast": {5, 10, 20}}.Combinations()
+	if len(sets) != 6 {
+		t.Fatalf("got %d sets, want 6", len(sets))
+	}
+	if sets[0].String() != "fast=5,slow=30" || sets[1].String() != "fast=5,slow=50" || sets[5].String() != "fast=20,slow=50" {
+		t.Errorf("sets = %v", sets)
+	}
+	if got := (Grid{}).Combinations(); len(got) != 1 || len(got[0]) != 0 {
+		t.Errorf("empty grid = %v, want one empty set", got)
+	}
+}
+
+func TestParseSweepConfig(t *testing.T) {
+	c, err := ParseSweepConfig([]byte("strategy: sma_cross\nbase: {position_pct: 4}\ngrid:\n  fast: [5, 10]\nin_sample_days: 60\nout_of_sample_days: 20\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.StepDays != 20 || c.Base["position_pct"] != 4 {
+		t.Errorf("config = %+v", c)
+	}
+	for name, in := range map[string]string{
+		"unknown strategy":  "strategy: nope\nin_sample_days: 1\nout_of_sample_days: 1\n",
+		"unknown parameter": "strategy: momentum\ngrid: {fast: [1]}\nin_sample_days: 1\nout_of_sample_days: 1\n",
+		"no windows":        "strategy: momentum\n",
+		"unknown field":     "strategy: momentum\nin_sample_days: 1\nout_of_sample_days: 1\nspeed: 2\n",
+	} {
+		if _, err := ParseSweepConfig([]byte(in)); !errors.Is(err, ErrInvalidSweep) {
+			t.Errorf("%s: err = %v, want ErrInvalidSweep", name, err)
+		}
+	}
+}
+
+func TestSpearman(t *testing.T) {
+	if rho, ok := spearman([]float64{1, 2, 3, 4}, []float64{10, 20, 30, 40}); !ok || math.Abs(rho-1) > 1e-9 {
+		t.Errorf("monotone rho = %v", rho)
+	}
+	if rho, _ := spearman([]float64{1, 2, 3, 4}, []float64{4, 3, 2, 1}); math.Abs(rho+1) > 1e-9 {
+		t.Errorf("reversed rho = %v", rho)
+	}
+	if _, ok := spearman([]float64{1, 2}, []float64{5, 5}); ok {
+		t.Error("constant series should have no correlation")
+	}
+	if r := ranks([]float64{3, 1, 3}); r[0] != 2.5 || r[1] != 1 || r[2] != 2.5 {
+		t.Errorf("ranks with ties = %v", r)
+	}
+}
+
+func TestSMACrossSignal(t *testing.T) {
+	p := Parameters{"fast": 2, "slow": 3}
+	sig := RuleStrategies["sma_cross"].Signal
+	if s := sig(p, []float64{10, 9, 8, 12}); s <= 0 {
+		t.Errorf("upward cross = %v, want > 0", s)
+	}
+	if s := sig(p, []float64{8, 9, 10, 6}); s >= 0 {
+		t.Errorf("downward cross = %v, want < 0", s)
+	}
+	if s := sig(p, []float64{8, 9, 10, 11}); s != 0 {
+		t.Errorf("no cross = %v, want 0", s)
+	}
+	if _, err := NewRuleDecider("sma_cross", Parameters{"fast": 30, "slow": 10}, nil); err == nil {
+		t.Error("fast >= slow should be rejected")
+	}
+}
+
+// trendingMarket rises about 0.5% a day with a little alternating noise
+func trendingMarket(days int) *Market {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := make([]simulation.Bar, days)
+	for i := range bars {
+		c := 100 * math.Pow(1.005, float64(i)) * (1 + 0.002*math.Pow(-1, float64(i)))
+		bars[i] = simulation.Bar{Symbol: "UP", Time: start.AddDate(0, 0, i), Open: c, High: c, Low: c, Close: c, Volume: 1}
+	}
+	return NewMarket(bars)
+}
+
+func TestRuleDeciderBuysTrend(t *testing.T) {
+	m := trendingMarket(30)
+	d, err := NewRuleDecider("momentum", Parameters{"lookback": 5}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decision, err := d.Decide(context.Background(), &ai.DecisionRequest{CurrentBalance: 100000, Now: time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	price, _ := m.Price("UP", time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC))
+	if decision.Action != models.ActionBuy || decision.StockSymbol != "UP" || decision.Quantity != int(5000/price) {
+		t.Errorf("decision = %+v, want a 5%% buy of UP", decision)
+	}
+}
+
+func TestRunSweepRanksByOutOfSampleSharpe(t *testing.T) {
+	cfg := SweepConfig{
+		Strategy:        "momentum",
+		Base:            Parameters{"lookback": 5},
+		Grid:            Grid{"threshold": {1000, 1}},
+		InSampleDays:    40,
+		OutOfSampleDays: 20,
+		Workers:         3,
+	}
+	report, err := RunSweep(context.Background(), cfg, trendingMarket(120), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Windows) != 4 || report.Backtests != 16 {
+		t.Fatalf("windows = %d, backtests = %d", len(report.Windows), report.Backtests)
+	}
+	best := report.Results[0]
+	if best.Parameters["threshold"] != 1 || best.Parameters["lookback"] != 5 || best.OutOfSampleSharpe <= 0 || best.OutOfSampleTrades == 0 {
+		t.Errorf("best = %+v", best)
+	}
+	if idle := report.Results[1]; idle.OutOfSampleTrades != 0 || idle.Rank != 2 {
+		t.Errorf("idle set = %+v", idle)
+	}
+
+	o := report.Overfitting
+	if len(o.Selections) != 4 || o.Selections[0].OutOfSampleRank != 1 || o.PBO != 0 {
+		t.Errorf("overfitting = %+v", o)
+	}
+	if o.RankCorrelation != 1 {
+		t.Errorf("rank correlation = %v, want 1 when the in-sample ranking holds", o.RankCorrelation)
+	}
+}
@@ -0,0 +1,187 @@
+// Package badges defines the achievement rules agents are awarded badges by.
+// Rules are declarative: each names the event it is evaluated on, one of a
+// fixed set of conditions and the condition's parameters. The default rules
+// are embedded (rules.yaml); a file in the same format replaces them. The
+// badge engine in services loads the data each condition needs.
+package badges
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/1batu/market-ai/internal/performance"
+	"go.yaml.in/yaml/v3"
+)
+
+// Events a rule is evaluated on
+const (
+	OnTrade    = "trade"    // after a live trade of the agent commits
+	OnSnapshot = "snapshot" // after every leaderboard update, for all active agents
+)
+
+// Conditions
+const (
+	WinStreak       = "win_streak"       // count consecutive profitable sells
+	TradeCount      = "trade_count"      // at least count trades since the last ledger reset
+	ROIAbove        = "roi_above"        // ROI of at least min percent
+	BestCalibration = "best_calibration" // lowest Brier score of last week at horizon, min_samples decisions
+	MarketDownDay   = "market_down_day"  // positive P/L on a finished day the benchmark returned max_return percent or less
+)
+
+// Repeat periods
+const (
+	RepeatOnce = ""     // once per agent
+	RepeatDay  = "day"  // once per Istanbul day
+	RepeatWeek = "week" // once per ISO week
+)
+
+// ErrInvalidRules is returned for rule sets that fail validation
+var ErrInvalidRules = errors.New("invalid badge rules")
+
+//go:embed rules.yaml
+var defaultRules []byte
+
+// Rule is one badge and the condition it is awarded on
+type Rule struct {
+	ID          string `yaml:"id" json:"id"`
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	On          string `yaml:"on" json:"on"`
+	When        string `yaml:"when" json:"when"`
+	Params      Params `yaml:"params" json:"params"`
+	Repeat      string `yaml:"repeat,omitempty" json:"repeat,omitempty"`
+}
+
+// Params are the condition parameters; each condition reads its own
+type Params struct {
+	Count      int     `yaml:"count,omitempty" json:"count,omitempty"`             // win_streak, trade_count
+	Min        float64 `yaml:"min,omitempty" json:"min,omitempty"`                 // roi_above: percent
+	Horizon    string  `yaml:"horizon,omitempty" json:"horizon,omitempty"`         // best_calibration: 1h | 1d | 5d
+	MinSamples int     `yaml:"min_samples,omitempty" json:"min_samples,omitempty"` // best_calibration
+	Benchmark  string  `yaml:"benchmark,omitempty" json:"benchmark,omitempty"`     // market_down_day: bist100 | equal_weight | cash
+	MaxReturn  float64 `yaml:"max_return,omitempty" json:"max_return,omitempty"`   // market_down_day: percent, e.g. -5
+}
+
+type ruleFile struct {
+	Badges []Rule `yaml:"badges"`
+}
+
+// Parse reads a YAML rule set and validates it
+func Parse(data []byte) ([]Rule, error) {
+	var f ruleFile
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+	if err := Validate(f.Badges); err != nil {
+		return nil, err
+	}
+	return f.Badges, nil
+}
+
+// Load reads the rule file at path, or the embedded rules if path is empty
+func Load(path string) ([]Rule, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Default returns the embedded rules
+func Default() []Rule {
+	rules, err := Parse(defaultRules)
+	if err != nil {
+		panic(err) // rules.yaml is part of the binary; failing to parse it is a build bug
+	}
+	return rules
+}
+
+// Validate checks every rule's event, condition and parameters
+func Validate(rules []Rule) error {
+	seen := make(map[string]bool, len(rules))
+	for i := range rules {
+		r := &rules[i]
+		r.ID = strings.TrimSpace(r.ID)
+		if r.ID == "" || seen[r.ID] || len(r.ID) > 50 {
+			return fmt.Errorf("%w: rule %d needs a unique id of at most 50 characters", ErrInvalidRules, i+1)
+		}
+		seen[r.ID] = true
+		if strings.TrimSpace(r.Name) == "" {
+			return fmt.Errorf("%w: %s: name is required", ErrInvalidRules, r.ID)
+		}
+		if r.On != OnTrade && r.On != OnSnapshot {
+			return fmt.Errorf("%w: %s: on must be %q or %q", ErrInvalidRules, r.ID, OnTrade, OnSnapshot)
+		}
+		if r.Repeat != RepeatOnce && r.Repeat != RepeatDay && r.Repeat != RepeatWeek {
+			return fmt.Errorf("%w: %s: repeat must be empty, %q or %q", ErrInvalidRules, r.ID, RepeatDay, RepeatWeek)
+		}
+		p := r.Params
+		switch r.When {
+		case WinStreak, TradeCount:
+			if p.Count <= 0 {
+				return fmt.Errorf("%w: %s: %s needs a positive count", ErrInvalidRules, r.ID, r.When)
+			}
+		case ROIAbove:
+		case BestCalibration:
+			if r.On != OnSnapshot {
+				return fmt.Errorf("%w: %s: %s compares agents and runs on snapshot", ErrInvalidRules, r.ID, r.When)
+			}
+			if p.Horizon == "" || p.MinSamples <= 0 {
+				return fmt.Errorf("%w: %s: %s needs a horizon and positive min_samples", ErrInvalidRules, r.ID, r.When)
+			}
+		case MarketDownDay:
+			if r.On != OnSnapshot {
+				return fmt.Errorf("%w: %s: %s uses finished days and runs on snapshot", ErrInvalidRules, r.ID, r.When)
+			}
+			if p.Benchmark == "" || p.MaxReturn >= 0 {
+				return fmt.Errorf("%w: %s: %s needs a benchmark and a negative max_return", ErrInvalidRules, r.ID, r.When)
+			}
+		default:
+			return fmt.Errorf("%w: %s: unknown condition %q", ErrInvalidRules, r.ID, r.When)
+		}
+	}
+	return nil
+}
+
+// Period is the key an award is stored under: empty for once-only badges,
+// otherwise the day or ISO week of at (in at's location)
+func (r Rule) Period(at time.Time) string {
+	switch r.Repeat {
+	case RepeatDay:
+		return at.Format(time.DateOnly)
+	case RepeatWeek:
+		year, week := at.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return ""
+}
+
+// LongestWinStreak is the longest run of consecutive profitable closed trades
+func LongestWinStreak(closed []performance.ClosedTrade) int {
+	best, run := 0, 0
+	for _, c := range closed {
+		if c.ProfitLoss > 0 {
+			run++
+			best = max(best, run)
+		} else {
+			run = 0
+		}
+	}
+	return best
+}
+
+// LastWeek returns the Monday-to-Monday window of the week before now's, in now's location
+func LastWeek(now time.Time) (time.Time, time.Time) {
+	offset := (int(now.Weekday()) + 6) % 7 // days since Monday
+	thisWeek := time.Date(now.Year(), now.Month(), now.Day()-offset, 0, 0, 0, 0, now.Location())
+	return thisWeek.AddDate(0, 0, -7), thisWeek
+}
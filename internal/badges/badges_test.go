@@ -0,0 +1,71 @@
+package badges
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/1batu/market-ai/internal/performance"
+)
+
+func TestDefaultRulesParse(t *testing.T) {
+	rules := Default()
+	if len(rules) == 0 {
+		t.Fatal("no default rules")
+	}
+	for _, r := range rules {
+		if r.On == OnTrade && (r.When == BestCalibration || r.When == MarketDownDay) {
+			t.Errorf("%s runs on trade", r.ID)
+		}
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":     "badges:\n  - {id: a, name: A, on: trade, when: trade_count, params: {count: 1}, points: 5}",
+		"duplicate id":      "badges:\n  - {id: a, name: A, on: trade, when: trade_count, params: {count: 1}}\n  - {id: a, name: B, on: trade, when: trade_count, params: {count: 2}}",
+		"unknown event":     "badges:\n  - {id: a, name: A, on: decision, when: trade_count, params: {count: 1}}",
+		"unknown condition": "badges:\n  - {id: a, name: A, on: trade, when: sharpe_above}",
+		"missing count":     "badges:\n  - {id: a, name: A, on: trade, when: win_streak}",
+		"calibration trade": "badges:\n  - {id: a, name: A, on: trade, when: best_calibration, params: {horizon: 1d, min_samples: 5}}",
+		"positive drop":     "badges:\n  - {id: a, name: A, on: snapshot, when: market_down_day, params: {benchmark: bist100, max_return: 5}}",
+		"bad repeat":        "badges:\n  - {id: a, name: A, on: trade, when: trade_count, params: {count: 1}, repeat: hourly}",
+	}
+	for name, yaml := range cases {
+		if _, err := Parse([]byte(yaml)); !errors.Is(err, ErrInvalidRules) {
+			t.Errorf("%s: err = %v, want ErrInvalidRules", name, err)
+		}
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	at := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	for repeat, want := range map[string]string{RepeatOnce: "", RepeatDay: "2024-03-06", RepeatWeek: "2024-W10"} {
+		if got := (Rule{Repeat: repeat}).Period(at); got != want {
+			t.Errorf("repeat %q: period = %q, want %q", repeat, got, want)
+		}
+	}
+}
+
+func TestLongestWinStreak(t *testing.T) {
+	closed := []performance.ClosedTrade{{ProfitLoss: 1}, {ProfitLoss: 2}, {ProfitLoss: 0}, {ProfitLoss: 3}, {ProfitLoss: 1}, {ProfitLoss: 4}, {ProfitLoss: -1}}
+	if s := LongestWinStreak(closed); s != 3 {
+		t.Errorf("streak = %d, want 3 (a flat sale breaks it)", s)
+	}
+	if s := LongestWinStreak(nil); s != 0 {
+		t.Errorf("empty streak = %d", s)
+	}
+}
+
+func TestLastWeek(t *testing.T) {
+	// Sunday belongs to the week that started on the previous Monday
+	for _, now := range []time.Time{
+		time.Date(2024, 3, 11, 0, 30, 0, 0, time.UTC), // Monday
+		time.Date(2024, 3, 17, 23, 0, 0, 0, time.UTC), // Sunday
+	} {
+		from, to := LastWeek(now)
+		if !from.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("%v: last week = %v - %v", now.Weekday(), from, to)
+		}
+	}
+}
@@ -18,6 +18,7 @@ type Config struct {
 	Leaderboard LeaderboardConfig
 	DataSources DataSourcesConfig
 	Auth        AuthConfig
+	Simulator   SimulatorConfig
 }
 
 type ServerConfig struct {
@@ -78,11 +79,30 @@ type AIConfig struct {
 	// Cost optimization flags
 	BudgetMode          bool
 	EnablePremiumModels bool
+
+	// Prompt templates
+	PromptDir         string // extra/override prompt sets (<name>.system.tmpl + <name>.decision.tmpl)
+	PromptDefaultSet  string // fallback prompt set name
+	PromptStrategies  string // comma-separated strategy=set pairs (e.g. aggressive=default-v1-tr)
+	PromptTokenBudget int    // max estimated tokens for the decision prompt (negative = context window only)
+
+	// Ensemble (committee) agents
+	EnsembleMembers string // comma-separated member models, optional ":weight" (e.g. gpt-4o-mini:2,deepseek-chat)
+	EnsembleJudge   string // judge model reading all member votes (empty = confidence-weighted voting)
+
+	// Approval mode
+	ProposalTTL int // seconds a trade proposal waits for approval before expiring
 }
 
 // LeaderboardConfig v0.4 leaderboard update interval
 type LeaderboardConfig struct {
 	UpdateInterval int // seconds
+
+	BenchmarkIndex string  // Yahoo symbol of the index benchmark (default XU100 = BIST100)
+	DepositRate    float64 // annual TRY deposit rate of the cash benchmark, percent
+
+	BadgeRules string // YAML file replacing the embedded badge rules (empty = embedded)
+	Boards     string // YAML file replacing the embedded leaderboard boards (empty = embedded)
 }
 
 // DataSourcesConfig v0.5 multi-source collection configuration
@@ -106,6 +126,41 @@ type AuthConfig struct {
 	APIKey    string // Master API key for authentication
 }
 
+// SimulatorConfig simulated market configuration (annualized parameters)
+type SimulatorConfig struct {
+	Enabled      bool
+	Model        string  // gbm | jump | meanrev
+	Seed         int64   // 0 = time-based (logged for reproduction)
+	TickSeconds  int     // real seconds between price updates
+	Speed        float64 // simulated/replayed market time per real time (1 = real time, 0 = as fast as possible)
+	Drift        float64 // default annual drift
+	Volatility   float64 // default annual volatility
+	SymbolParams string  // comma-separated SYMBOL=drift:volatility[:level] overrides
+
+	JumpIntensity  float64 // expected jumps per year (jump model)
+	JumpMean       float64 // mean log jump size
+	JumpStd        float64 // log jump size standard deviation
+	ReversionSpeed float64 // mean-reversion speed per year (meanrev model)
+
+	Sectors           string  // extra sectors: sector=SYM1|SYM2;sector2=SYM3
+	SectorCorrelation float64 // shock correlation within a sector
+	MarketCorrelation float64 // shock correlation across sectors
+
+	ImpactCoefficient float64 // price impact of the agents' net order flow (0 = off)
+	Liquidity         float64 // default liquidity in shares for the impact model
+	SymbolLiquidity   string  // comma-separated SYMBOL=shares overrides
+
+	// Historical replay (instead of a price process)
+	Replay          string // "" (off) | market_data | csv
+	ReplayCSV       string // CSV file or directory of <SYMBOL>.csv files
+	ReplayFrom      string // replay start (RFC3339 or YYYY-MM-DD)
+	ReplayTo        string // replay end (empty = all data)
+	ReplayTimeframe string // bar size: 1m | 5m | 15m | 1h | 1d
+	ReplayMaxGap    int    // longest real wait between two bars (seconds)
+
+	ScenarioDir string // directory of YAML stress-test scenarios
+}
+
 // parseDatabaseURL parses DATABASE_URL and returns DatabaseConfig
 // Supports both DATABASE_URL and individual DB_* variables
 func parseDatabaseURL() DatabaseConfig {
@@ -166,6 +221,18 @@ func getFloat64WithDefault(key string, defaultValue float64) float64 {
 	return value
 }
 
+// getSpeed returns a speed multiplier: "max" = 0 (as fast as possible), unset or invalid = 1
+func getSpeed(key string) float64 {
+	value := strings.TrimSpace(viper.GetString(key))
+	if strings.EqualFold(value, "max") {
+		return 0
+	}
+	if v, err := strconv.ParseFloat(value, 64); err == nil && v > 0 {
+		return v
+	}
+	return 1
+}
+
 // parseRedisURL parses REDIS_URL and returns RedisConfig
 // Supports both REDIS_URL and individual REDIS_* variables
 func parseRedisURL() RedisConfig {
@@ -252,9 +319,23 @@ func Load() (*Config, error) {
 
 			BudgetMode:          viper.GetBool("BUDGET_MODE"),
 			EnablePremiumModels: viper.GetBool("ENABLE_PREMIUM_MODELS"),
+
+			PromptDir:         viper.GetString("PROMPT_DIR"),
+			PromptDefaultSet:  viper.GetString("PROMPT_DEFAULT_SET"),
+			PromptStrategies:  viper.GetString("PROMPT_STRATEGY_SETS"),
+			PromptTokenBudget: getIntWithDefault("PROMPT_TOKEN_BUDGET", 6000), // Default: 6000 tokens
+
+			EnsembleMembers: viper.GetString("ENSEMBLE_MEMBERS"),
+			EnsembleJudge:   viper.GetString("ENSEMBLE_JUDGE"),
+
+			ProposalTTL: getIntWithDefault("PROPOSAL_TTL", 900), // Default: 15 minutes
 		},
 		Leaderboard: LeaderboardConfig{
 			UpdateInterval: getIntWithDefault("LEADERBOARD_UPDATE_INTERVAL", 60), // Default: 60 seconds
+			BenchmarkIndex: viper.GetString("BENCHMARK_INDEX"),
+			DepositRate:    getFloat64WithDefault("BENCHMARK_DEPOSIT_RATE", 40), // Default: 40% per year
+			BadgeRules:     viper.GetString("BADGE_RULES_FILE"),
+			Boards:         viper.GetString("LEADERBOARD_BOARDS_FILE"),
 		},
 		DataSources: DataSourcesConfig{
 			YahooFetchInterval:      getIntWithDefault("YAHOO_FETCH_INTERVAL", 300),      // Default: 5 minutes
@@ -272,6 +353,37 @@ func Load() (*Config, error) {
 			JWTSecret: viper.GetString("JWT_SECRET"),
 			APIKey:    viper.GetString("API_KEY"),
 		},
+		Simulator: SimulatorConfig{
+			Enabled:      viper.GetBool("SIMULATOR_ENABLED"),
+			Model:        viper.GetString("SIM_MODEL"),
+			Seed:         viper.GetInt64("SIM_SEED"),
+			TickSeconds:  getIntWithDefault("SIM_TICK_SECONDS", 5),      // Default: 5 seconds
+			Speed:        getSpeed("SIM_SPEED"),                         // Default: real time, "max" = as fast as possible
+			Drift:        getFloat64WithDefault("SIM_DRIFT", 0.08),      // Default: 8% per year
+			Volatility:   getFloat64WithDefault("SIM_VOLATILITY", 0.35), // Default: 35% per year
+			SymbolParams: viper.GetString("SIM_SYMBOL_PARAMS"),
+
+			JumpIntensity:  getFloat64WithDefault("SIM_JUMP_INTENSITY", 4),  // Default: 4 jumps per year
+			JumpMean:       getFloat64WithDefault("SIM_JUMP_MEAN", -0.02),   // Default: -2% per jump
+			JumpStd:        getFloat64WithDefault("SIM_JUMP_STD", 0.05),     // Default: 5%
+			ReversionSpeed: getFloat64WithDefault("SIM_REVERSION_SPEED", 2), // Default: ~4 month half-life
+
+			Sectors:           viper.GetString("SIM_SECTORS"),
+			SectorCorrelation: getFloat64WithDefault("SIM_SECTOR_CORRELATION", 0.6), // Default: 0.6
+			MarketCorrelation: getFloat64WithDefault("SIM_MARKET_CORRELATION", 0.3), // Default: 0.3
+			ImpactCoefficient: viper.GetFloat64("SIM_IMPACT_COEFFICIENT"),
+			Liquidity:         getFloat64WithDefault("SIM_LIQUIDITY", 1000000), // Default: 1M shares
+			SymbolLiquidity:   viper.GetString("SIM_SYMBOL_LIQUIDITY"),
+
+			Replay:          viper.GetString("SIM_REPLAY"),
+			ReplayCSV:       viper.GetString("SIM_REPLAY_CSV"),
+			ReplayFrom:      viper.GetString("SIM_REPLAY_FROM"),
+			ReplayTo:        viper.GetString("SIM_REPLAY_TO"),
+			ReplayTimeframe: viper.GetString("SIM_REPLAY_TIMEFRAME"),
+			ReplayMaxGap:    getIntWithDefault("SIM_REPLAY_MAX_GAP", 60), // Default: 60 seconds
+
+			ScenarioDir: viper.GetString("SIM_SCENARIO_DIR"),
+		},
 	}
 
 	return config, nil
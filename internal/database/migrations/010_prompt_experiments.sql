@@ -0,0 +1,24 @@
+-- ============================================
+-- Market AI - Prompt A/B Experiments
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS prompt_experiments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'stopped')),
+    assignment VARCHAR(20) NOT NULL DEFAULT 'agent' CHECK (assignment IN ('agent', 'alternate')),
+    variants JSONB NOT NULL,          -- [{"name": "...", "prompt_set": "...", "strategy": "..."}]
+    agent_ids UUID[],                 -- NULL/empty = all active agents
+    started_at TIMESTAMP DEFAULT NOW(),
+    ended_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_status ON prompt_experiments(status);
+
+-- Variant each decision was made under
+ALTER TABLE agent_decisions ADD COLUMN IF NOT EXISTS experiment_id UUID REFERENCES prompt_experiments(id) ON DELETE SET NULL;
+ALTER TABLE agent_decisions ADD COLUMN IF NOT EXISTS experiment_variant VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_decisions_experiment ON agent_decisions(experiment_id, experiment_variant);
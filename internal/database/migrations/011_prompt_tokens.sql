@@ -0,0 +1,23 @@
+-- ============================================
+-- Market AI - Prompt Token Budgeting
+-- ============================================
+
+-- Estimated prompt size (system + decision prompt) for each decision
+ALTER TABLE agent_decisions ADD COLUMN IF NOT EXISTS prompt_tokens INTEGER;
+
+-- View: decision statistics per prompt revision (now with average prompt size)
+CREATE OR REPLACE VIEW v_prompt_version_performance AS
+SELECT
+    d.prompt_version,
+    d.prompt_hash,
+    COUNT(*) AS decisions,
+    COUNT(*) FILTER (WHERE d.decision = 'HOLD') AS holds,
+    COUNT(*) FILTER (WHERE d.executed) AS executed,
+    AVG(d.confidence_score) AS avg_confidence,
+    COALESCE(SUM(d.actual_profit_loss), 0) AS total_profit_loss,
+    MIN(d.created_at) AS first_seen,
+    MAX(d.created_at) AS last_seen,
+    AVG(d.prompt_tokens) AS avg_prompt_tokens
+FROM agent_decisions d
+WHERE d.prompt_hash IS NOT NULL
+GROUP BY d.prompt_version, d.prompt_hash;
@@ -0,0 +1,51 @@
+-- ============================================
+-- Market AI - Multi-Order & Rebalance Decisions
+-- ============================================
+
+-- Allow MULTI (several orders) and REBALANCE (target weights) decisions
+ALTER TABLE agent_decisions DROP CONSTRAINT IF EXISTS agent_decisions_decision_check;
+ALTER TABLE agent_decisions ADD CONSTRAINT agent_decisions_decision_check
+    CHECK (decision IN ('BUY', 'SELL', 'HOLD', 'MULTI', 'REBALANCE'));
+
+-- Orders of a MULTI decision (or the orders planned for a REBALANCE)
+ALTER TABLE agent_decisions ADD COLUMN IF NOT EXISTS orders JSONB;
+-- Target weights (symbol -> % of total equity) of a REBALANCE decision
+ALTER TABLE agent_decisions ADD COLUMN IF NOT EXISTS target_weights JSONB;
+ALTER TABLE agent_decisions ADD COLUMN IF NOT EXISTS execution_mode VARCHAR(10);
+
+-- Decision that produced a trade (one decision can produce several trades)
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS decision_id UUID REFERENCES agent_decisions(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_trades_decision ON trades(decision_id);
+
+-- Link trades to decisions: explicit decision_id first, otherwise the latest
+-- matching single-order decision of the last 5 minutes
+CREATE OR REPLACE FUNCTION update_decision_outcome()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.decision_id IS NOT NULL THEN
+        UPDATE agent_decisions
+        SET executed = TRUE,
+            trade_id = COALESCE(trade_id, NEW.id),
+            outcome = 'success'
+        WHERE id = NEW.decision_id;
+        RETURN NEW;
+    END IF;
+
+    UPDATE agent_decisions
+    SET executed = TRUE,
+        trade_id = NEW.id,
+        outcome = 'success'
+    WHERE id = (
+        SELECT id FROM agent_decisions
+        WHERE agent_id = NEW.agent_id
+          AND stock_symbol = NEW.stock_symbol
+          AND decision = NEW.trade_type
+          AND executed = FALSE
+          AND created_at >= NOW() - INTERVAL '5 minutes'
+        ORDER BY created_at DESC
+        LIMIT 1
+    );
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
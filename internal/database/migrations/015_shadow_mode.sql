@@ -0,0 +1,125 @@
+-- ============================================
+-- Market AI - Shadow (Paper) Mode
+-- ============================================
+
+-- Shadow agents decide as usual but trade on a separate ledger that never
+-- touches the live balances and stays off the competition leaderboard
+ALTER TABLE agents DROP CONSTRAINT IF EXISTS agents_status_check;
+ALTER TABLE agents ADD CONSTRAINT agents_status_check
+    CHECK (status IN ('active', 'inactive', 'paused', 'shadow'));
+
+-- Shadow ledger: balance, holdings and trades (same shape as the live tables)
+CREATE TABLE IF NOT EXISTS shadow_accounts (
+    agent_id UUID PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    initial_balance DECIMAL(15,2) NOT NULL,
+    current_balance DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS shadow_portfolio (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    stock_symbol VARCHAR(10) NOT NULL REFERENCES stocks(symbol),
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    avg_buy_price DECIMAL(10,2) NOT NULL CHECK (avg_buy_price > 0),
+    total_invested DECIMAL(15,2) NOT NULL,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(agent_id, stock_symbol)
+);
+
+CREATE TABLE IF NOT EXISTS shadow_trades (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    stock_symbol VARCHAR(10) NOT NULL REFERENCES stocks(symbol),
+    trade_type VARCHAR(10) NOT NULL CHECK (trade_type IN ('BUY', 'SELL')),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    price DECIMAL(10,2) NOT NULL CHECK (price > 0),
+    total_amount DECIMAL(15,2) NOT NULL,
+    commission DECIMAL(10,2) DEFAULT 0,
+    reasoning TEXT,
+    decision_id UUID REFERENCES agent_decisions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_shadow_trades_agent ON shadow_trades(agent_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_shadow_portfolio_agent ON shadow_portfolio(agent_id);
+
+CREATE TABLE IF NOT EXISTS shadow_metrics (
+    agent_id UUID PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    total_trades INTEGER DEFAULT 0,
+    winning_trades INTEGER DEFAULT 0,
+    losing_trades INTEGER DEFAULT 0,
+    total_profit_loss DECIMAL(15,2) DEFAULT 0,
+    total_portfolio_value DECIMAL(15,2) DEFAULT 0,
+    win_rate DECIMAL(5,2) DEFAULT 0,
+    roi DECIMAL(10,2) DEFAULT 0,
+    calculated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Shadow metrics: P/L is marked to market against the shadow starting balance.
+-- Winning/losing trades and win rate come from the leaderboard's exact
+-- average-cost P&L (Go) only, as for the live ledger.
+CREATE OR REPLACE FUNCTION update_shadow_metrics(p_agent_id UUID)
+RETURNS VOID AS $$
+DECLARE
+    v_initial DECIMAL(15,2);
+    v_balance DECIMAL(15,2);
+    v_portfolio_value DECIMAL(15,2);
+    v_total_trades INTEGER;
+    v_pl DECIMAL(15,2);
+BEGIN
+    SELECT initial_balance, current_balance INTO v_initial, v_balance
+    FROM shadow_accounts WHERE agent_id = p_agent_id;
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    SELECT COALESCE(SUM(p.quantity * s.current_price), 0) INTO v_portfolio_value
+    FROM shadow_portfolio p
+    JOIN stocks s ON s.symbol = p.stock_symbol
+    WHERE p.agent_id = p_agent_id;
+
+    SELECT COUNT(*) INTO v_total_trades
+    FROM shadow_trades WHERE agent_id = p_agent_id;
+
+    v_pl := v_balance + v_portfolio_value - v_initial;
+
+    INSERT INTO shadow_metrics (
+        agent_id, total_trades, total_profit_loss, total_portfolio_value, roi, calculated_at
+    ) VALUES (
+        p_agent_id, v_total_trades, v_pl, v_portfolio_value,
+        CASE WHEN v_initial > 0 THEN v_pl / v_initial * 100 ELSE 0 END,
+        NOW()
+    )
+    ON CONFLICT (agent_id) DO UPDATE SET
+        total_trades = EXCLUDED.total_trades,
+        total_profit_loss = EXCLUDED.total_profit_loss,
+        total_portfolio_value = EXCLUDED.total_portfolio_value,
+        roi = EXCLUDED.roi,
+        calculated_at = NOW();
+END;
+$$ LANGUAGE plpgsql;
+
+-- Shadow trades mark their decision executed (trade_id only references live trades)
+CREATE OR REPLACE FUNCTION update_shadow_decision_outcome()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.decision_id IS NOT NULL THEN
+        UPDATE agent_decisions
+        SET executed = TRUE,
+            outcome = 'success'
+        WHERE id = NEW.decision_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS after_shadow_trade_insert ON shadow_trades;
+CREATE TRIGGER after_shadow_trade_insert
+    AFTER INSERT ON shadow_trades
+    FOR EACH ROW
+    EXECUTE FUNCTION update_shadow_decision_outcome();
+
+-- ROI history per book: live snapshots feed the competition, shadow snapshots the shadow board
+ALTER TABLE agent_performance_snapshots ADD COLUMN IF NOT EXISTS book VARCHAR(10) NOT NULL DEFAULT 'live';
+CREATE INDEX IF NOT EXISTS idx_snapshots_book_time ON agent_performance_snapshots(book, snapshot_time DESC);
@@ -0,0 +1,25 @@
+-- ============================================
+-- Market AI - Decision Replay
+-- ============================================
+-- A stored decision re-run on its audited prompt, with the same or another
+-- model (and optionally another system prompt set), diffed against the original.
+-- Replays of one range request share a batch_id.
+CREATE TABLE IF NOT EXISTS decision_replays (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    batch_id UUID,
+    decision_id UUID NOT NULL REFERENCES agent_decisions(id) ON DELETE CASCADE,
+    model VARCHAR(100) NOT NULL,
+    provider VARCHAR(30),
+    prompt_set VARCHAR(100),
+    original JSONB NOT NULL,
+    replayed JSONB,
+    same_action BOOLEAN,
+    changes JSONB,
+    latency_ms INTEGER,
+    raw_response TEXT,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_decision_replays_decision ON decision_replays(decision_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_decision_replays_batch ON decision_replays(batch_id);
@@ -0,0 +1,26 @@
+-- ============================================
+-- Market AI - Decision Outcome Evaluation
+-- ============================================
+-- Every decision (HOLD included) scored at fixed horizons from later prices.
+-- profit_loss is the decision's hypothetical P/L (orders marked to the horizon
+-- price, or the held positions for HOLD); trade_profit_loss is the P/L of the
+-- trades it actually executed. no_data = no price close enough to the horizon.
+CREATE TABLE IF NOT EXISTS decision_evaluations (
+    decision_id UUID NOT NULL REFERENCES agent_decisions(id) ON DELETE CASCADE,
+    horizon VARCHAR(5) NOT NULL,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    action VARCHAR(10) NOT NULL,
+    confidence DECIMAL(5,2),
+    status VARCHAR(10) NOT NULL CHECK (status IN ('scored', 'no_data')),
+    return_pct DECIMAL(10,4),
+    profit_loss DECIMAL(15,2),
+    trade_profit_loss DECIMAL(15,2),
+    hit BOOLEAN,
+    evaluated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (decision_id, horizon)
+);
+
+CREATE INDEX IF NOT EXISTS idx_decision_evaluations_agent ON decision_evaluations(agent_id, horizon) WHERE status = 'scored';
+
+-- Price lookups around a point in time
+CREATE INDEX IF NOT EXISTS idx_price_sources_symbol_time ON price_sources(stock_symbol, timestamp);
@@ -0,0 +1,13 @@
+-- ============================================
+-- Market AI - Candle Aggregation
+-- ============================================
+-- The candle builder upserts one bar per symbol, timeframe and bar start, so
+-- duplicates (e.g. from running seed_data.sql twice) are removed first.
+DELETE FROM market_data a
+USING market_data b
+WHERE a.stock_symbol = b.stock_symbol
+  AND a.timeframe IS NOT DISTINCT FROM b.timeframe
+  AND a.timestamp = b.timestamp
+  AND a.id > b.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_market_data_bar ON market_data(stock_symbol, timeframe, timestamp);
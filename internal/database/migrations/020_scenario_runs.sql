@@ -0,0 +1,20 @@
+-- ============================================
+-- Market AI - Scenario Runs
+-- ============================================
+-- One row per stress-test scenario run: the parsed scenario, the events as
+-- they were applied and each agent's live equity at start and end, which the
+-- reaction report compares.
+CREATE TABLE IF NOT EXISTS scenario_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    spec JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'stopped', 'failed')),
+    events JSONB NOT NULL DEFAULT '[]',  -- applied events with wall-clock times
+    start_equity JSONB,                  -- agent id -> live equity
+    end_equity JSONB,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scenario_runs_started ON scenario_runs(started_at DESC);
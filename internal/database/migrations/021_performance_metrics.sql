@@ -0,0 +1,58 @@
+-- ============================================
+-- Market AI - Performance Metrics
+-- ============================================
+-- The leaderboard service computes each agent's performance from its
+-- snapshots and closed trades (internal/performance) and writes it here.
+-- Winning/losing trades now count closed sells only.
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS time_weighted_return DECIMAL(12,4) DEFAULT 0;
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS sortino_ratio DECIMAL(10,4) DEFAULT 0;
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS calmar_ratio DECIMAL(12,4) DEFAULT 0;
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS max_drawdown_seconds BIGINT DEFAULT 0;
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS profit_factor DECIMAL(12,4);  -- NULL without a losing trade
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS avg_win DECIMAL(15,2) DEFAULT 0;
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS avg_loss DECIMAL(15,2) DEFAULT 0;
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS exposure DECIMAL(5,2) DEFAULT 0;
+ALTER TABLE agent_metrics ADD COLUMN IF NOT EXISTS performance_updated_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_snapshots_agent_book_time ON agent_performance_snapshots(agent_id, book, snapshot_time);
+
+-- Trade count, P&L, value and ROI. Winning/losing trades and win rate are
+-- written by the leaderboard only (exact average-cost P&L of closed trades, net
+-- of commissions), so agent_metrics never mixes two definitions.
+CREATE OR REPLACE FUNCTION update_agent_metrics(p_agent_id UUID)
+RETURNS VOID AS $$
+DECLARE
+    v_initial DECIMAL(15,2);
+    v_portfolio_value DECIMAL(15,2);
+    v_total_trades INTEGER;
+    v_pl DECIMAL(15,2);
+BEGIN
+    SELECT initial_balance INTO v_initial
+    FROM agents WHERE id = p_agent_id;
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    v_portfolio_value := calculate_portfolio_value(p_agent_id);
+
+    SELECT COUNT(*) INTO v_total_trades
+    FROM trades WHERE agent_id = p_agent_id;
+
+    SELECT COALESCE(SUM(profit_loss), 0) INTO v_pl
+    FROM portfolio WHERE agent_id = p_agent_id;
+
+    INSERT INTO agent_metrics (
+        agent_id, total_trades, total_profit_loss, total_portfolio_value, roi, calculated_at
+    ) VALUES (
+        p_agent_id, v_total_trades, v_pl, v_portfolio_value,
+        CASE WHEN v_initial > 0 THEN v_pl / v_initial * 100 ELSE 0 END,
+        NOW()
+    )
+    ON CONFLICT (agent_id) DO UPDATE SET
+        total_trades = EXCLUDED.total_trades,
+        total_profit_loss = EXCLUDED.total_profit_loss,
+        total_portfolio_value = EXCLUDED.total_portfolio_value,
+        roi = EXCLUDED.roi,
+        calculated_at = NOW();
+END;
+$$ LANGUAGE plpgsql;
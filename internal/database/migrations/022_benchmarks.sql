@@ -0,0 +1,30 @@
+-- ============================================
+-- Market AI - Benchmarks
+-- ============================================
+-- Reference series for the leaderboard: the BIST100 index (Yahoo XU100.IS),
+-- an equal-weight basket of the active universe and cash earning the TRY
+-- deposit rate. The basket and cash start at 100; roi_percent is measured
+-- from each benchmark's first snapshot.
+CREATE TABLE IF NOT EXISTS benchmark_snapshots (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    benchmark VARCHAR(20) NOT NULL CHECK (benchmark IN ('bist100', 'equal_weight', 'cash')),
+    value DECIMAL(15,4) NOT NULL,
+    roi_percent DECIMAL(10,4) NOT NULL DEFAULT 0,
+    constituents JSONB,  -- equal_weight: symbol -> price the next return is measured from
+    snapshot_time TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_benchmark_snapshots_time ON benchmark_snapshots(benchmark, snapshot_time DESC);
+
+-- Each agent's live performance against each benchmark, from daily returns
+CREATE TABLE IF NOT EXISTS agent_benchmark_metrics (
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    benchmark VARCHAR(20) NOT NULL,
+    alpha DECIMAL(12,4) DEFAULT 0,             -- annualized, percent
+    beta DECIMAL(10,4) DEFAULT 0,
+    tracking_error DECIMAL(12,4) DEFAULT 0,    -- annualized, percent
+    information_ratio DECIMAL(10,4) DEFAULT 0,
+    days INTEGER DEFAULT 0,                    -- daily returns compared
+    calculated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (agent_id, benchmark)
+);
@@ -0,0 +1,13 @@
+-- ============================================
+-- Market AI - Daily Stats Rollup
+-- ============================================
+-- The end-of-day job fills agent_daily_stats per Istanbul calendar day at
+-- the BIST close and finalizes the previous day on its next run.
+-- profit_loss is the change of the live account value over the day.
+ALTER TABLE agent_daily_stats ADD COLUMN IF NOT EXISTS start_value DECIMAL(15,2);  -- account value when the day opened
+ALTER TABLE agent_daily_stats ADD COLUMN IF NOT EXISTS end_value DECIMAL(15,2);    -- last value of the day
+ALTER TABLE agent_daily_stats ADD COLUMN IF NOT EXISTS decisions_count INTEGER DEFAULT 0;
+ALTER TABLE agent_daily_stats ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;       -- last rollup (UTC)
+
+CREATE INDEX IF NOT EXISTS idx_daily_stats_agent_date ON agent_daily_stats(agent_id, stat_date DESC);
+CREATE INDEX IF NOT EXISTS idx_trades_agent_created ON trades(agent_id, created_at);
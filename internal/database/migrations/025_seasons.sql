@@ -0,0 +1,226 @@
+-- ============================================
+-- Market AI - Competition Seasons
+-- ============================================
+-- A season resets its agents' live ledger to the season's starting capital
+-- (archiving the previous one first) and ranks them on what they did since. While a season is active the
+-- leaderboard ranks only its participants; finished seasons keep their
+-- final standings and badges in season_participants.
+
+CREATE TABLE IF NOT EXISTS seasons (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'active', 'completed')),
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    starting_capital DECIMAL(15,2) NOT NULL CHECK (starting_capital > 0),
+    agent_ids UUID[],                 -- NULL/empty = all active agents
+    rules JSONB NOT NULL DEFAULT '{}', -- {"min_trades": 5, "ranking": "overall" | "roi" | "profit"}
+    started_at TIMESTAMP,             -- when the ledgers were reset
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+-- At most one season runs at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_seasons_one_active ON seasons(status) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_seasons_starts ON seasons(status, starts_at);
+
+-- Season ledger: starting capital and the standings, frozen when the season ends
+CREATE TABLE IF NOT EXISTS season_participants (
+    season_id UUID NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    starting_capital DECIMAL(15,2) NOT NULL,
+    total_value DECIMAL(15,2),
+    profit_loss DECIMAL(15,2) DEFAULT 0,
+    roi DECIMAL(10,2) DEFAULT 0,
+    win_rate DECIMAL(5,2) DEFAULT 0,
+    total_trades INTEGER DEFAULT 0,
+    winning_trades INTEGER DEFAULT 0,
+    losing_trades INTEGER DEFAULT 0,
+    score DECIMAL(15,4) DEFAULT 0,
+    eligible BOOLEAN DEFAULT FALSE,   -- met the season's min_trades
+    rank INTEGER,
+    badges TEXT[] DEFAULT '{}',
+    joined_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (season_id, agent_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_season_participants_agent ON season_participants(agent_id);
+
+-- Live ledger of each participant as it was just before the season reset it:
+-- the all-time starting capital, cash and open positions are kept here
+CREATE TABLE IF NOT EXISTS season_ledger_archives (
+    season_id UUID NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    initial_balance DECIMAL(15,2) NOT NULL,
+    current_balance DECIMAL(15,2) NOT NULL,
+    ledger_reset_at TIMESTAMP,        -- previous reset, NULL = never reset
+    positions JSONB NOT NULL DEFAULT '[]', -- [{"symbol", "quantity", "avg_buy_price", "total_invested"}]
+    archived_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (season_id, agent_id)
+);
+
+-- Last time the agent's live ledger was reset; metrics only look at what came after
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS ledger_reset_at TIMESTAMP;
+
+-- Live metrics count trades since the last ledger reset. Winning/losing trades
+-- and win rate come from the leaderboard's exact average-cost P&L (Go) only.
+CREATE OR REPLACE FUNCTION update_agent_metrics(p_agent_id UUID)
+RETURNS VOID AS $$
+DECLARE
+    v_initial DECIMAL(15,2);
+    v_since TIMESTAMP;
+    v_portfolio_value DECIMAL(15,2);
+    v_total_trades INTEGER;
+    v_pl DECIMAL(15,2);
+BEGIN
+    SELECT initial_balance, COALESCE(ledger_reset_at, '-infinity') INTO v_initial, v_since
+    FROM agents WHERE id = p_agent_id;
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    v_portfolio_value := calculate_portfolio_value(p_agent_id);
+
+    SELECT COUNT(*) INTO v_total_trades
+    FROM trades WHERE agent_id = p_agent_id AND created_at >= v_since;
+
+    SELECT COALESCE(SUM(profit_loss), 0) INTO v_pl
+    FROM portfolio WHERE agent_id = p_agent_id;
+
+    INSERT INTO agent_metrics (
+        agent_id, total_trades, total_profit_loss, total_portfolio_value, roi, calculated_at
+    ) VALUES (
+        p_agent_id, v_total_trades, v_pl, v_portfolio_value,
+        CASE WHEN v_initial > 0 THEN v_pl / v_initial * 100 ELSE 0 END,
+        NOW()
+    )
+    ON CONFLICT (agent_id) DO UPDATE SET
+        total_trades = EXCLUDED.total_trades,
+        total_profit_loss = EXCLUDED.total_profit_loss,
+        total_portfolio_value = EXCLUDED.total_portfolio_value,
+        roi = EXCLUDED.roi,
+        calculated_at = NOW();
+END;
+$$ LANGUAGE plpgsql;
+
+-- Season standings: value marked to market against the season capital and
+-- trades since the season started. Winning/losing trades and win rate are
+-- written by Go (exact average-cost P&L) before this runs. Agents below
+-- min_trades rank last.
+CREATE OR REPLACE FUNCTION update_season_standings(p_season_id UUID)
+RETURNS VOID AS $$
+DECLARE
+    v_started TIMESTAMP;
+    v_min_trades INTEGER;
+    v_ranking TEXT;
+BEGIN
+    SELECT started_at, COALESCE((rules->>'min_trades')::INTEGER, 0), COALESCE(rules->>'ranking', 'overall')
+    INTO v_started, v_min_trades, v_ranking
+    FROM seasons WHERE id = p_season_id AND status = 'active';
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+
+    WITH stats AS (
+        SELECT sp.agent_id,
+               sp.starting_capital,
+               sp.win_rate,
+               a.current_balance + calculate_portfolio_value(a.id) AS total_value,
+               (SELECT COUNT(*) FROM trades tr
+                WHERE tr.agent_id = sp.agent_id AND tr.created_at >= v_started) AS total_trades
+        FROM season_participants sp
+        JOIN agents a ON a.id = sp.agent_id
+        WHERE sp.season_id = p_season_id
+    ), scored AS (
+        SELECT s.*,
+               s.total_value - s.starting_capital AS pl,
+               (s.total_value - s.starting_capital) / s.starting_capital * 100 AS roi
+        FROM stats s
+    )
+    UPDATE season_participants sp SET
+        total_value = sc.total_value,
+        profit_loss = sc.pl,
+        roi = sc.roi,
+        total_trades = sc.total_trades,
+        score = CASE v_ranking
+                    WHEN 'roi' THEN sc.roi
+                    WHEN 'profit' THEN sc.pl
+                    ELSE sc.roi * 0.4 + sc.win_rate * 0.3 + (sc.pl / 1000) * 0.3
+                END,
+        eligible = sc.total_trades >= v_min_trades,
+        updated_at = NOW()
+    FROM scored sc
+    WHERE sp.season_id = p_season_id AND sp.agent_id = sc.agent_id;
+
+    UPDATE season_participants sp SET rank = r.rank
+    FROM (
+        SELECT agent_id, RANK() OVER (ORDER BY eligible DESC, score DESC) AS rank
+        FROM season_participants WHERE season_id = p_season_id
+    ) r
+    WHERE sp.season_id = p_season_id AND sp.agent_id = r.agent_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- During an active season only its participants are ranked, on season metrics;
+-- otherwise every active agent on its live metrics
+CREATE OR REPLACE FUNCTION update_leaderboard_rankings()
+RETURNS void AS $$
+DECLARE
+    v_season UUID;
+BEGIN
+    -- Clear old rankings
+    TRUNCATE leaderboard_rankings;
+
+    SELECT id INTO v_season FROM seasons WHERE status = 'active';
+    IF v_season IS NOT NULL THEN
+        PERFORM update_season_standings(v_season);
+
+        INSERT INTO leaderboard_rankings (
+            agent_id, rank_overall, rank_by_roi, rank_by_winrate, rank_by_profit,
+            current_roi, current_profit_loss, current_win_rate, total_trades, updated_at
+        )
+        SELECT
+            sp.agent_id,
+            sp.rank,
+            RANK() OVER (ORDER BY sp.roi DESC),
+            RANK() OVER (ORDER BY sp.win_rate DESC),
+            RANK() OVER (ORDER BY sp.profit_loss DESC),
+            sp.roi, sp.profit_loss, sp.win_rate, sp.total_trades, NOW()
+        FROM season_participants sp
+        JOIN agents a ON a.id = sp.agent_id
+        JOIN agent_metrics am ON am.agent_id = sp.agent_id
+        WHERE sp.season_id = v_season AND a.status = 'active';
+        RETURN;
+    END IF;
+
+    -- Calculate new rankings from agent_metrics
+    WITH agent_stats AS (
+        SELECT
+            a.id AS agent_id,
+            a.name,
+            am.roi,
+            am.total_profit_loss,
+            am.win_rate,
+            am.total_trades,
+            RANK() OVER (ORDER BY am.roi DESC) AS rank_roi,
+            RANK() OVER (ORDER BY am.total_profit_loss DESC) AS rank_profit,
+            RANK() OVER (ORDER BY am.win_rate DESC) AS rank_winrate,
+            RANK() OVER (ORDER BY (am.roi * 0.4 + am.win_rate * 0.3 + (am.total_profit_loss / 1000) * 0.3) DESC) AS rank_overall
+        FROM agents a
+        JOIN agent_metrics am ON a.id = am.agent_id
+        WHERE a.status = 'active'
+    )
+    INSERT INTO leaderboard_rankings (
+        agent_id, rank_overall, rank_by_roi, rank_by_winrate, rank_by_profit,
+        current_roi, current_profit_loss, current_win_rate, total_trades, updated_at
+    )
+    SELECT
+        agent_id, rank_overall, rank_roi, rank_winrate, rank_profit,
+        roi, total_profit_loss, win_rate, total_trades, NOW()
+    FROM agent_stats;
+
+    RAISE NOTICE 'Leaderboard rankings updated';
+END;
+$$ LANGUAGE plpgsql;
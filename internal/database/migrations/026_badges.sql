@@ -0,0 +1,21 @@
+-- ============================================
+-- Market AI - Badges
+-- ============================================
+-- Badges awarded by the rule engine (internal/badges). A badge is stored once
+-- per agent and period: '' for once-only badges, the day (2024-03-04) or ISO
+-- week (2024-W10) for repeatable ones. leaderboard_rankings.badges lists the
+-- ids of the agent's badges.
+CREATE TABLE IF NOT EXISTS agent_badges (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    badge VARCHAR(50) NOT NULL,
+    period VARCHAR(20) NOT NULL DEFAULT '',
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    event VARCHAR(10) NOT NULL CHECK (event IN ('trade', 'snapshot')),
+    details JSONB,                    -- what earned it (streak, brier score, market return, ...)
+    awarded_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (agent_id, badge, period)
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_badges_agent ON agent_badges(agent_id, awarded_at DESC);
@@ -38,6 +38,9 @@ type Service struct {
 
 	// güvenilirlik / metrikler
 	stats map[string]*sourceStats // anahtar: kaynak adı ("yahoo", sonra diğerleri)
+
+	// yeni getirilen fiyatları alan isteğe bağlı dinleyici (ör. mum oluşturucu)
+	priceObserver func([]*models.StockPrice)
 }
 
 func New(db *pgxpool.Pool, y *yahoo.YahooFinanceClient, s *scraper.WebScraper, t *tw.Client, a *tw.Analyzer) *Service {
@@ -56,6 +59,9 @@ func New(db *pgxpool.Pool, y *yahoo.YahooFinanceClient, s *scraper.WebScraper, t
 	}
 }
 
+// SetPriceObserver her yeni fiyat getirmesinde fn'i çağırır (önbellekten dönen bağlamda çağrılmaz)
+func (svc *Service) SetPriceObserver(fn func([]*models.StockPrice)) { svc.priceObserver = fn }
+
 // recordFetch güvenilirlik istatistiklerini günceller (bellekte ve veritabanında)
 func (svc *Service) recordFetch(source string, dur time.Duration, success bool) {
 	st, ok := svc.stats[source]
@@ -176,6 +182,9 @@ func (svc *Service) MarketContext(ctx context.Context, symbols []string) (*model
 
 	// 6) Güvenilirlik takibi için fiyatları kaydet
 	svc.storePriceSources(ctx, prices)
+	if svc.priceObserver != nil && len(prices) > 0 {
+		svc.priceObserver(prices)
+	}
 
 	// 7) Tweet duygularını kaydet
 	svc.storeTweets(ctx, tweets)
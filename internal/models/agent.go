@@ -13,6 +13,8 @@ type Agent struct {
 	Status         string    `json:"status" db:"status"`
 	InitialBalance float64   `json:"initial_balance" db:"initial_balance"`
 	CurrentBalance float64   `json:"current_balance" db:"current_balance"`
+	PromptSet      *string   `json:"prompt_set,omitempty" db:"prompt_set"`
+	ApprovalMode   bool      `json:"approval_mode" db:"approval_mode"` // non-HOLD decisions wait for human approval
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DecisionAudit is what was sent to and received from the model for one decision
+type DecisionAudit struct {
+	SystemPrompt string    `json:"system_prompt"`
+	Prompt       string    `json:"prompt"`
+	RawResponse  string    `json:"raw_response"`
+	LatencyMs    int       `json:"latency_ms"`
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	ModelVersion string    `json:"model_version"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// MarketSnapshot is the market state an agent saw when it decided, stored in
+// agent_decisions.market_context
+type MarketSnapshot struct {
+	Timestamp     time.Time         `json:"timestamp"`
+	Book          string            `json:"book"`
+	Balance       float64           `json:"balance"`
+	Holdings      []SnapshotHolding `json:"holdings"`
+	Prices        []SnapshotPrice   `json:"prices"`
+	ContextPrices []SnapshotPrice   `json:"context_prices,omitempty"` // fused multi-source prices
+	NewsIDs       []uuid.UUID       `json:"news_ids"`
+	TweetIDs      []string          `json:"tweet_ids"`
+	MemoryIDs     []uuid.UUID       `json:"memory_ids,omitempty"`
+}
+
+// SnapshotPrice is one symbol's price at decision time
+type SnapshotPrice struct {
+	Symbol        string  `json:"symbol"`
+	Price         float64 `json:"price"`
+	ChangePercent float64 `json:"change_percent,omitempty"`
+	Volume        int64   `json:"volume,omitempty"`
+	Source        string  `json:"source,omitempty"`
+}
+
+// SnapshotHolding is one position at decision time
+type SnapshotHolding struct {
+	Symbol      string  `json:"symbol"`
+	Quantity    int     `json:"quantity"`
+	AvgBuyPrice float64 `json:"avg_buy_price"`
+}
+
+// DecisionTrail explains a decision end to end: the stored decision, the
+// market it saw, the exact prompts and model answer, the reasoning steps and
+// the trades it produced
+type DecisionTrail struct {
+	Decision      AgentDecision        `json:"decision"`
+	AgentName     string               `json:"agent_name"`
+	MarketContext json.RawMessage      `json:"market_context,omitempty"`
+	Audit         *DecisionAudit       `json:"audit"` // nil for decisions made before auditing
+	Thoughts      []AgentThought       `json:"thoughts"`
+	Trades        []Trade              `json:"trades"`
+	ShadowTrades  []Trade              `json:"shadow_trades,omitempty"`
+	Evaluations   []DecisionEvaluation `json:"evaluations"` // outcome at 1h/1d/5d, once each horizon has passed
+}
@@ -6,6 +6,21 @@ import (
 	"github.com/google/uuid"
 )
 
+// Decision actions
+const (
+	ActionBuy       = "BUY"
+	ActionSell      = "SELL"
+	ActionHold      = "HOLD"
+	ActionMulti     = "MULTI"     // several orders executed together
+	ActionRebalance = "REBALANCE" // target weights converted into orders by the engine
+)
+
+// Execution modes for multi-order decisions
+const (
+	ExecutionAtomic  = "atomic"  // all orders in one transaction, all or nothing
+	ExecutionOrdered = "ordered" // sells before buys, each order stands on its own
+)
+
 // AIDecision represents a decision made by AI
 type AIDecision struct {
 	Action           string         `json:"action"`
@@ -18,6 +33,59 @@ type AIDecision struct {
 	Confidence       float64        `json:"confidence"`
 	RiskLevel        string         `json:"risk_level"`
 	ThinkingSteps    []ThinkingStep `json:"thinking_steps"`
+
+	// MULTI / REBALANCE
+	Orders        []Order            `json:"orders,omitempty"`
+	ExecutionMode string             `json:"execution_mode,omitempty"`
+	Rationale     string             `json:"rationale,omitempty"`
+	TargetWeights map[string]float64 `json:"target_weights,omitempty"` // symbol -> % of total equity
+
+	// Ensemble agents: each member's vote
+	Votes []CommitteeVote `json:"votes,omitempty"`
+
+	// Audit trail: the provider's unparsed answer and the model version that served it
+	RawResponse  string `json:"-"`
+	ModelVersion string `json:"-"`
+}
+
+// CommitteeVote is one ensemble member's decision
+type CommitteeVote struct {
+	Model       string  `json:"model"`
+	Weight      float64 `json:"weight"`
+	Action      string  `json:"action,omitempty"`
+	StockSymbol string  `json:"stock_symbol,omitempty"`
+	Quantity    int     `json:"quantity,omitempty"`
+	Confidence  float64 `json:"confidence"`
+	Summary     string  `json:"summary,omitempty"`
+	Elected     bool    `json:"elected"` // voted with the committee's decision
+	Error       string  `json:"error,omitempty"`
+}
+
+// Order is a single BUY/SELL inside a multi-order decision
+type Order struct {
+	Action      string `json:"action"`
+	StockSymbol string `json:"stock_symbol"`
+	Quantity    int    `json:"quantity"`
+}
+
+// TradeRequests converts the decision into trade requests for the given agent.
+// Single BUY/SELL decisions yield one request; MULTI yields its orders.
+func (d *AIDecision) TradeRequests(agentID uuid.UUID) []TradeRequest {
+	reasoning := d.Rationale
+	if reasoning == "" {
+		reasoning = d.ReasoningSummary
+	}
+
+	var reqs []TradeRequest
+	switch d.Action {
+	case ActionBuy, ActionSell:
+		reqs = append(reqs, TradeRequest{AgentID: agentID, StockSymbol: d.StockSymbol, TradeType: d.Action, Quantity: d.Quantity, Reasoning: reasoning})
+	case ActionMulti:
+		for _, o := range d.Orders {
+			reqs = append(reqs, TradeRequest{AgentID: agentID, StockSymbol: o.StockSymbol, TradeType: o.Action, Quantity: o.Quantity, Reasoning: reasoning})
+		}
+	}
+	return reqs
 }
 
 // ThinkingStep represents a step in the AI's reasoning process
@@ -28,24 +96,32 @@ type ThinkingStep struct {
 
 // AgentDecision represents a stored agent decision
 type AgentDecision struct {
-	ID               uuid.UUID  `json:"id" db:"id"`
-	AgentID          uuid.UUID  `json:"agent_id" db:"agent_id"`
-	StockSymbol      *string    `json:"stock_symbol" db:"stock_symbol"`
-	Decision         string     `json:"decision" db:"decision"`
-	Quantity         *int       `json:"quantity" db:"quantity"`
-	TargetPrice      *float64   `json:"target_price" db:"target_price"`
-	StopLoss         *float64   `json:"stop_loss" db:"stop_loss"`
-	ReasoningFull    string     `json:"reasoning_full" db:"reasoning_full"`
-	ReasoningSummary string     `json:"reasoning_summary" db:"reasoning_summary"`
-	ConfidenceScore  float64    `json:"confidence_score" db:"confidence_score"`
-	RiskScore        float64    `json:"risk_score" db:"risk_score"`
-	RiskLevel        string     `json:"risk_level" db:"risk_level"`
-	MarketContext    string     `json:"market_context" db:"market_context"`
-	Executed         bool       `json:"executed" db:"executed"`
-	TradeID          *uuid.UUID `json:"trade_id" db:"trade_id"`
-	Outcome          string     `json:"outcome" db:"outcome"`
-	ActualProfitLoss *float64   `json:"actual_profit_loss" db:"actual_profit_loss"`
-	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	ID                uuid.UUID          `json:"id" db:"id"`
+	AgentID           uuid.UUID          `json:"agent_id" db:"agent_id"`
+	StockSymbol       *string            `json:"stock_symbol" db:"stock_symbol"`
+	Decision          string             `json:"decision" db:"decision"`
+	Quantity          *int               `json:"quantity" db:"quantity"`
+	TargetPrice       *float64           `json:"target_price" db:"target_price"`
+	StopLoss          *float64           `json:"stop_loss" db:"stop_loss"`
+	ReasoningFull     string             `json:"reasoning_full" db:"reasoning_full"`
+	ReasoningSummary  string             `json:"reasoning_summary" db:"reasoning_summary"`
+	ConfidenceScore   float64            `json:"confidence_score" db:"confidence_score"`
+	RiskScore         float64            `json:"risk_score" db:"risk_score"`
+	RiskLevel         string             `json:"risk_level" db:"risk_level"`
+	MarketContext     string             `json:"market_context" db:"market_context"`
+	Executed          bool               `json:"executed" db:"executed"`
+	TradeID           *uuid.UUID         `json:"trade_id" db:"trade_id"`
+	Outcome           string             `json:"outcome" db:"outcome"`
+	ActualProfitLoss  *float64           `json:"actual_profit_loss" db:"actual_profit_loss"`
+	PromptVersion     *string            `json:"prompt_version" db:"prompt_version"`
+	PromptHash        *string            `json:"prompt_hash" db:"prompt_hash"`
+	ExperimentID      *uuid.UUID         `json:"experiment_id,omitempty" db:"experiment_id"`
+	ExperimentVariant *string            `json:"experiment_variant,omitempty" db:"experiment_variant"`
+	PromptTokens      *int               `json:"prompt_tokens,omitempty" db:"prompt_tokens"`
+	Orders            []Order            `json:"orders,omitempty" db:"orders"`
+	TargetWeights     map[string]float64 `json:"target_weights,omitempty" db:"target_weights"`
+	ExecutionMode     *string            `json:"execution_mode,omitempty" db:"execution_mode"`
+	CreatedAt         time.Time          `json:"created_at" db:"created_at"`
 }
 
 // AgentThought represents a thinking step stored in database
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Evaluation statuses
+const (
+	EvaluationScored = "scored"
+	EvaluationNoData = "no_data" // no price close enough to the horizon
+)
+
+// DecisionEvaluation scores a decision at one horizon using later prices
+type DecisionEvaluation struct {
+	DecisionID      uuid.UUID `json:"decision_id"`
+	AgentID         uuid.UUID `json:"agent_id"`
+	Horizon         string    `json:"horizon"` // 1h | 1d | 5d
+	Action          string    `json:"action"`
+	Confidence      float64   `json:"confidence"`
+	Status          string    `json:"status"`
+	ReturnPct       *float64  `json:"return_pct,omitempty"`
+	ProfitLoss      *float64  `json:"profit_loss,omitempty"`       // hypothetical P/L of the decision
+	TradeProfitLoss *float64  `json:"trade_profit_loss,omitempty"` // P/L of the trades it executed
+	Hit             *bool     `json:"hit,omitempty"`
+	EvaluatedAt     time.Time `json:"evaluated_at"`
+}
+
+// CalibrationBin compares stated confidence with the realised hit rate in one confidence band
+type CalibrationBin struct {
+	Lower         float64 `json:"lower"`
+	Upper         float64 `json:"upper"`
+	Count         int     `json:"count"`
+	AvgConfidence float64 `json:"avg_confidence"`
+	HitRate       float64 `json:"hit_rate"` // %
+}
+
+// Calibration is an agent's calibration curve and Brier score at one horizon
+type Calibration struct {
+	AgentID       uuid.UUID        `json:"agent_id"`
+	AgentName     string           `json:"agent_name"`
+	Horizon       string           `json:"horizon"`
+	Samples       int              `json:"samples"`
+	HitRate       float64          `json:"hit_rate"`       // %
+	AvgConfidence float64          `json:"avg_confidence"` // %
+	BrierScore    float64          `json:"brier_score"`    // 0 = perfect, 0.25 = always 50%
+	Bins          []CalibrationBin `json:"bins"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Experiment assignment rules
+const (
+	AssignmentPerAgent  = "agent"     // every agent sticks to one variant for the whole experiment
+	AssignmentAlternate = "alternate" // each agent cycles through variants decision by decision
+)
+
+// PromptExperiment is an A/B test between prompt/strategy variants
+type PromptExperiment struct {
+	ID          uuid.UUID           `json:"id" db:"id"`
+	Name        string              `json:"name" db:"name"`
+	Description string              `json:"description" db:"description"`
+	Status      string              `json:"status" db:"status"`
+	Assignment  string              `json:"assignment" db:"assignment"`
+	Variants    []ExperimentVariant `json:"variants" db:"variants"`
+	AgentIDs    []uuid.UUID         `json:"agent_ids" db:"agent_ids"` // empty = all active agents
+	StartedAt   time.Time           `json:"started_at" db:"started_at"`
+	EndedAt     *time.Time          `json:"ended_at,omitempty" db:"ended_at"`
+	CreatedAt   time.Time           `json:"created_at" db:"created_at"`
+}
+
+// ExperimentVariant is one arm of an experiment. Empty fields keep the agent's own setting.
+type ExperimentVariant struct {
+	Name      string `json:"name"`
+	PromptSet string `json:"prompt_set,omitempty"`
+	Strategy  string `json:"strategy,omitempty"`
+}
+
+// CreateExperimentRequest is the payload for creating an experiment
+type CreateExperimentRequest struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Assignment  string              `json:"assignment"`
+	Variants    []ExperimentVariant `json:"variants"`
+	AgentIDs    []uuid.UUID         `json:"agent_ids"`
+}
+
+// ExperimentReport compares the variants of an experiment against the first (control) variant
+type ExperimentReport struct {
+	Experiment  PromptExperiment    `json:"experiment"`
+	Variants    []VariantStats      `json:"variants"`
+	Comparisons []VariantComparison `json:"comparisons"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+// VariantStats aggregates the decisions and trade outcomes of one variant
+type VariantStats struct {
+	Variant       string  `json:"variant"`
+	Agents        int     `json:"agents"`
+	Decisions     int     `json:"decisions"`
+	Holds         int     `json:"holds"`
+	Executed      int     `json:"executed"`
+	ExecutionRate float64 `json:"execution_rate"`
+	AvgConfidence float64 `json:"avg_confidence"`
+	Wins          int     `json:"wins"`
+	WinRate       float64 `json:"win_rate"`
+	TotalPL       float64 `json:"total_profit_loss"`
+	MeanPL        float64 `json:"mean_profit_loss"`
+	StdDevPL      float64 `json:"stddev_profit_loss"`
+}
+
+// VariantComparison holds significance tests of a variant against the control
+type VariantComparison struct {
+	Variant     string  `json:"variant"`
+	Control     string  `json:"control"`
+	MeanPLDiff  float64 `json:"mean_profit_loss_diff"`
+	TStat       float64 `json:"t_stat"`
+	DegreesFree float64 `json:"degrees_of_freedom"`
+	PValuePL    float64 `json:"p_value_profit_loss"`
+	WinRateDiff float64 `json:"win_rate_diff"`
+	ZStat       float64 `json:"z_stat"`
+	PValueWin   float64 `json:"p_value_win_rate"`
+	Significant bool    `json:"significant"` // either test below 0.05
+	SufficientN bool    `json:"sufficient_sample"`
+	Notes       string  `json:"notes,omitempty"`
+}
@@ -22,3 +22,84 @@ type LeaderboardEntry struct {
 	Badges         []string  `json:"badges" db:"badges"`
 	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
+
+// BoardEntry is a leaderboard row on a named board: the agent's score (0-100)
+// from the board's weighted components, each scaled to 0..1 among the ranked
+// agents. Ineligible agents (short of the board's minimum activity) rank last.
+type BoardEntry struct {
+	LeaderboardEntry
+	Board      string             `json:"board"`
+	Score      float64            `json:"score"`
+	Eligible   bool               `json:"eligible"`
+	Components map[string]float64 `json:"components"`
+}
+
+// BenchmarkComparison is an agent's performance against one benchmark, from
+// daily returns. Alpha and tracking error are annualized percentages.
+type BenchmarkComparison struct {
+	AgentID          uuid.UUID `json:"agent_id"`
+	AgentName        string    `json:"agent_name"`
+	Benchmark        string    `json:"benchmark"` // bist100 | equal_weight | cash
+	Alpha            float64   `json:"alpha"`
+	Beta             float64   `json:"beta"`
+	TrackingError    float64   `json:"tracking_error"`
+	InformationRatio float64   `json:"information_ratio"`
+	Days             int       `json:"days"`
+	CalculatedAt     time.Time `json:"calculated_at"`
+}
+
+// ROIPoint is one point of an ROI history line
+type ROIPoint struct {
+	Time time.Time `json:"time"`
+	ROI  float64   `json:"roi"`
+}
+
+// ROIHistory holds the ROI lines of the agents, keyed by agent ID, and of the
+// benchmarks, keyed by benchmark name (bist100 | equal_weight | cash)
+type ROIHistory struct {
+	Agents     map[string][]ROIPoint `json:"agents"`
+	Benchmarks map[string][]ROIPoint `json:"benchmarks"`
+}
+
+// EloRating is an agent's head-to-head rating from daily matchups
+type EloRating struct {
+	Rank       int        `json:"rank"`
+	AgentID    uuid.UUID  `json:"agent_id"`
+	AgentName  string     `json:"agent_name"`
+	Model      string     `json:"model"`
+	Rating     float64    `json:"rating"`
+	PeakRating float64    `json:"peak_rating"`
+	Games      int        `json:"games"`
+	Wins       int        `json:"wins"`
+	Losses     int        `json:"losses"`
+	Draws      int        `json:"draws"`
+	LastPlayed *time.Time `json:"last_played,omitempty"`
+}
+
+// Matchup is an agent's record against one opponent; a day with the higher
+// daily return is a win
+type Matchup struct {
+	OpponentID         uuid.UUID  `json:"opponent_id"`
+	OpponentName       string     `json:"opponent_name"`
+	Wins               int        `json:"wins"`
+	Losses             int        `json:"losses"`
+	Draws              int        `json:"draws"`
+	ProfitLoss         float64    `json:"profit_loss"`           // this agent's P/L on the days played
+	OpponentProfitLoss float64    `json:"opponent_profit_loss"`  // the opponent's P/L on those days
+	LastResult         string     `json:"last_result,omitempty"` // win | loss | draw
+	LastPlayed         *time.Time `json:"last_played,omitempty"`
+}
+
+// AgentBadge is a badge awarded to an agent by a badge rule
+type AgentBadge struct {
+	ID          uuid.UUID      `json:"id"`
+	AgentID     uuid.UUID      `json:"agent_id"`
+	AgentName   string         `json:"agent_name"`
+	Badge       string         `json:"badge"` // rule id
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Period      string         `json:"period,omitempty"` // day or ISO week of repeatable badges
+	Event       string         `json:"event"`            // trade | snapshot
+	Details     map[string]any `json:"details,omitempty"`
+	AwardedAt   time.Time      `json:"awarded_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory kinds
+const (
+	MemoryLesson     = "lesson"     // lesson learned from a single decision outcome
+	MemoryReflection = "reflection" // standing note summarising recent mistakes
+)
+
+// AgentMemory is a lesson or reflection an agent carries into future decisions
+type AgentMemory struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	AgentID     uuid.UUID  `json:"agent_id" db:"agent_id"`
+	DecisionID  *uuid.UUID `json:"decision_id,omitempty" db:"decision_id"`
+	Kind        string     `json:"kind" db:"kind"`
+	StockSymbol *string    `json:"stock_symbol,omitempty" db:"stock_symbol"`
+	Situation   string     `json:"situation" db:"situation"`
+	Content     string     `json:"content" db:"content"`
+	ProfitLoss  *float64   `json:"profit_loss,omitempty" db:"profit_loss"`
+	Importance  float64    `json:"importance" db:"importance"`
+	Source      string     `json:"source" db:"source"` // rule | agent
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
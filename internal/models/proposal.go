@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Proposal statuses
+const (
+	ProposalPending  = "pending"
+	ProposalApproved = "approved"
+	ProposalRejected = "rejected"
+	ProposalExpired  = "expired"
+	ProposalFailed   = "failed" // approved but execution failed
+)
+
+// TradeProposal is a decision of an agent in approval mode waiting for a human
+type TradeProposal struct {
+	ID            uuid.UUID     `json:"id" db:"id"`
+	AgentID       uuid.UUID     `json:"agent_id" db:"agent_id"`
+	AgentName     string        `json:"agent_name,omitempty"`
+	DecisionID    *uuid.UUID    `json:"decision_id,omitempty" db:"decision_id"`
+	Status        string        `json:"status" db:"status"`
+	Action        string        `json:"action" db:"action"`
+	Orders        []Order       `json:"orders" db:"orders"`
+	ExecutionMode string        `json:"execution_mode" db:"execution_mode"`
+	Confidence    float64       `json:"confidence" db:"confidence"`
+	Reasoning     string        `json:"reasoning" db:"reasoning"`
+	ExpiresAt     time.Time     `json:"expires_at" db:"expires_at"`
+	DecidedBy     *string       `json:"decided_by,omitempty" db:"decided_by"`
+	DecidedAt     *time.Time    `json:"decided_at,omitempty" db:"decided_at"`
+	DecisionNote  *string       `json:"decision_note,omitempty" db:"decision_note"`
+	Results       []OrderResult `json:"results,omitempty" db:"results"`
+	Error         *string       `json:"error,omitempty" db:"error"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+}
+
+// ProposalReviewRequest is the optional body of approve/reject calls
+type ProposalReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// ApprovalModeRequest toggles an agent's approval mode
+type ApprovalModeRequest struct {
+	Enabled bool `json:"enabled"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReplayedDecision is the comparable part of a decision
+type ReplayedDecision struct {
+	Action           string             `json:"action"`
+	StockSymbol      string             `json:"stock_symbol,omitempty"`
+	Quantity         int                `json:"quantity,omitempty"`
+	Confidence       float64            `json:"confidence"`
+	Orders           []Order            `json:"orders,omitempty"`
+	TargetWeights    map[string]float64 `json:"target_weights,omitempty"`
+	ReasoningSummary string             `json:"reasoning_summary,omitempty"`
+}
+
+// DecisionDiff lists what changed between the original and the replayed decision
+type DecisionDiff struct {
+	SameAction bool     `json:"same_action"` // same action (and symbol for BUY/SELL)
+	Changes    []string `json:"changes"`
+}
+
+// DecisionReplay is one stored decision re-run on its original prompt
+type DecisionReplay struct {
+	ID          uuid.UUID         `json:"id"`
+	BatchID     *uuid.UUID        `json:"batch_id,omitempty"`
+	DecisionID  uuid.UUID         `json:"decision_id"`
+	AgentID     uuid.UUID         `json:"agent_id"`
+	Model       string            `json:"model"`
+	Provider    string            `json:"provider"`
+	PromptSet   string            `json:"prompt_set,omitempty"` // empty = original system prompt
+	Original    ReplayedDecision  `json:"original"`
+	Replayed    *ReplayedDecision `json:"replayed,omitempty"`
+	Diff        *DecisionDiff     `json:"diff,omitempty"`
+	LatencyMs   int               `json:"latency_ms"`
+	RawResponse string            `json:"raw_response,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ReplayOptions selects what to replay a decision with
+type ReplayOptions struct {
+	Model     string `json:"model"`      // empty = the model that made the decision
+	PromptSet string `json:"prompt_set"` // empty = the original system prompt
+}
+
+// ReplayRangeRequest replays the audited decisions of a time range
+type ReplayRangeRequest struct {
+	ReplayOptions
+	AgentID *uuid.UUID `json:"agent_id,omitempty"`
+	From    time.Time  `json:"from"`
+	To      time.Time  `json:"to"`
+	Limit   int        `json:"limit"`
+}
+
+// ReplayReport summarises a range replay. Range replays started over the API
+// run in the background; the report then shows the progress so far.
+type ReplayReport struct {
+	BatchID   uuid.UUID        `json:"batch_id"`
+	Model     string           `json:"model,omitempty"`
+	PromptSet string           `json:"prompt_set,omitempty"`
+	Status    string           `json:"status"`  // running | completed | failed
+	Planned   int              `json:"planned"` // decisions selected for the batch
+	Error     string           `json:"error,omitempty"`
+	Total     int              `json:"total"`
+	Matching  int              `json:"matching"` // same action as the original
+	Failed    int              `json:"failed"`
+	MatchRate float64          `json:"match_rate"` // % of successful replays with the same action
+	Results   []DecisionReplay `json:"results"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scenario run statuses
+const (
+	ScenarioRunning   = "running"
+	ScenarioCompleted = "completed"
+	ScenarioStopped   = "stopped"
+	ScenarioFailed    = "failed"
+)
+
+// ScenarioRun is one execution of a stress-test scenario
+type ScenarioRun struct {
+	ID          uuid.UUID             `json:"id" db:"id"`
+	Name        string                `json:"name" db:"name"`
+	Scenario    json.RawMessage       `json:"scenario" db:"spec"`
+	Status      string                `json:"status" db:"status"`
+	Events      []ScenarioEventLog    `json:"events" db:"events"`
+	StartEquity map[uuid.UUID]float64 `json:"start_equity,omitempty" db:"start_equity"`
+	EndEquity   map[uuid.UUID]float64 `json:"end_equity,omitempty" db:"end_equity"`
+	Error       string                `json:"error,omitempty" db:"error"`
+	StartedAt   time.Time             `json:"started_at" db:"started_at"`
+	EndedAt     *time.Time            `json:"ended_at,omitempty" db:"ended_at"`
+}
+
+// ScenarioEventLog records an event as it was applied
+type ScenarioEventLog struct {
+	Index     int        `json:"index"`
+	Type      string     `json:"type"`
+	End       bool       `json:"end,omitempty"` // a timed regime ended
+	Symbols   []string   `json:"symbols,omitempty"`
+	Sector    string     `json:"sector,omitempty"`
+	Detail    string     `json:"detail"`
+	NewsID    *uuid.UUID `json:"news_id,omitempty"`
+	AppliedAt time.Time  `json:"applied_at"`
+}
+
+// StartScenarioRequest starts a scenario from the scenario directory (name)
+// or from an inline YAML document
+type StartScenarioRequest struct {
+	Name string `json:"name"`
+	YAML string `json:"yaml"`
+}
+
+// ScenarioInfo describes a scenario file
+type ScenarioInfo struct {
+	File        string `json:"file"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Events      int    `json:"events"`
+	Length      string `json:"length"`
+}
+
+// ScenarioReport shows how each agent reacted during a scenario run
+type ScenarioReport struct {
+	Run         ScenarioRun             `json:"run"`
+	Agents      []ScenarioAgentReaction `json:"agents"`
+	GeneratedAt time.Time               `json:"generated_at"`
+}
+
+// ScenarioAgentReaction summarizes one agent's behaviour during a run
+type ScenarioAgentReaction struct {
+	AgentID       uuid.UUID               `json:"agent_id"`
+	AgentName     string                  `json:"agent_name"`
+	Decisions     int                     `json:"decisions"`
+	Buys          int                     `json:"buys"`
+	Sells         int                     `json:"sells"`
+	Holds         int                     `json:"holds"`
+	AvgConfidence float64                 `json:"avg_confidence"`
+	Trades        int                     `json:"trades"`
+	BoughtValue   float64                 `json:"bought_value"`
+	SoldValue     float64                 `json:"sold_value"`
+	StartEquity   float64                 `json:"start_equity"`
+	EndEquity     float64                 `json:"end_equity"`
+	ReturnPercent float64                 `json:"return_percent"`
+	Reactions     []ScenarioEventReaction `json:"reactions"`
+}
+
+// ScenarioEventReaction is the agent's first decision after an event
+type ScenarioEventReaction struct {
+	EventIndex   int        `json:"event_index"`
+	EventType    string     `json:"event_type"`
+	DecisionID   *uuid.UUID `json:"decision_id,omitempty"` // nil = no decision before the next event or the end of the run
+	Decision     string     `json:"decision,omitempty"`
+	Symbol       string     `json:"symbol,omitempty"`
+	Confidence   float64    `json:"confidence,omitempty"`
+	DelaySeconds float64    `json:"delay_seconds,omitempty"`
+	SawNews      bool       `json:"saw_news,omitempty"` // the injected article was in the decision's prompt
+}
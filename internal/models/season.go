@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Season statuses
+const (
+	SeasonScheduled = "scheduled"
+	SeasonActive    = "active"
+	SeasonCompleted = "completed"
+)
+
+// Season ranking rules
+const (
+	SeasonRankingOverall = "overall" // the default leaderboard board (same as the all-time rank_overall)
+	SeasonRankingROI     = "roi"
+	SeasonRankingProfit  = "profit"
+)
+
+// Season is a competition window. When it starts every participant's live
+// ledger is reset to StartingCapital and rankings only count what follows.
+type Season struct {
+	ID              uuid.UUID   `json:"id" db:"id"`
+	Name            string      `json:"name" db:"name"`
+	Status          string      `json:"status" db:"status"`
+	StartsAt        time.Time   `json:"starts_at" db:"starts_at"`
+	EndsAt          time.Time   `json:"ends_at" db:"ends_at"`
+	StartingCapital float64     `json:"starting_capital" db:"starting_capital"`
+	AgentIDs        []uuid.UUID `json:"agent_ids" db:"agent_ids"` // empty = all active agents
+	Rules           SeasonRules `json:"rules" db:"rules"`
+	StartedAt       *time.Time  `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt     *time.Time  `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+}
+
+// SeasonRules is the rule set a season is scored under
+type SeasonRules struct {
+	MinTrades int    `json:"min_trades"` // agents with fewer trades rank after everyone else and get no badges
+	Ranking   string `json:"ranking"`    // overall | roi | profit | <board id>
+}
+
+// SeasonStanding is an agent's place in a season; frozen once the season completes
+type SeasonStanding struct {
+	Rank            *int      `json:"rank"`
+	AgentID         uuid.UUID `json:"agent_id"`
+	AgentName       string    `json:"agent_name"`
+	Model           string    `json:"model"`
+	StartingCapital float64   `json:"starting_capital"`
+	TotalValue      *float64  `json:"total_value"`
+	ProfitLoss      float64   `json:"profit_loss"`
+	ROI             float64   `json:"roi"`
+	WinRate         float64   `json:"win_rate"`
+	TotalTrades     int       `json:"total_trades"`
+	WinningTrades   int       `json:"winning_trades"`
+	LosingTrades    int       `json:"losing_trades"`
+	Score           float64   `json:"score"`
+	Eligible        bool      `json:"eligible"`
+	Badges          []string  `json:"badges"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SeasonDetail is a season with its standings
+type SeasonDetail struct {
+	Season    Season           `json:"season"`
+	Standings []SeasonStanding `json:"standings"`
+}
+
+// CreateSeasonRequest is the payload for scheduling a season
+type CreateSeasonRequest struct {
+	Name            string      `json:"name"`
+	StartsAt        *time.Time  `json:"starts_at"` // default: now
+	EndsAt          time.Time   `json:"ends_at"`
+	StartingCapital float64     `json:"starting_capital"` // default: 100000
+	AgentIDs        []uuid.UUID `json:"agent_ids"`
+	Rules           SeasonRules `json:"rules"`
+}
@@ -24,20 +24,28 @@ type AgentPerformanceSnapshot struct {
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
 }
 
+// AgentDailyStats is an agent's rollup of one Istanbul calendar day. Wins and
+// losses count the day's closed sells; ProfitLoss is the change of the live
+// account value. Best/worst trade and the averages are nil without sells or
+// decisions.
 type AgentDailyStats struct {
-	ID              uuid.UUID `json:"id" db:"id"`
-	AgentID         uuid.UUID `json:"agent_id" db:"agent_id"`
-	StatDate        time.Time `json:"stat_date" db:"stat_date"`
-	TradesCount     int       `json:"trades_count" db:"trades_count"`
-	Wins            int       `json:"wins" db:"wins"`
-	Losses          int       `json:"losses" db:"losses"`
-	ProfitLoss      float64   `json:"profit_loss" db:"profit_loss"`
-	VolumeTraded    float64   `json:"volume_traded" db:"volume_traded"`
-	BestTradeProfit float64   `json:"best_trade_profit" db:"best_trade_profit"`
-	WorstTradeLoss  float64   `json:"worst_trade_loss" db:"worst_trade_loss"`
-	AvgConfidence   float64   `json:"avg_confidence" db:"avg_confidence"`
-	AvgExecTimeMS   int       `json:"avg_execution_time_ms" db:"avg_execution_time_ms"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	ID              uuid.UUID  `json:"id" db:"id"`
+	AgentID         uuid.UUID  `json:"agent_id" db:"agent_id"`
+	StatDate        time.Time  `json:"stat_date" db:"stat_date"`
+	TradesCount     int        `json:"trades_count" db:"trades_count"`
+	Wins            int        `json:"wins" db:"wins"`
+	Losses          int        `json:"losses" db:"losses"`
+	ProfitLoss      float64    `json:"profit_loss" db:"profit_loss"`
+	VolumeTraded    float64    `json:"volume_traded" db:"volume_traded"`
+	BestTradeProfit *float64   `json:"best_trade_profit" db:"best_trade_profit"`
+	WorstTradeLoss  *float64   `json:"worst_trade_loss" db:"worst_trade_loss"`
+	AvgConfidence   *float64   `json:"avg_confidence" db:"avg_confidence"`
+	AvgExecTimeMS   *int       `json:"avg_execution_time_ms" db:"avg_execution_time_ms"`
+	StartValue      *float64   `json:"start_value" db:"start_value"`
+	EndValue        *float64   `json:"end_value" db:"end_value"`
+	DecisionsCount  int        `json:"decisions_count" db:"decisions_count"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       *time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type AgentMatchup struct {
@@ -7,22 +7,31 @@ import (
 )
 
 type Trade struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	AgentID     uuid.UUID `json:"agent_id" db:"agent_id"`
-	StockSymbol string    `json:"stock_symbol" db:"stock_symbol"`
-	TradeType   string    `json:"trade_type" db:"trade_type"`
-	Quantity    int       `json:"quantity" db:"quantity"`
-	Price       float64   `json:"price" db:"price"`
-	TotalAmount float64   `json:"total_amount" db:"total_amount"`
-	Commission  float64   `json:"commission" db:"commission"`
-	Reasoning   string    `json:"reasoning" db:"reasoning"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	AgentID     uuid.UUID  `json:"agent_id" db:"agent_id"`
+	StockSymbol string     `json:"stock_symbol" db:"stock_symbol"`
+	TradeType   string     `json:"trade_type" db:"trade_type"`
+	Quantity    int        `json:"quantity" db:"quantity"`
+	Price       float64    `json:"price" db:"price"`
+	TotalAmount float64    `json:"total_amount" db:"total_amount"`
+	Commission  float64    `json:"commission" db:"commission"`
+	Reasoning   string     `json:"reasoning" db:"reasoning"`
+	DecisionID  *uuid.UUID `json:"decision_id,omitempty" db:"decision_id"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 }
 
 type TradeRequest struct {
-	AgentID     uuid.UUID `json:"agent_id" validate:"required"`
-	StockSymbol string    `json:"stock_symbol" validate:"required"`
-	TradeType   string    `json:"trade_type" validate:"required,oneof=BUY SELL"`
-	Quantity    int       `json:"quantity" validate:"required,min=1"`
-	Reasoning   string    `json:"reasoning"`
+	AgentID     uuid.UUID  `json:"agent_id" validate:"required"`
+	StockSymbol string     `json:"stock_symbol" validate:"required"`
+	TradeType   string     `json:"trade_type" validate:"required,oneof=BUY SELL"`
+	Quantity    int        `json:"quantity" validate:"required,min=1"`
+	Reasoning   string     `json:"reasoning"`
+	DecisionID  *uuid.UUID `json:"decision_id,omitempty"` // decision that produced the order, if any
+}
+
+// OrderResult is the outcome of one order of a multi-order decision
+type OrderResult struct {
+	Order TradeRequest `json:"order"`
+	Trade *Trade       `json:"trade,omitempty"`
+	Error string       `json:"error,omitempty"`
 }
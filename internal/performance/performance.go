@@ -0,0 +1,353 @@
+// Package performance computes an account's performance metrics from its
+// equity history and trades. It has no database access: the leaderboard
+// loads agent_performance_snapshots and trades, the backtester passes its
+// simulated equity curve and fills.
+package performance
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TradingDaysPerYear annualizes ratios of daily returns
+const TradingDaysPerYear = 252
+
+// Point is the account's total value (cash + holdings) at a time
+type Point struct {
+	Time           time.Time
+	Equity         float64
+	PortfolioValue float64 // value of the holdings, used for exposure
+}
+
+// Fill is one executed trade
+type Fill struct {
+	Time       time.Time
+	Symbol     string
+	Side       string // "BUY" | "SELL"
+	Quantity   int
+	Price      float64
+	Commission float64
+}
+
+// ClosedTrade is a sell matched against the average cost of the position.
+// ProfitLoss is net of the sell's commission.
+type ClosedTrade struct {
+	Time       time.Time
+	Symbol     string
+	Quantity   int
+	ProfitLoss float64
+}
+
+// Metrics are the performance figures of one account. Percentages are in
+// percent (5 = 5%); ratios are annualized from daily returns with a zero
+// risk-free rate.
+type Metrics struct {
+	TimeWeightedReturn  float64       `json:"time_weighted_return"`
+	AnnualizedReturn    float64       `json:"annualized_return"`
+	SharpeRatio         float64       `json:"sharpe_ratio"`
+	SortinoRatio        float64       `json:"sortino_ratio"`
+	CalmarRatio         float64       `json:"calmar_ratio"`
+	MaxDrawdown         float64       `json:"max_drawdown"`
+	MaxDrawdownDuration time.Duration `json:"max_drawdown_duration"`
+	ClosedTrades        int           `json:"closed_trades"`
+	WinningTrades       int           `json:"winning_trades"`
+	LosingTrades        int           `json:"losing_trades"`
+	WinRate             float64       `json:"win_rate"`
+	ProfitFactor        *float64      `json:"profit_factor"` // nil without a losing trade
+	AverageWin          float64       `json:"average_win"`
+	AverageLoss         float64       `json:"average_loss"` // negative
+	Exposure            float64       `json:"exposure"`
+}
+
+// Compute returns the metrics of an account that started with initial cash.
+// The equity points must be in time order; closed trades may be in any order.
+func Compute(initial float64, equity []Point, closed []ClosedTrade) Metrics {
+	var m Metrics
+	m.TimeWeightedReturn = TimeWeightedReturn(initial, equity)
+	m.MaxDrawdown, m.MaxDrawdownDuration = MaxDrawdown(initial, equity)
+	m.Exposure = Exposure(equity)
+
+	returns := DailyReturns(initial, equity)
+	m.SharpeRatio = Sharpe(returns)
+	m.SortinoRatio = Sortino(returns)
+	if len(returns) >= 2 && m.TimeWeightedReturn > -100 {
+		m.AnnualizedReturn = (math.Pow(1+m.TimeWeightedReturn/100, TradingDaysPerYear/float64(len(returns))) - 1) * 100
+		if m.MaxDrawdown > 0 {
+			m.CalmarRatio = m.AnnualizedReturn / m.MaxDrawdown
+		}
+	}
+
+	var grossWin, grossLoss float64
+	for _, t := range closed {
+		switch {
+		case t.ProfitLoss > 0:
+			m.WinningTrades++
+			grossWin += t.ProfitLoss
+		case t.ProfitLoss < 0:
+			m.LosingTrades++
+			grossLoss -= t.ProfitLoss
+		}
+	}
+	m.ClosedTrades = len(closed)
+	if m.ClosedTrades > 0 {
+		m.WinRate = float64(m.WinningTrades) / float64(m.ClosedTrades) * 100
+	}
+	if m.WinningTrades > 0 {
+		m.AverageWin = grossWin / float64(m.WinningTrades)
+	}
+	if m.LosingTrades > 0 {
+		m.AverageLoss = -grossLoss / float64(m.LosingTrades)
+	}
+	if grossLoss > 0 {
+		pf := grossWin / grossLoss
+		m.ProfitFactor = &pf
+	}
+	return m
+}
+
+// TimeWeightedReturn chains the returns between consecutive points, starting
+// from initial, in percent. Only trades move cash in this market, so it
+// equals the simple return unless history is missing.
+func TimeWeightedReturn(initial float64, equity []Point) float64 {
+	growth, prev := 1.0, initial
+	for _, p := range equity {
+		if prev > 0 {
+			growth *= p.Equity / prev
+		}
+		prev = p.Equity
+	}
+	return (growth - 1) * 100
+}
+
+// MaxDrawdown returns the largest peak-to-trough fall in percent and the
+// longest time spent below a previous peak. A drawdown that has not
+// recovered lasts until the last point.
+func MaxDrawdown(initial float64, equity []Point) (float64, time.Duration) {
+	peak, dd := initial, 0.0
+	var peakAt time.Time
+	var longest time.Duration
+	for _, p := range equity {
+		if peakAt.IsZero() {
+			peakAt = p.Time
+		}
+		if p.Equity >= peak {
+			peak, peakAt = p.Equity, p.Time
+			continue
+		}
+		if peak > 0 {
+			dd = math.Max(dd, (peak-p.Equity)/peak*100)
+		}
+		if d := p.Time.Sub(peakAt); d > longest {
+			longest = d
+		}
+	}
+	return dd, longest
+}
+
+// DailyReturns returns the returns between the last points of consecutive
+// UTC days; the first day is measured from initial
+func DailyReturns(initial float64, equity []Point) []float64 {
+	_, closes := dailyCloses(equity)
+	prev := initial
+	returns := make([]float64, 0, len(closes))
+	for _, c := range closes {
+		if prev > 0 {
+			returns = append(returns, c/prev-1)
+		}
+		prev = c
+	}
+	return returns
+}
+
+// dailyCloses returns each UTC day that has a point and its last equity
+func dailyCloses(equity []Point) ([]time.Time, []float64) {
+	var days []time.Time
+	var closes []float64
+	for _, p := range equity {
+		d := p.Time.UTC().Truncate(24 * time.Hour)
+		if len(days) > 0 && d.Equal(days[len(days)-1]) {
+			closes[len(closes)-1] = p.Equity
+			continue
+		}
+		days = append(days, d)
+		closes = append(closes, p.Equity)
+	}
+	return days, closes
+}
+
+// Sharpe returns the annualized mean over standard deviation of daily returns
+func Sharpe(returns []float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+	mean := mean(returns)
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std == 0 {
+		return 0
+	}
+	return mean / std * math.Sqrt(TradingDaysPerYear)
+}
+
+// Sortino is Sharpe with the downside deviation (losses only) in place of
+// the standard deviation
+func Sortino(returns []float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+	var downside float64
+	for _, r := range returns {
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	dev := math.Sqrt(downside / float64(len(returns)))
+	if dev == 0 {
+		return 0
+	}
+	return mean(returns) / dev * math.Sqrt(TradingDaysPerYear)
+}
+
+// Exposure returns the time-weighted share of equity held in stocks, in
+// percent. Each point's share holds until the next point.
+func Exposure(equity []Point) float64 {
+	var weighted, total float64
+	for i := 0; i+1 < len(equity); i++ {
+		p := equity[i]
+		dt := equity[i+1].Time.Sub(p.Time).Seconds()
+		if dt <= 0 || p.Equity <= 0 {
+			continue
+		}
+		weighted += p.PortfolioValue / p.Equity * dt
+		total += dt
+	}
+	if total == 0 {
+		return 0
+	}
+	return weighted / total * 100
+}
+
+// Relative compares an account with a benchmark. Alpha and tracking error
+// are annualized percentages; beta is the sensitivity of the account's daily
+// return to the benchmark's.
+type Relative struct {
+	Alpha            float64 `json:"alpha"`
+	Beta             float64 `json:"beta"`
+	TrackingError    float64 `json:"tracking_error"`
+	InformationRatio float64 `json:"information_ratio"`
+	Days             int     `json:"days"` // daily returns compared
+}
+
+// CompareDaily compares the daily returns of an account and a benchmark on
+// the UTC days both curves have a point. At least two returns are needed;
+// otherwise the zero Relative is returned.
+func CompareDaily(account, benchmark []Point) Relative {
+	aDays, aCloses := dailyCloses(account)
+	bDays, bCloses := dailyCloses(benchmark)
+
+	var ra, rb []float64
+	var prevA, prevB float64
+	matched := false
+	for i, j := 0, 0; i < len(aDays) && j < len(bDays); {
+		switch {
+		case aDays[i].Before(bDays[j]):
+			i++
+		case bDays[j].Before(aDays[i]):
+			j++
+		default:
+			if matched && prevA > 0 && prevB > 0 {
+				ra = append(ra, aCloses[i]/prevA-1)
+				rb = append(rb, bCloses[j]/prevB-1)
+			}
+			prevA, prevB, matched = aCloses[i], bCloses[j], true
+			i++
+			j++
+		}
+	}
+	return Compare(ra, rb)
+}
+
+// Compare computes the relative metrics of aligned return series
+func Compare(account, benchmark []float64) Relative {
+	n := len(account)
+	if n < 2 || len(benchmark) != n {
+		return Relative{}
+	}
+	meanA, meanB := mean(account), mean(benchmark)
+	var cov, varB float64
+	active := make([]float64, n)
+	for i := range account {
+		cov += (account[i] - meanA) * (benchmark[i] - meanB)
+		varB += (benchmark[i] - meanB) * (benchmark[i] - meanB)
+		active[i] = account[i] - benchmark[i]
+	}
+
+	r := Relative{Days: n}
+	if varB > 0 {
+		r.Beta = cov / varB
+	}
+	r.Alpha = (meanA - r.Beta*meanB) * TradingDaysPerYear * 100
+
+	meanActive := mean(active)
+	var v float64
+	for _, x := range active {
+		v += (x - meanActive) * (x - meanActive)
+	}
+	std := math.Sqrt(v / float64(n-1))
+	r.TrackingError = std * math.Sqrt(TradingDaysPerYear) * 100
+	if std > 0 {
+		r.InformationRatio = meanActive / std * math.Sqrt(TradingDaysPerYear)
+	}
+	return r
+}
+
+// position is an open position at average cost
+type position struct {
+	quantity int
+	cost     float64
+}
+
+// ClosedTrades matches every sell against the average cost of the position
+// it reduces, the way the portfolio tracks total_invested. Sells beyond the
+// known position (e.g. history before the trades loaded) are ignored.
+func ClosedTrades(fills []Fill) []ClosedTrade {
+	fills = append([]Fill(nil), fills...)
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time.Before(fills[j].Time) })
+
+	positions := map[string]*position{}
+	var out []ClosedTrade
+	for _, f := range fills {
+		p := positions[f.Symbol]
+		if p == nil {
+			p = &position{}
+			positions[f.Symbol] = p
+		}
+		amount := f.Price * float64(f.Quantity)
+		switch f.Side {
+		case "BUY":
+			p.quantity += f.Quantity
+			p.cost += amount
+		case "SELL":
+			if p.quantity <= 0 || f.Quantity > p.quantity {
+				continue
+			}
+			cost := p.cost * float64(f.Quantity) / float64(p.quantity)
+			p.quantity -= f.Quantity
+			p.cost -= cost
+			out = append(out, ClosedTrade{Time: f.Time, Symbol: f.Symbol, Quantity: f.Quantity, ProfitLoss: amount - f.Commission - cost})
+		}
+	}
+	return out
+}
+
+func mean(xs []float64) float64 {
+	var s float64
+	for _, x := range xs {
+		s += x
+	}
+	return s / float64(len(xs))
+}
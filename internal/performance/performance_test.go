@@ -0,0 +1,139 @@
+package performance
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var day0 = time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+
+func curve(values ...float64) []Point {
+	out := make([]Point, len(values))
+	for i, v := range values {
+		out[i] = Point{Time: day0.AddDate(0, 0, i), Equity: v}
+	}
+	return out
+}
+
+func TestMaxDrawdownAndDuration(t *testing.T) {
+	dd, d := MaxDrawdown(100, curve(110, 99, 105, 111, 108))
+	if math.Abs(dd-10) > 1e-9 {
+		t.Errorf("drawdown = %v, want 10", dd)
+	}
+	// below the 110 peak from day 0 until day 3
+	if d != 2*24*time.Hour {
+		t.Errorf("duration = %v, want 48h", d)
+	}
+	if _, d := MaxDrawdown(100, curve(100, 90, 80, 85)); d != 3*24*time.Hour {
+		t.Errorf("unrecovered duration = %v, want 72h", d)
+	}
+}
+
+func TestTimeWeightedReturn(t *testing.T) {
+	if r := TimeWeightedReturn(100, curve(110, 99, 121)); math.Abs(r-21) > 1e-9 {
+		t.Errorf("twr = %v, want 21", r)
+	}
+	if r := TimeWeightedReturn(100, nil); r != 0 {
+		t.Errorf("empty twr = %v", r)
+	}
+}
+
+func TestDailyReturnsUseLastPointOfDay(t *testing.T) {
+	eq := []Point{
+		{Time: day0, Equity: 90},
+		{Time: day0.Add(time.Hour), Equity: 110},
+		{Time: day0.Add(24 * time.Hour), Equity: 121},
+	}
+	r := DailyReturns(100, eq)
+	if len(r) != 2 || math.Abs(r[0]-0.1) > 1e-9 || math.Abs(r[1]-0.1) > 1e-9 {
+		t.Errorf("returns = %v", r)
+	}
+}
+
+func TestSharpeAndSortino(t *testing.T) {
+	returns := []float64{0.01, -0.01, 0.02, 0.00}
+	if s := Sharpe(returns); s <= 0 {
+		t.Errorf("sharpe = %v, want > 0", s)
+	}
+	// downside deviation is sqrt(0.0001/4) = 0.005, mean 0.005
+	if s := Sortino(returns); math.Abs(s-math.Sqrt(TradingDaysPerYear)) > 1e-9 {
+		t.Errorf("sortino = %v, want sqrt(252)", s)
+	}
+	if s := Sortino([]float64{0.01, 0.02}); s != 0 {
+		t.Errorf("sortino without losses = %v, want 0", s)
+	}
+	if s := Sharpe([]float64{0.01}); s != 0 {
+		t.Errorf("sharpe of one return = %v, want 0", s)
+	}
+}
+
+func TestExposureIsTimeWeighted(t *testing.T) {
+	eq := []Point{
+		{Time: day0, Equity: 100, PortfolioValue: 0},
+		{Time: day0.Add(3 * time.Hour), Equity: 100, PortfolioValue: 50},
+		{Time: day0.Add(4 * time.Hour), Equity: 100, PortfolioValue: 100},
+	}
+	if e := Exposure(eq); math.Abs(e-12.5) > 1e-9 {
+		t.Errorf("exposure = %v, want 12.5", e)
+	}
+}
+
+func TestClosedTradesUseAverageCost(t *testing.T) {
+	fills := []Fill{
+		{Time: day0.Add(2 * time.Hour), Symbol: "AAA", Side: "SELL", Quantity: 10, Price: 13, Commission: 1},
+		{Time: day0, Symbol: "AAA", Side: "BUY", Quantity: 10, Price: 10},
+		{Time: day0.Add(time.Hour), Symbol: "AAA", Side: "BUY", Quantity: 10, Price: 12},
+		{Time: day0.Add(3 * time.Hour), Symbol: "AAA", Side: "SELL", Quantity: 10, Price: 10},
+		{Time: day0.Add(4 * time.Hour), Symbol: "BBB", Side: "SELL", Quantity: 5, Price: 10},
+	}
+	closed := ClosedTrades(fills)
+	if len(closed) != 2 {
+		t.Fatalf("closed = %+v, want 2 sells", closed)
+	}
+	// average cost 11: (13-11)*10 - 1 commission, then (10-11)*10
+	if math.Abs(closed[0].ProfitLoss-19) > 1e-9 || math.Abs(closed[1].ProfitLoss+10) > 1e-9 {
+		t.Errorf("closed = %+v", closed)
+	}
+}
+
+func TestComputeTradeStatistics(t *testing.T) {
+	closed := []ClosedTrade{{ProfitLoss: 30}, {ProfitLoss: 10}, {ProfitLoss: -20}, {ProfitLoss: 0}}
+	m := Compute(100, curve(105, 100, 110), closed)
+	if m.ClosedTrades != 4 || m.WinningTrades != 2 || m.LosingTrades != 1 || m.WinRate != 50 {
+		t.Errorf("counts = %+v", m)
+	}
+	if m.AverageWin != 20 || m.AverageLoss != -20 || m.ProfitFactor == nil || *m.ProfitFactor != 2 {
+		t.Errorf("averages = %+v", m)
+	}
+	if math.Abs(m.TimeWeightedReturn-10) > 1e-9 || m.MaxDrawdown <= 0 || m.CalmarRatio <= 0 || m.SharpeRatio <= 0 {
+		t.Errorf("returns = %+v", m)
+	}
+	if m := Compute(100, nil, []ClosedTrade{{ProfitLoss: 5}}); m.ProfitFactor != nil || m.WinRate != 100 {
+		t.Errorf("no losses = %+v", m)
+	}
+}
+
+func TestCompareDaily(t *testing.T) {
+	bench := curve(100, 101, 99, 102, 100)
+	// twice the benchmark's daily moves plus 0.1% a day
+	account := []Point{{Time: day0, Equity: 1000}}
+	prev := 1000.0
+	for i := 1; i < len(bench); i++ {
+		prev *= 1 + 2*(bench[i].Equity/bench[i-1].Equity-1) + 0.001
+		account = append(account, Point{Time: day0.AddDate(0, 0, i).Add(time.Hour), Equity: prev})
+	}
+	// a benchmark day the account has no point for is skipped
+	bench = append(bench, Point{Time: day0.AddDate(0, 0, 10), Equity: 90})
+
+	r := CompareDaily(account, bench)
+	if r.Days != 4 || math.Abs(r.Beta-2) > 1e-9 || math.Abs(r.Alpha-0.1*TradingDaysPerYear) > 1e-6 {
+		t.Errorf("relative = %+v, want beta 2, alpha 25.2%%", r)
+	}
+	if r.TrackingError <= 0 || r.InformationRatio == 0 {
+		t.Errorf("relative = %+v", r)
+	}
+	if r := CompareDaily(account[:2], bench); r != (Relative{}) {
+		t.Errorf("one return = %+v, want zero", r)
+	}
+}
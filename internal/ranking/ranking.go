@@ -0,0 +1,269 @@
+// Package ranking scores agents for the leaderboard. A board is a named,
+// weighted blend of risk-adjusted metrics (Sharpe, ROI, drawdown, calibration,
+// cost efficiency) plus the minimum activity an agent needs to be ranked on
+// merit. Each metric is scaled to 0..1 across the agents being ranked, so the
+// weights compare like with like. The default boards are embedded
+// (boards.yaml); a file in the same format replaces them.
+package ranking
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// DefaultHorizon is the calibration horizon of boards that don't name one
+	DefaultHorizon = "1d"
+	// DefaultMinSamples is the number of scored decisions a Brier score needs by default
+	DefaultMinSamples = 10
+	// NoSkillBrier is the Brier score of always answering 50%; agents without
+	// enough scored decisions are calibrated as if they had no skill
+	NoSkillBrier = 0.25
+)
+
+// ErrInvalidBoards is returned for board sets that fail validation
+var ErrInvalidBoards = errors.New("invalid leaderboard boards")
+
+//go:embed boards.yaml
+var defaultBoards []byte
+
+// Weights of the score components; only their ratios matter
+type Weights struct {
+	Sharpe         float64 `yaml:"sharpe,omitempty" json:"sharpe"`
+	ROI            float64 `yaml:"roi,omitempty" json:"roi"`
+	Drawdown       float64 `yaml:"drawdown,omitempty" json:"drawdown"`               // lower max drawdown scores higher
+	Calibration    float64 `yaml:"calibration,omitempty" json:"calibration"`         // lower Brier score scores higher
+	CostEfficiency float64 `yaml:"cost_efficiency,omitempty" json:"cost_efficiency"` // lower commissions per capital score higher
+}
+
+func (w Weights) sum() float64 {
+	return w.Sharpe + w.ROI + w.Drawdown + w.Calibration + w.CostEfficiency
+}
+
+// Eligibility is the activity an agent needs before it is ranked on its
+// score; agents short of it are listed after every eligible agent
+type Eligibility struct {
+	MinTrades       int `yaml:"min_trades,omitempty" json:"min_trades"`
+	MinClosedTrades int `yaml:"min_closed_trades,omitempty" json:"min_closed_trades"`
+	MinActiveDays   int `yaml:"min_active_days,omitempty" json:"min_active_days"` // Istanbul days with at least one trade
+}
+
+// Board is one named leaderboard
+type Board struct {
+	ID          string      `yaml:"id" json:"id"`
+	Name        string      `yaml:"name" json:"name"`
+	Description string      `yaml:"description" json:"description"`
+	Weights     Weights     `yaml:"weights" json:"weights"`
+	Eligibility Eligibility `yaml:"eligibility" json:"eligibility"`
+	Horizon     string      `yaml:"horizon,omitempty" json:"horizon"`         // calibration horizon: 1h | 1d | 5d
+	MinSamples  int         `yaml:"min_samples,omitempty" json:"min_samples"` // scored decisions a Brier score needs
+}
+
+type boardFile struct {
+	Boards []Board `yaml:"boards"`
+}
+
+// Parse reads a YAML board set and validates it
+func Parse(data []byte) ([]Board, error) {
+	var f boardFile
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBoards, err)
+	}
+	if err := Validate(f.Boards); err != nil {
+		return nil, err
+	}
+	return f.Boards, nil
+}
+
+// Load reads the board file at path, or the embedded boards if path is empty
+func Load(path string) ([]Board, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Default returns the embedded boards
+func Default() []Board {
+	boards, err := Parse(defaultBoards)
+	if err != nil {
+		panic(err) // boards.yaml is part of the binary; failing to parse it is a build bug
+	}
+	return boards
+}
+
+// Validate checks every board and fills in the calibration defaults. The
+// first board is the default one, so at least one is required.
+func Validate(boards []Board) error {
+	if len(boards) == 0 {
+		return fmt.Errorf("%w: at least one board is required", ErrInvalidBoards)
+	}
+	seen := make(map[string]bool, len(boards))
+	for i := range boards {
+		b := &boards[i]
+		b.ID = strings.TrimSpace(b.ID)
+		if b.ID == "" || seen[b.ID] || len(b.ID) > 50 {
+			return fmt.Errorf("%w: board %d needs a unique id of at most 50 characters", ErrInvalidBoards, i+1)
+		}
+		seen[b.ID] = true
+		if strings.TrimSpace(b.Name) == "" {
+			return fmt.Errorf("%w: %s: name is required", ErrInvalidBoards, b.ID)
+		}
+		w := b.Weights
+		if w.Sharpe < 0 || w.ROI < 0 || w.Drawdown < 0 || w.Calibration < 0 || w.CostEfficiency < 0 || w.sum() <= 0 {
+			return fmt.Errorf("%w: %s: weights must be non-negative with a positive sum", ErrInvalidBoards, b.ID)
+		}
+		e := b.Eligibility
+		if e.MinTrades < 0 || e.MinClosedTrades < 0 || e.MinActiveDays < 0 {
+			return fmt.Errorf("%w: %s: eligibility minimums must be non-negative", ErrInvalidBoards, b.ID)
+		}
+		if b.MinSamples < 0 {
+			return fmt.Errorf("%w: %s: min_samples must be non-negative", ErrInvalidBoards, b.ID)
+		}
+		if b.Horizon == "" {
+			b.Horizon = DefaultHorizon
+		}
+		if b.MinSamples == 0 {
+			b.MinSamples = DefaultMinSamples
+		}
+	}
+	return nil
+}
+
+// Input is what an agent is ranked on
+type Input struct {
+	AgentID      uuid.UUID
+	ROI          float64  // %
+	Sharpe       float64  // annualized, from daily returns
+	MaxDrawdown  float64  // %
+	Brier        *float64 // nil without enough scored decisions
+	CostDrag     float64  // commissions paid, % of starting capital
+	Trades       int
+	ClosedTrades int
+	ActiveDays   int
+}
+
+// Components are an agent's scaled metrics, 0 (worst) to 1 (best) among the ranked agents
+type Components struct {
+	Sharpe         float64 `json:"sharpe"`
+	ROI            float64 `json:"roi"`
+	Drawdown       float64 `json:"drawdown"`
+	Calibration    float64 `json:"calibration"`
+	CostEfficiency float64 `json:"cost_efficiency"`
+}
+
+// Result is an agent's place on a board
+type Result struct {
+	AgentID    uuid.UUID  `json:"agent_id"`
+	Rank       int        `json:"rank"`
+	Score      float64    `json:"score"` // 0..100
+	Eligible   bool       `json:"eligible"`
+	Components Components `json:"components"`
+}
+
+// Eligible reports whether the agent meets the board's minimum activity
+func (b Board) Eligible(in Input) bool {
+	e := b.Eligibility
+	return in.Trades >= e.MinTrades && in.ClosedTrades >= e.MinClosedTrades && in.ActiveDays >= e.MinActiveDays
+}
+
+// Rank scores and ranks the inputs on the board: eligible agents first, then
+// by score. Equal scores share a rank. Metrics are scaled over the eligible
+// agents (all of them if none is), so an ineligible outlier can't stretch the
+// scale the eligible agents are compared on.
+func Rank(b Board, inputs []Input) []Result {
+	eligible := make([]bool, len(inputs))
+	var scaleOn []Input
+	for i, in := range inputs {
+		eligible[i] = b.Eligible(in)
+		if eligible[i] {
+			scaleOn = append(scaleOn, in)
+		}
+	}
+	if len(scaleOn) == 0 {
+		scaleOn = inputs
+	}
+
+	brier := func(in Input) float64 {
+		if in.Brier == nil {
+			return NoSkillBrier
+		}
+		return *in.Brier
+	}
+	sharpe := newScale(scaleOn, func(in Input) float64 { return in.Sharpe }, true)
+	roi := newScale(scaleOn, func(in Input) float64 { return in.ROI }, true)
+	drawdown := newScale(scaleOn, func(in Input) float64 { return in.MaxDrawdown }, false)
+	calibration := newScale(scaleOn, brier, false)
+	cost := newScale(scaleOn, func(in Input) float64 { return in.CostDrag }, false)
+
+	w := b.Weights
+	results := make([]Result, len(inputs))
+	for i, in := range inputs {
+		c := Components{
+			Sharpe:         sharpe.of(in.Sharpe),
+			ROI:            roi.of(in.ROI),
+			Drawdown:       drawdown.of(in.MaxDrawdown),
+			Calibration:    calibration.of(brier(in)),
+			CostEfficiency: cost.of(in.CostDrag),
+		}
+		score := (w.Sharpe*c.Sharpe + w.ROI*c.ROI + w.Drawdown*c.Drawdown +
+			w.Calibration*c.Calibration + w.CostEfficiency*c.CostEfficiency) / w.sum() * 100
+		results[i] = Result{AgentID: in.AgentID, Score: math.Round(score*1e4) / 1e4, Eligible: eligible[i], Components: c}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Eligible != results[j].Eligible {
+			return results[i].Eligible
+		}
+		return results[i].Score > results[j].Score
+	})
+	for i := range results {
+		if i > 0 && results[i].Eligible == results[i-1].Eligible && results[i].Score == results[i-1].Score {
+			results[i].Rank = results[i-1].Rank
+		} else {
+			results[i].Rank = i + 1
+		}
+	}
+	return results
+}
+
+// scale maps a metric linearly onto 0..1 between the lowest and highest value
+type scale struct {
+	lo, hi       float64
+	higherBetter bool
+}
+
+func newScale(inputs []Input, metric func(Input) float64, higherBetter bool) scale {
+	s := scale{lo: math.Inf(1), hi: math.Inf(-1), higherBetter: higherBetter}
+	for _, in := range inputs {
+		v := metric(in)
+		s.lo = math.Min(s.lo, v)
+		s.hi = math.Max(s.hi, v)
+	}
+	return s
+}
+
+func (s scale) of(v float64) float64 {
+	if !(s.hi > s.lo) {
+		return 1 // everyone is equally good at it
+	}
+	x := math.Max(0, math.Min(1, (v-s.lo)/(s.hi-s.lo)))
+	if !s.higherBetter {
+		x = 1 - x
+	}
+	return x
+}
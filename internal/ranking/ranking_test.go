@@ -0,0 +1,99 @@
+package ranking
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDefaultBoardsParse(t *testing.T) {
+	boards := Default()
+	if len(boards) == 0 {
+		t.Fatal("no default boards")
+	}
+	for _, b := range boards {
+		if b.Horizon == "" || b.MinSamples <= 0 {
+			t.Errorf("%s: calibration defaults not filled in: %q, %d", b.ID, b.Horizon, b.MinSamples)
+		}
+	}
+}
+
+func TestParseRejectsInvalidBoards(t *testing.T) {
+	cases := map[string]string{
+		"no boards":        "boards: []",
+		"unknown field":    "boards:\n  - {id: a, name: A, weights: {roi: 1}, bonus: 5}",
+		"unknown weight":   "boards:\n  - {id: a, name: A, weights: {alpha: 1}}",
+		"duplicate id":     "boards:\n  - {id: a, name: A, weights: {roi: 1}}\n  - {id: a, name: B, weights: {roi: 1}}",
+		"missing name":     "boards:\n  - {id: a, weights: {roi: 1}}",
+		"zero weights":     "boards:\n  - {id: a, name: A}",
+		"negative weight":  "boards:\n  - {id: a, name: A, weights: {roi: 1, drawdown: -0.5}}",
+		"negative minimum": "boards:\n  - {id: a, name: A, weights: {roi: 1}, eligibility: {min_trades: -1}}",
+	}
+	for name, yaml := range cases {
+		if _, err := Parse([]byte(yaml)); !errors.Is(err, ErrInvalidBoards) {
+			t.Errorf("%s: err = %v, want ErrInvalidBoards", name, err)
+		}
+	}
+}
+
+func TestRankPutsIneligibleAgentsLast(t *testing.T) {
+	board := Board{ID: "test", Name: "Test", Weights: Weights{ROI: 1}, Eligibility: Eligibility{MinClosedTrades: 3}}
+	lucky := Input{AgentID: uuid.New(), ROI: 40, Trades: 2, ClosedTrades: 1, ActiveDays: 1}
+	steady := Input{AgentID: uuid.New(), ROI: 8, Trades: 20, ClosedTrades: 9, ActiveDays: 6}
+	slow := Input{AgentID: uuid.New(), ROI: 2, Trades: 12, ClosedTrades: 5, ActiveDays: 6}
+
+	got := Rank(board, []Input{lucky, slow, steady})
+	want := []struct {
+		id       uuid.UUID
+		rank     int
+		eligible bool
+	}{{steady.AgentID, 1, true}, {slow.AgentID, 2, true}, {lucky.AgentID, 3, false}}
+	for i, w := range want {
+		if got[i].AgentID != w.id || got[i].Rank != w.rank || got[i].Eligible != w.eligible {
+			t.Errorf("place %d = %+v, want agent %s rank %d eligible %v", i, got[i], w.id, w.rank, w.eligible)
+		}
+	}
+	// The scale comes from the eligible agents; the lucky agent's 40% is clamped
+	if got[0].Score != 100 || got[1].Score != 0 || got[2].Score != 100 {
+		t.Errorf("scores = %v, %v, %v, want 100, 0, 100", got[0].Score, got[1].Score, got[2].Score)
+	}
+}
+
+func TestRankWeightsComponents(t *testing.T) {
+	board := Board{ID: "test", Name: "Test", Weights: Weights{Sharpe: 3, Drawdown: 1}}
+	// a: best Sharpe, worst drawdown; b: the opposite
+	a := Input{AgentID: uuid.New(), Sharpe: 2, MaxDrawdown: 20}
+	b := Input{AgentID: uuid.New(), Sharpe: 0.5, MaxDrawdown: 5}
+
+	got := Rank(board, []Input{b, a})
+	if got[0].AgentID != a.AgentID || got[0].Score != 75 || got[1].Score != 25 {
+		t.Fatalf("got %+v, want a first with 75 and b with 25", got)
+	}
+	if c := got[1].Components; c.Sharpe != 0 || c.Drawdown != 1 {
+		t.Errorf("b components = %+v, want sharpe 0 and drawdown 1", c)
+	}
+}
+
+func TestRankTreatsMissingCalibrationAsNoSkill(t *testing.T) {
+	board := Board{ID: "test", Name: "Test", Weights: Weights{Calibration: 1}}
+	good, bad := 0.1, 0.4
+	calibrated := Input{AgentID: uuid.New(), Brier: &good}
+	unknown := Input{AgentID: uuid.New()}
+	overconfident := Input{AgentID: uuid.New(), Brier: &bad}
+
+	got := Rank(board, []Input{overconfident, unknown, calibrated})
+	if got[0].AgentID != calibrated.AgentID || got[1].AgentID != unknown.AgentID || got[2].AgentID != overconfident.AgentID {
+		t.Errorf("order = %v, %v, %v", got[0].AgentID, got[1].AgentID, got[2].AgentID)
+	}
+}
+
+func TestRankSharesTies(t *testing.T) {
+	board := Board{ID: "test", Name: "Test", Weights: Weights{ROI: 1}}
+	inputs := []Input{{AgentID: uuid.New(), ROI: 5}, {AgentID: uuid.New(), ROI: 5}, {AgentID: uuid.New(), ROI: 1}}
+
+	got := Rank(board, inputs)
+	if got[0].Rank != 1 || got[1].Rank != 1 || got[2].Rank != 3 {
+		t.Errorf("ranks = %d, %d, %d, want 1, 1, 3", got[0].Rank, got[1].Rank, got[2].Rank)
+	}
+}
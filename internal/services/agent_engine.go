@@ -3,7 +3,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,9 +17,17 @@ import (
 	"github.com/1batu/market-ai/internal/ai"
 	"github.com/1batu/market-ai/internal/datasources/fusion"
 	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
 	"github.com/1batu/market-ai/internal/websocket"
 )
 
+// Prompta eklenen mumlar: en fazla 3 sembolün son 5 adet 15 dakikalık mumu
+const (
+	PromptCandleTimeframe  = "15m"
+	PromptCandleSymbols    = 3
+	PromptCandlesPerSymbol = 5
+)
+
 // AgentEngine ajanlar için otonom ticareti düzenler
 type AgentEngine struct {
 	db             *pgxpool.Pool
@@ -32,6 +43,52 @@ type AgentEngine struct {
 	// v0.5 bağlam
 	fusionService  *fusion.Service
 	contextSymbols []string
+
+	prompts           *ai.PromptRegistry
+	promptTokenBudget int // karar promptu için token bütçesi (0 = yalnızca bağlam penceresi)
+	experiments       *ExperimentService
+	memory            *MemoryService
+	proposals         *ProposalService
+	clock             simulation.Clock // geçmiş oynatmada sanal saat (nil = duvar saati)
+}
+
+// decisionMeta bir kararın hangi prompt ile nasıl üretildiğine dair kayıt bilgileri
+type decisionMeta struct {
+	PromptSet    *ai.PromptSet
+	Experiment   *ExperimentAssignment
+	PromptTokens int
+
+	// Denetim kaydı: modele giden promptlar, yanıt süresi ve kararın dayandığı piyasa görüntüsü
+	SystemPrompt string
+	Prompt       string
+	Latency      time.Duration
+	Provider     string
+	Model        string
+	Snapshot     models.MarketSnapshot
+}
+
+// activeAgent bir karar döngüsünde işlenen ajanın özet bilgisi
+type activeAgent struct {
+	ID           uuid.UUID
+	Name         string
+	Balance      float64
+	PromptSet    string
+	Strategy     string
+	ApprovalMode bool   // işlemler insan onayı bekler
+	Ledger       Ledger // gölge ajanlar kağıt üzerindeki defterde işlem yapar
+}
+
+// tradeEvent gerçekleşen işlemin yayın adını döndürür; gölge işlemler canlı akışa karışmaz
+func (a activeAgent) tradeEvent() string {
+	if a.Ledger.IsShadow() {
+		return "shadow_trade_executed"
+	}
+	return "trade_executed"
+}
+
+// needsApproval ajanın işlemleri insan onayı bekliyor mu (gölge işlemler onaysız simüle edilir)
+func (a activeAgent) needsApproval() bool {
+	return a.ApprovalMode && !a.Ledger.IsShadow()
 }
 
 // NewAgentEngine yeni bir ajan motoru oluşturur
@@ -54,6 +111,7 @@ func NewAgentEngine(
 		aiClients:      make(map[uuid.UUID]ai.Client),
 		minInterval:    minInterval,
 		maxInterval:    maxInterval,
+		prompts:        ai.DefaultPrompts(),
 	}
 }
 
@@ -63,6 +121,31 @@ func (ae *AgentEngine) SetFusionService(fs *fusion.Service) { ae.fusionService =
 // SetContextSymbols piyasa bağlamı için kullanılacak sembolleri yapılandırır
 func (ae *AgentEngine) SetContextSymbols(symbols []string) { ae.contextSymbols = symbols }
 
+// SetPromptRegistry prompt şablonlarının seçileceği kayıt defterini enjekte eder
+func (ae *AgentEngine) SetPromptRegistry(r *ai.PromptRegistry) { ae.prompts = r }
+
+// SetPromptTokenBudget karar promptunun tahmini token üst sınırını ayarlar
+func (ae *AgentEngine) SetPromptTokenBudget(tokens int) { ae.promptTokenBudget = tokens }
+
+// SetExperimentService prompt A/B deneylerinin varyant atamasını etkinleştirir
+func (ae *AgentEngine) SetExperimentService(es *ExperimentService) { ae.experiments = es }
+
+// SetMemoryService geçmiş derslerin ve yansıma notlarının prompta eklenmesini etkinleştirir
+func (ae *AgentEngine) SetMemoryService(ms *MemoryService) { ae.memory = ms }
+
+// SetProposalService onay modundaki ajanların kararlarını öneriye çevirmeyi etkinleştirir
+func (ae *AgentEngine) SetProposalService(ps *ProposalService) { ae.proposals = ps }
+
+// SetClock ajanların piyasayı bu saatin anında görmesini sağlar (geçmiş oynatma):
+// haberler o ana kadar yayınlananlarla sınırlanır, prompttaki yaşlar ona göre hesaplanır
+func (ae *AgentEngine) SetClock(c simulation.Clock) { ae.clock = c }
+
+// Client ajana kayıtlı YZ istemcisini döndürür
+func (ae *AgentEngine) Client(agentID uuid.UUID) (ai.Client, bool) {
+	c, ok := ae.aiClients[agentID]
+	return c, ok
+}
+
 // RegisterAgent bir ajan için YZ istemcisi kaydeder
 func (ae *AgentEngine) RegisterAgent(agentID uuid.UUID, client ai.Client) {
 	ae.aiClients[agentID] = client
@@ -99,8 +182,16 @@ func (ae *AgentEngine) Start(ctx context.Context) {
 
 // processAllAgents tüm aktif ajanlar için kararlar verir
 func (ae *AgentEngine) processAllAgents(ctx context.Context) {
-	// Tüm aktif ajanları al
-	query := `SELECT id, name, model, current_balance FROM agents WHERE status = 'active'`
+	// Tüm aktif ajanları (prompt seti ve stratejileriyle) al
+	query := `
+		SELECT a.id, a.name, a.status,
+		       CASE WHEN a.status = 'shadow' THEN COALESCE(sa.current_balance, a.initial_balance) ELSE a.current_balance END,
+		       COALESCE(a.prompt_set, ''), COALESCE(s.strategy_type, 'balanced'),
+		       COALESCE(a.approval_mode, FALSE)
+		FROM agents a
+		LEFT JOIN agent_strategies s ON s.agent_id = a.id AND s.is_active
+		LEFT JOIN shadow_accounts sa ON sa.agent_id = a.id
+		WHERE a.status IN ('active', 'shadow')`
 	rows, err := ae.db.Query(ctx, query)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch active agents")
@@ -109,35 +200,29 @@ func (ae *AgentEngine) processAllAgents(ctx context.Context) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var agentID uuid.UUID
-		var agentName, model string
-		var balance float64
-
-		if err := rows.Scan(&agentID, &agentName, &model, &balance); err != nil {
+		var agent activeAgent
+		var status string
+		if err := rows.Scan(&agent.ID, &agent.Name, &status, &agent.Balance, &agent.PromptSet, &agent.Strategy, &agent.ApprovalMode); err != nil {
 			log.Error().Err(err).Msg("Failed to scan agent")
 			continue
 		}
+		agent.Ledger = LedgerFor(status)
 
 		// YZ istemcisinin var olup olmadığını kontrol et
-		aiClient, exists := ae.aiClients[agentID]
+		aiClient, exists := ae.aiClients[agent.ID]
 		if !exists {
-			log.Warn().Str("agent_id", agentID.String()).Msg("No AI client registered")
+			log.Warn().Str("agent_id", agent.ID.String()).Msg("No AI client registered")
 			continue
 		}
 
 		// Goroutine içinde işle
-		go ae.processAgentDecision(ctx, agentID, agentName, balance, aiClient)
+		go ae.processAgentDecision(ctx, agent, aiClient)
 	}
 }
 
 // processAgentDecision tek bir ajan için ticaret kararı verir
-func (ae *AgentEngine) processAgentDecision(
-	ctx context.Context,
-	agentID uuid.UUID,
-	agentName string,
-	balance float64,
-	aiClient ai.Client,
-) {
+func (ae *AgentEngine) processAgentDecision(ctx context.Context, agent activeAgent, aiClient ai.Client) {
+	agentID, agentName := agent.ID, agent.Name
 	log.Debug().Str("agent", agentName).Msg("Processing agent decision")
 
 	// "Düşünüyor" durumunu yayınla
@@ -148,31 +233,92 @@ func (ae *AgentEngine) processAgentDecision(
 	})
 
 	// Karar için veri topla
-	decisionReq, err := ae.gatherDecisionData(ctx, agentID, agentName, balance)
+	decisionReq, err := ae.gatherDecisionData(ctx, agentID, agentName, agent.Balance, agent.Ledger)
 	if err != nil {
 		log.Error().Err(err).Str("agent", agentName).Msg("Failed to gather decision data")
 		return
 	}
 
-	// Prompt oluştur
-	prompt := ai.BuildDecisionPrompt(decisionReq)
+	promptSetName, strategy := agent.PromptSet, agent.Strategy
+
+	// Çalışan bir deney varsa varyantın prompt seti/stratejisi ajanınkini ezer
+	var assignment *ExperimentAssignment
+	if ae.experiments != nil {
+		if a, err := ae.experiments.Assign(ctx, agentID); err != nil {
+			log.Warn().Err(err).Str("agent", agentName).Msg("Experiment assignment failed, using agent defaults")
+		} else if a != nil {
+			assignment = a
+			if a.Variant.PromptSet != "" {
+				promptSetName = a.Variant.PromptSet
+			}
+			if a.Variant.Strategy != "" {
+				strategy = a.Variant.Strategy
+			}
+		}
+	}
+	decisionReq.Strategy = strategy
+
+	// Ajanın/stratejinin prompt setine göre promptları oluştur
+	promptSet := ae.prompts.Select(promptSetName, strategy)
+	systemPrompt, err := promptSet.RenderSystem(decisionReq)
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to render system prompt")
+		return
+	}
+
+	// Karar promptunu modelin token bütçesine sığacak şekilde oluştur
+	model := aiClient.GetModelName()
+	systemTokens := ai.EstimateTokens(model, systemPrompt)
+	rendered, err := promptSet.BuildDecision(decisionReq, model, ai.PromptTokenLimit(model, ae.promptTokenBudget, systemTokens))
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to render decision prompt")
+		return
+	}
+	promptTokens := systemTokens + rendered.Tokens
+	if len(rendered.Compressed) > 0 {
+		log.Debug().
+			Str("agent", agentName).
+			Int("prompt_tokens", promptTokens).
+			Strs("compressed", rendered.Compressed).
+			Msg("Decision prompt compressed to fit token budget")
+	}
 
 	// YZ kararını al
-	aiDecision, err := aiClient.GetTradingDecision(ctx, prompt)
+	started := time.Now()
+	aiDecision, err := aiClient.GetTradingDecision(ctx, systemPrompt, rendered.Text)
+	latency := time.Since(started)
 	if err != nil {
 		log.Error().Err(err).Str("agent", agentName).Msg("Failed to get AI decision")
 		return
 	}
 
+	var variant string
+	if assignment != nil {
+		variant = assignment.Variant.Name
+	}
+
 	log.Info().
 		Str("agent", agentName).
 		Str("action", aiDecision.Action).
 		Str("stock", aiDecision.StockSymbol).
 		Float64("confidence", aiDecision.Confidence).
+		Str("prompt_set", promptSet.Name).
+		Str("experiment_variant", variant).
+		Int("prompt_tokens", promptTokens).
 		Msg("AI decision received")
 
 	// Kararı kaydet
-	decisionID, err := ae.storeDecision(ctx, agentID, aiDecision)
+	decisionID, err := ae.storeDecision(ctx, agentID, aiDecision, decisionMeta{
+		PromptSet:    promptSet,
+		Experiment:   assignment,
+		PromptTokens: promptTokens,
+		SystemPrompt: systemPrompt,
+		Prompt:       rendered.Text,
+		Latency:      latency,
+		Provider:     ai.ProviderOf(aiClient),
+		Model:        model,
+		Snapshot:     marketSnapshot(decisionReq, agent.Ledger, decisionTime(decisionReq, started)),
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to store decision")
 		return
@@ -180,30 +326,36 @@ func (ae *AgentEngine) processAgentDecision(
 
 	// Kararı yayınla
 	ae.hub.BroadcastMessage("agent_decision", map[string]interface{}{
-		"agent_id":          agentID,
-		"agent_name":        agentName,
-		"decision_id":       decisionID,
-		"action":            aiDecision.Action,
-		"stock_symbol":      aiDecision.StockSymbol,
-		"quantity":          aiDecision.Quantity,
-		"reasoning_summary": aiDecision.ReasoningSummary,
-		"confidence":        aiDecision.Confidence,
-		"risk_level":        aiDecision.RiskLevel,
-		"thinking_steps":    aiDecision.ThinkingSteps,
-		"timestamp":         time.Now().Unix(),
+		"agent_id":           agentID,
+		"agent_name":         agentName,
+		"decision_id":        decisionID,
+		"action":             aiDecision.Action,
+		"stock_symbol":       aiDecision.StockSymbol,
+		"quantity":           aiDecision.Quantity,
+		"reasoning_summary":  aiDecision.ReasoningSummary,
+		"confidence":         aiDecision.Confidence,
+		"risk_level":         aiDecision.RiskLevel,
+		"thinking_steps":     aiDecision.ThinkingSteps,
+		"orders":             aiDecision.Orders,
+		"target_weights":     aiDecision.TargetWeights,
+		"rationale":          aiDecision.Rationale,
+		"prompt_version":     promptSet.Name,
+		"experiment_variant": variant,
+		"prompt_tokens":      promptTokens,
+		"book":               agent.Ledger.Name,
+		"timestamp":          time.Now().Unix(),
 	})
 
-	// HOLD değilse işlemi gerçekleştir
-	if aiDecision.Action != "HOLD" {
+	switch aiDecision.Action {
+	case models.ActionHold:
+		// HOLD action - no trade executed
+		log.Debug().Str("agent", agentName).Msg("Agent decided to HOLD - no trade executed")
+	case models.ActionMulti, models.ActionRebalance:
+		ae.executeOrders(ctx, agent, decisionID, aiDecision)
+	default:
 		// Risk yöneticisi ile doğrula
-		if err := ae.riskManager.ValidateTrade(ctx, agentID, aiDecision); err != nil {
-			log.Warn().Err(err).Str("agent", agentName).Msg("Trade rejected by risk manager")
-			ae.hub.BroadcastMessage("trade_rejected", map[string]interface{}{
-				"agent_id":   agentID,
-				"agent_name": agentName,
-				"reason":     err.Error(),
-				"timestamp":  time.Now().Unix(),
-			})
+		if err := ae.riskManager.On(agent.Ledger).ValidateTrade(ctx, agentID, aiDecision); err != nil {
+			ae.rejectTrade(agentID, agentName, err)
 			return
 		}
 
@@ -214,9 +366,16 @@ func (ae *AgentEngine) processAgentDecision(
 			TradeType:   aiDecision.Action,
 			Quantity:    aiDecision.Quantity,
 			Reasoning:   aiDecision.ReasoningSummary,
+			DecisionID:  &decisionID,
+		}
+
+		// Onay modunda işlem yerine öneri oluştur
+		if agent.needsApproval() && ae.proposals != nil {
+			ae.propose(ctx, agentName, decisionID, aiDecision.Action, []models.TradeRequest{tradeReq}, models.ExecutionAtomic, aiDecision.Confidence)
+			return
 		}
 
-		trade, err := ae.tradingEngine.ExecuteTrade(ctx, tradeReq)
+		trade, err := ae.tradingEngine.On(agent.Ledger).ExecuteTrade(ctx, tradeReq)
 		if err != nil {
 			log.Error().Err(err).Str("agent", agentName).Msg("Failed to execute trade")
 			return
@@ -225,14 +384,130 @@ func (ae *AgentEngine) processAgentDecision(
 		log.Info().
 			Str("agent", agentName).
 			Str("trade_id", trade.ID.String()).
+			Str("book", agent.Ledger.Name).
 			Msg("Trade executed successfully")
 
 		// İşlemi yayınla
-		ae.hub.BroadcastMessage("trade_executed", trade)
-	} else {
-		// HOLD action - no trade executed
-		log.Debug().Str("agent", agentName).Msg("Agent decided to HOLD - no trade executed")
+		ae.hub.BroadcastMessage(agent.tradeEvent(), trade)
+	}
+}
+
+// rejectTrade risk yöneticisinin reddettiği kararı loglar ve yayınlar
+func (ae *AgentEngine) rejectTrade(agentID uuid.UUID, agentName string, err error) {
+	log.Warn().Err(err).Str("agent", agentName).Msg("Trade rejected by risk manager")
+	ae.hub.BroadcastMessage("trade_rejected", map[string]interface{}{
+		"agent_id":   agentID,
+		"agent_name": agentName,
+		"reason":     err.Error(),
+		"timestamp":  time.Now().Unix(),
+	})
+}
+
+// propose onay modundaki ajanın emirlerini onay bekleyen öneri olarak kaydeder
+func (ae *AgentEngine) propose(ctx context.Context, agentName string, decisionID uuid.UUID, action string, orders []models.TradeRequest, mode string, confidence float64) {
+	p, err := ae.proposals.Create(ctx, agentName, decisionID, action, orders, mode, confidence)
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Msg("Failed to create trade proposal")
+		return
 	}
+	log.Info().
+		Str("agent", agentName).
+		Str("proposal_id", p.ID.String()).
+		Time("expires_at", p.ExpiresAt).
+		Msg("Trade proposal awaiting approval")
+}
+
+// executeOrders MULTI ve REBALANCE kararlarını emirlere çevirip birlikte gerçekleştirir
+func (ae *AgentEngine) executeOrders(ctx context.Context, agent activeAgent, decisionID uuid.UUID, decision *models.AIDecision) {
+	agentID, agentName := agent.ID, agent.Name
+	orders := decision.TradeRequests(agentID)
+	mode := decision.ExecutionMode
+	if decision.Action == models.ActionRebalance {
+		balance, err := ae.riskManager.On(agent.Ledger).balance(ctx, agentID)
+		if err != nil {
+			log.Error().Err(err).Str("agent", agentName).Msg("Failed to load balance for rebalance")
+			return
+		}
+		planned, err := ae.tradingEngine.On(agent.Ledger).PlanRebalance(ctx, agentID, decision.TargetWeights, ae.riskManager.MaxBuyAmount(balance))
+		if err != nil {
+			ae.rejectTrade(agentID, agentName, err)
+			return
+		}
+		orders = planned
+		// Yeniden dengeleme tek parça uygulanır
+		mode = models.ExecutionAtomic
+
+		reasoning := decision.Rationale
+		if reasoning == "" {
+			reasoning = decision.ReasoningSummary
+		}
+		for i := range orders {
+			orders[i].Reasoning = reasoning
+		}
+		if planJSON, err := json.Marshal(orderList(orders)); err == nil {
+			_, _ = ae.db.Exec(ctx, "UPDATE agent_decisions SET orders = $1, execution_mode = $2 WHERE id = $3", planJSON, mode, decisionID)
+		}
+	}
+	if len(orders) == 0 {
+		log.Debug().Str("agent", agentName).Msg("No orders to execute")
+		return
+	}
+	if mode != models.ExecutionOrdered {
+		mode = models.ExecutionAtomic
+	}
+	for i := range orders {
+		orders[i].DecisionID = &decisionID
+	}
+
+	if err := ae.riskManager.On(agent.Ledger).ValidateOrders(ctx, agentID, orders, decision.Confidence); err != nil {
+		ae.rejectTrade(agentID, agentName, err)
+		return
+	}
+
+	if agent.needsApproval() && ae.proposals != nil {
+		ae.propose(ctx, agentName, decisionID, decision.Action, orders, mode, decision.Confidence)
+		return
+	}
+
+	results, err := ae.tradingEngine.On(agent.Ledger).ExecuteOrders(ctx, orders, mode)
+	if err != nil {
+		log.Error().Err(err).Str("agent", agentName).Str("mode", mode).Msg("Failed to execute orders")
+	}
+
+	executed := 0
+	for _, r := range results {
+		if r.Trade != nil {
+			executed++
+			ae.hub.BroadcastMessage(agent.tradeEvent(), r.Trade)
+		}
+	}
+	log.Info().
+		Str("agent", agentName).
+		Str("action", decision.Action).
+		Str("mode", mode).
+		Int("orders", len(orders)).
+		Int("executed", executed).
+		Msg("Orders processed")
+
+	ae.hub.BroadcastMessage("orders_executed", map[string]interface{}{
+		"agent_id":    agentID,
+		"agent_name":  agentName,
+		"decision_id": decisionID,
+		"action":      decision.Action,
+		"mode":        mode,
+		"book":        agent.Ledger.Name,
+		"results":     results,
+		"timestamp":   time.Now().Unix(),
+	})
+}
+
+// orderList işlem isteklerini kararlarda saklanan emir biçimine çevirir
+func orderList(reqs []models.TradeRequest) []models.Order {
+	orders := make([]models.Order, len(reqs))
+	for i, r := range reqs {
+		orders[i] = models.Order{Action: r.TradeType, StockSymbol: r.StockSymbol, Quantity: r.Quantity}
+	}
+	return orders
 }
 
 // gatherDecisionData bir karar için gereken tüm verileri toplar
@@ -241,6 +516,7 @@ func (ae *AgentEngine) gatherDecisionData(
 	agentID uuid.UUID,
 	agentName string,
 	balance float64,
+	ledger Ledger,
 ) (*ai.DecisionRequest, error) {
 	req := &ai.DecisionRequest{
 		AgentID:        agentID.String(),
@@ -248,16 +524,19 @@ func (ae *AgentEngine) gatherDecisionData(
 		CurrentBalance: balance,
 		Strategy:       "balanced",
 	}
+	if ae.clock != nil {
+		req.Now = ae.clock.Now()
+	}
 
 	// Portföyü al (güncel fiyat ile hesapla)
-	portfolioQuery := `
+	portfolioQuery := fmt.Sprintf(`
 		SELECT p.stock_symbol, p.quantity, p.avg_buy_price,
 			   COALESCE(p.quantity * s.current_price, 0) as current_value,
 			   COALESCE(p.quantity * s.current_price - p.total_invested, 0) as profit_loss
-		FROM portfolio p
+		FROM %s p
 		JOIN stocks s ON s.symbol = p.stock_symbol
 		WHERE p.agent_id = $1
-	`
+	`, ledger.portfolio)
 	rows, err := ae.db.Query(ctx, portfolioQuery, agentID)
 	if err == nil {
 		defer rows.Close()
@@ -284,11 +563,28 @@ func (ae *AgentEngine) gatherDecisionData(
 		}
 	}
 
+	// Son mumlar: portföydeki ve en çok hareket eden hisseler (oynatmada yalnızca kapanmış mumlar)
+	if candles, err := LatestCandles(ctx, ae.db, CandleSymbols(req), PromptCandleTimeframe, PromptCandlesPerSymbol, req.Now); err == nil {
+		req.MarketData = candles
+	} else {
+		log.Warn().Err(err).Str("agent", agentName).Msg("Failed to load candles")
+	}
+
+	// Hafıza: elde tutulan ve en çok hareket eden hisselerle ilgili dersler öne çıkar
+	if ae.memory != nil {
+		if memories, note, err := ae.memory.Recall(ctx, agentID, memorySymbols(req), req.Now, 5); err == nil {
+			req.Memories = memories
+			req.StandingNote = note
+		} else {
+			log.Warn().Err(err).Str("agent", agentName).Msg("Failed to recall agent memories")
+		}
+	}
+
 	// Son işlemleri al
-	tradesQuery := `
+	tradesQuery := fmt.Sprintf(`
 		SELECT stock_symbol, trade_type, quantity, price, reasoning, created_at
-		FROM trades WHERE agent_id = $1 ORDER BY created_at DESC LIMIT 5
-	`
+		FROM %s WHERE agent_id = $1 ORDER BY created_at DESC LIMIT 5
+	`, ledger.trades)
 	rows, err = ae.db.Query(ctx, tradesQuery, agentID)
 	if err == nil {
 		defer rows.Close()
@@ -301,8 +597,14 @@ func (ae *AgentEngine) gatherDecisionData(
 		}
 	}
 
-	// Toplayıcıdan en son haberleri al
-	if latestNews, err := ae.newsAggregator.GetLatestNews(ctx); err == nil {
+	// Toplayıcıdan en son haberleri al (geçmiş oynatmada yalnızca o ana kadar yayınlananlar)
+	getNews := ae.newsAggregator.GetLatestNews
+	if !req.Now.IsZero() {
+		getNews = func(ctx context.Context) ([]models.NewsArticle, error) {
+			return ae.newsAggregator.GetNewsAsOf(ctx, req.Now)
+		}
+	}
+	if latestNews, err := getNews(ctx); err == nil {
 		req.News = latestNews
 		req.NewsCount = len(latestNews)
 	} else {
@@ -325,43 +627,201 @@ func (ae *AgentEngine) gatherDecisionData(
 	return req, nil
 }
 
+// marketSnapshot kararın verildiği andaki bakiye, pozisyon, fiyat ve kullanılan
+// haber/tweet/hatıra kimliklerini toplar
+func marketSnapshot(req *ai.DecisionRequest, ledger Ledger, at time.Time) models.MarketSnapshot {
+	snap := models.MarketSnapshot{
+		Timestamp: at,
+		Book:      ledger.Name,
+		Balance:   req.CurrentBalance,
+		Holdings:  make([]models.SnapshotHolding, 0, len(req.Portfolio)),
+		Prices:    make([]models.SnapshotPrice, 0, len(req.Stocks)),
+		NewsIDs:   make([]uuid.UUID, 0, len(req.News)),
+		TweetIDs:  make([]string, 0, len(req.MCTopTweets)),
+	}
+	for _, p := range req.Portfolio {
+		snap.Holdings = append(snap.Holdings, models.SnapshotHolding{Symbol: p.StockSymbol, Quantity: p.Quantity, AvgBuyPrice: p.AvgBuyPrice})
+	}
+	for _, s := range req.Stocks {
+		snap.Prices = append(snap.Prices, models.SnapshotPrice{Symbol: s.Symbol, Price: s.CurrentPrice, ChangePercent: s.ChangePercent, Volume: s.Volume})
+	}
+	for _, p := range req.MCPrices {
+		if p != nil {
+			snap.ContextPrices = append(snap.ContextPrices, models.SnapshotPrice{Symbol: p.Symbol, Price: p.Price, Volume: p.Volume, Source: p.Source})
+		}
+	}
+	for _, n := range req.News {
+		snap.NewsIDs = append(snap.NewsIDs, n.ID)
+	}
+	for _, t := range req.MCTopTweets {
+		snap.TweetIDs = append(snap.TweetIDs, t.ID)
+	}
+	for _, m := range req.Memories {
+		snap.MemoryIDs = append(snap.MemoryIDs, m.ID)
+	}
+	return snap
+}
+
+// decisionTime kararın piyasa zamanıdır: geçmiş oynatmada sanal saat, aksi halde started
+func decisionTime(req *ai.DecisionRequest, started time.Time) time.Time {
+	if !req.Now.IsZero() {
+		return req.Now
+	}
+	return started
+}
+
+// CandleSymbols prompttaki mumlar için en fazla PromptCandleSymbols sembol seçer:
+// önce portföydekiler, sonra en çok hareket edenler
+func CandleSymbols(req *ai.DecisionRequest) []string {
+	seen := map[string]bool{}
+	var symbols []string
+	for _, s := range memorySymbols(req) {
+		if !seen[s] && len(symbols) < PromptCandleSymbols {
+			seen[s] = true
+			symbols = append(symbols, s)
+		}
+	}
+	return symbols
+}
+
+// memorySymbols hatıra geri çağırma için ilgili sembolleri döndürür: portföydekiler
+// ve mutlak değişimi en yüksek 5 hisse
+func memorySymbols(req *ai.DecisionRequest) []string {
+	symbols := make([]string, 0, len(req.Portfolio)+5)
+	for _, p := range req.Portfolio {
+		symbols = append(symbols, p.StockSymbol)
+	}
+	movers := append([]models.Stock(nil), req.Stocks...)
+	sort.Slice(movers, func(i, j int) bool {
+		return math.Abs(movers[i].ChangePercent) > math.Abs(movers[j].ChangePercent)
+	})
+	for i := 0; i < len(movers) && i < 5; i++ {
+		symbols = append(symbols, movers[i].Symbol)
+	}
+	return symbols
+}
+
 // storeDecision bir YZ kararını veritabanına kaydeder
-func (ae *AgentEngine) storeDecision(ctx context.Context, agentID uuid.UUID, decision *models.AIDecision) (uuid.UUID, error) {
+func (ae *AgentEngine) storeDecision(
+	ctx context.Context,
+	agentID uuid.UUID,
+	decision *models.AIDecision,
+	meta decisionMeta,
+) (uuid.UUID, error) {
 	decisionID := uuid.New()
 
-	// Piyasa bağlamını marshal et
-	marketContext, _ := json.Marshal(map[string]interface{}{
-		"timestamp": time.Now(),
-	})
+	// Deney dışındaki kararlar için NULL
+	var experimentID *uuid.UUID
+	var experimentVariant *string
+	if meta.Experiment != nil {
+		experimentID = &meta.Experiment.ExperimentID
+		experimentVariant = &meta.Experiment.Variant.Name
+	}
+
+	// Kararın dayandığı piyasa görüntüsünü marshal et
+	marketContext, _ := json.Marshal(meta.Snapshot)
 
 	// Risk skorunu hesapla
 	riskScore := 100.0 - decision.Confidence
 
+	// Çoklu emir alanları; tek emirli kararlarda NULL
+	var stockSymbol *string
+	if decision.StockSymbol != "" {
+		stockSymbol = &decision.StockSymbol
+	}
+	summary := decision.ReasoningSummary
+	if summary == "" {
+		summary = decision.Rationale
+	}
+	var orders, targetWeights []byte
+	var executionMode *string
+	if len(decision.Orders) > 0 {
+		orders, _ = json.Marshal(decision.Orders)
+	}
+	if len(decision.TargetWeights) > 0 {
+		targetWeights, _ = json.Marshal(decision.TargetWeights)
+	}
+	if decision.ExecutionMode != "" {
+		executionMode = &decision.ExecutionMode
+	}
+
 	query := `
 		INSERT INTO agent_decisions (
 			id, agent_id, stock_symbol, decision, quantity, target_price, stop_loss,
 			reasoning_full, reasoning_summary, confidence_score, risk_score, risk_level,
-			market_context, outcome
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			market_context, outcome, prompt_version, prompt_hash, experiment_id, experiment_variant, prompt_tokens,
+			orders, target_weights, execution_mode
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
 	`
 
-	_, err := ae.db.Exec(ctx, query,
-		decisionID, agentID, decision.StockSymbol, decision.Action, decision.Quantity,
-		decision.TargetPrice, decision.StopLoss, decision.ReasoningFull, decision.ReasoningSummary,
+	// Karar, denetim kaydı, düşünme adımları ve oylar birlikte yazılır ya da hiçbiri
+	tx, err := ae.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, query,
+		decisionID, agentID, stockSymbol, decision.Action, decision.Quantity,
+		decision.TargetPrice, decision.StopLoss, decision.ReasoningFull, summary,
 		decision.Confidence, riskScore, decision.RiskLevel, string(marketContext), "pending",
+		meta.PromptSet.Name, meta.PromptSet.Hash, experimentID, experimentVariant, meta.PromptTokens,
+		orders, targetWeights, executionMode,
 	)
 	if err != nil {
 		return uuid.Nil, err
 	}
 
+	// Denetim kaydı: kararın sonradan açıklanabilmesi için promptlar ve ham yanıt
+	modelVersion := decision.ModelVersion
+	if modelVersion == "" {
+		modelVersion = meta.Model
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO agent_decision_audits (decision_id, system_prompt, prompt, raw_response, latency_ms, provider, model, model_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, decisionID, meta.SystemPrompt, meta.Prompt, decision.RawResponse, meta.Latency.Milliseconds(),
+		meta.Provider, truncateName(meta.Model, 100), truncateName(modelVersion, 100))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to store decision audit: %w", err)
+	}
+
 	// Düşünme adımlarını kaydet
 	for i, step := range decision.ThinkingSteps {
 		thoughtQuery := `
 			INSERT INTO agent_thoughts (agent_id, decision_id, step_number, step_name, thought)
 			VALUES ($1, $2, $3, $4, $5)
 		`
-		_, _ = ae.db.Exec(ctx, thoughtQuery, agentID, decisionID, i+1, step.Step, step.Observation)
+		if _, err := tx.Exec(ctx, thoughtQuery, agentID, decisionID, i+1, step.Step, step.Observation); err != nil {
+			return uuid.Nil, fmt.Errorf("failed to store decision thoughts: %w", err)
+		}
+	}
+
+	// Komite üyelerinin oylarını kaydet (topluluk ajanları)
+	for i, vote := range decision.Votes {
+		thought := fmt.Sprintf("%s %s x%d (%.0f%%): %s", vote.Action, vote.StockSymbol, vote.Quantity, vote.Confidence, vote.Summary)
+		if vote.Error != "" {
+			thought = "no answer: " + vote.Error
+		}
+		data, _ := json.Marshal(vote)
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO agent_thoughts (agent_id, decision_id, step_number, step_name, thought, data)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, agentID, decisionID, len(decision.ThinkingSteps)+i+1, "Vote: "+truncateName(vote.Model, 94), thought, data); err != nil {
+			return uuid.Nil, fmt.Errorf("failed to store ensemble votes: %w", err)
+		}
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, err
+	}
 	return decisionID, nil
 }
+
+// truncateName kısa metin alanları için (VARCHAR) çok uzun değerleri kırpar
+func truncateName(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
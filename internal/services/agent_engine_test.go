@@ -0,0 +1,53 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+)
+
+func TestMarketSnapshot(t *testing.T) {
+	newsID, memoryID := uuid.New(), uuid.New()
+	req := &ai.DecisionRequest{
+		CurrentBalance: 95000,
+		Portfolio:      []models.Portfolio{{StockSymbol: "THYAO", Quantity: 10, AvgBuyPrice: 250}},
+		Stocks:         []models.Stock{{Symbol: "THYAO", CurrentPrice: 260, ChangePercent: 4, Volume: 1000}},
+		News:           []models.NewsArticle{{ID: newsID}},
+		MCPrices:       []*models.StockPrice{{Symbol: "THYAO", Price: 259.5, Source: "yahoo"}, nil},
+		MCTopTweets:    []models.Tweet{{ID: "tw1"}},
+		Memories:       []models.AgentMemory{{ID: memoryID}},
+	}
+	at := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	snap := marketSnapshot(req, ShadowLedger, at)
+	if snap.Book != BookShadow || snap.Balance != 95000 || !snap.Timestamp.Equal(at) {
+		t.Errorf("snapshot header = %+v", snap)
+	}
+	if len(snap.Holdings) != 1 || snap.Holdings[0].AvgBuyPrice != 250 {
+		t.Errorf("holdings = %+v", snap.Holdings)
+	}
+	if len(snap.Prices) != 1 || snap.Prices[0].Price != 260 || len(snap.ContextPrices) != 1 {
+		t.Errorf("prices = %+v, context = %+v", snap.Prices, snap.ContextPrices)
+	}
+	if len(snap.NewsIDs) != 1 || snap.NewsIDs[0] != newsID || len(snap.TweetIDs) != 1 || snap.TweetIDs[0] != "tw1" {
+		t.Errorf("news = %v, tweets = %v", snap.NewsIDs, snap.TweetIDs)
+	}
+	if len(snap.MemoryIDs) != 1 || snap.MemoryIDs[0] != memoryID {
+		t.Errorf("memories = %v", snap.MemoryIDs)
+	}
+
+	// An empty request still stores empty lists, not nulls
+	raw, _ := json.Marshal(marketSnapshot(&ai.DecisionRequest{}, LiveLedger, at))
+	var m map[string]any
+	_ = json.Unmarshal(raw, &m)
+	for _, k := range []string{"holdings", "prices", "news_ids", "tweet_ids"} {
+		if _, ok := m[k].([]any); !ok {
+			t.Errorf("%s = %v, want []", k, m[k])
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/1batu/market-ai/internal/performance"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AgentPerformance ajanın canlı defterdeki performans metriklerini
+// agent_performance_snapshots geçmişinden, güncel hesap değerinden ve
+// kapanmış işlemlerinden hesaplar. Defter sıfırlandıysa (sezon başı)
+// yalnızca sonrası sayılır.
+func AgentPerformance(ctx context.Context, db *pgxpool.Pool, agentID uuid.UUID) (performance.Metrics, error) {
+	return LedgerPerformance(ctx, db, LiveLedger, agentID)
+}
+
+// LedgerPerformance AgentPerformance'ın verilen defterdeki (canlı ya da gölge)
+// karşılığı; iki defterin kazanma oranı aynı tanımla hesaplanır
+func LedgerPerformance(ctx context.Context, db *pgxpool.Pool, l Ledger, agentID uuid.UUID) (performance.Metrics, error) {
+	initial, equity, err := ledgerEquityHistory(ctx, db, l, agentID)
+	if err != nil {
+		return performance.Metrics{}, err
+	}
+
+	fills, err := ledgerFills(ctx, db, l, agentID)
+	if err != nil {
+		return performance.Metrics{}, err
+	}
+
+	return performance.Compute(initial, equity, performance.ClosedTrades(fills)), nil
+}
+
+// liveFills ajanın canlı defterdeki (son sıfırlamadan bu yana) işlemleri, eskiden yeniye
+func liveFills(ctx context.Context, db *pgxpool.Pool, agentID uuid.UUID) ([]performance.Fill, error) {
+	return ledgerFills(ctx, db, LiveLedger, agentID)
+}
+
+// ledgerFills ajanın defterdeki işlemleri, eskiden yeniye. Canlı defterde
+// yalnızca son sıfırlamadan sonrası sayılır; gölge defter hiç sıfırlanmaz.
+func ledgerFills(ctx context.Context, db *pgxpool.Pool, l Ledger, agentID uuid.UUID) ([]performance.Fill, error) {
+	since := ""
+	if !l.IsShadow() {
+		since = `AND created_at >= COALESCE((SELECT ledger_reset_at FROM agents WHERE id = $1), '-infinity')`
+	}
+	rows, err := db.Query(ctx, fmt.Sprintf(`
+		SELECT created_at, stock_symbol, trade_type, quantity, price, COALESCE(commission, 0)
+		FROM %s
+		WHERE agent_id = $1 %s
+		ORDER BY created_at ASC`, l.trades, since), agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var fills []performance.Fill
+	for rows.Next() {
+		var f performance.Fill
+		if err := rows.Scan(&f.Time, &f.Symbol, &f.Side, &f.Quantity, &f.Price, &f.Commission); err != nil {
+			return nil, err
+		}
+		fills = append(fills, f)
+	}
+	return fills, rows.Err()
+}
+
+// equityHistory ajanın başlangıç bakiyesini ve canlı defterdeki (son
+// sıfırlamadan bu yana) değer geçmişini döndürür. Son anlık görüntüden
+// sonraki işlemler de sayılsın diye güncel değer sona eklenir.
+func equityHistory(ctx context.Context, db *pgxpool.Pool, agentID uuid.UUID) (float64, []performance.Point, error) {
+	return ledgerEquityHistory(ctx, db, LiveLedger, agentID)
+}
+
+// ledgerEquityHistory equityHistory'nin verilen defterdeki karşılığı
+func ledgerEquityHistory(ctx context.Context, db *pgxpool.Pool, l Ledger, agentID uuid.UUID) (float64, []performance.Point, error) {
+	account := `
+		SELECT initial_balance, current_balance, calculate_portfolio_value(id)
+		FROM agents WHERE id = $1`
+	since := `AND snapshot_time >= COALESCE((SELECT ledger_reset_at FROM agents WHERE id = $1), '-infinity')`
+	if l.IsShadow() {
+		account = `
+		SELECT sa.initial_balance, sa.current_balance,
+		       COALESCE((SELECT SUM(p.quantity * s.current_price)
+		                 FROM shadow_portfolio p JOIN stocks s ON s.symbol = p.stock_symbol
+		                 WHERE p.agent_id = sa.agent_id), 0)
+		FROM shadow_accounts sa WHERE sa.agent_id = $1`
+		since = ""
+	}
+	var initial, balance, portfolioValue float64
+	if err := db.QueryRow(ctx, account, agentID).Scan(&initial, &balance, &portfolioValue); err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT snapshot_time, total_value, portfolio_value
+		FROM agent_performance_snapshots
+		WHERE agent_id = $1 AND book = $2 `+since+`
+		ORDER BY snapshot_time ASC`, agentID, l.Name)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	var equity []performance.Point
+	for rows.Next() {
+		var p performance.Point
+		if err := rows.Scan(&p.Time, &p.Equity, &p.PortfolioValue); err != nil {
+			return 0, nil, err
+		}
+		equity = append(equity, p)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	equity = append(equity, performance.Point{Time: time.Now(), Equity: balance + portfolioValue, PortfolioValue: portfolioValue})
+	return initial, equity, nil
+}
+
+// savePerformance metrikleri agent_metrics satırına yazar. Değerler sütun
+// hassasiyetine sığacak şekilde sınırlanır.
+func savePerformance(ctx context.Context, db *pgxpool.Pool, agentID uuid.UUID, m performance.Metrics) error {
+	var profitFactor *float64
+	if m.ProfitFactor != nil {
+		pf := bounded(*m.ProfitFactor, 1e8)
+		profitFactor = &pf
+	}
+	_, err := db.Exec(ctx, `
+		UPDATE agent_metrics SET
+			time_weighted_return = $2,
+			sharpe_ratio = $3,
+			sortino_ratio = $4,
+			calmar_ratio = $5,
+			max_drawdown = $6,
+			max_drawdown_seconds = $7,
+			winning_trades = $8,
+			losing_trades = $9,
+			win_rate = $10,
+			profit_factor = $11,
+			avg_win = $12,
+			avg_loss = $13,
+			exposure = $14,
+			performance_updated_at = NOW()
+		WHERE agent_id = $1`,
+		agentID,
+		bounded(m.TimeWeightedReturn, 1e8),
+		bounded(m.SharpeRatio, 1e6),
+		bounded(m.SortinoRatio, 1e6),
+		bounded(m.CalmarRatio, 1e8),
+		bounded(m.MaxDrawdown, 1e8),
+		int64(m.MaxDrawdownDuration/time.Second),
+		m.WinningTrades,
+		m.LosingTrades,
+		m.WinRate,
+		profitFactor,
+		m.AverageWin,
+		m.AverageLoss,
+		m.Exposure,
+	)
+	return err
+}
+
+// bounded v'yi (-limit, limit) aralığına sıkıştırır
+func bounded(v, limit float64) float64 {
+	limit -= 1e-4
+	return math.Max(-limit, math.Min(limit, v))
+}
+
+// saveShadowPerformance gölge defterin kapanmış işlem sayılarını ve kazanma
+// oranını shadow_metrics satırına yazar
+func saveShadowPerformance(ctx context.Context, db *pgxpool.Pool, agentID uuid.UUID, m performance.Metrics) error {
+	_, err := db.Exec(ctx, `
+		UPDATE shadow_metrics SET
+			winning_trades = $2,
+			losing_trades = $3,
+			win_rate = $4
+		WHERE agent_id = $1`,
+		agentID, m.WinningTrades, m.LosingTrades, m.WinRate)
+	return err
+}
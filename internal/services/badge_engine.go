@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/1batu/market-ai/internal/badges"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/performance"
+	"github.com/1batu/market-ai/internal/websocket"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// BadgeEngine rozet kurallarını (internal/badges) işlem ve lider tablosu
+// olaylarında değerlendirir, kazanılan rozetleri agent_badges'e yazar ve
+// "badge_awarded" olarak yayınlar. Bir rozet ajan ve dönem başına bir kez verilir.
+// İşlem kuralları işlem yolunu bekletmemek için kuyruktan arka planda değerlendirilir.
+type BadgeEngine struct {
+	db    *pgxpool.Pool
+	hub   *websocket.Hub
+	rules []badges.Rule
+
+	mu      sync.Mutex
+	pending map[uuid.UUID]bool // kuyrukta bekleyen ajanlar
+	queue   chan uuid.UUID
+}
+
+// badgeQueueSize işlem kuyruğunun kapasitesi; dolduğunda yeni işlemler atlanır
+const badgeQueueSize = 256
+
+func NewBadgeEngine(db *pgxpool.Pool, hub *websocket.Hub, rules []badges.Rule) *BadgeEngine {
+	for _, r := range rules {
+		if r.When == badges.MarketDownDay && !slices.Contains(Benchmarks, r.Params.Benchmark) {
+			log.Warn().Str("badge", r.ID).Str("benchmark", r.Params.Benchmark).Msg("Badge rule uses an unknown benchmark and will never be awarded")
+		}
+		if r.When == badges.BestCalibration && !slices.ContainsFunc(EvaluationHorizons, func(h EvaluationHorizon) bool { return h.Name == r.Params.Horizon }) {
+			log.Warn().Str("badge", r.ID).Str("horizon", r.Params.Horizon).Msg("Badge rule uses an unknown horizon and will never be awarded")
+		}
+	}
+	return &BadgeEngine{db: db, hub: hub, rules: rules, pending: map[uuid.UUID]bool{}, queue: make(chan uuid.UUID, badgeQueueSize)}
+}
+
+// Start kuyruğa alınan ajanların işlem kurallarını sırayla değerlendirir
+func (be *BadgeEngine) Start(ctx context.Context) {
+	log.Info().Msg("Badge engine started")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Badge engine stopped")
+			return
+		case id := <-be.queue:
+			be.mu.Lock()
+			delete(be.pending, id) // değerlendirme sırasında gelen işlem ajanı yeniden kuyruğa alır
+			be.mu.Unlock()
+			be.OnTrade(ctx, id)
+		}
+	}
+}
+
+// Queue ajanı işlem kurallarının değerlendirilmesi için kuyruğa alır; beklemez.
+// Zaten kuyrukta olan ajan tekrar eklenmez.
+func (be *BadgeEngine) Queue(agentID uuid.UUID) {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	if be.pending[agentID] {
+		return
+	}
+	select {
+	case be.queue <- agentID:
+		be.pending[agentID] = true
+	default:
+		log.Warn().Str("agent_id", agentID.String()).Msg("Badge queue full, skipping trade badges")
+	}
+}
+
+// Rules yüklü rozet kuralları
+func (be *BadgeEngine) Rules() []badges.Rule { return be.rules }
+
+// OnTrade ajanın canlı işleminden sonra işlem kurallarını değerlendirir
+func (be *BadgeEngine) OnTrade(ctx context.Context, agentID uuid.UUID) {
+	be.run(ctx, badges.OnTrade, []uuid.UUID{agentID})
+}
+
+// OnSnapshot lider tablosu güncellemesinden sonra tüm aktif ajanlar için
+// anlık görüntü kurallarını değerlendirir
+func (be *BadgeEngine) OnSnapshot(ctx context.Context) {
+	rows, err := be.db.Query(ctx, "SELECT id FROM agents WHERE status = 'active'")
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list agents for badges")
+		return
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if len(ids) > 0 {
+		be.run(ctx, badges.OnSnapshot, ids)
+	}
+}
+
+// badgeAward bir kuralın bir ajana verdiği rozet
+type badgeAward struct {
+	agentID uuid.UUID
+	at      time.Time // başarının zamanı (İstanbul); tekrarlanan rozetlerde dönemi belirler
+	details map[string]any
+}
+
+// badgeFacts ajan düzeyindeki kuralların okuduğu veriler (son defter sıfırlamasından bu yana)
+type badgeFacts struct {
+	trades int
+	closed []performance.ClosedTrade
+	roi    float64
+}
+
+func (be *BadgeEngine) run(ctx context.Context, event string, agents []uuid.UUID) {
+	facts := map[uuid.UUID]*badgeFacts{}
+	for _, r := range be.rules {
+		if r.On != event {
+			continue
+		}
+		awards, err := be.evaluate(ctx, r, agents, facts)
+		if err != nil {
+			log.Warn().Err(err).Str("badge", r.ID).Msg("Failed to evaluate badge rule")
+			continue
+		}
+		for _, a := range awards {
+			if err := be.award(ctx, r, a); err != nil {
+				log.Warn().Err(err).Str("badge", r.ID).Str("agent_id", a.agentID.String()).Msg("Failed to award badge")
+			}
+		}
+	}
+}
+
+// evaluate kuralı karşılayan ajanları döndürür
+func (be *BadgeEngine) evaluate(ctx context.Context, r badges.Rule, agents []uuid.UUID, facts map[uuid.UUID]*badgeFacts) ([]badgeAward, error) {
+	switch r.When {
+	case badges.BestCalibration:
+		return be.bestCalibrated(ctx, r, agents)
+	case badges.MarketDownDay:
+		return be.marketDownDays(ctx, r, agents)
+	}
+
+	now := time.Now().In(bistLocation)
+	var awards []badgeAward
+	for _, id := range agents {
+		f, ok := facts[id]
+		if !ok {
+			var err error
+			if f, err = be.facts(ctx, id); err != nil {
+				return nil, err
+			}
+			facts[id] = f
+		}
+		switch r.When {
+		case badges.TradeCount:
+			if f.trades >= r.Params.Count {
+				awards = append(awards, badgeAward{agentID: id, at: now, details: map[string]any{"trades": f.trades}})
+			}
+		case badges.WinStreak:
+			if streak := badges.LongestWinStreak(f.closed); streak >= r.Params.Count {
+				awards = append(awards, badgeAward{agentID: id, at: now, details: map[string]any{"streak": streak}})
+			}
+		case badges.ROIAbove:
+			if f.roi >= r.Params.Min {
+				awards = append(awards, badgeAward{agentID: id, at: now, details: map[string]any{"roi": f.roi}})
+			}
+		}
+	}
+	return awards, nil
+}
+
+func (be *BadgeEngine) facts(ctx context.Context, agentID uuid.UUID) (*badgeFacts, error) {
+	fills, err := liveFills(ctx, be.db, agentID)
+	if err != nil {
+		return nil, err
+	}
+	f := &badgeFacts{trades: len(fills), closed: performance.ClosedTrades(fills)}
+	err = be.db.QueryRow(ctx, "SELECT COALESCE(roi, 0) FROM agent_metrics WHERE agent_id = $1", agentID).Scan(&f.roi)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return f, nil
+}
+
+// bestCalibrated geçen haftanın (İstanbul, pazartesiden pazartesiye) kararlarında
+// en düşük Brier skorunu alan ajanları döndürür. Hafta, son kararların ufku
+// dolup puanlanana kadar değerlendirilmez.
+func (be *BadgeEngine) bestCalibrated(ctx context.Context, r badges.Rule, agents []uuid.UUID) ([]badgeAward, error) {
+	i := slices.IndexFunc(EvaluationHorizons, func(h EvaluationHorizon) bool { return h.Name == r.Params.Horizon })
+	if i < 0 {
+		return nil, nil
+	}
+	from, to := badges.LastWeek(time.Now().In(bistLocation))
+	if time.Now().Before(to.Add(EvaluationHorizons[i].Duration + evaluationInterval)) {
+		return nil, nil
+	}
+
+	rows, err := be.db.Query(ctx, `
+		SELECT e.agent_id, COALESCE(e.confidence, 0), e.hit
+		FROM decision_evaluations e
+		JOIN agent_decisions d ON d.id = e.decision_id
+		WHERE e.horizon = $1 AND e.status = 'scored' AND e.hit IS NOT NULL
+		  AND d.created_at >= $2 AND d.created_at < $3
+		  AND e.agent_id = ANY($4)`, r.Params.Horizon, from.UTC(), to.UTC(), agents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	samples := map[uuid.UUID][]calibrationSample{}
+	for rows.Next() {
+		var id uuid.UUID
+		var s calibrationSample
+		if err := rows.Scan(&id, &s.Confidence, &s.Hit); err != nil {
+			return nil, err
+		}
+		samples[id] = append(samples[id], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scores := map[uuid.UUID]models.Calibration{}
+	for id, s := range samples {
+		if len(s) >= r.Params.MinSamples {
+			scores[id] = calibrate(s)
+		}
+	}
+	var awards []badgeAward
+	for _, id := range bestBrier(scores) {
+		awards = append(awards, badgeAward{agentID: id, at: from, details: map[string]any{
+			"brier_score": scores[id].BrierScore,
+			"samples":     scores[id].Samples,
+			"horizon":     r.Params.Horizon,
+		}})
+	}
+	return awards, nil
+}
+
+// bestBrier en düşük Brier skorlu ajanlar (eşitlikte hepsi)
+func bestBrier(scores map[uuid.UUID]models.Calibration) []uuid.UUID {
+	var best []uuid.UUID
+	for id, c := range scores {
+		switch {
+		case len(best) == 0 || c.BrierScore < scores[best[0]].BrierScore:
+			best = []uuid.UUID{id}
+		case c.BrierScore == scores[best[0]].BrierScore:
+			best = append(best, id)
+		}
+	}
+	slices.SortFunc(best, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	return best
+}
+
+// marketDownDays karşılaştırma serisinin max_return ya da daha kötü kapandığı
+// son günlerden birini kârla kapatan ajanları döndürür. Yalnızca gün sonu
+// özeti kesinleşmiş günlere bakılır.
+func (be *BadgeEngine) marketDownDays(ctx context.Context, r badges.Rule, agents []uuid.UUID) ([]badgeAward, error) {
+	rows, err := be.db.Query(ctx, `
+		WITH closes AS (
+			SELECT DISTINCT ON (day) day, value
+			FROM (
+				SELECT (snapshot_time AT TIME ZONE 'UTC' AT TIME ZONE 'Europe/Istanbul')::date AS day, value, snapshot_time
+				FROM benchmark_snapshots
+				WHERE benchmark = $1 AND snapshot_time >= NOW() - INTERVAL '10 days'
+			) s
+			ORDER BY day, snapshot_time DESC
+		), returns AS (
+			SELECT day, (value / NULLIF(LAG(value) OVER (ORDER BY day), 0) - 1) * 100 AS ret
+			FROM closes
+		)
+		SELECT d.agent_id, d.stat_date, d.profit_loss, r.ret
+		FROM agent_daily_stats d
+		JOIN returns r ON r.day = d.stat_date
+		WHERE d.agent_id = ANY($3) AND r.ret <= $2 AND d.profit_loss > 0
+		  AND d.updated_at >= ((d.stat_date + 1)::timestamp AT TIME ZONE 'Europe/Istanbul') AT TIME ZONE 'UTC'
+		ORDER BY d.stat_date ASC`, r.Params.Benchmark, r.Params.MaxReturn, agents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var awards []badgeAward
+	for rows.Next() {
+		var id uuid.UUID
+		var day time.Time
+		var pl, ret float64
+		if err := rows.Scan(&id, &day, &pl, &ret); err != nil {
+			return nil, err
+		}
+		awards = append(awards, badgeAward{
+			agentID: id,
+			at:      time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, bistLocation),
+			details: map[string]any{"date": day.Format(time.DateOnly), "market_return": ret, "profit_loss": pl},
+		})
+	}
+	return awards, rows.Err()
+}
+
+// award rozeti yazar; ajan o dönemde rozete zaten sahipse bir şey yapmaz
+func (be *BadgeEngine) award(ctx context.Context, r badges.Rule, a badgeAward) error {
+	b := models.AgentBadge{
+		AgentID:     a.agentID,
+		Badge:       r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Period:      r.Period(a.at),
+		Event:       r.On,
+		Details:     a.details,
+	}
+	err := be.db.QueryRow(ctx, `
+		INSERT INTO agent_badges (agent_id, badge, period, name, description, event, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (agent_id, badge, period) DO NOTHING
+		RETURNING id, awarded_at, (SELECT name FROM agents WHERE id = $1)`,
+		b.AgentID, b.Badge, b.Period, b.Name, b.Description, b.Event, b.Details).Scan(&b.ID, &b.AwardedAt, &b.AgentName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Info().Str("agent", b.AgentName).Str("badge", b.Badge).Str("period", b.Period).Msg("Badge awarded")
+	be.hub.BroadcastMessage("badge_awarded", b)
+	return nil
+}
+
+// AgentBadges ajanın rozetleri, yeniden eskiye
+func AgentBadges(ctx context.Context, db *pgxpool.Pool, agentID uuid.UUID) ([]models.AgentBadge, error) {
+	rows, err := db.Query(ctx, `
+		SELECT b.id, b.agent_id, a.name, b.badge, b.name, COALESCE(b.description, ''), b.period, b.event, b.details, b.awarded_at
+		FROM agent_badges b
+		JOIN agents a ON a.id = b.agent_id
+		WHERE b.agent_id = $1
+		ORDER BY b.awarded_at DESC`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.AgentBadge{}
+	for rows.Next() {
+		var b models.AgentBadge
+		if err := rows.Scan(&b.ID, &b.AgentID, &b.AgentName, &b.Badge, &b.Name, &b.Description, &b.Period, &b.Event, &b.Details, &b.AwardedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestBestBrierSharesTies(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	best := bestBrier(map[uuid.UUID]models.Calibration{
+		a: {BrierScore: 0.12},
+		b: {BrierScore: 0.30},
+		c: {BrierScore: 0.12},
+	})
+	want := []uuid.UUID{a, c}
+	slices.SortFunc(want, func(x, y uuid.UUID) int { return slices.Compare(x[:], y[:]) })
+	if !slices.Equal(best, want) {
+		t.Errorf("best = %v, want %v", best, want)
+	}
+	if best := bestBrier(nil); len(best) != 0 {
+		t.Errorf("no scores: best = %v", best)
+	}
+}
+
+func TestQueueSkipsPendingAgents(t *testing.T) {
+	be := NewBadgeEngine(nil, nil, nil)
+	a, b := uuid.New(), uuid.New()
+	be.Queue(a)
+	be.Queue(b)
+	be.Queue(a)
+	if n := len(be.queue); n != 2 {
+		t.Fatalf("queued %d agents, want 2", n)
+	}
+	if got := <-be.queue; got != a {
+		t.Errorf("first queued = %v, want %v", got, a)
+	}
+}
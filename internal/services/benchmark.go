@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/1batu/market-ai/internal/datasources/yahoo"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/performance"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Karşılaştırma serileri
+const (
+	BenchmarkBIST100     = "bist100"
+	BenchmarkEqualWeight = "equal_weight"
+	BenchmarkCash        = "cash"
+)
+
+// Benchmarks tüm karşılaştırma serileri, yanıtlarda bu sırayla listelenir
+var Benchmarks = []string{BenchmarkBIST100, BenchmarkEqualWeight, BenchmarkCash}
+
+// DefaultBenchmarkIndex BIST100 endeksinin Yahoo sembolü (XU100.IS)
+const DefaultBenchmarkIndex = "XU100"
+
+// benchmarkBase sepet ve mevduat serilerinin başlangıç değeri
+const benchmarkBase = 100.0
+
+// BenchmarkService liderlik tablosu için karşılaştırma serilerini (BIST100,
+// eşit ağırlıklı evren sepeti, TL mevduat) kaydeder ve her ajanın bu serilere
+// göre alfa, beta, takip hatası ve bilgi oranını hesaplar
+type BenchmarkService struct {
+	db          *pgxpool.Pool
+	depositRate float64 // yıllık mevduat faizi, yüzde
+	yahoo       *yahoo.YahooFinanceClient
+	indexSymbol string // Yahoo sembolü, .IS eki olmadan (XU100)
+}
+
+// NewBenchmarkService yıllık depositRate (yüzde) ile servis oluşturur
+func NewBenchmarkService(db *pgxpool.Pool, depositRate float64) *BenchmarkService {
+	return &BenchmarkService{db: db, depositRate: depositRate}
+}
+
+// SetIndexSource BIST100 serisinin Yahoo kaynağını ayarlar; symbol boşsa
+// XU100 kullanılır. Ayarlanmazsa (ör. simüle piyasada) endeks serisi kaydedilmez.
+func (bs *BenchmarkService) SetIndexSource(client *yahoo.YahooFinanceClient, symbol string) {
+	if symbol == "" {
+		symbol = DefaultBenchmarkIndex
+	}
+	bs.yahoo = client
+	bs.indexSymbol = strings.TrimSuffix(strings.ToUpper(symbol), ".IS")
+}
+
+// benchmarkSnapshot bir serinin son kaydı
+type benchmarkSnapshot struct {
+	value        float64
+	base         float64 // serinin ilk değeri
+	constituents map[string]float64
+	at           time.Time
+}
+
+// Update serilere yeni birer nokta ekler ve ajan karşılaştırmalarını günceller
+func (bs *BenchmarkService) Update(ctx context.Context) {
+	last, err := bs.latest(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load benchmark snapshots")
+		return
+	}
+	now := time.Now()
+
+	if bs.yahoo != nil {
+		if q, err := bs.yahoo.GetStockPrice(ctx, bs.indexSymbol); err != nil {
+			log.Warn().Err(err).Str("symbol", bs.indexSymbol).Msg("Failed to fetch benchmark index")
+		} else if q.Price > 0 {
+			bs.insert(ctx, BenchmarkBIST100, q.Price, last[BenchmarkBIST100], nil)
+		}
+	}
+
+	if prices, err := bs.universePrices(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to load universe prices for benchmark")
+	} else if len(prices) > 0 {
+		value := benchmarkBase
+		if prev := last[BenchmarkEqualWeight]; prev != nil {
+			r, _ := equalWeightReturn(prev.constituents, prices)
+			value = prev.value * (1 + r)
+		}
+		bs.insert(ctx, BenchmarkEqualWeight, value, last[BenchmarkEqualWeight], prices)
+	}
+
+	value := benchmarkBase
+	if prev := last[BenchmarkCash]; prev != nil {
+		value = depositGrowth(prev.value, bs.depositRate, now.Sub(prev.at))
+	}
+	bs.insert(ctx, BenchmarkCash, value, last[BenchmarkCash], nil)
+
+	bs.updateAgents(ctx)
+}
+
+// latest her serinin son kaydını ve ilk değerini döndürür
+func (bs *BenchmarkService) latest(ctx context.Context) (map[string]*benchmarkSnapshot, error) {
+	rows, err := bs.db.Query(ctx, `
+		SELECT DISTINCT ON (b.benchmark) b.benchmark, b.value, b.constituents, b.snapshot_time,
+		       (SELECT f.value FROM benchmark_snapshots f WHERE f.benchmark = b.benchmark ORDER BY f.snapshot_time ASC LIMIT 1)
+		FROM benchmark_snapshots b
+		ORDER BY b.benchmark, b.snapshot_time DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]*benchmarkSnapshot{}
+	for rows.Next() {
+		var name string
+		var raw []byte
+		s := &benchmarkSnapshot{}
+		if err := rows.Scan(&name, &s.value, &raw, &s.at, &s.base); err != nil {
+			return nil, err
+		}
+		if len(raw) > 0 {
+			_ = json.Unmarshal(raw, &s.constituents)
+		}
+		out[name] = s
+	}
+	return out, rows.Err()
+}
+
+func (bs *BenchmarkService) insert(ctx context.Context, benchmark string, value float64, prev *benchmarkSnapshot, constituents map[string]float64) {
+	base := value
+	if prev != nil && prev.base > 0 {
+		base = prev.base
+	}
+	var raw []byte
+	if constituents != nil {
+		raw, _ = json.Marshal(constituents)
+	}
+	if _, err := bs.db.Exec(ctx, `
+		INSERT INTO benchmark_snapshots (benchmark, value, roi_percent, constituents)
+		VALUES ($1, $2, $3, $4)`,
+		benchmark, value, bounded((value/base-1)*100, 1e6), raw); err != nil {
+		log.Warn().Err(err).Str("benchmark", benchmark).Msg("Failed to insert benchmark snapshot")
+	}
+}
+
+// universePrices aktif evrendeki hisselerin güncel fiyatları
+func (bs *BenchmarkService) universePrices(ctx context.Context) (map[string]float64, error) {
+	rows, err := bs.db.Query(ctx, `
+		SELECT symbol, current_price FROM stocks
+		WHERE COALESCE(is_active, TRUE) AND current_price > 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	prices := map[string]float64{}
+	for rows.Next() {
+		var sym string
+		var price float64
+		if err := rows.Scan(&sym, &price); err != nil {
+			return nil, err
+		}
+		prices[sym] = price
+	}
+	return prices, rows.Err()
+}
+
+// updateAgents aktif ajanların günlük getirilerini her seriyle karşılaştırır
+func (bs *BenchmarkService) updateAgents(ctx context.Context) {
+	series := map[string][]performance.Point{}
+	for _, b := range Benchmarks {
+		points, err := bs.history(ctx, b)
+		if err != nil {
+			log.Warn().Err(err).Str("benchmark", b).Msg("Failed to load benchmark history")
+			continue
+		}
+		series[b] = points
+	}
+
+	rows, err := bs.db.Query(ctx, "SELECT id FROM agents WHERE status = 'active'")
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list agents for benchmark metrics")
+		return
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		_, equity, err := equityHistory(ctx, bs.db, id)
+		if err != nil {
+			log.Warn().Err(err).Str("agent_id", id.String()).Msg("Failed to load agent equity for benchmarks")
+			continue
+		}
+		for b, points := range series {
+			r := performance.CompareDaily(equity, points)
+			if _, err := bs.db.Exec(ctx, `
+				INSERT INTO agent_benchmark_metrics (agent_id, benchmark, alpha, beta, tracking_error, information_ratio, days, calculated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+				ON CONFLICT (agent_id, benchmark) DO UPDATE SET
+					alpha = EXCLUDED.alpha,
+					beta = EXCLUDED.beta,
+					tracking_error = EXCLUDED.tracking_error,
+					information_ratio = EXCLUDED.information_ratio,
+					days = EXCLUDED.days,
+					calculated_at = NOW()`,
+				id, b, bounded(r.Alpha, 1e8), bounded(r.Beta, 1e6), bounded(r.TrackingError, 1e8), bounded(r.InformationRatio, 1e6), r.Days); err != nil {
+				log.Warn().Err(err).Str("agent_id", id.String()).Str("benchmark", b).Msg("Failed to save benchmark metrics")
+			}
+		}
+	}
+}
+
+func (bs *BenchmarkService) history(ctx context.Context, benchmark string) ([]performance.Point, error) {
+	rows, err := bs.db.Query(ctx, `
+		SELECT snapshot_time, value FROM benchmark_snapshots
+		WHERE benchmark = $1
+		ORDER BY snapshot_time ASC`, benchmark)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []performance.Point
+	for rows.Next() {
+		var p performance.Point
+		if err := rows.Scan(&p.Time, &p.Equity); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// equalWeightReturn iki fiyat kümesinde de bulunan hisselerin getirilerinin
+// ortalamasıdır; sepet her adımda eşit ağırlığa dengelenir. Evrene yeni
+// giren hisseler bir sonraki adımdan itibaren sayılır.
+func equalWeightReturn(prev, cur map[string]float64) (float64, int) {
+	var sum float64
+	n := 0
+	for sym, p := range cur {
+		if q, ok := prev[sym]; ok && q > 0 {
+			sum += p/q - 1
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return sum / float64(n), n
+}
+
+// depositGrowth yıllık yüzde faizle bileşik büyümeyi uygular
+func depositGrowth(value, annualRate float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return value
+	}
+	years := elapsed.Hours() / (365 * 24)
+	return value * math.Pow(1+annualRate/100, years)
+}
+
+// BenchmarkComparisons aktif ajanların karşılaştırma metriklerini döndürür;
+// benchmark boşsa tüm seriler listelenir
+func BenchmarkComparisons(ctx context.Context, db *pgxpool.Pool, benchmark string) ([]models.BenchmarkComparison, error) {
+	rows, err := db.Query(ctx, `
+		SELECT m.agent_id, a.name, m.benchmark, m.alpha, m.beta, m.tracking_error, m.information_ratio, m.days, m.calculated_at
+		FROM agent_benchmark_metrics m
+		JOIN agents a ON a.id = m.agent_id
+		WHERE a.status = 'active' AND ($1 = '' OR m.benchmark = $1)
+		ORDER BY m.benchmark, m.alpha DESC`, benchmark)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.BenchmarkComparison{}
+	for rows.Next() {
+		var c models.BenchmarkComparison
+		if err := rows.Scan(&c.AgentID, &c.AgentName, &c.Benchmark, &c.Alpha, &c.Beta, &c.TrackingError, &c.InformationRatio, &c.Days, &c.CalculatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// BenchmarkROIHistory her serinin son limit kaydındaki getirisi (yüzde)
+func BenchmarkROIHistory(ctx context.Context, db *pgxpool.Pool, limit int) (map[string][]models.ROIPoint, error) {
+	rows, err := db.Query(ctx, `
+		SELECT benchmark, snapshot_time, roi_percent FROM (
+			SELECT benchmark, snapshot_time, roi_percent,
+			       ROW_NUMBER() OVER (PARTITION BY benchmark ORDER BY snapshot_time DESC) AS rn
+			FROM benchmark_snapshots
+		) s
+		WHERE rn <= $1
+		ORDER BY snapshot_time DESC`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string][]models.ROIPoint{}
+	for rows.Next() {
+		var name string
+		var p models.ROIPoint
+		if err := rows.Scan(&name, &p.Time, &p.ROI); err != nil {
+			return nil, err
+		}
+		out[name] = append(out[name], p)
+	}
+	return out, rows.Err()
+}
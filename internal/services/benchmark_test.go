@@ -0,0 +1,28 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEqualWeightReturn(t *testing.T) {
+	prev := map[string]float64{"AAA": 10, "BBB": 20, "OLD": 5}
+	cur := map[string]float64{"AAA": 11, "BBB": 19, "NEW": 7}
+	r, n := equalWeightReturn(prev, cur)
+	if n != 2 || math.Abs(r-0.025) > 1e-12 {
+		t.Errorf("return = %v over %d stocks, want 2.5%% over 2", r, n)
+	}
+	if r, n := equalWeightReturn(nil, cur); r != 0 || n != 0 {
+		t.Errorf("no previous prices = %v, %d", r, n)
+	}
+}
+
+func TestDepositGrowth(t *testing.T) {
+	if v := depositGrowth(100, 40, 365*24*time.Hour); math.Abs(v-140) > 1e-9 {
+		t.Errorf("one year at 40%% = %v, want 140", v)
+	}
+	if v := depositGrowth(100, 40, 0); v != 100 {
+		t.Errorf("no time = %v", v)
+	}
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/performance"
+	"github.com/1batu/market-ai/internal/ranking"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// BoardRanker lider tablosundaki ajanları adlandırılmış tablolara
+// (internal/ranking) göre puanlar ve sıralamaları leaderboard_board_rankings'e
+// yazar. İlk (varsayılan) tablonun sırası leaderboard_rankings.rank_overall
+// olur; sezonda sezon sıralaması (kurallarında adı geçen tablo, yoksa varsayılan
+// tablo) yazılır.
+type BoardRanker struct {
+	db     *pgxpool.Pool
+	boards []ranking.Board
+}
+
+func NewBoardRanker(db *pgxpool.Pool, boards []ranking.Board) *BoardRanker {
+	for _, b := range boards {
+		if b.Weights.Calibration > 0 && !slices.ContainsFunc(EvaluationHorizons, func(h EvaluationHorizon) bool { return h.Name == b.Horizon }) {
+			log.Warn().Str("board", b.ID).Str("horizon", b.Horizon).Msg("Leaderboard board uses an unknown horizon; every agent counts as uncalibrated")
+		}
+	}
+	return &BoardRanker{db: db, boards: boards}
+}
+
+// Boards yüklü tablolar; ilki varsayılandır
+func (br *BoardRanker) Boards() []ranking.Board { return br.boards }
+
+// Board id'si verilen tabloyu bulur
+func (br *BoardRanker) Board(id string) (ranking.Board, bool) {
+	i := slices.IndexFunc(br.boards, func(b ranking.Board) bool { return b.ID == id })
+	if i < 0 {
+		return ranking.Board{}, false
+	}
+	return br.boards[i], true
+}
+
+// Update leaderboard_rankings'teki ajanları her tabloda yeniden sıralar.
+// update_leaderboard_rankings ve performans metrikleri güncellendikten sonra çağrılır.
+func (br *BoardRanker) Update(ctx context.Context) error {
+	inputs, err := rankingInputs(ctx, br.db)
+	if err != nil {
+		return err
+	}
+
+	calibrations := br.calibrations(ctx, br.boards)
+
+	tx, err := br.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM leaderboard_board_rankings"); err != nil {
+		return err
+	}
+	batch := &pgx.Batch{}
+	for i, b := range br.boards {
+		boardInputs := inputs
+		if b.Weights.Calibration > 0 {
+			boardInputs = withBrier(inputs, calibrations[b.Horizon], b.MinSamples)
+		}
+		for _, r := range ranking.Rank(b, boardInputs) {
+			batch.Queue(`
+				INSERT INTO leaderboard_board_rankings (board, agent_id, rank, score, eligible, components, is_default, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())`,
+				b.ID, r.AgentID, r.Rank, r.Score, r.Eligible, r.Components, i == 0)
+		}
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	// Sezonda sıralama yeni tablo skorlarıyla yenilenir ve genel sıra sezon
+	// sırasıdır; sezon yoksa varsayılan tablonun sırası
+	var season uuid.UUID
+	err = tx.QueryRow(ctx, `SELECT id FROM seasons WHERE status = 'active'`).Scan(&season)
+	switch {
+	case err == nil:
+		if _, err := tx.Exec(ctx, `SELECT update_season_standings($1)`, season); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE leaderboard_rankings lr SET rank_overall = sp.rank
+			FROM season_participants sp
+			WHERE sp.season_id = $1 AND sp.agent_id = lr.agent_id`, season); err != nil {
+			return err
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		if _, err := tx.Exec(ctx, `
+			UPDATE leaderboard_rankings lr SET rank_overall = b.rank
+			FROM leaderboard_board_rankings b
+			WHERE b.board = $1 AND b.agent_id = lr.agent_id`, br.boards[0].ID); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// calibrations tabloların kullandığı ufuklarda ajanların Brier skorları; ufuk başına bir kez yüklenir
+func (br *BoardRanker) calibrations(ctx context.Context, boards []ranking.Board) map[string]map[uuid.UUID]models.Calibration {
+	out := map[string]map[uuid.UUID]models.Calibration{}
+	for _, b := range boards {
+		if b.Weights.Calibration == 0 {
+			continue
+		}
+		if _, done := out[b.Horizon]; done {
+			continue
+		}
+		byAgent := map[uuid.UUID]models.Calibration{}
+		cals, err := Calibrations(ctx, br.db, b.Horizon, nil)
+		if err != nil {
+			log.Warn().Err(err).Str("board", b.ID).Msg("Failed to load calibrations for leaderboard board")
+		}
+		for _, c := range cals {
+			byAgent[c.AgentID] = c
+		}
+		out[b.Horizon] = byAgent
+	}
+	return out
+}
+
+// RankShadow gölge moddaki ajanları gölge defterleri üzerinden varsayılan
+// tabloda sıralar ve sıra ile skoru shadow_metrics'e yazar. Sharpe, düşüş ve
+// kapanmış işlemler perf'ten (LedgerPerformance) gelir; metrikleri
+// hesaplanamayan ajanlar sıralanmaz.
+func (br *BoardRanker) RankShadow(ctx context.Context, perf map[uuid.UUID]performance.Metrics) error {
+	rows, err := br.db.Query(ctx, `
+		SELECT sm.agent_id,
+		       sm.roi,
+		       CASE WHEN sa.initial_balance > 0 THEN t.commission / sa.initial_balance * 100 ELSE 0 END,
+		       sm.total_trades,
+		       t.days
+		FROM shadow_metrics sm
+		JOIN shadow_accounts sa ON sa.agent_id = sm.agent_id
+		JOIN agents a ON a.id = sm.agent_id
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM(tr.commission), 0)::float8 AS commission,
+			       COUNT(DISTINCT (tr.created_at AT TIME ZONE 'UTC' AT TIME ZONE 'Europe/Istanbul')::date)::int AS days
+			FROM shadow_trades tr
+			WHERE tr.agent_id = sm.agent_id
+		) t
+		WHERE a.status = 'shadow'
+		ORDER BY a.name`)
+	if err != nil {
+		return err
+	}
+	var inputs []ranking.Input
+	for rows.Next() {
+		var in ranking.Input
+		if err := rows.Scan(&in.AgentID, &in.ROI, &in.CostDrag, &in.Trades, &in.ActiveDays); err != nil {
+			rows.Close()
+			return err
+		}
+		m, ok := perf[in.AgentID]
+		if !ok {
+			continue
+		}
+		in.Sharpe, in.MaxDrawdown, in.ClosedTrades = m.SharpeRatio, m.MaxDrawdown, m.ClosedTrades
+		inputs = append(inputs, in)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	b := br.boards[0]
+	if b.Weights.Calibration > 0 {
+		inputs = withBrier(inputs, br.calibrations(ctx, []ranking.Board{b})[b.Horizon], b.MinSamples)
+	}
+	batch := &pgx.Batch{}
+	batch.Queue(`UPDATE shadow_metrics SET rank = NULL, score = NULL`)
+	for _, r := range ranking.Rank(b, inputs) {
+		batch.Queue(`UPDATE shadow_metrics SET rank = $2, score = $3 WHERE agent_id = $1`, r.AgentID, r.Rank, r.Score)
+	}
+	return br.db.SendBatch(ctx, batch).Close()
+}
+
+// rankingInputs lider tablosundaki ajanların sıralama girdileri: tablodaki
+// (sezonda sezonun) getiri ve işlem sayısı, agent_metrics'teki Sharpe, en büyük
+// düşüş ve kapanmış işlemler, son defter sıfırlamasından bu yana ödenen
+// komisyon ve işlem yapılan günler (İstanbul)
+func rankingInputs(ctx context.Context, db *pgxpool.Pool) ([]ranking.Input, error) {
+	rows, err := db.Query(ctx, `
+		SELECT lr.agent_id,
+		       lr.current_roi,
+		       COALESCE(am.sharpe_ratio, 0),
+		       COALESCE(am.max_drawdown, 0),
+		       CASE WHEN a.initial_balance > 0 THEN t.commission / a.initial_balance * 100 ELSE 0 END,
+		       lr.total_trades,
+		       COALESCE(am.winning_trades, 0) + COALESCE(am.losing_trades, 0),
+		       t.days
+		FROM leaderboard_rankings lr
+		JOIN agents a ON a.id = lr.agent_id
+		JOIN agent_metrics am ON am.agent_id = lr.agent_id
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM(tr.commission), 0)::float8 AS commission,
+			       COUNT(DISTINCT (tr.created_at AT TIME ZONE 'UTC' AT TIME ZONE 'Europe/Istanbul')::date)::int AS days
+			FROM trades tr
+			WHERE tr.agent_id = a.id AND tr.created_at >= COALESCE(a.ledger_reset_at, '-infinity')
+		) t
+		ORDER BY a.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var inputs []ranking.Input
+	for rows.Next() {
+		var in ranking.Input
+		if err := rows.Scan(&in.AgentID, &in.ROI, &in.Sharpe, &in.MaxDrawdown, &in.CostDrag, &in.Trades, &in.ClosedTrades, &in.ActiveDays); err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, rows.Err()
+}
+
+// withBrier girdilerin kopyasına en az minSamples puanlanmış kararı olan
+// ajanların Brier skorunu ekler
+func withBrier(inputs []ranking.Input, cals map[uuid.UUID]models.Calibration, minSamples int) []ranking.Input {
+	out := slices.Clone(inputs)
+	for i := range out {
+		if c, ok := cals[out[i].AgentID]; ok && c.Samples >= minSamples {
+			brier := c.BrierScore
+			out[i].Brier = &brier
+		}
+	}
+	return out
+}
+
+// BoardLeaderboard bir tablonun son sıralaması, skor ve bileşenleriyle
+func BoardLeaderboard(ctx context.Context, db *pgxpool.Pool, board string) ([]models.BoardEntry, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			b.rank,
+			a.id,
+			a.name,
+			a.model,
+			lr.current_roi,
+			lr.current_profit_loss,
+			lr.current_win_rate,
+			lr.total_trades,
+			a.current_balance,
+			am.total_portfolio_value,
+			lr.badges,
+			b.updated_at,
+			b.score,
+			b.eligible,
+			b.components
+		FROM leaderboard_board_rankings b
+		JOIN leaderboard_rankings lr ON lr.agent_id = b.agent_id
+		JOIN agents a ON a.id = b.agent_id
+		JOIN agent_metrics am ON am.agent_id = b.agent_id
+		WHERE b.board = $1
+		ORDER BY b.rank ASC, a.name ASC`, board)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.BoardEntry{}
+	for rows.Next() {
+		e := models.BoardEntry{Board: board}
+		var badges []string
+		if err := rows.Scan(&e.Rank, &e.AgentID, &e.AgentName, &e.Model, &e.ROI, &e.ProfitLoss, &e.WinRate, &e.TotalTrades,
+			&e.Balance, &e.PortfolioValue, &badges, &e.UpdatedAt, &e.Score, &e.Eligible, &e.Components); err != nil {
+			return nil, err
+		}
+		e.Badges = badges
+		e.TotalValue = e.Balance + e.PortfolioValue
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/ranking"
+	"github.com/google/uuid"
+)
+
+func TestWithBrierRequiresMinSamples(t *testing.T) {
+	seasoned, newcomer, silent := uuid.New(), uuid.New(), uuid.New()
+	inputs := []ranking.Input{{AgentID: seasoned}, {AgentID: newcomer}, {AgentID: silent}}
+	cals := map[uuid.UUID]models.Calibration{
+		seasoned: {AgentID: seasoned, Samples: 25, BrierScore: 0.12},
+		newcomer: {AgentID: newcomer, Samples: 4, BrierScore: 0.01},
+	}
+
+	got := withBrier(inputs, cals, 10)
+	if got[0].Brier == nil || *got[0].Brier != 0.12 {
+		t.Errorf("seasoned Brier = %v, want 0.12", got[0].Brier)
+	}
+	if got[1].Brier != nil || got[2].Brier != nil {
+		t.Errorf("newcomer/silent Brier = %v/%v, want nil", got[1].Brier, got[2].Brier)
+	}
+	if inputs[0].Brier != nil {
+		t.Error("withBrier modified its input")
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
+)
+
+// CandleTimeframes market_data'ya yazılan mum boyutları (küçükten büyüğe)
+var CandleTimeframes = []string{"1m", "5m", "15m", "1h", "1d"}
+
+// candle yapım aşamasındaki bir OHLCV mumu
+type candle struct {
+	symbol    string
+	timeframe string
+	start     time.Time
+	open      float64
+	high      float64
+	low       float64
+	close     float64
+	volume    int64
+	last      time.Time // son işlenen fiyatın zamanı
+	dirty     bool      // son yazımdan beri değişti
+}
+
+// sessionVolume bir sembolün gün içi kümülatif hacmi (Yahoo günlük toplamı verir)
+type sessionVolume struct {
+	day   string
+	total int64
+}
+
+// CandleBuilder her fiyat güncellemesini (simülatör, geçmiş oynatma, Yahoo)
+// 1m/5m/15m/1h/1d mumlarına işler ve market_data'ya periyodik olarak yazar.
+// Açık mumlar da yazılır; böylece son mum her zaman günceldir.
+type CandleBuilder struct {
+	db       *pgxpool.Pool
+	interval time.Duration
+
+	mu       sync.Mutex
+	open     map[string]*candle // symbol|timeframe -> açık mum
+	finished []*candle          // kapanmış, henüz yazılmamış mumlar
+	volumes  map[string]sessionVolume
+}
+
+// NewCandleBuilder mumları her interval'de yazan bir oluşturucu döndürür
+func NewCandleBuilder(db *pgxpool.Pool, interval time.Duration) *CandleBuilder {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &CandleBuilder{
+		db:       db,
+		interval: interval,
+		open:     map[string]*candle{},
+		volumes:  map[string]sessionVolume{},
+	}
+}
+
+// Start mumları periyodik olarak yazar; durunca son durumu da yazar
+func (cb *CandleBuilder) Start(ctx context.Context) {
+	ticker := time.NewTicker(cb.interval)
+	defer ticker.Stop()
+
+	log.Info().Dur("interval", cb.interval).Msg("Candle builder started")
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			cb.Flush(flushCtx)
+			cancel()
+			log.Info().Msg("Candle builder stopped")
+			return
+		case <-ticker.C:
+			cb.Flush(ctx)
+		}
+	}
+}
+
+// Observe tek bir fiyat güncellemesini işler. dayVolume günün kümülatif hacmidir
+// (bilinmiyorsa 0); mum hacmi ardışık güncellemelerin farkından hesaplanır.
+func (cb *CandleBuilder) Observe(symbol string, price float64, dayVolume int64, at time.Time) {
+	if price <= 0 {
+		return
+	}
+	at = wallClock(at)
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	var volume int64
+	if dayVolume > 0 {
+		day := at.Format("2006-01-02")
+		prev := cb.volumes[symbol]
+		if prev.day == day && dayVolume >= prev.total {
+			volume = dayVolume - prev.total
+		} else if prev.day != day {
+			volume = dayVolume
+		}
+		cb.volumes[symbol] = sessionVolume{day: day, total: dayVolume}
+	}
+	cb.add(symbol, at, price, price, price, price, volume, 0)
+}
+
+// ObserveBar geçmişten oynatılan bir barı işler; barın kendisinden küçük mumlar
+// oluşturulmaz (günlük bardan dakikalık mum çıkmaz)
+func (cb *CandleBuilder) ObserveBar(b simulation.Bar, interval time.Duration) {
+	if b.Close <= 0 {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.add(b.Symbol, wallClock(b.Time), b.Open, b.High, b.Low, b.Close, b.Volume, interval)
+}
+
+// ObservePrices füzyon servisinin getirdiği fiyatları işler
+func (cb *CandleBuilder) ObservePrices(prices []*models.StockPrice) {
+	for _, p := range prices {
+		if p != nil {
+			cb.Observe(p.Symbol, p.Price, p.Volume, p.Timestamp)
+		}
+	}
+}
+
+// add fiyatı her zaman dilimindeki açık muma ekler; yeni döneme geçen mum
+// kapanır, mumun son fiyatından eski (geç gelen) fiyatlar yok sayılır
+func (cb *CandleBuilder) add(symbol string, at time.Time, o, h, l, c float64, volume int64, minInterval time.Duration) {
+	for _, tf := range CandleTimeframes {
+		size := simulation.Timeframes[tf]
+		if size < minInterval {
+			continue
+		}
+		start := at.Truncate(size)
+		key := symbol + "|" + tf
+		cur := cb.open[key]
+		if cur != nil && at.Before(cur.last) {
+			continue
+		}
+		if cur != nil && start.After(cur.start) {
+			cb.finished = append(cb.finished, cur)
+			cur = nil
+		}
+		if cur == nil {
+			cb.open[key] = &candle{symbol: symbol, timeframe: tf, start: start, open: o, high: h, low: l, close: c, volume: volume, last: at, dirty: true}
+			continue
+		}
+		cur.high = math.Max(cur.high, h)
+		cur.low = math.Min(cur.low, l)
+		cur.close = c
+		cur.volume += volume
+		cur.last = at
+		cur.dirty = true
+	}
+}
+
+// pending yazılacak mumların kopyalarını alır ve kirli işaretlerini temizler
+func (cb *CandleBuilder) pending() []candle {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	out := make([]candle, 0, len(cb.finished)+len(cb.open))
+	for _, c := range cb.finished {
+		out = append(out, *c)
+	}
+	cb.finished = nil
+	for _, c := range cb.open {
+		if c.dirty {
+			out = append(out, *c)
+			c.dirty = false
+		}
+	}
+	return out
+}
+
+// Flush değişen mumları market_data'ya yazar. Aynı mum tekrar yazıldığında
+// (ya da yeniden başlatma sonrası) yüksek/düşük birleştirilir, açılış korunur.
+func (cb *CandleBuilder) Flush(ctx context.Context) {
+	candles := cb.pending()
+	for _, c := range candles {
+		_, err := cb.db.Exec(ctx, `
+			INSERT INTO market_data (stock_symbol, open_price, close_price, high_price, low_price, volume, timestamp, timeframe)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (stock_symbol, timeframe, timestamp) DO UPDATE SET
+			    close_price = EXCLUDED.close_price,
+			    high_price = GREATEST(market_data.high_price, EXCLUDED.high_price),
+			    low_price = LEAST(market_data.low_price, EXCLUDED.low_price),
+			    volume = GREATEST(market_data.volume, EXCLUDED.volume)`,
+			c.symbol, c.open, c.close, c.high, c.low, c.volume, c.start, c.timeframe)
+		if err != nil {
+			log.Warn().Err(err).Str("symbol", c.symbol).Str("timeframe", c.timeframe).Msg("Failed to store candle")
+		}
+	}
+	if len(candles) > 0 {
+		log.Debug().Int("count", len(candles)).Msg("Candles stored")
+	}
+}
+
+// LatestCandles sembol başına son perSymbol mumu symbols sırasıyla, her sembol
+// içinde eskiden yeniye döndürür. asOf sıfır değilse yalnızca o ana kadar
+// kapanmış mumlar döner (geçmiş oynatmada gelecek görünmez).
+func LatestCandles(ctx context.Context, db *pgxpool.Pool, symbols []string, timeframe string, perSymbol int, asOf time.Time) ([]models.MarketData, error) {
+	var closedBy *time.Time
+	if !asOf.IsZero() {
+		t := asOf.Add(-simulation.Timeframes[timeframe])
+		closedBy = &t
+	}
+	rows, err := db.Query(ctx, `
+		SELECT id, stock_symbol, open_price, close_price, high_price, low_price, COALESCE(volume, 0), timestamp, timeframe
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY stock_symbol ORDER BY timestamp DESC) AS rn
+			FROM market_data
+			WHERE stock_symbol = ANY($1) AND timeframe = $2 AND ($3::timestamp IS NULL OR timestamp <= $3)
+		) bars
+		WHERE rn <= $4
+		ORDER BY array_position($1, stock_symbol), timestamp`, symbols, timeframe, closedBy, perSymbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candles []models.MarketData
+	for rows.Next() {
+		var md models.MarketData
+		if err := rows.Scan(&md.ID, &md.Symbol, &md.OpenPrice, &md.ClosePrice, &md.HighPrice, &md.LowPrice,
+			&md.Volume, &md.Timestamp, &md.Timeframe); err != nil {
+			return nil, err
+		}
+		candles = append(candles, md)
+	}
+	return candles, rows.Err()
+}
+
+// wallClock zamanın duvar saatini UTC olarak etiketler; TIMESTAMP sütunları
+// saat dilimi tutmaz ve mum sınırları yerel saatle hizalanmalıdır
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
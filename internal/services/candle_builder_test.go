@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
+)
+
+func candlesByKey(cs []candle) map[string]candle {
+	out := map[string]candle{}
+	for _, c := range cs {
+		out[c.symbol+"|"+c.timeframe+"|"+c.start.Format("15:04")] = c
+	}
+	return out
+}
+
+func TestCandleBuilderAggregatesTicks(t *testing.T) {
+	cb := NewCandleBuilder(nil, 0)
+	t0 := time.Date(2024, 3, 1, 10, 0, 5, 0, time.UTC)
+	for i, p := range []float64{100, 103, 98, 101} {
+		cb.Observe("THYAO", p, 0, t0.Add(time.Duration(i)*10*time.Second))
+	}
+	cb.Observe("THYAO", 102, 0, t0.Add(time.Minute)) // next minute closes the 10:00 bar
+
+	got := candlesByKey(cb.pending())
+	first := got["THYAO|1m|10:00"]
+	if first.open != 100 || first.high != 103 || first.low != 98 || first.close != 101 {
+		t.Errorf("10:00 1m candle = %+v", first)
+	}
+	if c := got["THYAO|1m|10:01"]; c.open != 102 || c.close != 102 {
+		t.Errorf("10:01 1m candle = %+v", c)
+	}
+	if c := got["THYAO|5m|10:00"]; c.open != 100 || c.high != 103 || c.low != 98 || c.close != 102 {
+		t.Errorf("5m candle = %+v, want every tick", c)
+	}
+	if c := got["THYAO|1d|00:00"]; c.close != 102 {
+		t.Errorf("1d candle = %+v", c)
+	}
+
+	// Nothing changed since the last flush
+	if rest := cb.pending(); len(rest) != 0 {
+		t.Errorf("pending after flush = %d candles, want 0", len(rest))
+	}
+	// Late ticks for a closed bar are ignored
+	cb.Observe("THYAO", 500, 0, t0)
+	for _, c := range cb.pending() {
+		if c.high == 500 {
+			t.Errorf("late tick changed %+v", c)
+		}
+	}
+}
+
+func TestCandleBuilderVolumeFromSessionTotals(t *testing.T) {
+	cb := NewCandleBuilder(nil, 0)
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	cb.ObservePrices([]*models.StockPrice{{Symbol: "AKBNK", Price: 40, Volume: 1000, Timestamp: t0}})
+	cb.ObservePrices([]*models.StockPrice{{Symbol: "AKBNK", Price: 41, Volume: 1600, Timestamp: t0.Add(5 * time.Minute)}})
+
+	got := candlesByKey(cb.pending())
+	if got["AKBNK|1m|10:05"].volume != 600 {
+		t.Errorf("10:05 volume = %d, want the 600 traded since the last update", got["AKBNK|1m|10:05"].volume)
+	}
+	if got["AKBNK|1d|00:00"].volume != 1600 {
+		t.Errorf("day volume = %d, want 1600", got["AKBNK|1d|00:00"].volume)
+	}
+}
+
+func TestCandleBuilderObserveBar(t *testing.T) {
+	cb := NewCandleBuilder(nil, 0)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cb.ObserveBar(simulation.Bar{Symbol: "SISE", Time: day, Open: 40, High: 42, Low: 39, Close: 41, Volume: 5000}, 24*time.Hour)
+
+	got := cb.pending()
+	if len(got) != 1 || got[0].timeframe != "1d" || got[0].high != 42 || got[0].volume != 5000 {
+		t.Errorf("got %+v, want only the daily candle", got)
+	}
+}
+
+func TestCandleSymbols(t *testing.T) {
+	req := &ai.DecisionRequest{
+		Portfolio: []models.Portfolio{{StockSymbol: "SISE"}},
+		Stocks: []models.Stock{
+			{Symbol: "SISE", ChangePercent: 3},
+			{Symbol: "THYAO", ChangePercent: -5},
+			{Symbol: "AKBNK", ChangePercent: 1},
+			{Symbol: "GARAN", ChangePercent: 0.5},
+		},
+	}
+	got := CandleSymbols(req)
+	if len(got) != 3 || got[0] != "SISE" || got[1] != "THYAO" || got[2] != "AKBNK" {
+		t.Errorf("CandleSymbols = %v, want held first, then movers, no duplicates", got)
+	}
+}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/performance"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// BIST kapanışı (İstanbul saati); gün sonu özeti bu saatte çalışır
+const (
+	BISTCloseHour   = 18
+	BISTCloseMinute = 15
+)
+
+// DailyStatsService agent_daily_stats tablosunu İstanbul takvim günü başına
+// doldurur: işlemler, kapanmış işlemlerin kazanç/kayıpları, hacim, en iyi/kötü
+// işlem, günlük hesap değeri değişimi, ortalama güven ve karar süresi.
+// Açılışta eksik günleri tamamlar, sonra her BIST kapanışında önceki günü
+// kesinleştirip bugünü yazar. Veritabanındaki TIMESTAMP değerleri UTC kabul edilir.
+type DailyStatsService struct {
+	db *pgxpool.Pool
+}
+
+func NewDailyStatsService(db *pgxpool.Pool) *DailyStatsService {
+	return &DailyStatsService{db: db}
+}
+
+// Start açılışta geçmişi tamamlar ve her kapanışta özet çıkarır
+func (ds *DailyStatsService) Start(ctx context.Context) {
+	ds.Backfill(ctx)
+	log.Info().Msg("Daily stats service started")
+	for {
+		wait := time.Until(NextBISTClose(time.Now()))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Info().Msg("Daily stats service stopped")
+			return
+		case <-timer.C:
+			today := bistDay(time.Now())
+			ds.rollupAll(ctx, func(uuid.UUID, map[time.Time]bool) []time.Time {
+				return []time.Time{today.AddDate(0, 0, -1), today}
+			})
+		}
+	}
+}
+
+// NextBISTClose now'dan sonraki ilk 18:15 (İstanbul)
+func NextBISTClose(now time.Time) time.Time {
+	local := now.In(bistLocation)
+	next := time.Date(local.Year(), local.Month(), local.Day(), BISTCloseHour, BISTCloseMinute, 0, 0, bistLocation)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// bistDay t'nin İstanbul takvim günü (UTC gece yarısı olarak, DATE sütunlarıyla aynı)
+func bistDay(t time.Time) time.Time {
+	l := t.In(bistLocation)
+	return time.Date(l.Year(), l.Month(), l.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// dayBounds İstanbul günü day'in [başlangıç, bitiş) aralığı
+func dayBounds(day time.Time) (time.Time, time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, bistLocation)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// Backfill her ajan için ilk etkinlik gününden dünkü güne kadar eksik
+// günleri yazar ve dünü kesinleştirir. Bugün, kapanış geçtiyse yazılır.
+func (ds *DailyStatsService) Backfill(ctx context.Context) {
+	now := time.Now()
+	today := bistDay(now)
+	dayStart, _ := dayBounds(today)
+	closed := !now.Before(dayStart.Add(BISTCloseHour*time.Hour + BISTCloseMinute*time.Minute))
+	ds.rollupAll(ctx, func(_ uuid.UUID, have map[time.Time]bool) []time.Time {
+		var days []time.Time
+		first := today
+		for d := range have {
+			if d.Before(first) {
+				first = d
+			}
+		}
+		for d := first; d.Before(today); d = d.AddDate(0, 0, 1) {
+			if d.Equal(today.AddDate(0, 0, -1)) || !have[d] {
+				days = append(days, d)
+			}
+		}
+		if closed {
+			days = append(days, today)
+		}
+		return days
+	})
+}
+
+// rollupAll canlı defteri olan her ajan için pick'in seçtiği günleri yazar.
+// pick'e ajanın etkinlik günleri (true = satırı zaten var) verilir.
+func (ds *DailyStatsService) rollupAll(ctx context.Context, pick func(uuid.UUID, map[time.Time]bool) []time.Time) {
+	rows, err := ds.db.Query(ctx, "SELECT id FROM agents WHERE status <> 'shadow'")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list agents for daily stats")
+		return
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	written := 0
+	for _, id := range ids {
+		h, err := ds.loadHistory(ctx, id)
+		if err != nil {
+			log.Warn().Err(err).Str("agent_id", id.String()).Msg("Failed to load history for daily stats")
+			continue
+		}
+		have := h.existing
+		for d := range h.activeDays() {
+			if _, ok := have[d]; !ok {
+				have[d] = false
+			}
+		}
+		for _, day := range pick(id, have) {
+			stats, ok := h.day(day)
+			if !ok {
+				continue
+			}
+			if err := ds.save(ctx, stats); err != nil {
+				log.Warn().Err(err).Str("agent_id", id.String()).Msg("Failed to save daily stats")
+				continue
+			}
+			written++
+		}
+	}
+	if written > 0 {
+		log.Info().Int("rows", written).Msg("Daily stats rolled up")
+	}
+}
+
+// dayDecisions bir günün karar özeti
+type dayDecisions struct {
+	count         int
+	avgConfidence *float64
+	avgLatencyMS  *float64
+}
+
+// agentHistory bir ajanın günlük özet için gereken geçmişi
+type agentHistory struct {
+	agentID   uuid.UUID
+	initial   float64
+	created   time.Time
+	fills     []performance.Fill
+	closed    []performance.ClosedTrade
+	equity    []performance.Point
+	decisions map[time.Time]dayDecisions
+	existing  map[time.Time]bool // satırı olan günler
+	resetAt   time.Time          // son defter sıfırlaması (sezon başı); sıfırsa hiç olmadı
+}
+
+func (ds *DailyStatsService) loadHistory(ctx context.Context, agentID uuid.UUID) (*agentHistory, error) {
+	h := &agentHistory{agentID: agentID, decisions: map[time.Time]dayDecisions{}, existing: map[time.Time]bool{}}
+	var resetAt *time.Time
+	if err := ds.db.QueryRow(ctx, "SELECT initial_balance, created_at, ledger_reset_at FROM agents WHERE id = $1", agentID).Scan(&h.initial, &h.created, &resetAt); err != nil {
+		return nil, err
+	}
+	if resetAt != nil {
+		h.resetAt = asUTC(*resetAt)
+	}
+
+	rows, err := ds.db.Query(ctx, `
+		SELECT created_at, stock_symbol, trade_type, quantity, price, COALESCE(commission, 0)
+		FROM trades WHERE agent_id = $1 ORDER BY created_at ASC`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var f performance.Fill
+		if err := rows.Scan(&f.Time, &f.Symbol, &f.Side, &f.Quantity, &f.Price, &f.Commission); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		f.Time = asUTC(f.Time)
+		h.fills = append(h.fills, f)
+	}
+	rows.Close()
+	h.closed = h.closedTrades()
+
+	rows, err = ds.db.Query(ctx, `
+		SELECT snapshot_time, total_value FROM agent_performance_snapshots
+		WHERE agent_id = $1 AND book = 'live' ORDER BY snapshot_time ASC`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p performance.Point
+		if err := rows.Scan(&p.Time, &p.Equity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.Time = asUTC(p.Time)
+		h.equity = append(h.equity, p)
+	}
+	rows.Close()
+
+	rows, err = ds.db.Query(ctx, `
+		SELECT (d.created_at AT TIME ZONE 'UTC' AT TIME ZONE 'Europe/Istanbul')::date AS day,
+		       COUNT(*), AVG(d.confidence_score), AVG(a.latency_ms)
+		FROM agent_decisions d
+		LEFT JOIN agent_decision_audits a ON a.decision_id = d.id
+		WHERE d.agent_id = $1
+		GROUP BY day`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var day time.Time
+		var dd dayDecisions
+		if err := rows.Scan(&day, &dd.count, &dd.avgConfidence, &dd.avgLatencyMS); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		h.decisions[day] = dd
+	}
+	rows.Close()
+
+	rows, err = ds.db.Query(ctx, "SELECT stat_date FROM agent_daily_stats WHERE agent_id = $1", agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		h.existing[day] = true
+	}
+	return h, rows.Err()
+}
+
+// closedTrades sıfırlamadan önceki ve sonraki işlemleri ayrı defterler olarak
+// kapatır; sıfırlamada silinen pozisyonların maliyeti sonraki satışlara karışmaz
+func (h *agentHistory) closedTrades() []performance.ClosedTrade {
+	split := len(h.fills)
+	for i, f := range h.fills {
+		if !f.Time.Before(h.resetAt) {
+			split = i
+			break
+		}
+	}
+	return append(performance.ClosedTrades(h.fills[:split]), performance.ClosedTrades(h.fills[split:])...)
+}
+
+// asUTC TIMESTAMP değerini UTC saat olarak yorumlar
+func asUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// activeDays işlem, karar ya da anlık görüntü bulunan günler
+func (h *agentHistory) activeDays() map[time.Time]bool {
+	days := map[time.Time]bool{}
+	for _, f := range h.fills {
+		days[bistDay(f.Time)] = true
+	}
+	for _, p := range h.equity {
+		days[bistDay(p.Time)] = true
+	}
+	for d := range h.decisions {
+		days[d] = true
+	}
+	return days
+}
+
+// day bir günün özetini hesaplar; ajan o gün yoksa ya da hiç etkinlik
+// yoksa false döner
+func (h *agentHistory) day(day time.Time) (models.AgentDailyStats, bool) {
+	start, end := dayBounds(day)
+	s := models.AgentDailyStats{AgentID: h.agentID, StatDate: day}
+	if !asUTC(h.created).Before(end) {
+		return s, false
+	}
+
+	active := false
+	for _, f := range h.fills {
+		if !f.Time.Before(start) && f.Time.Before(end) {
+			s.TradesCount++
+			s.VolumeTraded += f.Price * float64(f.Quantity)
+			active = true
+		}
+	}
+	for _, c := range h.closed {
+		if c.Time.Before(start) || !c.Time.Before(end) {
+			continue
+		}
+		pl := c.ProfitLoss
+		switch {
+		case pl > 0:
+			s.Wins++
+		case pl < 0:
+			s.Losses++
+		}
+		if s.BestTradeProfit == nil || pl > *s.BestTradeProfit {
+			s.BestTradeProfit = &pl
+		}
+		if s.WorstTradeLoss == nil || pl < *s.WorstTradeLoss {
+			s.WorstTradeLoss = &pl
+		}
+	}
+
+	// Sıfırlama günü ve sonrasında eski defterin değerleri yok sayılır; gün,
+	// sıfırlamada verilen sermayeyle (initial) açılır
+	startValue, endValue := h.initial, -1.0
+	for _, p := range h.equity {
+		if p.Time.Before(h.resetAt) && h.resetAt.Before(end) {
+			continue
+		}
+		if p.Time.Before(start) {
+			startValue = p.Equity
+		}
+		if p.Time.Before(end) {
+			endValue = p.Equity
+			if !p.Time.Before(start) {
+				active = true
+			}
+		}
+	}
+	if endValue < 0 {
+		endValue = startValue
+	}
+	s.StartValue, s.EndValue = &startValue, &endValue
+	s.ProfitLoss = endValue - startValue
+
+	if dd, ok := h.decisions[day]; ok {
+		s.DecisionsCount = dd.count
+		s.AvgConfidence = dd.avgConfidence
+		if dd.avgLatencyMS != nil {
+			ms := int(*dd.avgLatencyMS + 0.5)
+			s.AvgExecTimeMS = &ms
+		}
+		active = true
+	}
+	return s, active
+}
+
+func (ds *DailyStatsService) save(ctx context.Context, s models.AgentDailyStats) error {
+	_, err := ds.db.Exec(ctx, `
+		INSERT INTO agent_daily_stats (agent_id, stat_date, trades_count, wins, losses, profit_loss, volume_traded,
+			best_trade_profit, worst_trade_loss, avg_confidence, avg_execution_time_ms,
+			start_value, end_value, decisions_count, updated_at)
+		VALUES ($1, $2::date, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (agent_id, stat_date) DO UPDATE SET
+			trades_count = EXCLUDED.trades_count,
+			wins = EXCLUDED.wins,
+			losses = EXCLUDED.losses,
+			profit_loss = EXCLUDED.profit_loss,
+			volume_traded = EXCLUDED.volume_traded,
+			best_trade_profit = EXCLUDED.best_trade_profit,
+			worst_trade_loss = EXCLUDED.worst_trade_loss,
+			avg_confidence = EXCLUDED.avg_confidence,
+			avg_execution_time_ms = EXCLUDED.avg_execution_time_ms,
+			start_value = EXCLUDED.start_value,
+			end_value = EXCLUDED.end_value,
+			decisions_count = EXCLUDED.decisions_count,
+			updated_at = EXCLUDED.updated_at`,
+		s.AgentID, s.StatDate, s.TradesCount, s.Wins, s.Losses, s.ProfitLoss, s.VolumeTraded,
+		s.BestTradeProfit, s.WorstTradeLoss, s.AvgConfidence, s.AvgExecTimeMS,
+		s.StartValue, s.EndValue, s.DecisionsCount, time.Now().UTC())
+	return err
+}
+
+// DailyStats ajanın [from, to] (dahil) aralığındaki günlük özetleri, yeniden eskiye
+func DailyStats(ctx context.Context, db *pgxpool.Pool, agentID uuid.UUID, from, to time.Time) ([]models.AgentDailyStats, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, agent_id, stat_date, trades_count, wins, losses, profit_loss, volume_traded,
+		       best_trade_profit, worst_trade_loss, avg_confidence, avg_execution_time_ms,
+		       start_value, end_value, COALESCE(decisions_count, 0), created_at, updated_at
+		FROM agent_daily_stats
+		WHERE agent_id = $1 AND stat_date BETWEEN $2::date AND $3::date
+		ORDER BY stat_date DESC`, agentID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.AgentDailyStats{}
+	for rows.Next() {
+		var s models.AgentDailyStats
+		if err := rows.Scan(&s.ID, &s.AgentID, &s.StatDate, &s.TradesCount, &s.Wins, &s.Losses, &s.ProfitLoss, &s.VolumeTraded,
+			&s.BestTradeProfit, &s.WorstTradeLoss, &s.AvgConfidence, &s.AvgExecTimeMS,
+			&s.StartValue, &s.EndValue, &s.DecisionsCount, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
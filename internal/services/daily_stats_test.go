@@ -0,0 +1,114 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1batu/market-ai/internal/performance"
+)
+
+func TestNextBISTClose(t *testing.T) {
+	// 15:14 UTC is 18:14 in Istanbul
+	now := time.Date(2024, 3, 1, 15, 14, 0, 0, time.UTC)
+	if got, want := NextBISTClose(now), time.Date(2024, 3, 1, 15, 15, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("before close = %v, want %v", got, want)
+	}
+	if got, want := NextBISTClose(now.Add(time.Minute)), time.Date(2024, 3, 2, 15, 15, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("at close = %v, want %v", got, want)
+	}
+}
+
+func TestBISTDayBounds(t *testing.T) {
+	// 22:30 UTC is already the next day in Istanbul
+	if got, want := bistDay(time.Date(2024, 3, 1, 22, 30, 0, 0, time.UTC)), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("bistDay = %v, want %v", got, want)
+	}
+	start, end := dayBounds(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2024, 3, 1, 21, 0, 0, 0, time.UTC); !start.Equal(want) || !end.Equal(want.AddDate(0, 0, 1)) {
+		t.Errorf("bounds = %v - %v", start, end)
+	}
+}
+
+func TestAgentHistoryDay(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	open := time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC) // 10:00 Istanbul
+	confidence, latency := 70.0, 1200.4
+	h := &agentHistory{
+		initial: 1000,
+		created: day.AddDate(0, 0, -3),
+		fills: []performance.Fill{
+			{Time: open, Symbol: "AAA", Side: "BUY", Quantity: 10, Price: 10},
+			{Time: open.Add(time.Hour), Symbol: "AAA", Side: "SELL", Quantity: 5, Price: 12},
+			{Time: open.Add(2 * time.Hour), Symbol: "AAA", Side: "SELL", Quantity: 5, Price: 9},
+		},
+		equity: []performance.Point{
+			{Time: day.AddDate(0, 0, -1).Add(15 * time.Hour), Equity: 1010},
+			{Time: open.Add(8 * time.Hour), Equity: 1015},
+			{Time: day.AddDate(0, 0, 1).Add(15 * time.Hour), Equity: 990},
+		},
+		decisions: map[time.Time]dayDecisions{day: {count: 3, avgConfidence: &confidence, avgLatencyMS: &latency}},
+	}
+	h.closed = performance.ClosedTrades(h.fills)
+
+	s, ok := h.day(day)
+	if !ok {
+		t.Fatal("day with trades reported inactive")
+	}
+	if s.TradesCount != 3 || s.Wins != 1 || s.Losses != 1 || s.VolumeTraded != 205 {
+		t.Errorf("counts = %+v", s)
+	}
+	if *s.BestTradeProfit != 10 || *s.WorstTradeLoss != -5 {
+		t.Errorf("best/worst = %v/%v", *s.BestTradeProfit, *s.WorstTradeLoss)
+	}
+	if *s.StartValue != 1010 || *s.EndValue != 1015 || s.ProfitLoss != 5 {
+		t.Errorf("values = %v -> %v, p/l %v", *s.StartValue, *s.EndValue, s.ProfitLoss)
+	}
+	if s.DecisionsCount != 3 || *s.AvgConfidence != 70 || *s.AvgExecTimeMS != 1200 {
+		t.Errorf("decisions = %+v", s)
+	}
+
+	if _, ok := h.day(day.AddDate(0, 0, -5)); ok {
+		t.Error("day before the agent existed reported active")
+	}
+	// a quiet day carries the previous value forward
+	if s, ok := h.day(day.AddDate(0, 0, 2)); ok || *s.StartValue != 990 || s.ProfitLoss != 0 {
+		t.Errorf("quiet day = %+v, active %v", s, ok)
+	}
+}
+
+func TestAgentHistoryDayAfterLedgerReset(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	reset := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	h := &agentHistory{
+		initial: 50000,
+		created: day.AddDate(0, 0, -10),
+		resetAt: reset,
+		fills: []performance.Fill{
+			{Time: reset.Add(-2 * time.Hour), Symbol: "AAA", Side: "BUY", Quantity: 10, Price: 5},
+			{Time: reset.Add(time.Hour), Symbol: "AAA", Side: "BUY", Quantity: 10, Price: 20},
+			{Time: reset.Add(2 * time.Hour), Symbol: "AAA", Side: "SELL", Quantity: 10, Price: 21},
+		},
+		equity: []performance.Point{
+			{Time: day.AddDate(0, 0, -1).Add(15 * time.Hour), Equity: 120000},
+			{Time: reset.Add(-time.Hour), Equity: 121000},
+			{Time: reset.Add(3 * time.Hour), Equity: 50010},
+		},
+	}
+	h.closed = h.closedTrades()
+
+	s, ok := h.day(day)
+	if !ok {
+		t.Fatal("reset day reported inactive")
+	}
+	// the sale is matched against the post-reset buy only
+	if s.Wins != 1 || *s.BestTradeProfit != 10 {
+		t.Errorf("closed = %+v", h.closed)
+	}
+	if *s.StartValue != 50000 || *s.EndValue != 50010 || s.ProfitLoss != 10 {
+		t.Errorf("values = %v -> %v", *s.StartValue, *s.EndValue)
+	}
+	// days before the reset keep the old ledger
+	if s, _ := h.day(day.AddDate(0, 0, -1)); *s.EndValue != 120000 {
+		t.Errorf("previous day end = %v", *s.EndValue)
+	}
+}
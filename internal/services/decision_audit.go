@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+// ErrDecisionNotFound karar bulunamadığında döner
+var ErrDecisionNotFound = errors.New("decision not found")
+
+// DecisionTrail bir kararın tam denetim izini döndürür: karar, gördüğü piyasa,
+// modele giden promptlar ve ham yanıt, düşünme adımları, ürettiği işlemler ve sonuç puanları
+func DecisionTrail(ctx context.Context, db *pgxpool.Pool, id uuid.UUID) (*models.DecisionTrail, error) {
+	var t models.DecisionTrail
+	d := &t.Decision
+	var marketContext, orders, targetWeights []byte
+	err := db.QueryRow(ctx, `
+		SELECT d.id, d.agent_id, a.name, d.stock_symbol, d.decision, d.quantity, d.target_price, d.stop_loss,
+		       d.reasoning_full, d.reasoning_summary, COALESCE(d.confidence_score, 0), COALESCE(d.risk_score, 0),
+		       COALESCE(d.risk_level, ''), d.market_context, COALESCE(d.executed, false), d.trade_id,
+		       COALESCE(d.outcome, ''), d.actual_profit_loss, d.prompt_version, d.prompt_hash,
+		       d.experiment_id, d.experiment_variant, d.prompt_tokens,
+		       d.orders, d.target_weights, d.execution_mode, d.created_at
+		FROM agent_decisions d
+		JOIN agents a ON a.id = d.agent_id
+		WHERE d.id = $1`, id).Scan(
+		&d.ID, &d.AgentID, &t.AgentName, &d.StockSymbol, &d.Decision, &d.Quantity, &d.TargetPrice, &d.StopLoss,
+		&d.ReasoningFull, &d.ReasoningSummary, &d.ConfidenceScore, &d.RiskScore,
+		&d.RiskLevel, &marketContext, &d.Executed, &d.TradeID,
+		&d.Outcome, &d.ActualProfitLoss, &d.PromptVersion, &d.PromptHash,
+		&d.ExperimentID, &d.ExperimentVariant, &d.PromptTokens,
+		&orders, &targetWeights, &d.ExecutionMode, &d.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDecisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(marketContext) > 0 {
+		t.MarketContext = json.RawMessage(marketContext)
+		d.MarketContext = string(marketContext)
+	}
+	if len(orders) > 0 {
+		_ = json.Unmarshal(orders, &d.Orders)
+	}
+	if len(targetWeights) > 0 {
+		_ = json.Unmarshal(targetWeights, &d.TargetWeights)
+	}
+
+	// Denetim kaydı yalnızca denetim öncesi kararlarda eksiktir
+	var a models.DecisionAudit
+	var latency *int
+	var raw, provider, model, version *string
+	err = db.QueryRow(ctx, `
+		SELECT system_prompt, prompt, raw_response, latency_ms, provider, model, model_version, created_at
+		FROM agent_decision_audits WHERE decision_id = $1`, id).Scan(
+		&a.SystemPrompt, &a.Prompt, &raw, &latency, &provider, &model, &version, &a.CreatedAt,
+	)
+	switch {
+	case err == nil:
+		a.RawResponse, a.Provider, a.Model, a.ModelVersion = deref(raw), deref(provider), deref(model), deref(version)
+		if latency != nil {
+			a.LatencyMs = *latency
+		}
+		t.Audit = &a
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	t.Thoughts = []models.AgentThought{}
+	rows, err := db.Query(ctx, `
+		SELECT id, agent_id, decision_id, step_number, step_name, thought, COALESCE(data::text, ''), created_at
+		FROM agent_thoughts WHERE decision_id = $1 ORDER BY step_number`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var th models.AgentThought
+		if err := rows.Scan(&th.ID, &th.AgentID, &th.DecisionID, &th.StepNumber, &th.StepName, &th.Thought, &th.Data, &th.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.Thoughts = append(t.Thoughts, th)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if t.Trades, err = decisionTrades(ctx, db, LiveLedger, id); err != nil {
+		return nil, err
+	}
+	if t.ShadowTrades, err = decisionTrades(ctx, db, ShadowLedger, id); err != nil {
+		return nil, err
+	}
+	if t.Evaluations, err = DecisionEvaluations(ctx, db, id); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// decisionTrades kararın defterdeki işlemlerini döndürür; canlı işlemlerde
+// eski kayıtlar trade_id bağlantısıyla da bulunur
+func decisionTrades(ctx context.Context, db *pgxpool.Pool, ledger Ledger, decisionID uuid.UUID) ([]models.Trade, error) {
+	query := fmt.Sprintf(`
+		SELECT id, agent_id, stock_symbol, trade_type, quantity, price, total_amount,
+		       COALESCE(commission, 0), COALESCE(reasoning, ''), decision_id, created_at
+		FROM %s
+		WHERE decision_id = $1`, ledger.trades)
+	if !ledger.IsShadow() {
+		query += " OR id = (SELECT trade_id FROM agent_decisions WHERE id = $1)"
+	}
+	rows, err := db.Query(ctx, query+" ORDER BY created_at", decisionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trades := []models.Trade{}
+	for rows.Next() {
+		var tr models.Trade
+		if err := rows.Scan(&tr.ID, &tr.AgentID, &tr.StockSymbol, &tr.TradeType, &tr.Quantity, &tr.Price, &tr.TotalAmount,
+			&tr.Commission, &tr.Reasoning, &tr.DecisionID, &tr.CreatedAt); err != nil {
+			return nil, err
+		}
+		trades = append(trades, tr)
+	}
+	return trades, rows.Err()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
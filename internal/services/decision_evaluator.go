@@ -0,0 +1,600 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+// EvaluationHorizon bir kararın sonucunun ölçüldüğü süre
+type EvaluationHorizon struct {
+	Name     string
+	Duration time.Duration
+}
+
+// EvaluationHorizons kararların puanlandığı sabit ufuklar
+var EvaluationHorizons = []EvaluationHorizon{
+	{Name: "1h", Duration: time.Hour},
+	{Name: "1d", Duration: 24 * time.Hour},
+	{Name: "5d", Duration: 5 * 24 * time.Hour},
+}
+
+// PrimaryHorizon agent_decisions.actual_profit_loss ve outcome alanlarını dolduran ufuk
+const PrimaryHorizon = "1d"
+
+// ErrUnknownHorizon tanımsız bir ufuk istendiğinde döner
+var ErrUnknownHorizon = errors.New("unknown horizon")
+
+const (
+	evaluationInterval = 5 * time.Minute
+	evaluationBatch    = 200
+	calibrationBins    = 10
+)
+
+// DecisionEvaluator her kararı (HOLD dahil) sabit ufuklarda sonraki fiyatlarla
+// puanlar: kararın varsayımsal K/Z'si, gerçekleşen işlemlerin K/Z'si ve isabet.
+// Birincil ufukta agent_decisions.actual_profit_loss ve outcome alanlarını doldurur.
+type DecisionEvaluator struct {
+	db       *pgxpool.Pool
+	interval time.Duration
+}
+
+// NewDecisionEvaluator yeni bir karar değerlendiricisi oluşturur
+func NewDecisionEvaluator(db *pgxpool.Pool) *DecisionEvaluator {
+	return &DecisionEvaluator{db: db, interval: evaluationInterval}
+}
+
+// Start ufku dolan kararları periyodik olarak puanlar
+func (de *DecisionEvaluator) Start(ctx context.Context) {
+	ticker := time.NewTicker(de.interval)
+	defer ticker.Stop()
+
+	log.Info().Dur("interval", de.interval).Msg("Decision evaluator started")
+	de.EvaluatePending(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Decision evaluator stopped")
+			return
+		case <-ticker.C:
+			de.EvaluatePending(ctx)
+		}
+	}
+}
+
+// EvaluatePending her ufuk için süresi dolmuş ve henüz puanlanmamış kararları puanlar
+func (de *DecisionEvaluator) EvaluatePending(ctx context.Context) {
+	for _, h := range EvaluationHorizons {
+		scored, missing, err := de.evaluateHorizon(ctx, h)
+		if err != nil {
+			log.Error().Err(err).Str("horizon", h.Name).Msg("Failed to evaluate decisions")
+			continue
+		}
+		if scored+missing > 0 {
+			log.Info().Str("horizon", h.Name).Int("scored", scored).Int("no_data", missing).Msg("Decisions evaluated")
+		}
+	}
+}
+
+// evalDecision puanlanan kararın ihtiyaç duyulan alanları
+type evalDecision struct {
+	ID         uuid.UUID
+	AgentID    uuid.UUID
+	Action     string
+	Symbol     string
+	Quantity   int
+	Confidence float64
+	Orders     []models.Order
+	Snapshot   models.MarketSnapshot
+	CreatedAt  time.Time
+}
+
+// evalTrade kararın gerçekleşen bir işlemi
+type evalTrade struct {
+	Type       string
+	Symbol     string
+	Quantity   int
+	Price      float64
+	Commission float64
+}
+
+// evalScore bir kararın bir ufuktaki puanı
+type evalScore struct {
+	ReturnPct  float64
+	ProfitLoss float64
+	Hit        bool
+}
+
+func (de *DecisionEvaluator) evaluateHorizon(ctx context.Context, h EvaluationHorizon) (scored, missing int, err error) {
+	rows, err := de.db.Query(ctx, `
+		SELECT d.id, d.agent_id, d.decision, COALESCE(d.stock_symbol, ''), COALESCE(d.quantity, 0),
+		       COALESCE(d.confidence_score, 0), d.orders, d.market_context, d.created_at
+		FROM agent_decisions d
+		WHERE d.created_at <= NOW() - $1 * INTERVAL '1 second'
+		  AND NOT EXISTS (SELECT 1 FROM decision_evaluations e WHERE e.decision_id = d.id AND e.horizon = $2)
+		ORDER BY d.created_at
+		LIMIT $3`, h.Duration.Seconds(), h.Name, evaluationBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	var decisions []evalDecision
+	for rows.Next() {
+		var d evalDecision
+		var orders, snapshot []byte
+		if err := rows.Scan(&d.ID, &d.AgentID, &d.Action, &d.Symbol, &d.Quantity, &d.Confidence,
+			&orders, &snapshot, &d.CreatedAt); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		if len(orders) > 0 {
+			_ = json.Unmarshal(orders, &d.Orders)
+		}
+		if len(snapshot) > 0 {
+			_ = json.Unmarshal(snapshot, &d.Snapshot)
+		}
+		decisions = append(decisions, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	// Fiyat, ufkun dörtte biri kadar yakınında gözlenmiş olmalı (1h: 15 dk, 1d: 6 saat)
+	tolerance := h.Duration / 4
+	for _, d := range decisions {
+		if ctx.Err() != nil {
+			return scored, missing, ctx.Err()
+		}
+		ok, err := de.evaluate(ctx, d, h, tolerance)
+		if err != nil {
+			log.Warn().Err(err).Str("decision_id", d.ID.String()).Str("horizon", h.Name).Msg("Decision evaluation failed")
+			continue
+		}
+		if ok {
+			scored++
+		} else {
+			missing++
+		}
+	}
+	return scored, missing, nil
+}
+
+// evaluate tek bir kararı puanlar ve kaydeder; fiyat yoksa no_data olarak işaretler
+func (de *DecisionEvaluator) evaluate(ctx context.Context, d evalDecision, h EvaluationHorizon, tolerance time.Duration) (bool, error) {
+	trades, err := de.decisionTrades(ctx, d.ID)
+	if err != nil {
+		return false, err
+	}
+
+	symbols := evaluationSymbols(d)
+	for _, t := range trades {
+		symbols = append(symbols, t.Symbol)
+	}
+
+	start := snapshotPrices(d.Snapshot)
+	var missingStart []string
+	for _, s := range symbols {
+		if _, ok := start[s]; !ok {
+			missingStart = append(missingStart, s)
+		}
+	}
+	if len(missingStart) > 0 {
+		found, err := de.pricesNear(ctx, missingStart, d.CreatedAt, tolerance)
+		if err != nil {
+			return false, err
+		}
+		for s, p := range found {
+			start[s] = p
+		}
+	}
+	end, err := de.pricesNear(ctx, symbols, d.CreatedAt.Add(h.Duration), tolerance)
+	if err != nil {
+		return false, err
+	}
+
+	score, ok := scoreDecision(d, start, end)
+	var tradePL *float64
+	if pl, tok := tradesProfitLoss(trades, end); tok && len(trades) > 0 {
+		tradePL = &pl
+	}
+
+	status := models.EvaluationScored
+	var ret, pl *float64
+	var hit *bool
+	if ok {
+		ret, pl, hit = &score.ReturnPct, &score.ProfitLoss, &score.Hit
+	} else {
+		status = models.EvaluationNoData
+	}
+
+	tx, err := de.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO decision_evaluations (decision_id, horizon, agent_id, action, confidence, status, return_pct, profit_loss, trade_profit_loss, hit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (decision_id, horizon) DO NOTHING
+	`, d.ID, h.Name, d.AgentID, d.Action, d.Confidence, status, ret, pl, tradePL, hit)
+	if err != nil {
+		return false, fmt.Errorf("insert evaluation: %w", err)
+	}
+
+	// Birincil ufuk: gerçekleşen K/Z ve sonuç. İşlem yapan kararlar profit/loss,
+	// yapmayanlar (HOLD, reddedilen) yön isabetine göre success/failed olur.
+	if h.Name == PrimaryHorizon && ok {
+		outcome := "failed"
+		switch {
+		case tradePL != nil && *tradePL > 0:
+			outcome = "profit"
+		case tradePL != nil:
+			outcome = "loss"
+		case score.Hit:
+			outcome = "success"
+		}
+		if _, err := tx.Exec(ctx,
+			"UPDATE agent_decisions SET actual_profit_loss = $1, outcome = $2 WHERE id = $3",
+			tradePL, outcome, d.ID); err != nil {
+			return false, fmt.Errorf("update decision outcome: %w", err)
+		}
+	}
+	return ok, tx.Commit(ctx)
+}
+
+// decisionTrades kararın canlı ve gölge defterdeki işlemlerini döndürür
+func (de *DecisionEvaluator) decisionTrades(ctx context.Context, decisionID uuid.UUID) ([]evalTrade, error) {
+	rows, err := de.db.Query(ctx, `
+		SELECT trade_type, stock_symbol, quantity, price, COALESCE(commission, 0)
+		FROM trades
+		WHERE decision_id = $1 OR id = (SELECT trade_id FROM agent_decisions WHERE id = $1)
+		UNION ALL
+		SELECT trade_type, stock_symbol, quantity, price, COALESCE(commission, 0)
+		FROM shadow_trades
+		WHERE decision_id = $1`, decisionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []evalTrade
+	for rows.Next() {
+		var t evalTrade
+		if err := rows.Scan(&t.Type, &t.Symbol, &t.Quantity, &t.Price, &t.Commission); err != nil {
+			return nil, err
+		}
+		trades = append(trades, t)
+	}
+	return trades, rows.Err()
+}
+
+// pricesNear her sembol için verilen ana en yakın fiyat gözlemini (price_sources,
+// market_data) tolerans içinde döndürür. An yeni geçmişse ve gözlem yoksa güncel fiyat kullanılır.
+func (de *DecisionEvaluator) pricesNear(ctx context.Context, symbols []string, at time.Time, tolerance time.Duration) (map[string]float64, error) {
+	prices := make(map[string]float64, len(symbols))
+	if len(symbols) == 0 {
+		return prices, nil
+	}
+	rows, err := de.db.Query(ctx, `
+		SELECT DISTINCT ON (symbol) symbol, price
+		FROM (
+			SELECT stock_symbol AS symbol, final_price AS price, timestamp AS ts
+			FROM price_sources
+			WHERE stock_symbol = ANY($1) AND timestamp BETWEEN $2::timestamp - $3 * INTERVAL '1 second' AND $2::timestamp + $3 * INTERVAL '1 second'
+			UNION ALL
+			SELECT stock_symbol, close_price, timestamp
+			FROM market_data
+			WHERE stock_symbol = ANY($1) AND timestamp BETWEEN $2::timestamp - $3 * INTERVAL '1 second' AND $2::timestamp + $3 * INTERVAL '1 second'
+		) p
+		WHERE price > 0
+		ORDER BY symbol, ABS(EXTRACT(EPOCH FROM ts - $2::timestamp))`, symbols, at, tolerance.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s string
+		var p float64
+		if err := rows.Scan(&s, &p); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		prices[s] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(prices) < len(symbols) {
+		rows, err := de.db.Query(ctx, `
+			SELECT symbol, current_price FROM stocks
+			WHERE symbol = ANY($1) AND current_price > 0
+			  AND ABS(EXTRACT(EPOCH FROM NOW()::timestamp - $2::timestamp)) <= $3`, symbols, at, tolerance.Seconds())
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var s string
+			var p float64
+			if err := rows.Scan(&s, &p); err != nil {
+				return nil, err
+			}
+			if _, ok := prices[s]; !ok {
+				prices[s] = p
+			}
+		}
+		return prices, rows.Err()
+	}
+	return prices, nil
+}
+
+// evaluationSymbols kararı puanlamak için fiyatı gereken semboller
+func evaluationSymbols(d evalDecision) []string {
+	var symbols []string
+	switch {
+	case len(evaluationLegs(d)) > 0:
+		for _, l := range evaluationLegs(d) {
+			symbols = append(symbols, l.StockSymbol)
+		}
+	case len(d.Snapshot.Holdings) > 0:
+		for _, h := range d.Snapshot.Holdings {
+			symbols = append(symbols, h.Symbol)
+		}
+	default:
+		for _, p := range d.Snapshot.Prices {
+			symbols = append(symbols, p.Symbol)
+		}
+	}
+	return symbols
+}
+
+// evaluationLegs kararın alım/satım bacakları (BUY/SELL tek bacak, MULTI/REBALANCE emirleri)
+func evaluationLegs(d evalDecision) []models.Order {
+	switch strings.ToUpper(d.Action) {
+	case models.ActionBuy, models.ActionSell:
+		if d.Symbol == "" {
+			return nil
+		}
+		return []models.Order{{Action: strings.ToUpper(d.Action), StockSymbol: d.Symbol, Quantity: d.Quantity}}
+	case models.ActionMulti, models.ActionRebalance:
+		return d.Orders
+	}
+	return nil
+}
+
+func snapshotPrices(s models.MarketSnapshot) map[string]float64 {
+	prices := make(map[string]float64, len(s.Prices))
+	for _, p := range s.Prices {
+		if p.Price > 0 {
+			prices[p.Symbol] = p.Price
+		}
+	}
+	return prices
+}
+
+// scoreDecision kararı başlangıç ve ufuk fiyatlarıyla puanlar.
+//   - Alım/satım bacakları: K/Z = Σ yön × adet × (son − ilk); isabet K/Z (adet 0 ise yön) > 0
+//   - HOLD ve pozisyon varsa: pozisyonların K/Z'si; isabet zarar etmemesi
+//   - HOLD ve pozisyon yoksa: nakitte kalmak, piyasa (eşit ağırlıklı) yükselmediyse isabet
+//
+// Gerekli fiyatlardan biri eksikse false döner.
+func scoreDecision(d evalDecision, start, end map[string]float64) (evalScore, bool) {
+	if legs := evaluationLegs(d); len(legs) > 0 {
+		var pl, dirPL, invested float64
+		for _, l := range legs {
+			p0, ok0 := start[l.StockSymbol]
+			p1, ok1 := end[l.StockSymbol]
+			if !ok0 || !ok1 || p0 <= 0 {
+				return evalScore{}, false
+			}
+			sign := 1.0
+			if strings.ToUpper(l.Action) == models.ActionSell {
+				sign = -1
+			}
+			qty := float64(l.Quantity)
+			unit := math.Max(qty, 1)
+			pl += sign * qty * (p1 - p0)
+			dirPL += sign * unit * (p1 - p0)
+			invested += unit * p0
+		}
+		return evalScore{ReturnPct: dirPL / invested * 100, ProfitLoss: pl, Hit: dirPL > 0}, true
+	}
+
+	if strings.ToUpper(d.Action) != models.ActionHold {
+		return evalScore{}, false
+	}
+	if len(d.Snapshot.Holdings) > 0 {
+		var pl, invested float64
+		for _, h := range d.Snapshot.Holdings {
+			p0, ok0 := start[h.Symbol]
+			p1, ok1 := end[h.Symbol]
+			if !ok0 || !ok1 || p0 <= 0 {
+				return evalScore{}, false
+			}
+			pl += float64(h.Quantity) * (p1 - p0)
+			invested += float64(h.Quantity) * p0
+		}
+		if invested <= 0 {
+			return evalScore{}, false
+		}
+		return evalScore{ReturnPct: pl / invested * 100, ProfitLoss: pl, Hit: pl >= 0}, true
+	}
+
+	var sum float64
+	n := 0
+	for _, p := range d.Snapshot.Prices {
+		p0, ok0 := start[p.Symbol]
+		p1, ok1 := end[p.Symbol]
+		if ok0 && ok1 && p0 > 0 {
+			sum += (p1 - p0) / p0
+			n++
+		}
+	}
+	if n == 0 {
+		return evalScore{}, false
+	}
+	market := sum / float64(n) * 100
+	return evalScore{ReturnPct: market, ProfitLoss: 0, Hit: market <= 0}, true
+}
+
+// tradesProfitLoss gerçekleşen işlemlerin ufuk fiyatına göre komisyon sonrası K/Z'si
+func tradesProfitLoss(trades []evalTrade, end map[string]float64) (float64, bool) {
+	var pl float64
+	for _, t := range trades {
+		p1, ok := end[t.Symbol]
+		if !ok {
+			return 0, false
+		}
+		if t.Type == models.ActionBuy {
+			pl += (p1-t.Price)*float64(t.Quantity) - t.Commission
+		} else {
+			pl += (t.Price-p1)*float64(t.Quantity) - t.Commission
+		}
+	}
+	return pl, true
+}
+
+// calibrationSample bir kararın beyan edilen güveni (%) ve isabeti
+type calibrationSample struct {
+	Confidence float64
+	Hit        bool
+}
+
+// calibrate güven bantlarına göre kalibrasyon eğrisini ve Brier skorunu hesaplar.
+// Brier skoru = ortalama (güven/100 − isabet)²; boş bantlar da listelenir.
+func calibrate(samples []calibrationSample) models.Calibration {
+	cal := models.Calibration{Samples: len(samples), Bins: make([]models.CalibrationBin, calibrationBins)}
+	width := 100.0 / calibrationBins
+	hitsPerBin := make([]int, calibrationBins)
+	for i := range cal.Bins {
+		cal.Bins[i].Lower = float64(i) * width
+		cal.Bins[i].Upper = float64(i+1) * width
+	}
+	if len(samples) == 0 {
+		return cal
+	}
+
+	var brier float64
+	hits := 0
+	for _, s := range samples {
+		p := math.Min(math.Max(s.Confidence, 0), 100)
+		o := 0.0
+		if s.Hit {
+			o = 1
+			hits++
+		}
+		brier += (p/100 - o) * (p/100 - o)
+		cal.AvgConfidence += p
+
+		bin := int(p / width)
+		if bin >= calibrationBins {
+			bin = calibrationBins - 1
+		}
+		cal.Bins[bin].Count++
+		cal.Bins[bin].AvgConfidence += p
+		if s.Hit {
+			hitsPerBin[bin]++
+		}
+	}
+	n := float64(len(samples))
+	cal.BrierScore = brier / n
+	cal.AvgConfidence /= n
+	cal.HitRate = float64(hits) / n * 100
+	for i := range cal.Bins {
+		if c := cal.Bins[i].Count; c > 0 {
+			cal.Bins[i].AvgConfidence /= float64(c)
+			cal.Bins[i].HitRate = float64(hitsPerBin[i]) / float64(c) * 100
+		}
+	}
+	return cal
+}
+
+// Calibrations ajanların bir ufuktaki kalibrasyon eğrilerini ve Brier skorlarını
+// döndürür; agentID verilirse yalnızca o ajan
+func Calibrations(ctx context.Context, db *pgxpool.Pool, horizon string, agentID *uuid.UUID) ([]models.Calibration, error) {
+	known := false
+	for _, h := range EvaluationHorizons {
+		known = known || h.Name == horizon
+	}
+	if !known {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHorizon, horizon)
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT e.agent_id, a.name, COALESCE(e.confidence, 0), e.hit
+		FROM decision_evaluations e
+		JOIN agents a ON a.id = e.agent_id
+		WHERE e.horizon = $1 AND e.status = 'scored' AND e.hit IS NOT NULL
+		  AND ($2::uuid IS NULL OR e.agent_id = $2)
+		ORDER BY a.name`, horizon, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var order []uuid.UUID
+	names := map[uuid.UUID]string{}
+	samples := map[uuid.UUID][]calibrationSample{}
+	for rows.Next() {
+		var id uuid.UUID
+		var name string
+		var s calibrationSample
+		if err := rows.Scan(&id, &name, &s.Confidence, &s.Hit); err != nil {
+			return nil, err
+		}
+		if _, seen := names[id]; !seen {
+			order = append(order, id)
+			names[id] = name
+		}
+		samples[id] = append(samples[id], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Calibration, 0, len(order))
+	for _, id := range order {
+		cal := calibrate(samples[id])
+		cal.AgentID, cal.AgentName, cal.Horizon = id, names[id], horizon
+		result = append(result, cal)
+	}
+	return result, nil
+}
+
+// DecisionEvaluations bir kararın tüm ufuklardaki puanlarını döndürür
+func DecisionEvaluations(ctx context.Context, db *pgxpool.Pool, decisionID uuid.UUID) ([]models.DecisionEvaluation, error) {
+	rows, err := db.Query(ctx, `
+		SELECT decision_id, agent_id, horizon, action, COALESCE(confidence, 0), status,
+		       return_pct, profit_loss, trade_profit_loss, hit, evaluated_at
+		FROM decision_evaluations
+		WHERE decision_id = $1
+		ORDER BY evaluated_at`, decisionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	evals := []models.DecisionEvaluation{}
+	for rows.Next() {
+		var e models.DecisionEvaluation
+		if err := rows.Scan(&e.DecisionID, &e.AgentID, &e.Horizon, &e.Action, &e.Confidence, &e.Status,
+			&e.ReturnPct, &e.ProfitLoss, &e.TradeProfitLoss, &e.Hit, &e.EvaluatedAt); err != nil {
+			return nil, err
+		}
+		evals = append(evals, e)
+	}
+	return evals, rows.Err()
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestScoreDecision(t *testing.T) {
+	start := map[string]float64{"THYAO": 100, "AKBNK": 50, "SISE": 40}
+	end := map[string]float64{"THYAO": 110, "AKBNK": 45, "SISE": 40}
+
+	cases := []struct {
+		name    string
+		d       evalDecision
+		wantRet float64
+		wantPL  float64
+		wantHit bool
+	}{
+		{"buy that rose", evalDecision{Action: "BUY", Symbol: "THYAO", Quantity: 10}, 10, 100, true},
+		{"sell before a drop", evalDecision{Action: "SELL", Symbol: "AKBNK", Quantity: 20}, 10, 100, true},
+		{"buy with zero quantity scores direction only", evalDecision{Action: "BUY", Symbol: "AKBNK"}, -10, 0, false},
+		{"multi nets its legs", evalDecision{Action: "MULTI", Orders: []models.Order{
+			{Action: "BUY", StockSymbol: "THYAO", Quantity: 10},
+			{Action: "BUY", StockSymbol: "AKBNK", Quantity: 10},
+		}}, 50.0 / 1500 * 100, 50, true},
+		{"hold with a losing position", evalDecision{Action: "HOLD", Snapshot: models.MarketSnapshot{
+			Holdings: []models.SnapshotHolding{{Symbol: "AKBNK", Quantity: 10}},
+		}}, -10, -50, false},
+		{"hold in cash while the market rose", evalDecision{Action: "HOLD", Snapshot: models.MarketSnapshot{
+			Prices: []models.SnapshotPrice{{Symbol: "THYAO"}, {Symbol: "SISE"}},
+		}}, 5, 0, false},
+	}
+	for _, c := range cases {
+		got, ok := scoreDecision(c.d, start, end)
+		if !ok {
+			t.Errorf("%s: not scored", c.name)
+			continue
+		}
+		if !approx(got.ReturnPct, c.wantRet) || !approx(got.ProfitLoss, c.wantPL) || got.Hit != c.wantHit {
+			t.Errorf("%s: got %+v, want return %.4f pl %.2f hit %v", c.name, got, c.wantRet, c.wantPL, c.wantHit)
+		}
+	}
+
+	if _, ok := scoreDecision(evalDecision{Action: "BUY", Symbol: "GARAN", Quantity: 1}, start, end); ok {
+		t.Error("decision without prices should not be scored")
+	}
+	if _, ok := scoreDecision(evalDecision{Action: "HOLD"}, start, end); ok {
+		t.Error("HOLD without a snapshot should not be scored")
+	}
+}
+
+func TestTradesProfitLoss(t *testing.T) {
+	trades := []evalTrade{
+		{Type: "BUY", Symbol: "THYAO", Quantity: 10, Price: 100, Commission: 1},
+		{Type: "SELL", Symbol: "AKBNK", Quantity: 10, Price: 50, Commission: 0.5},
+	}
+	pl, ok := tradesProfitLoss(trades, map[string]float64{"THYAO": 105, "AKBNK": 48})
+	if !ok || !approx(pl, 50-1+20-0.5) {
+		t.Errorf("tradesProfitLoss = %v, %v", pl, ok)
+	}
+	if _, ok := tradesProfitLoss(trades, map[string]float64{"THYAO": 105}); ok {
+		t.Error("missing price should not be scored")
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	samples := []calibrationSample{
+		{Confidence: 90, Hit: true},
+		{Confidence: 90, Hit: false},
+		{Confidence: 95, Hit: true},
+		{Confidence: 30, Hit: false},
+		{Confidence: 100, Hit: true},
+	}
+	cal := calibrate(samples)
+	if cal.Samples != 5 || !approx(cal.HitRate, 60) || !approx(cal.AvgConfidence, 81) {
+		t.Errorf("calibration = %+v", cal)
+	}
+	// (0.01 + 0.81 + 0.0025 + 0.09 + 0) / 5
+	if !approx(cal.BrierScore, 0.9125/5) {
+		t.Errorf("brier = %v", cal.BrierScore)
+	}
+	if len(cal.Bins) != 10 {
+		t.Fatalf("bins = %d", len(cal.Bins))
+	}
+	top := cal.Bins[9] // 90-100, includes confidence 100
+	if top.Count != 4 || !approx(top.HitRate, 75) || !approx(top.AvgConfidence, 93.75) {
+		t.Errorf("top bin = %+v", top)
+	}
+	if cal.Bins[3].Count != 1 || cal.Bins[3].HitRate != 0 {
+		t.Errorf("30-40 bin = %+v", cal.Bins[3])
+	}
+
+	if empty := calibrate(nil); empty.Samples != 0 || empty.BrierScore != 0 || len(empty.Bins) != 10 {
+		t.Errorf("empty calibration = %+v", empty)
+	}
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+)
+
+var (
+	// ErrExperimentNotFound deney bulunamadığında döner
+	ErrExperimentNotFound = errors.New("experiment not found")
+	// ErrInvalidExperiment deney tanımı geçersiz olduğunda döner
+	ErrInvalidExperiment = errors.New("invalid experiment")
+)
+
+const (
+	experimentCacheTTL = 30 * time.Second
+	// minExperimentSample bir varyant karşılaştırmasının anlamlı sayılması için gereken gerçekleşmiş işlem sayısı
+	minExperimentSample = 30
+	significanceLevel   = 0.05
+)
+
+// ExperimentAssignment bir karar için seçilen deney varyantı
+type ExperimentAssignment struct {
+	ExperimentID uuid.UUID
+	Variant      models.ExperimentVariant
+}
+
+// ExperimentService prompt/strateji A/B deneylerini yönetir ve raporlar
+type ExperimentService struct {
+	db      *pgxpool.Pool
+	prompts *ai.PromptRegistry
+
+	mu       sync.Mutex
+	running  []models.PromptExperiment
+	loadedAt time.Time
+}
+
+// NewExperimentService yeni bir deney servisi oluşturur
+func NewExperimentService(db *pgxpool.Pool, prompts *ai.PromptRegistry) *ExperimentService {
+	if prompts == nil {
+		prompts = ai.DefaultPrompts()
+	}
+	return &ExperimentService{db: db, prompts: prompts}
+}
+
+const experimentColumns = `
+	id, name, COALESCE(description, ''), status, assignment, variants,
+	COALESCE(agent_ids::text[], '{}'), started_at, ended_at, created_at`
+
+func scanExperiment(row pgx.Row) (models.PromptExperiment, error) {
+	var e models.PromptExperiment
+	var agentIDs []string
+	if err := row.Scan(&e.ID, &e.Name, &e.Description, &e.Status, &e.Assignment, &e.Variants,
+		&agentIDs, &e.StartedAt, &e.EndedAt, &e.CreatedAt); err != nil {
+		return e, err
+	}
+	e.AgentIDs = make([]uuid.UUID, 0, len(agentIDs))
+	for _, s := range agentIDs {
+		if id, err := uuid.Parse(s); err == nil {
+			e.AgentIDs = append(e.AgentIDs, id)
+		}
+	}
+	return e, nil
+}
+
+// Assign ajanın bir sonraki kararı için varyant seçer. Ajanı kapsayan çalışan
+// bir deney yoksa nil döner. Birden fazla deney varsa en son başlayan kazanır.
+func (s *ExperimentService) Assign(ctx context.Context, agentID uuid.UUID) (*ExperimentAssignment, error) {
+	running, err := s.runningExperiments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, exp := range running {
+		if !experimentCovers(exp, agentID) || len(exp.Variants) == 0 {
+			continue
+		}
+
+		var idx int
+		switch exp.Assignment {
+		case models.AssignmentAlternate:
+			// Ajanın bu deneydeki karar sayısına göre sırayla dön
+			var n int
+			if err := s.db.QueryRow(ctx,
+				`SELECT COUNT(*) FROM agent_decisions WHERE agent_id = $1 AND experiment_id = $2`,
+				agentID, exp.ID).Scan(&n); err != nil {
+				return nil, fmt.Errorf("count experiment decisions: %w", err)
+			}
+			idx = n % len(exp.Variants)
+		default:
+			// Ajan başına sabit varyant: deney + ajan kimliğinden kararlı hash
+			h := fnv.New32a()
+			_, _ = h.Write(exp.ID[:])
+			_, _ = h.Write(agentID[:])
+			idx = int(h.Sum32() % uint32(len(exp.Variants)))
+		}
+
+		return &ExperimentAssignment{ExperimentID: exp.ID, Variant: exp.Variants[idx]}, nil
+	}
+	return nil, nil
+}
+
+func experimentCovers(exp models.PromptExperiment, agentID uuid.UUID) bool {
+	if len(exp.AgentIDs) == 0 {
+		return true
+	}
+	for _, id := range exp.AgentIDs {
+		if id == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// runningExperiments çalışan deneyleri kısa süreli önbellekle döndürür
+func (s *ExperimentService) runningExperiments(ctx context.Context) ([]models.PromptExperiment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil && time.Since(s.loadedAt) < experimentCacheTTL {
+		return s.running, nil
+	}
+
+	rows, err := s.db.Query(ctx, `SELECT`+experimentColumns+`
+		FROM prompt_experiments WHERE status = 'running' ORDER BY started_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("load running experiments: %w", err)
+	}
+	defer rows.Close()
+
+	running := []models.PromptExperiment{}
+	for rows.Next() {
+		exp, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		running = append(running, exp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	s.running, s.loadedAt = running, time.Now()
+	return running, nil
+}
+
+func (s *ExperimentService) invalidate() {
+	s.mu.Lock()
+	s.running = nil
+	s.mu.Unlock()
+}
+
+// Create yeni bir deney oluşturur ve hemen başlatır
+func (s *ExperimentService) Create(ctx context.Context, req models.CreateExperimentRequest) (*models.PromptExperiment, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidExperiment)
+	}
+	if req.Assignment == "" {
+		req.Assignment = models.AssignmentPerAgent
+	}
+	if req.Assignment != models.AssignmentPerAgent && req.Assignment != models.AssignmentAlternate {
+		return nil, fmt.Errorf("%w: assignment must be %q or %q", ErrInvalidExperiment, models.AssignmentPerAgent, models.AssignmentAlternate)
+	}
+	if len(req.Variants) < 2 {
+		return nil, fmt.Errorf("%w: at least two variants are required", ErrInvalidExperiment)
+	}
+	seen := make(map[string]bool, len(req.Variants))
+	for i, v := range req.Variants {
+		v.Name = strings.TrimSpace(v.Name)
+		if v.Name == "" || seen[v.Name] {
+			return nil, fmt.Errorf("%w: variant %d needs a unique name", ErrInvalidExperiment, i+1)
+		}
+		seen[v.Name] = true
+		if v.PromptSet != "" {
+			if _, ok := s.prompts.Get(v.PromptSet); !ok {
+				return nil, fmt.Errorf("%w: unknown prompt set %q", ErrInvalidExperiment, v.PromptSet)
+			}
+		}
+		req.Variants[i] = v
+	}
+
+	agentIDs := make([]string, 0, len(req.AgentIDs))
+	for _, id := range req.AgentIDs {
+		agentIDs = append(agentIDs, id.String())
+	}
+
+	row := s.db.QueryRow(ctx, `
+		INSERT INTO prompt_experiments (name, description, assignment, variants, agent_ids)
+		VALUES ($1, $2, $3, $4, $5::uuid[])
+		RETURNING`+experimentColumns,
+		req.Name, req.Description, req.Assignment, req.Variants, agentIDs)
+	exp, err := scanExperiment(row)
+	if err != nil {
+		return nil, fmt.Errorf("create experiment: %w", err)
+	}
+	s.invalidate()
+	return &exp, nil
+}
+
+// Stop çalışan bir deneyi durdurur; toplanan kararlar rapor için saklanır
+func (s *ExperimentService) Stop(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE prompt_experiments SET status = 'stopped', ended_at = NOW()
+		WHERE id = $1 AND status = 'running'`, id)
+	if err != nil {
+		return fmt.Errorf("stop experiment: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrExperimentNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+// List tüm deneyleri en yeniden eskiye döndürür
+func (s *ExperimentService) List(ctx context.Context) ([]models.PromptExperiment, error) {
+	rows, err := s.db.Query(ctx, `SELECT`+experimentColumns+` FROM prompt_experiments ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiments := []models.PromptExperiment{}
+	for rows.Next() {
+		exp, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, exp)
+	}
+	return experiments, rows.Err()
+}
+
+// Get tek bir deneyi döndürür
+func (s *ExperimentService) Get(ctx context.Context, id uuid.UUID) (*models.PromptExperiment, error) {
+	exp, err := scanExperiment(s.db.QueryRow(ctx, `SELECT`+experimentColumns+` FROM prompt_experiments WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrExperimentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &exp, nil
+}
+
+// experimentSample bir varyantın rapor için toplanan ham verisi
+type experimentSample struct {
+	agents     map[uuid.UUID]bool
+	decisions  int
+	holds      int
+	executed   int
+	confidence float64
+	profitLoss []float64
+	wins       int
+}
+
+// Report varyantları kontrol grubuna (ilk varyant) karşı karşılaştırır.
+// K/Z, karar kaydında gerçekleşen K/Z yoksa işlemin güncel fiyata göre değerlemesidir.
+func (s *ExperimentService) Report(ctx context.Context, id uuid.UUID) (*models.ExperimentReport, error) {
+	exp, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT d.experiment_variant, d.agent_id, d.decision, COALESCE(d.executed, FALSE), d.confidence_score,
+		       COALESCE(d.actual_profit_loss,
+		           CASE
+		               WHEN t.id IS NULL THEN NULL
+		               WHEN t.trade_type = 'BUY' THEN (s.current_price - t.price) * t.quantity - t.commission
+		               ELSE (t.price - s.current_price) * t.quantity - t.commission
+		           END)
+		FROM agent_decisions d
+		LEFT JOIN trades t ON t.id = d.trade_id
+		LEFT JOIN stocks s ON s.symbol = t.stock_symbol
+		WHERE d.experiment_id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("load experiment decisions: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make(map[string]*experimentSample, len(exp.Variants))
+	for _, v := range exp.Variants {
+		samples[v.Name] = &experimentSample{agents: map[uuid.UUID]bool{}}
+	}
+	for rows.Next() {
+		var (
+			variant    string
+			agentID    uuid.UUID
+			action     string
+			executed   bool
+			confidence float64
+			pl         *float64
+		)
+		if err := rows.Scan(&variant, &agentID, &action, &executed, &confidence, &pl); err != nil {
+			return nil, err
+		}
+		smp, ok := samples[variant]
+		if !ok {
+			continue
+		}
+		smp.agents[agentID] = true
+		smp.decisions++
+		smp.confidence += confidence
+		if action == "HOLD" {
+			smp.holds++
+		}
+		if executed {
+			smp.executed++
+		}
+		if pl != nil {
+			smp.profitLoss = append(smp.profitLoss, *pl)
+			if *pl > 0 {
+				smp.wins++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &models.ExperimentReport{Experiment: *exp, GeneratedAt: time.Now()}
+	for _, v := range exp.Variants {
+		report.Variants = append(report.Variants, variantStats(v.Name, samples[v.Name]))
+	}
+
+	control := exp.Variants[0].Name
+	for _, v := range exp.Variants[1:] {
+		report.Comparisons = append(report.Comparisons, compareVariants(v.Name, samples[v.Name], control, samples[control]))
+	}
+	return report, nil
+}
+
+func variantStats(name string, smp *experimentSample) models.VariantStats {
+	st := models.VariantStats{
+		Variant:   name,
+		Agents:    len(smp.agents),
+		Decisions: smp.decisions,
+		Holds:     smp.holds,
+		Executed:  smp.executed,
+		Wins:      smp.wins,
+	}
+	if smp.decisions > 0 {
+		st.ExecutionRate = float64(smp.executed) / float64(smp.decisions) * 100
+		st.AvgConfidence = smp.confidence / float64(smp.decisions)
+	}
+	if n := len(smp.profitLoss); n > 0 {
+		st.WinRate = float64(smp.wins) / float64(n) * 100
+		for _, pl := range smp.profitLoss {
+			st.TotalPL += pl
+		}
+		st.MeanPL, st.StdDevPL = meanStdDev(smp.profitLoss)
+	}
+	return st
+}
+
+func compareVariants(name string, smp *experimentSample, control string, ctrl *experimentSample) models.VariantComparison {
+	cmp := models.VariantComparison{Variant: name, Control: control}
+
+	meanV, _ := meanStdDev(smp.profitLoss)
+	meanC, _ := meanStdDev(ctrl.profitLoss)
+	cmp.MeanPLDiff = meanV - meanC
+	cmp.TStat, cmp.DegreesFree, cmp.PValuePL = welchTTest(smp.profitLoss, ctrl.profitLoss)
+
+	nV, nC := len(smp.profitLoss), len(ctrl.profitLoss)
+	if nV > 0 && nC > 0 {
+		cmp.WinRateDiff = (float64(smp.wins)/float64(nV) - float64(ctrl.wins)/float64(nC)) * 100
+	}
+	cmp.ZStat, cmp.PValueWin = twoProportionZTest(smp.wins, nV, ctrl.wins, nC)
+
+	cmp.Significant = cmp.PValuePL < significanceLevel || cmp.PValueWin < significanceLevel
+	cmp.SufficientN = nV >= minExperimentSample && nC >= minExperimentSample
+	if !cmp.SufficientN {
+		cmp.Notes = fmt.Sprintf("fewer than %d executed trades in a variant; results are indicative only", minExperimentSample)
+	}
+	return cmp
+}
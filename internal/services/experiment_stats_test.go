@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+func TestWelchTTest(t *testing.T) {
+	a := []float64{27.5, 21.0, 19.0, 23.6, 17.0, 17.9, 16.9, 20.1, 21.9, 22.6, 23.1, 19.6, 19.0, 21.7, 21.4}
+	b := []float64{27.1, 22.0, 20.8, 23.4, 23.4, 23.5, 25.8, 22.0, 24.8, 20.2, 21.9, 22.1, 22.9, 20.5, 24.4}
+
+	tStat, df, p := welchTTest(a, b)
+	if math.Abs(tStat-(-2.46)) > 0.01 {
+		t.Errorf("t = %.4f, want -2.46", tStat)
+	}
+	if math.Abs(df-24.99) > 0.1 {
+		t.Errorf("df = %.4f, want ~25", df)
+	}
+	if math.Abs(p-0.021) > 0.001 {
+		t.Errorf("p = %.4f, want 0.021", p)
+	}
+
+	if _, _, p := welchTTest([]float64{1}, b); p != 1 {
+		t.Errorf("p with n<2 = %v, want 1", p)
+	}
+	if _, _, p := welchTTest([]float64{1, 1}, []float64{1, 1}); p != 1 {
+		t.Errorf("p with zero variance = %v, want 1", p)
+	}
+}
+
+func TestStudentTTailProbability(t *testing.T) {
+	// two-sided critical values of Student's t at alpha = 0.05
+	cases := []struct{ df, t float64 }{{1, 12.706}, {5, 2.571}, {10, 2.228}, {30, 2.042}}
+	for _, c := range cases {
+		if p := regIncBeta(c.df/2, 0.5, c.df/(c.df+c.t*c.t)); math.Abs(p-0.05) > 0.0005 {
+			t.Errorf("df=%v t=%v: p = %.5f, want 0.05", c.df, c.t, p)
+		}
+	}
+}
+
+func TestTwoProportionZTest(t *testing.T) {
+	z, p := twoProportionZTest(60, 100, 45, 100)
+	if math.Abs(z-2.124) > 0.001 {
+		t.Errorf("z = %.4f, want 2.124", z)
+	}
+	if math.Abs(p-0.0337) > 0.0005 {
+		t.Errorf("p = %.4f, want 0.0337", p)
+	}
+	if _, p := twoProportionZTest(0, 0, 5, 10); p != 1 {
+		t.Errorf("p with empty sample = %v, want 1", p)
+	}
+}
+
+func TestExperimentAssignPerAgent(t *testing.T) {
+	exp := models.PromptExperiment{
+		ID:         uuid.New(),
+		Assignment: models.AssignmentPerAgent,
+		Variants:   []models.ExperimentVariant{{Name: "control"}, {Name: "turkish", PromptSet: "default-v1-tr"}},
+	}
+	svc := NewExperimentService(nil, nil)
+	svc.running, svc.loadedAt = []models.PromptExperiment{exp}, time.Now()
+
+	counts := map[string]int{}
+	for i := 0; i < 200; i++ {
+		agentID := uuid.New()
+		first, err := svc.Assign(context.Background(), agentID)
+		if err != nil || first == nil {
+			t.Fatalf("Assign() = %v, %v", first, err)
+		}
+		again, _ := svc.Assign(context.Background(), agentID)
+		if again.Variant.Name != first.Variant.Name {
+			t.Fatalf("agent %s switched variant %s -> %s", agentID, first.Variant.Name, again.Variant.Name)
+		}
+		counts[first.Variant.Name]++
+	}
+	if counts["control"] < 60 || counts["turkish"] < 60 {
+		t.Errorf("unbalanced assignment: %v", counts)
+	}
+
+	// experiments limited to specific agents leave the others untouched
+	svc.running[0].AgentIDs = []uuid.UUID{uuid.New()}
+	if a, _ := svc.Assign(context.Background(), uuid.New()); a != nil {
+		t.Errorf("Assign() for uncovered agent = %+v, want nil", a)
+	}
+}
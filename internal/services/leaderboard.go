@@ -2,10 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/performance"
 	"github.com/1batu/market-ai/internal/websocket"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
@@ -15,12 +19,26 @@ type LeaderboardService struct {
 	db       *pgxpool.Pool
 	hub      *websocket.Hub
 	interval time.Duration
+
+	benchmarks *BenchmarkService // optional
+	badges     *BadgeEngine      // optional
+	boards     *BoardRanker      // optional; without it rank_overall is the ROI rank
 }
 
 func NewLeaderboardService(db *pgxpool.Pool, hub *websocket.Hub, interval time.Duration) *LeaderboardService {
 	return &LeaderboardService{db: db, hub: hub, interval: interval}
 }
 
+// SetBenchmarks enables benchmark snapshots and per-agent alpha/beta on every update
+func (ls *LeaderboardService) SetBenchmarks(bs *BenchmarkService) { ls.benchmarks = bs }
+
+// SetBoards scores agents on the named boards on every update; the default
+// board's order becomes rank_overall
+func (ls *LeaderboardService) SetBoards(br *BoardRanker) { ls.boards = br }
+
+// SetBadges evaluates the snapshot badge rules on every update
+func (ls *LeaderboardService) SetBadges(be *BadgeEngine) { ls.badges = be }
+
 // Start begins periodic updates
 func (ls *LeaderboardService) Start(ctx context.Context) {
 	ls.update(ctx)
@@ -39,10 +57,18 @@ func (ls *LeaderboardService) Start(ctx context.Context) {
 }
 
 func (ls *LeaderboardService) update(ctx context.Context) {
+	ls.updatePerformance(ctx)
+	ls.updateSeasonTrades(ctx)
+
 	if _, err := ls.db.Exec(ctx, "SELECT update_leaderboard_rankings()"); err != nil {
 		log.Error().Err(err).Msg("Failed to update leaderboard rankings")
 		return
 	}
+	if ls.boards != nil {
+		if err := ls.boards.Update(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to rank leaderboard boards")
+		}
+	}
 
 	// Insert performance snapshots (lightweight ROI history) per agent from agent_metrics
 	snapshotInsert := `
@@ -71,6 +97,26 @@ func (ls *LeaderboardService) update(ctx context.Context) {
 		log.Warn().Err(err).Msg("Failed to insert performance snapshots")
 	}
 
+	if ls.benchmarks != nil {
+		ls.benchmarks.Update(ctx)
+	}
+
+	if ls.badges != nil {
+		ls.badges.OnSnapshot(ctx)
+	}
+	// leaderboard_rankings.badges lists the ids of every badge the agent holds
+	if _, err := ls.db.Exec(ctx, `
+		UPDATE leaderboard_rankings lr SET badges = b.badges
+		FROM (
+			SELECT agent_id, array_agg(DISTINCT badge ORDER BY badge) AS badges
+			FROM agent_badges GROUP BY agent_id
+		) b
+		WHERE b.agent_id = lr.agent_id`); err != nil {
+		log.Warn().Err(err).Msg("Failed to update leaderboard badges")
+	}
+
+	ls.updateShadow(ctx)
+
 	entries, err := ls.getCurrent(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch leaderboard")
@@ -80,6 +126,54 @@ func (ls *LeaderboardService) update(ctx context.Context) {
 	log.Info().Int("agents", len(entries)).Msg("Leaderboard updated")
 }
 
+// updatePerformance recomputes every active agent's performance metrics
+// (Sharpe, drawdown, closed-trade win rate, ...) into agent_metrics
+func (ls *LeaderboardService) updatePerformance(ctx context.Context) {
+	rows, err := ls.db.Query(ctx, `
+		SELECT a.id FROM agents a
+		JOIN agent_metrics am ON am.agent_id = a.id
+		WHERE a.status = 'active'`)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list agents for performance metrics")
+		return
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		m, err := AgentPerformance(ctx, ls.db, id)
+		if err != nil {
+			log.Warn().Err(err).Str("agent_id", id.String()).Msg("Failed to compute performance metrics")
+			continue
+		}
+		if err := savePerformance(ctx, ls.db, id, m); err != nil {
+			log.Warn().Err(err).Str("agent_id", id.String()).Msg("Failed to save performance metrics")
+		}
+	}
+}
+
+// updateSeasonTrades writes the running season's closed-trade win rates to
+// season_participants before update_season_standings ranks on them
+func (ls *LeaderboardService) updateSeasonTrades(ctx context.Context) {
+	var season uuid.UUID
+	err := ls.db.QueryRow(ctx, `SELECT id FROM seasons WHERE status = 'active'`).Scan(&season)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err == nil {
+		err = saveSeasonTrades(ctx, ls.db, ls.db, season)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to update season trade statistics")
+	}
+}
+
 func (ls *LeaderboardService) getCurrent(ctx context.Context) ([]models.LeaderboardEntry, error) {
 	const q = `
         SELECT
@@ -119,3 +213,134 @@ func (ls *LeaderboardService) getCurrent(ctx context.Context) ([]models.Leaderbo
 	}
 	return out, nil
 }
+
+// updateShadow marks shadow ledgers to market, stores their ROI snapshots and
+// broadcasts the shadow leaderboard. Shadow agents never enter leaderboard_rankings.
+func (ls *LeaderboardService) updateShadow(ctx context.Context) {
+	if _, err := ls.db.Exec(ctx, `
+		SELECT update_shadow_metrics(sa.agent_id)
+		FROM shadow_accounts sa
+		JOIN agents a ON a.id = sa.agent_id
+		WHERE a.status = 'shadow'`); err != nil {
+		log.Warn().Err(err).Msg("Failed to update shadow metrics")
+		return
+	}
+	ls.updateShadowPerformance(ctx)
+
+	shadowSnapshotInsert := `
+		INSERT INTO agent_performance_snapshots (
+			agent_id, balance, portfolio_value, total_value,
+			total_profit_loss, roi_percent, total_trades,
+			winning_trades, losing_trades, win_rate, snapshot_time, book
+		)
+		SELECT sa.agent_id,
+			   sa.current_balance,
+			   sm.total_portfolio_value,
+			   sa.current_balance + sm.total_portfolio_value,
+			   sm.total_profit_loss,
+			   sm.roi,
+			   sm.total_trades,
+			   sm.winning_trades,
+			   sm.losing_trades,
+			   sm.win_rate,
+			   NOW(),
+			   'shadow'
+		FROM shadow_accounts sa
+		JOIN shadow_metrics sm ON sm.agent_id = sa.agent_id
+		JOIN agents a ON a.id = sa.agent_id
+		WHERE a.status = 'shadow';`
+	if _, err := ls.db.Exec(ctx, shadowSnapshotInsert); err != nil {
+		log.Warn().Err(err).Msg("Failed to insert shadow performance snapshots")
+	}
+
+	entries, err := ShadowLeaderboard(ctx, ls.db)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch shadow leaderboard")
+		return
+	}
+	if len(entries) > 0 {
+		ls.hub.BroadcastMessage("shadow_leaderboard_updated", entries)
+	}
+}
+
+// updateShadowPerformance recomputes the shadow ledgers' closed-trade win
+// rates the same way as the live ones (average-cost P&L net of commissions)
+// and ranks the shadow agents on the default board
+func (ls *LeaderboardService) updateShadowPerformance(ctx context.Context) {
+	rows, err := ls.db.Query(ctx, `
+		SELECT sm.agent_id FROM shadow_metrics sm
+		JOIN agents a ON a.id = sm.agent_id
+		WHERE a.status = 'shadow'`)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list shadow agents for performance metrics")
+		return
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	perf := make(map[uuid.UUID]performance.Metrics, len(ids))
+	for _, id := range ids {
+		m, err := LedgerPerformance(ctx, ls.db, ShadowLedger, id)
+		if err != nil {
+			log.Warn().Err(err).Str("agent_id", id.String()).Msg("Failed to compute shadow performance metrics")
+			continue
+		}
+		perf[id] = m
+		if err := saveShadowPerformance(ctx, ls.db, id, m); err != nil {
+			log.Warn().Err(err).Str("agent_id", id.String()).Msg("Failed to save shadow performance metrics")
+		}
+	}
+
+	if ls.boards != nil {
+		if err := ls.boards.RankShadow(ctx, perf); err != nil {
+			log.Warn().Err(err).Msg("Failed to rank shadow agents on the default board")
+		}
+	}
+}
+
+// ShadowLeaderboard ranks shadow agents by their shadow ledger on the default
+// board, as scored by the last leaderboard update. Agents not yet scored (or
+// every agent, without boards) follow on ROI.
+func ShadowLeaderboard(ctx context.Context, db *pgxpool.Pool) ([]models.LeaderboardEntry, error) {
+	const q = `
+        SELECT
+            RANK() OVER (ORDER BY sm.rank ASC NULLS LAST, CASE WHEN sm.rank IS NULL THEN sm.roi END DESC),
+            a.id,
+            a.name,
+            a.model,
+            sm.roi,
+            sm.total_profit_loss,
+            sm.win_rate,
+            sm.total_trades,
+            sa.current_balance,
+            sm.total_portfolio_value,
+            sm.calculated_at
+        FROM shadow_metrics sm
+        JOIN shadow_accounts sa ON sa.agent_id = sm.agent_id
+        JOIN agents a ON a.id = sm.agent_id
+        WHERE a.status = 'shadow'
+        ORDER BY 1 ASC`
+
+	rows, err := db.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.LeaderboardEntry{}
+	for rows.Next() {
+		var e models.LeaderboardEntry
+		if err := rows.Scan(&e.Rank, &e.AgentID, &e.AgentName, &e.Model, &e.ROI, &e.ProfitLoss, &e.WinRate, &e.TotalTrades, &e.Balance, &e.PortfolioValue, &e.UpdatedAt); err != nil {
+			log.Error().Err(err).Msg("scan shadow leaderboard row")
+			continue
+		}
+		e.TotalValue = e.Balance + e.PortfolioValue
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
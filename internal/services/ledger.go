@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Ledger bir işlemin hangi defterde (canlı ya da gölge) tutulduğunu tanımlar.
+// Canlı ve gölge defterler aynı dolum mantığını paylaşır; yalnızca tablolar farklıdır.
+type Ledger struct {
+	Name       string // "live" | "shadow"
+	accounts   string // bakiyeyi tutan tablo
+	accountKey string // bakiye tablosundaki ajan sütunu
+	portfolio  string
+	trades     string
+	metricsFn  string // işlem sonrası çağrılan metrik fonksiyonu
+}
+
+// Defter adları
+const (
+	BookLive   = "live"
+	BookShadow = "shadow"
+)
+
+var (
+	// LiveLedger yarışmaya sayılan gerçek bakiye, portföy ve işlemler
+	LiveLedger = Ledger{
+		Name:       BookLive,
+		accounts:   "agents",
+		accountKey: "id",
+		portfolio:  "portfolio",
+		trades:     "trades",
+		metricsFn:  "update_agent_metrics",
+	}
+	// ShadowLedger gölge moddaki ajanların kağıt üzerindeki defteri
+	ShadowLedger = Ledger{
+		Name:       BookShadow,
+		accounts:   "shadow_accounts",
+		accountKey: "agent_id",
+		portfolio:  "shadow_portfolio",
+		trades:     "shadow_trades",
+		metricsFn:  "update_shadow_metrics",
+	}
+)
+
+// LedgerFor ajan durumuna göre defteri seçer
+func LedgerFor(status string) Ledger {
+	if status == "shadow" {
+		return ShadowLedger
+	}
+	return LiveLedger
+}
+
+// IsShadow gölge defter mi
+func (l Ledger) IsShadow() bool { return l.Name == BookShadow }
+
+func (l Ledger) balanceQuery() string {
+	return fmt.Sprintf("SELECT current_balance FROM %s WHERE %s = $1", l.accounts, l.accountKey)
+}
+
+func (l Ledger) balanceUpdate() string {
+	return fmt.Sprintf("UPDATE %s SET current_balance = current_balance + $1 WHERE %s = $2", l.accounts, l.accountKey)
+}
+
+// execer hem havuz hem işlem (tx) için ortak Exec arayüzü
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// EnsureAccount gölge hesabı yoksa ajanın başlangıç bakiyesiyle açar; canlı defterde işlem yapmaz
+func (l Ledger) EnsureAccount(ctx context.Context, db execer, agentID uuid.UUID) error {
+	if !l.IsShadow() {
+		return nil
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO shadow_accounts (agent_id, initial_balance, current_balance)
+		SELECT id, initial_balance, initial_balance FROM agents WHERE id = $1
+		ON CONFLICT (agent_id) DO NOTHING
+	`, agentID)
+	return err
+}
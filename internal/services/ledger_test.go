@@ -0,0 +1,28 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLedgerFor(t *testing.T) {
+	if l := LedgerFor("shadow"); !l.IsShadow() || l.Name != BookShadow {
+		t.Fatalf("shadow status should use shadow ledger, got %+v", l)
+	}
+	for _, status := range []string{"active", "paused", ""} {
+		if l := LedgerFor(status); l.IsShadow() {
+			t.Fatalf("status %q should use live ledger", status)
+		}
+	}
+}
+
+func TestLedgerQueriesUseOwnTables(t *testing.T) {
+	shadow := ShadowLedger.balanceQuery() + ShadowLedger.balanceUpdate()
+	if !strings.Contains(shadow, "shadow_accounts") || strings.Contains(shadow, "agents") {
+		t.Fatalf("shadow balance SQL touches the wrong table: %s", shadow)
+	}
+	live := LiveLedger.balanceQuery() + LiveLedger.balanceUpdate()
+	if !strings.Contains(live, "FROM agents") || strings.Contains(live, "shadow") {
+		t.Fatalf("live balance SQL touches the wrong table: %s", live)
+	}
+}
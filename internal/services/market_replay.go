@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
+	"github.com/1batu/market-ai/internal/websocket"
+)
+
+// marketDataPage bir sorguda okunan en fazla bar sayısı
+const marketDataPage = 5000
+
+// MarketDataSource market_data tablosundaki barları zaman sırasıyla sayfa
+// sayfa okuyan bir bar kaynağıdır
+type MarketDataSource struct {
+	db        *pgxpool.Pool
+	timeframe string
+	to        time.Time // sıfır = sınırsız
+	cursor    time.Time
+	buf       *simulation.SliceSource
+	done      bool
+}
+
+// NewMarketDataSource [from, to) aralığındaki timeframe barlarını okur
+func NewMarketDataSource(db *pgxpool.Pool, timeframe string, from, to time.Time) *MarketDataSource {
+	return &MarketDataSource{db: db, timeframe: timeframe, to: to, cursor: from.Add(-time.Microsecond)}
+}
+
+// Next bir sonraki bar grubunu döndürür
+func (s *MarketDataSource) Next(ctx context.Context) (time.Time, []simulation.Bar, error) {
+	for {
+		if s.buf != nil {
+			at, bars, err := s.buf.Next(ctx)
+			if err != io.EOF {
+				return at, bars, err
+			}
+		}
+		if s.done {
+			return time.Time{}, nil, io.EOF
+		}
+		if err := s.fill(ctx); err != nil {
+			return time.Time{}, nil, err
+		}
+	}
+}
+
+// fill bir sonraki sayfayı okur; sayfa doluysa son zaman damgasının barları
+// eksik olabileceğinden bir sonraki sayfaya bırakılır
+func (s *MarketDataSource) fill(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT stock_symbol, timestamp, open_price, high_price, low_price, close_price, COALESCE(volume, 0)
+		FROM market_data
+		WHERE timeframe = $1 AND timestamp > $2 AND ($3::timestamp IS NULL OR timestamp < $3)
+		ORDER BY timestamp, stock_symbol
+		LIMIT $4`, s.timeframe, s.cursor, nullTime(s.to), marketDataPage)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var bars []simulation.Bar
+	for rows.Next() {
+		var b simulation.Bar
+		if err := rows.Scan(&b.Symbol, &b.Time, &b.Open, &b.High, &b.Low, &b.Close, &b.Volume); err != nil {
+			return err
+		}
+		bars = append(bars, b)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(bars) < marketDataPage {
+		s.done = true
+	} else if last := bars[len(bars)-1].Time; !bars[0].Time.Equal(last) {
+		for len(bars) > 0 && bars[len(bars)-1].Time.Equal(last) {
+			bars = bars[:len(bars)-1]
+		}
+	}
+	if len(bars) > 0 {
+		s.cursor = bars[len(bars)-1].Time
+	}
+	s.buf = simulation.NewSliceSource(bars)
+	return nil
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// replayState geçmiş oynatmada sembol başına gün içi durum
+type replayState struct {
+	day       string  // barın günü (YYYY-MM-DD)
+	prevClose float64 // önceki günün son kapanışı
+	lastClose float64
+	volume    int64 // gün içi toplam hacim
+}
+
+// NewReplaySimulator stocks fiyatlarını geçmiş barlardan güncelleyen, arşivlenmiş
+// haberleri orijinal zamanlarında yayınlayan bir simülatör oluşturur
+func NewReplaySimulator(db *pgxpool.Pool, hub *websocket.Hub, replay *simulation.Replayer) *MarketSimulator {
+	return &MarketSimulator{
+		db:       db,
+		hub:      hub,
+		replay:   replay,
+		states:   map[string]*replayState{},
+		newsSeen: replay.Clock().Now(),
+	}
+}
+
+// runReplay barları sonuna kadar oynatır; saat son barda kalır
+func (ms *MarketSimulator) runReplay(ctx context.Context) {
+	log.Info().Time("from", ms.replay.Clock().Now()).Msg("Historical replay started")
+	err := ms.replay.Run(ctx, ms.applyBars)
+	switch {
+	case ctx.Err() != nil:
+		log.Info().Msg("Historical replay stopped")
+	case err != nil:
+		log.Error().Err(err).Msg("Historical replay failed")
+	default:
+		log.Info().Time("at", ms.replay.Clock().Now()).Msg("Historical replay finished")
+	}
+}
+
+// applyBars kapanan barların fiyatlarını yazar, o ana kadar yayınlanmış
+// haberleri yayınlar ve sanal saati duyurur
+func (ms *MarketSimulator) applyBars(ctx context.Context, at time.Time, bars []simulation.Bar) error {
+	var updates []models.Stock
+	for _, b := range bars {
+		st := ms.advanceState(b)
+		changePercent := 0.0
+		if st.prevClose > 0 {
+			changePercent = (b.Close - st.prevClose) / st.prevClose * 100
+		}
+		_, err := ms.db.Exec(ctx, `
+			UPDATE stocks
+			SET current_price = $1,
+			    previous_close = $2,
+			    change_percent = $3,
+			    volume = $4,
+			    last_updated = $5
+			WHERE symbol = $6`, b.Close, st.prevClose, changePercent, st.volume, at, b.Symbol)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Error().Err(err).Str("symbol", b.Symbol).Msg("Failed to update price")
+			continue
+		}
+		if ms.candles != nil {
+			ms.candles.ObserveBar(b, ms.replay.Interval())
+		}
+		updates = append(updates, models.Stock{
+			Symbol:        b.Symbol,
+			CurrentPrice:  b.Close,
+			PreviousClose: st.prevClose,
+			ChangePercent: changePercent,
+			Volume:        st.volume,
+			LastUpdated:   at,
+		})
+	}
+	if len(updates) > 0 {
+		ms.hub.BroadcastMessage("price_update", updates)
+	}
+
+	news, err := NewsBetween(ctx, ms.db, ms.newsSeen, at, 50)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to replay news")
+	} else {
+		ms.newsSeen = at
+		if len(news) > 0 {
+			ms.hub.BroadcastMessage("news_update", map[string]interface{}{
+				"count":     len(news),
+				"articles":  news[:min(len(news), 5)],
+				"timestamp": at.Unix(),
+			})
+		}
+	}
+
+	ms.hub.BroadcastMessage("market_clock", map[string]interface{}{
+		"mode":      "replay",
+		"timestamp": at.Unix(),
+	})
+	return nil
+}
+
+// advanceState sembolün gün içi durumunu barla ilerletir; yeni günde önceki
+// günün son kapanışı önceki kapanış olur (ilk gün için ilk barın açılışı)
+func (ms *MarketSimulator) advanceState(b simulation.Bar) *replayState {
+	st, ok := ms.states[b.Symbol]
+	if !ok {
+		st = &replayState{prevClose: b.Open, lastClose: b.Open}
+		ms.states[b.Symbol] = st
+	}
+	if day := b.Time.Format("2006-01-02"); day != st.day {
+		if st.day != "" {
+			st.prevClose = st.lastClose
+		}
+		st.day, st.volume = day, 0
+	}
+	st.lastClose = b.Close
+	st.volume += b.Volume
+	return st
+}
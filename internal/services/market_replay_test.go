@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1batu/market-ai/internal/simulation"
+)
+
+func TestAdvanceStateRollsOverDays(t *testing.T) {
+	ms := &MarketSimulator{states: map[string]*replayState{}}
+	day1 := time.Date(2024, 3, 1, 17, 58, 0, 0, time.UTC)
+	day2 := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	st := ms.advanceState(simulation.Bar{Symbol: "THYAO", Time: day1, Open: 300, Close: 302, Volume: 100})
+	if st.prevClose != 300 || st.volume != 100 {
+		t.Errorf("first bar: %+v, want the open as previous close", *st)
+	}
+	st = ms.advanceState(simulation.Bar{Symbol: "THYAO", Time: day1.Add(time.Minute), Open: 302, Close: 305, Volume: 50})
+	if st.prevClose != 300 || st.volume != 150 {
+		t.Errorf("same day: %+v", *st)
+	}
+	st = ms.advanceState(simulation.Bar{Symbol: "THYAO", Time: day2, Open: 306, Close: 310, Volume: 70})
+	if st.prevClose != 305 || st.volume != 70 || st.lastClose != 310 {
+		t.Errorf("next day: %+v, want previous close 305 and volume reset", *st)
+	}
+}
@@ -2,32 +2,86 @@ package services
 
 import (
 	"context"
-	"math/rand"
+	"math"
 	"time"
 
 	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
 	"github.com/1batu/market-ai/internal/websocket"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 )
 
+// MarketSimulator stocks tablosundaki fiyatları bir fiyat süreciyle (GBM,
+// sıçramalı difüzyon, ortalamaya dönüş) ya da geçmiş barları oynatarak
+// ilerletir ve price_update yayınlar
 type MarketSimulator struct {
-	db  *pgxpool.Pool
-	hub *websocket.Hub
+	db     *pgxpool.Pool
+	hub    *websocket.Hub
+	market *simulation.Market
+	tick   time.Duration
+	dt     float64 // her tikte ilerleyen simüle seans süresi (yıl)
+
+	// DB fiyatı kuruşa yuvarlar; yuvarlanmamış son fiyatı saklamazsak düşük
+	// fiyatlı hisselerde küçük adımlar kaybolur
+	last map[string]float64
+
+	// Geçmiş oynatma kaynağı (nil = fiyat süreci)
+	replay   *simulation.Replayer
+	states   map[string]*replayState
+	newsSeen time.Time // bu ana kadar yayınlanan haberler duyuruldu
+
+	candles *CandleBuilder // isteğe bağlı; fiyatlar mumlara işlenir
+
+	flow *simulation.OrderFlow // isteğe bağlı; ajan işlemlerinin net hacmi fiyatı iter
 }
 
-func NewMarketSimulator(db *pgxpool.Pool, hub *websocket.Hub) *MarketSimulator {
+// NewMarketSimulator her tick aralığında fiyatları speed kat hızlı simüle
+// seans süresi kadar ilerleten bir simülatör oluşturur
+func NewMarketSimulator(db *pgxpool.Pool, hub *websocket.Hub, market *simulation.Market, tick time.Duration, speed float64) *MarketSimulator {
+	if tick <= 0 {
+		tick = 5 * time.Second
+	}
+	if speed <= 0 {
+		speed = 1
+	}
 	return &MarketSimulator{
-		db:  db,
-		hub: hub,
+		db:     db,
+		hub:    hub,
+		market: market,
+		tick:   tick,
+		dt:     simulation.YearFraction(time.Duration(float64(tick) * speed)),
+		last:   map[string]float64{},
+	}
+}
+
+// SetCandleBuilder her fiyat güncellemesini mum oluşturucuya iletir
+func (ms *MarketSimulator) SetCandleBuilder(cb *CandleBuilder) { ms.candles = cb }
+
+// SetOrderFlow ajanların her tikte biriken net alım/satım hacmini fiyat
+// etkisi modeliyle fiyatlara yansıtır (yalnızca fiyat süreci modunda; geçmiş
+// oynatmada fiyatlar kayıttan gelir)
+func (ms *MarketSimulator) SetOrderFlow(f *simulation.OrderFlow) { ms.flow = f }
+
+// Overlay senaryo şoklarının ve rejimlerinin uygulandığı katmanı döndürür;
+// geçmiş oynatmada fiyatlar kayıttan geldiği için nil
+func (ms *MarketSimulator) Overlay() *simulation.Overlay {
+	if ms.replay != nil || ms.market == nil {
+		return nil
 	}
+	return ms.market.Overlay()
 }
 
 func (ms *MarketSimulator) Start(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
+	if ms.replay != nil {
+		ms.runReplay(ctx)
+		return
+	}
+
+	ticker := time.NewTicker(ms.tick)
 	defer ticker.Stop()
 
-	log.Info().Msg("Market simulator started")
+	log.Info().Str("model", ms.market.Model()).Int64("seed", ms.market.Seed()).Dur("tick", ms.tick).Msg("Market simulator started")
 
 	for {
 		select {
@@ -35,46 +89,74 @@ func (ms *MarketSimulator) Start(ctx context.Context) {
 			log.Info().Msg("Market simulator stopped")
 			return
 		case <-ticker.C:
-			ms.updatePrices()
+			ms.updatePrices(ctx)
 		}
 	}
 }
 
-func (ms *MarketSimulator) updatePrices() {
-	query := `SELECT symbol, current_price FROM stocks`
-	rows, err := ms.db.Query(context.Background(), query)
+func (ms *MarketSimulator) updatePrices(ctx context.Context) {
+	rows, err := ms.db.Query(ctx, `SELECT symbol, current_price, COALESCE(previous_close, 0) FROM stocks WHERE current_price > 0`)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch stocks")
 		return
 	}
-	defer rows.Close()
-
-	var updates []models.Stock
-
+	prices := map[string]float64{}
+	closes := map[string]float64{}
 	for rows.Next() {
 		var symbol string
-		var currentPrice float64
-
-		if err := rows.Scan(&symbol, &currentPrice); err != nil {
+		var price, prevClose float64
+		if err := rows.Scan(&symbol, &price, &prevClose); err != nil {
 			log.Error().Err(err).Msg("Failed to scan stock")
 			continue
 		}
+		// Fiyat başka bir kaynaktan değişmediyse yuvarlanmamış değerden devam et
+		if last, ok := ms.last[symbol]; ok && math.Abs(last-price) < 0.005 {
+			price = last
+		}
+		prices[symbol] = price
+		closes[symbol] = prevClose
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Failed to fetch stocks")
+		return
+	}
+
+	next, err := ms.market.Step(prices, ms.dt)
+	if err != nil {
+		log.Error().Err(err).Msg("Price simulation failed")
+		return
+	}
+	if ms.flow != nil {
+		if flow := ms.flow.Drain(); len(flow) > 0 {
+			ms.market.ApplyOrderFlow(next, flow)
+			log.Debug().Interface("flow", flow).Msg("Order flow applied")
+		}
+	}
 
-		changePercent := (rand.Float64() - 0.5) * 4
-		newPrice := currentPrice * (1 + changePercent/100)
+	var updates []models.Stock
+	for symbol, newPrice := range next {
+		// Günlük değişim önceki kapanışa göre; kapanış yoksa tik değişimi
+		base := closes[symbol]
+		if base <= 0 {
+			base = prices[symbol]
+		}
+		changePercent := (newPrice - base) / base * 100
 
-		updateQuery := `
+		_, err := ms.db.Exec(ctx, `
 			UPDATE stocks
 			SET current_price = $1,
 			    change_percent = $2,
 			    last_updated = NOW()
-			WHERE symbol = $3
-		`
-		_, err := ms.db.Exec(context.Background(), updateQuery, newPrice, changePercent, symbol)
+			WHERE symbol = $3`, newPrice, changePercent, symbol)
 		if err != nil {
 			log.Error().Err(err).Str("symbol", symbol).Msg("Failed to update price")
 			continue
 		}
+		ms.last[symbol] = newPrice
+		if ms.candles != nil {
+			ms.candles.Observe(symbol, newPrice, 0, time.Now())
+		}
 
 		updates = append(updates, models.Stock{
 			Symbol:        symbol,
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/websocket"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Elo ayarları
+const (
+	EloInitialRating = 1500.0
+	// EloK bir günün toplam ağırlığı; gün içindeki rakiplere bölünür, böylece
+	// ajan sayısı arttıkça günlük puan oynaklığı büyümez
+	EloK = 32.0
+	// drawMargin bu kadar yüzde puanından az fark beraberlik sayılır
+	drawMargin = 0.01
+)
+
+// bistLocation Borsa İstanbul saat dilimi; tz verisi yoksa sabit UTC+3
+var bistLocation = func() *time.Location {
+	if loc, err := time.LoadLocation("Europe/Istanbul"); err == nil {
+		return loc
+	}
+	return time.FixedZone("TRT", 3*60*60)
+}()
+
+// MatchupService her tamamlanmış gün için ajanları ikili eşleştirir: günlük
+// getirisi yüksek olan kazanır. Sonuçlar agent_matchups'a, Elo puanları
+// agent_ratings'e yazılır; her gün bir kez oynanır.
+type MatchupService struct {
+	db       *pgxpool.Pool
+	hub      *websocket.Hub
+	interval time.Duration
+}
+
+// NewMatchupService oynanmamış günleri interval aralıklarla işleyen servis
+func NewMatchupService(db *pgxpool.Pool, hub *websocket.Hub, interval time.Duration) *MatchupService {
+	return &MatchupService{db: db, hub: hub, interval: interval}
+}
+
+// Start döngüsü; açılışta birikmiş günleri de oynar
+func (ms *MatchupService) Start(ctx context.Context) {
+	ms.run(ctx)
+	ticker := time.NewTicker(ms.interval)
+	defer ticker.Stop()
+	log.Info().Dur("interval", ms.interval).Msg("Matchup service started")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Matchup service stopped")
+			return
+		case <-ticker.C:
+			ms.run(ctx)
+		}
+	}
+}
+
+// run İstanbul saatine göre bugünden önceki oynanmamış günleri sırayla oynar
+func (ms *MatchupService) run(ctx context.Context) {
+	// Yalnızca gün bittikten sonra özeti kesinleşmiş (updated_at >= gün sonu, UTC) günler oynanır
+	today := time.Now().In(bistLocation).Format("2006-01-02")
+	rows, err := ms.db.Query(ctx, `
+		SELECT d.stat_date FROM agent_daily_stats d
+		WHERE d.stat_date < $1::date
+		  AND NOT EXISTS (SELECT 1 FROM matchup_days m WHERE m.stat_date = d.stat_date)
+		GROUP BY d.stat_date
+		HAVING bool_and(d.updated_at >= ((d.stat_date + 1)::timestamp AT TIME ZONE 'Europe/Istanbul') AT TIME ZONE 'UTC')
+		ORDER BY d.stat_date ASC`, today)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list matchup days")
+		return
+	}
+	var days []time.Time
+	for rows.Next() {
+		var d time.Time
+		if err := rows.Scan(&d); err == nil {
+			days = append(days, d)
+		}
+	}
+	rows.Close()
+
+	played := 0
+	for _, d := range days {
+		if err := ms.playDay(ctx, d); err != nil {
+			log.Error().Err(err).Str("date", d.Format("2006-01-02")).Msg("Failed to play matchup day")
+			return
+		}
+		played++
+	}
+	if played == 0 {
+		return
+	}
+	log.Info().Int("days", played).Msg("Matchups played")
+	if ratings, err := EloLeaderboard(ctx, ms.db); err == nil {
+		ms.hub.BroadcastMessage("elo_updated", ratings)
+	}
+}
+
+// dayReturn bir ajanın gün sonucu; ret günlük kârın gün başı hesap değerine oranı (yüzde)
+type dayReturn struct {
+	agentID    uuid.UUID
+	profitLoss float64
+	ret        float64
+}
+
+// game iki ajanın bir günlük karşılaşması; a < b, score a'nın puanı (1, 0.5, 0)
+type game struct {
+	a, b  dayReturn
+	score float64
+}
+
+// pairGames günün tüm ikili eşleşmelerini id sırasıyla üretir
+func pairGames(returns []dayReturn) []game {
+	sorted := append([]dayReturn(nil), returns...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].agentID.String() < sorted[j].agentID.String() })
+	var games []game
+	for i := range sorted {
+		for j := i + 1; j < len(sorted); j++ {
+			g := game{a: sorted[i], b: sorted[j], score: 0.5}
+			switch diff := sorted[i].ret - sorted[j].ret; {
+			case diff > drawMargin:
+				g.score = 1
+			case diff < -drawMargin:
+				g.score = 0
+			}
+			games = append(games, g)
+		}
+	}
+	return games
+}
+
+// eloExpected a'nın b'ye karşı beklenen puanı
+func eloExpected(ra, rb float64) float64 {
+	return 1 / (1 + math.Pow(10, (rb-ra)/400))
+}
+
+// eloChanges günün oyunlarından gün başı puanlara göre puan değişimlerini
+// hesaplar; K, ajanın o günkü rakip sayısına bölünür
+func eloChanges(ratings map[uuid.UUID]float64, games []game, agents int) map[uuid.UUID]float64 {
+	changes := map[uuid.UUID]float64{}
+	if agents < 2 {
+		return changes
+	}
+	k := EloK / float64(agents-1)
+	for _, g := range games {
+		ra, rb := ratings[g.a.agentID], ratings[g.b.agentID]
+		delta := k * (g.score - eloExpected(ra, rb))
+		changes[g.a.agentID] += delta
+		changes[g.b.agentID] -= delta
+	}
+	return changes
+}
+
+// playDay bir günü tek işlemde oynar
+func (ms *MatchupService) playDay(ctx context.Context, day time.Time) error {
+	tx, err := ms.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT d.agent_id, COALESCE(d.profit_loss, 0), COALESCE(NULLIF(d.start_value, 0), a.initial_balance), COALESCE(r.rating, $2)
+		FROM agent_daily_stats d
+		JOIN agents a ON a.id = d.agent_id
+		LEFT JOIN agent_ratings r ON r.agent_id = d.agent_id
+		WHERE d.stat_date = $1`, day, EloInitialRating)
+	if err != nil {
+		return err
+	}
+	var returns []dayReturn
+	ratings := map[uuid.UUID]float64{}
+	for rows.Next() {
+		var r dayReturn
+		var startValue, rating float64
+		if err := rows.Scan(&r.agentID, &r.profitLoss, &startValue, &rating); err != nil {
+			rows.Close()
+			return err
+		}
+		if startValue > 0 {
+			r.ret = r.profitLoss / startValue * 100
+		}
+		returns = append(returns, r)
+		ratings[r.agentID] = rating
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	games := pairGames(returns)
+	changes := eloChanges(ratings, games, len(returns))
+
+	for _, g := range games {
+		var aWin, bWin, draw int
+		var winner *uuid.UUID
+		switch g.score {
+		case 1:
+			aWin, winner = 1, &g.a.agentID
+		case 0:
+			bWin, winner = 1, &g.b.agentID
+		default:
+			draw = 1
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO agent_matchups (agent1_id, agent2_id, agent1_wins, agent2_wins, draws,
+				agent1_total_profit, agent2_total_profit, last_winner, last_matchup_time, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::date, NOW())
+			ON CONFLICT (agent1_id, agent2_id) DO UPDATE SET
+				agent1_wins = agent_matchups.agent1_wins + EXCLUDED.agent1_wins,
+				agent2_wins = agent_matchups.agent2_wins + EXCLUDED.agent2_wins,
+				draws = agent_matchups.draws + EXCLUDED.draws,
+				agent1_total_profit = agent_matchups.agent1_total_profit + EXCLUDED.agent1_total_profit,
+				agent2_total_profit = agent_matchups.agent2_total_profit + EXCLUDED.agent2_total_profit,
+				last_winner = EXCLUDED.last_winner,
+				last_matchup_time = EXCLUDED.last_matchup_time,
+				updated_at = NOW()`,
+			g.a.agentID, g.b.agentID, aWin, bWin, draw, g.a.profitLoss, g.b.profitLoss, winner, day); err != nil {
+			return err
+		}
+	}
+
+	if len(returns) >= 2 {
+		wins, losses, draws := map[uuid.UUID]int{}, map[uuid.UUID]int{}, map[uuid.UUID]int{}
+		for _, g := range games {
+			switch g.score {
+			case 1:
+				wins[g.a.agentID]++
+				losses[g.b.agentID]++
+			case 0:
+				wins[g.b.agentID]++
+				losses[g.a.agentID]++
+			default:
+				draws[g.a.agentID]++
+				draws[g.b.agentID]++
+			}
+		}
+		for _, r := range returns {
+			id := r.agentID
+			rating := ratings[id] + changes[id]
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO agent_ratings (agent_id, rating, peak_rating, games, wins, losses, draws, last_played, updated_at)
+				VALUES ($1, $2, $8, $3, $4, $5, $6, $7::date, NOW())
+				ON CONFLICT (agent_id) DO UPDATE SET
+					rating = EXCLUDED.rating,
+					peak_rating = GREATEST(agent_ratings.peak_rating, EXCLUDED.rating),
+					games = agent_ratings.games + EXCLUDED.games,
+					wins = agent_ratings.wins + EXCLUDED.wins,
+					losses = agent_ratings.losses + EXCLUDED.losses,
+					draws = agent_ratings.draws + EXCLUDED.draws,
+					last_played = EXCLUDED.last_played,
+					updated_at = NOW()`,
+				id, rating, len(returns)-1, wins[id], losses[id], draws[id], day, math.Max(rating, EloInitialRating)); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO agent_rating_history (agent_id, stat_date, rating, change)
+				VALUES ($1, $2::date, $3, $4)
+				ON CONFLICT (agent_id, stat_date) DO NOTHING`, id, day, rating, changes[id]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "INSERT INTO matchup_days (stat_date, agents) VALUES ($1::date, $2)", day, len(returns)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// EloLeaderboard ajanları Elo puanına göre sıralar
+func EloLeaderboard(ctx context.Context, db *pgxpool.Pool) ([]models.EloRating, error) {
+	rows, err := db.Query(ctx, `
+		SELECT RANK() OVER (ORDER BY r.rating DESC), a.id, a.name, a.model,
+		       r.rating, r.peak_rating, r.games, r.wins, r.losses, r.draws, r.last_played
+		FROM agent_ratings r
+		JOIN agents a ON a.id = r.agent_id
+		WHERE a.status = 'active'
+		ORDER BY r.rating DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.EloRating{}
+	for rows.Next() {
+		var e models.EloRating
+		if err := rows.Scan(&e.Rank, &e.AgentID, &e.AgentName, &e.Model, &e.Rating, &e.PeakRating, &e.Games, &e.Wins, &e.Losses, &e.Draws, &e.LastPlayed); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// AgentMatchups ajanın her rakibe karşı ikili karnesini döndürür
+func AgentMatchups(ctx context.Context, db *pgxpool.Pool, agentID uuid.UUID) ([]models.Matchup, error) {
+	rows, err := db.Query(ctx, `
+		SELECT o.id, o.name,
+		       CASE WHEN m.agent1_id = $1 THEN m.agent1_wins ELSE m.agent2_wins END,
+		       CASE WHEN m.agent1_id = $1 THEN m.agent2_wins ELSE m.agent1_wins END,
+		       m.draws,
+		       CASE WHEN m.agent1_id = $1 THEN m.agent1_total_profit ELSE m.agent2_total_profit END,
+		       CASE WHEN m.agent1_id = $1 THEN m.agent2_total_profit ELSE m.agent1_total_profit END,
+		       m.last_winner, m.last_matchup_time
+		FROM agent_matchups m
+		JOIN agents o ON o.id = CASE WHEN m.agent1_id = $1 THEN m.agent2_id ELSE m.agent1_id END
+		WHERE m.agent1_id = $1 OR m.agent2_id = $1
+		ORDER BY o.name`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Matchup{}
+	for rows.Next() {
+		var m models.Matchup
+		var lastWinner *uuid.UUID
+		if err := rows.Scan(&m.OpponentID, &m.OpponentName, &m.Wins, &m.Losses, &m.Draws, &m.ProfitLoss, &m.OpponentProfitLoss, &lastWinner, &m.LastPlayed); err != nil {
+			return nil, err
+		}
+		switch {
+		case m.LastPlayed == nil:
+		case lastWinner == nil:
+			m.LastResult = "draw"
+		case *lastWinner == agentID:
+			m.LastResult = "win"
+		default:
+			m.LastResult = "loss"
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
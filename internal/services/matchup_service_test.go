@@ -0,0 +1,46 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPairGames(t *testing.T) {
+	a := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	b := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	c := uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+	games := pairGames([]dayReturn{{agentID: c, ret: 1}, {agentID: a, ret: 0.5}, {agentID: b, ret: 0.505}})
+	if len(games) != 3 {
+		t.Fatalf("games = %d, want 3", len(games))
+	}
+	// pairs in id order: a-b (within the draw margin), a-c, b-c
+	want := []struct {
+		a, b  uuid.UUID
+		score float64
+	}{{a, b, 0.5}, {a, c, 0}, {b, c, 0}}
+	for i, w := range want {
+		if g := games[i]; g.a.agentID != w.a || g.b.agentID != w.b || g.score != w.score {
+			t.Errorf("game %d = %v-%v %v, want %v-%v %v", i, g.a.agentID, g.b.agentID, g.score, w.a, w.b, w.score)
+		}
+	}
+}
+
+func TestEloChanges(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	ratings := map[uuid.UUID]float64{a: 1500, b: 1500, c: 1500}
+	games := []game{
+		{a: dayReturn{agentID: a}, b: dayReturn{agentID: b}, score: 1},
+		{a: dayReturn{agentID: a}, b: dayReturn{agentID: c}, score: 1},
+		{a: dayReturn{agentID: b}, b: dayReturn{agentID: c}, score: 0.5},
+	}
+	ch := eloChanges(ratings, games, 3)
+	// K is split over two opponents: a wins twice at even odds, 2 * 16 * 0.5
+	if math.Abs(ch[a]-16) > 1e-9 || math.Abs(ch[b]+8) > 1e-9 || math.Abs(ch[c]+8) > 1e-9 {
+		t.Errorf("changes = %v", ch)
+	}
+	if e := eloExpected(1900, 1500); math.Abs(e-10.0/11) > 1e-9 {
+		t.Errorf("expected score = %v, want 10/11", e)
+	}
+}
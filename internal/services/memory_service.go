@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/websocket"
+)
+
+const (
+	// lessonDelay bir işlemin sonucunun "bilinmiş" sayılması için geçmesi gereken süre
+	lessonDelay = time.Hour
+	// memoryHalfLife hatıra öneminin yarıya indiği süre
+	memoryHalfLife = 72 * time.Hour
+	// reflectionWindow yansıma notunun baktığı geçmiş
+	reflectionWindow = 7 * 24 * time.Hour
+	// bigMovePercent anlamlı kâr/zarar sayılan hareket yüzdesi
+	bigMovePercent = 2.0
+	// overconfidentScore bu güvenin üzerinde zarar edilirse aşırı güven sayılır
+	overconfidentScore = 80.0
+)
+
+// MemoryService ajan hatıralarını (ders ve yansıma notları) üretir ve geri çağırır
+type MemoryService struct {
+	db                 *pgxpool.Pool
+	hub                *websocket.Hub
+	interval           time.Duration
+	reflectionInterval time.Duration
+	clients            func(uuid.UUID) (ai.Client, bool)
+}
+
+// NewMemoryService yeni bir hafıza servisi oluşturur
+func NewMemoryService(db *pgxpool.Pool, hub *websocket.Hub, interval, reflectionInterval time.Duration) *MemoryService {
+	return &MemoryService{db: db, hub: hub, interval: interval, reflectionInterval: reflectionInterval}
+}
+
+// SetClientLookup yansıma notlarını ajanın kendi modeline yazdırmak için istemci bulucuyu enjekte eder.
+// Ayarlanmazsa (veya model hata verirse) notlar kural tabanlı üretilir.
+func (ms *MemoryService) SetClientLookup(fn func(uuid.UUID) (ai.Client, bool)) { ms.clients = fn }
+
+// Start ders kaydı ve periyodik yansıma döngüsünü başlatır
+func (ms *MemoryService) Start(ctx context.Context) {
+	lessonTicker := time.NewTicker(ms.interval)
+	defer lessonTicker.Stop()
+	reflectionTicker := time.NewTicker(ms.reflectionInterval)
+	defer reflectionTicker.Stop()
+
+	log.Info().
+		Dur("interval", ms.interval).
+		Dur("reflection_interval", ms.reflectionInterval).
+		Msg("Memory service started")
+
+	ms.RecordLessons(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Memory service stopped")
+			return
+		case <-lessonTicker.C:
+			ms.RecordLessons(ctx)
+		case <-reflectionTicker.C:
+			ms.ReflectAll(ctx)
+		}
+	}
+}
+
+// decisionOutcome sonucu bilinen, işleme dönüşmüş bir karar
+type decisionOutcome struct {
+	DecisionID uuid.UUID
+	AgentID    uuid.UUID
+	Action     string
+	Symbol     string
+	Quantity   int
+	Price      float64
+	Current    float64
+	Commission float64
+	Confidence float64
+	RiskLevel  string
+	HeldHours  float64
+}
+
+// ProfitLoss kararın güncel fiyata göre sonucu. SELL için pozitif değer,
+// satıştan sonra fiyatın düştüğünü (doğru çıkış) gösterir.
+func (o decisionOutcome) ProfitLoss() float64 {
+	diff := (o.Current - o.Price) * float64(o.Quantity)
+	if o.Action == "SELL" {
+		diff = -diff
+	}
+	return diff - o.Commission
+}
+
+// MovePercent fiyatın işlem fiyatından bu yana yüzde hareketi (karar yönüne göre işaretli)
+func (o decisionOutcome) MovePercent() float64 {
+	if o.Price <= 0 {
+		return 0
+	}
+	move := (o.Current - o.Price) / o.Price * 100
+	if o.Action == "SELL" {
+		move = -move
+	}
+	return move
+}
+
+// RecordLessons sonucu bilinen ve henüz dersi yazılmamış kararlar için ders kaydeder
+func (ms *MemoryService) RecordLessons(ctx context.Context) {
+	rows, err := ms.db.Query(ctx, `
+		SELECT d.id, d.agent_id, t.trade_type, t.stock_symbol, t.quantity, t.price,
+		       s.current_price, COALESCE(t.commission, 0), d.confidence_score,
+		       COALESCE(d.risk_level, ''), EXTRACT(EPOCH FROM NOW() - t.created_at) / 3600
+		FROM agent_decisions d
+		JOIN trades t ON t.id = d.trade_id
+		JOIN stocks s ON s.symbol = t.stock_symbol
+		WHERE d.executed
+		  AND t.created_at <= NOW() - $1 * INTERVAL '1 second'
+		  AND NOT EXISTS (
+		      SELECT 1 FROM agent_memories m WHERE m.decision_id = d.id AND m.kind = 'lesson'
+		  )
+		ORDER BY t.created_at
+		LIMIT 200`, lessonDelay.Seconds())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load decision outcomes")
+		return
+	}
+
+	var outcomes []decisionOutcome
+	for rows.Next() {
+		var o decisionOutcome
+		if err := rows.Scan(&o.DecisionID, &o.AgentID, &o.Action, &o.Symbol, &o.Quantity, &o.Price,
+			&o.Current, &o.Commission, &o.Confidence, &o.RiskLevel, &o.HeldHours); err != nil {
+			log.Error().Err(err).Msg("Failed to scan decision outcome")
+			continue
+		}
+		outcomes = append(outcomes, o)
+	}
+	rows.Close()
+
+	recorded := 0
+	for _, o := range outcomes {
+		situation, lesson, importance := lessonFromOutcome(o)
+		pl := o.ProfitLoss()
+		_, err := ms.db.Exec(ctx, `
+			INSERT INTO agent_memories (agent_id, decision_id, kind, stock_symbol, situation, content, profit_loss, importance, source)
+			VALUES ($1, $2, 'lesson', $3, $4, $5, $6, $7, 'rule')
+			ON CONFLICT DO NOTHING`,
+			o.AgentID, o.DecisionID, o.Symbol, situation, lesson, pl, importance)
+		if err != nil {
+			log.Error().Err(err).Str("decision_id", o.DecisionID.String()).Msg("Failed to store lesson")
+			continue
+		}
+		recorded++
+	}
+	if recorded > 0 {
+		log.Info().Int("lessons", recorded).Msg("Agent lessons recorded")
+	}
+}
+
+// lessonFromOutcome bir karar sonucundan kural tabanlı ders üretir
+func lessonFromOutcome(o decisionOutcome) (situation, lesson string, importance float64) {
+	move := o.MovePercent()
+	pl := o.ProfitLoss()
+	situation = fmt.Sprintf("%s %d %s @ %.2f TL (confidence %.0f%%, risk %s)",
+		o.Action, o.Quantity, o.Symbol, o.Price, o.Confidence, o.RiskLevel)
+	overconfident := pl < 0 && o.Confidence >= overconfidentScore
+
+	switch {
+	case o.Action == "BUY" && move <= -bigMovePercent:
+		lesson = fmt.Sprintf("Buying %s at %.2f lost %.1f%% (%.2f TL) within %s. Wait for confirmation before buying; a falling price kept falling.",
+			o.Symbol, o.Price, -move, pl, heldFor(o.HeldHours))
+	case o.Action == "BUY" && pl < 0:
+		lesson = fmt.Sprintf("Buying %s at %.2f has not paid off yet (%.2f TL, commission included). Small edges are eaten by commission; trade only with a clear signal.",
+			o.Symbol, o.Price, pl)
+	case o.Action == "BUY" && move >= bigMovePercent:
+		lesson = fmt.Sprintf("Buying %s at %.2f worked: +%.1f%% (%.2f TL). The same setup is worth repeating.",
+			o.Symbol, o.Price, move, pl)
+	case o.Action == "BUY":
+		lesson = fmt.Sprintf("Buying %s at %.2f made a small gain (%.2f TL).", o.Symbol, o.Price, pl)
+	case o.Action == "SELL" && move <= -bigMovePercent:
+		lesson = fmt.Sprintf("Selling %s at %.2f was early: the price rose %.1f%% afterwards. Do not cut winners too soon.",
+			o.Symbol, o.Price, -move)
+	case o.Action == "SELL" && move >= bigMovePercent:
+		lesson = fmt.Sprintf("Selling %s at %.2f avoided a %.1f%% drop. The exit signal was right.",
+			o.Symbol, o.Price, move)
+	default:
+		lesson = fmt.Sprintf("Selling %s at %.2f made little difference (price moved %.1f%%).", o.Symbol, o.Price, -move)
+	}
+	if overconfident {
+		lesson += fmt.Sprintf(" Confidence was %.0f%% but the trade lost money: be more skeptical.", o.Confidence)
+	}
+
+	// Önem: hareket büyüklüğü (en fazla 10) + aşırı güven cezası
+	importance = math.Min(math.Abs(move), 10)
+	if pl < 0 {
+		importance += 1 // hatalar başarılardan daha öğretici
+	}
+	if overconfident {
+		importance += 2
+	}
+	return situation, lesson, math.Round(importance*100) / 100
+}
+
+func heldFor(hours float64) string {
+	if hours >= 24 {
+		return fmt.Sprintf("%d days", int(hours/24))
+	}
+	return fmt.Sprintf("%d hours", int(hours))
+}
+
+// Recall bir sonraki karar için en ilgili dersleri ve güncel yansıma notunu döndürür.
+// symbols ajanın elindeki ve öne çıkan hisselerdir; bu hisselerle ilgili dersler öne alınır.
+// now kararın piyasa zamanıdır (geçmiş oynatmada sanal saat); dersler buna göre
+// eskir. Sıfırsa duvar saati kullanılır.
+func (ms *MemoryService) Recall(ctx context.Context, agentID uuid.UUID, symbols []string, now time.Time, limit int) ([]models.AgentMemory, string, error) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	rows, err := ms.db.Query(ctx, `
+		SELECT id, agent_id, decision_id, kind, stock_symbol, COALESCE(situation, ''), content,
+		       profit_loss, COALESCE(importance, 0), COALESCE(source, 'rule'), created_at
+		FROM agent_memories
+		WHERE agent_id = $1 AND kind = 'lesson'
+		ORDER BY created_at DESC
+		LIMIT 100`, agentID)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var lessons []models.AgentMemory
+	for rows.Next() {
+		var m models.AgentMemory
+		if err := rows.Scan(&m.ID, &m.AgentID, &m.DecisionID, &m.Kind, &m.StockSymbol, &m.Situation, &m.Content,
+			&m.ProfitLoss, &m.Importance, &m.Source, &m.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		lessons = append(lessons, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var note string
+	err = ms.db.QueryRow(ctx, `
+		SELECT content FROM agent_memories
+		WHERE agent_id = $1 AND kind = 'reflection'
+		ORDER BY created_at DESC LIMIT 1`, agentID).Scan(&note)
+	if err != nil {
+		note = "" // henüz yansıma yok
+	}
+
+	return rankMemories(lessons, symbols, now, limit), note, nil
+}
+
+// rankMemories dersleri sembol eşleşmesi, tazelik ve önem skoruna göre sıralar
+func rankMemories(mems []models.AgentMemory, symbols []string, now time.Time, limit int) []models.AgentMemory {
+	relevant := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		relevant[s] = true
+	}
+	score := func(m models.AgentMemory) float64 {
+		s := 1 + m.Importance
+		if m.StockSymbol != nil && relevant[*m.StockSymbol] {
+			s *= 3
+		}
+		age := max(now.Sub(m.CreatedAt), 0) // sanal saatten sonra yazılmış ders taze sayılır
+		return s * math.Pow(0.5, age.Hours()/memoryHalfLife.Hours())
+	}
+
+	ranked := append([]models.AgentMemory(nil), mems...)
+	sort.SliceStable(ranked, func(i, j int) bool { return score(ranked[i]) > score(ranked[j]) })
+	return firstMemories(ranked, limit)
+}
+
+func firstMemories(mems []models.AgentMemory, n int) []models.AgentMemory {
+	if len(mems) > n {
+		return mems[:n]
+	}
+	return mems
+}
+
+// ReflectAll tüm aktif ajanlar için yansıma notu üretir
+func (ms *MemoryService) ReflectAll(ctx context.Context) {
+	rows, err := ms.db.Query(ctx, `SELECT id, name FROM agents WHERE status = 'active'`)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch agents for reflection")
+		return
+	}
+	type agentRef struct {
+		id   uuid.UUID
+		name string
+	}
+	var agents []agentRef
+	for rows.Next() {
+		var a agentRef
+		if err := rows.Scan(&a.id, &a.name); err == nil {
+			agents = append(agents, a)
+		}
+	}
+	rows.Close()
+
+	for _, a := range agents {
+		if err := ms.Reflect(ctx, a.id, a.name); err != nil {
+			log.Error().Err(err).Str("agent", a.name).Msg("Reflection failed")
+		}
+	}
+}
+
+// Reflect ajanın son hatalarını bir kalıcı nota özetler. Son pencerede
+// zararlı ders yoksa not yazılmaz.
+func (ms *MemoryService) Reflect(ctx context.Context, agentID uuid.UUID, agentName string) error {
+	rows, err := ms.db.Query(ctx, `
+		SELECT m.id, m.agent_id, m.decision_id, m.kind, m.stock_symbol, COALESCE(m.situation, ''), m.content,
+		       m.profit_loss, COALESCE(m.importance, 0), COALESCE(m.source, 'rule'), m.created_at,
+		       COALESCE(d.confidence_score, 0)
+		FROM agent_memories m
+		LEFT JOIN agent_decisions d ON d.id = m.decision_id
+		WHERE m.agent_id = $1 AND m.kind = 'lesson' AND m.profit_loss < 0
+		  AND m.created_at >= NOW() - $2 * INTERVAL '1 second'
+		ORDER BY m.importance DESC, m.created_at DESC
+		LIMIT 10`, agentID, reflectionWindow.Seconds())
+	if err != nil {
+		return err
+	}
+	var mistakes []models.AgentMemory
+	var confidences []float64
+	for rows.Next() {
+		var m models.AgentMemory
+		var confidence float64
+		if err := rows.Scan(&m.ID, &m.AgentID, &m.DecisionID, &m.Kind, &m.StockSymbol, &m.Situation, &m.Content,
+			&m.ProfitLoss, &m.Importance, &m.Source, &m.CreatedAt, &confidence); err != nil {
+			rows.Close()
+			return err
+		}
+		mistakes = append(mistakes, m)
+		confidences = append(confidences, confidence)
+	}
+	rows.Close()
+	if len(mistakes) == 0 {
+		return nil
+	}
+
+	note, source := ruleBasedReflection(mistakes, confidences), "rule"
+	if ms.clients != nil {
+		if client, ok := ms.clients(agentID); ok {
+			system, prompt := ai.BuildReflectionPrompt(agentName, mistakes)
+			if resp, err := client.GetTradingDecision(ctx, system, prompt); err != nil {
+				log.Warn().Err(err).Str("agent", agentName).Msg("Agent reflection failed, using rule-based note")
+			} else if text := strings.TrimSpace(resp.ReasoningSummary); text != "" {
+				note, source = text, "agent"
+			}
+		}
+	}
+
+	var totalLoss float64
+	for _, m := range mistakes {
+		totalLoss += *m.ProfitLoss
+	}
+	if _, err := ms.db.Exec(ctx, `
+		INSERT INTO agent_memories (agent_id, kind, situation, content, profit_loss, importance, source)
+		VALUES ($1, 'reflection', $2, $3, $4, $5, $6)`,
+		agentID, fmt.Sprintf("%d losing trades in the last %d days", len(mistakes), int(reflectionWindow.Hours()/24)),
+		note, totalLoss, float64(len(mistakes)), source); err != nil {
+		return err
+	}
+
+	ms.hub.BroadcastMessage("agent_reflection", map[string]interface{}{
+		"agent_id":   agentID,
+		"agent_name": agentName,
+		"note":       note,
+		"source":     source,
+		"mistakes":   len(mistakes),
+		"timestamp":  time.Now().Unix(),
+	})
+	log.Info().Str("agent", agentName).Str("source", source).Msg("Agent reflection stored")
+	return nil
+}
+
+// ruleBasedReflection zararlı derslerdeki tekrar eden örüntüleri kısa bir nota dönüştürür
+// confidences her hatanın karar anındaki güven skorudur (mistakes ile aynı sırada).
+func ruleBasedReflection(mistakes []models.AgentMemory, confidences []float64) string {
+	var total float64
+	bySymbol := map[string]int{}
+	overconfident := 0
+	for i, m := range mistakes {
+		if m.ProfitLoss != nil {
+			total += *m.ProfitLoss
+		}
+		if m.StockSymbol != nil {
+			bySymbol[*m.StockSymbol]++
+		}
+		if i < len(confidences) && confidences[i] >= overconfidentScore {
+			overconfident++
+		}
+	}
+
+	var repeated []string
+	for sym, n := range bySymbol {
+		if n > 1 {
+			repeated = append(repeated, fmt.Sprintf("%s (%d)", sym, n))
+		}
+	}
+	sort.Strings(repeated)
+
+	parts := []string{fmt.Sprintf("Recent mistakes: %d losing trades, %.2f TL in total.", len(mistakes), total)}
+	if len(repeated) > 0 {
+		parts = append(parts, fmt.Sprintf("Repeated losses on %s: require a stronger signal before trading these again.", strings.Join(repeated, ", ")))
+	}
+	if overconfident > 0 {
+		parts = append(parts, fmt.Sprintf("%d of them were high-confidence calls: lower confidence unless news and price action agree.", overconfident))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+func TestLessonFromOutcome(t *testing.T) {
+	cases := []struct {
+		name       string
+		outcome    decisionOutcome
+		wantPL     float64
+		wantLesson string
+		overconf   bool
+	}{
+		{
+			name:       "losing buy with high confidence",
+			outcome:    decisionOutcome{Action: "BUY", Symbol: "THYAO", Quantity: 10, Price: 100, Current: 95, Commission: 1, Confidence: 90},
+			wantPL:     -51,
+			wantLesson: "lost 5.0%",
+			overconf:   true,
+		},
+		{
+			name:       "winning buy",
+			outcome:    decisionOutcome{Action: "BUY", Symbol: "ASELS", Quantity: 10, Price: 50, Current: 52, Commission: 0.5, Confidence: 75},
+			wantPL:     19.5,
+			wantLesson: "worked: +4.0%",
+		},
+		{
+			name:       "early sell",
+			outcome:    decisionOutcome{Action: "SELL", Symbol: "GARAN", Quantity: 100, Price: 10, Current: 10.5, Commission: 1, Confidence: 70},
+			wantPL:     -51,
+			wantLesson: "was early",
+		},
+		{
+			name:       "good exit",
+			outcome:    decisionOutcome{Action: "SELL", Symbol: "KCHOL", Quantity: 10, Price: 200, Current: 190, Commission: 2, Confidence: 85},
+			wantPL:     98,
+			wantLesson: "avoided a 5.0% drop",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if pl := c.outcome.ProfitLoss(); pl < c.wantPL-0.001 || pl > c.wantPL+0.001 {
+				t.Errorf("ProfitLoss() = %.2f, want %.2f", pl, c.wantPL)
+			}
+			situation, lesson, importance := lessonFromOutcome(c.outcome)
+			if !strings.Contains(situation, c.outcome.Symbol) {
+				t.Errorf("situation %q does not mention symbol", situation)
+			}
+			if !strings.Contains(lesson, c.wantLesson) {
+				t.Errorf("lesson = %q, want it to contain %q", lesson, c.wantLesson)
+			}
+			if got := strings.Contains(lesson, "be more skeptical"); got != c.overconf {
+				t.Errorf("overconfidence note = %v, want %v", got, c.overconf)
+			}
+			if importance <= 0 {
+				t.Errorf("importance = %v, want > 0", importance)
+			}
+		})
+	}
+}
+
+func TestRankMemories(t *testing.T) {
+	now := time.Now()
+	sym := func(s string) *string { return &s }
+	mems := []models.AgentMemory{
+		{ID: uuid.New(), Content: "old big loss", StockSymbol: sym("AKBNK"), Importance: 8, CreatedAt: now.Add(-20 * 24 * time.Hour)},
+		{ID: uuid.New(), Content: "fresh unrelated", StockSymbol: sym("SISE"), Importance: 2, CreatedAt: now.Add(-time.Hour)},
+		{ID: uuid.New(), Content: "held symbol", StockSymbol: sym("THYAO"), Importance: 2, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: uuid.New(), Content: "fresh big", StockSymbol: sym("BIMAS"), Importance: 9, CreatedAt: now.Add(-3 * time.Hour)},
+	}
+
+	got := rankMemories(mems, []string{"THYAO"}, now, 3)
+	if len(got) != 3 {
+		t.Fatalf("len = %d, want 3", len(got))
+	}
+	if got[0].Content != "fresh big" || got[1].Content != "held symbol" || got[2].Content != "fresh unrelated" {
+		t.Errorf("order = %s, %s, %s", got[0].Content, got[1].Content, got[2].Content)
+	}
+	if mems[0].Content != "old big loss" {
+		t.Error("rankMemories must not reorder its input")
+	}
+}
+
+func TestRankMemoriesOnVirtualClock(t *testing.T) {
+	// Replay at a past market time: a lesson written after it (wall-clock
+	// created_at) must not outrank by a negative age
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mems := []models.AgentMemory{
+		{ID: uuid.New(), Content: "written later", Importance: 2, CreatedAt: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), Content: "day before", Importance: 9, CreatedAt: now.Add(-24 * time.Hour)},
+	}
+
+	got := rankMemories(mems, nil, now, 2)
+	if got[0].Content != "day before" {
+		t.Errorf("order = %s, %s", got[0].Content, got[1].Content)
+	}
+}
+
+func TestRuleBasedReflection(t *testing.T) {
+	sym := func(s string) *string { return &s }
+	pl := func(v float64) *float64 { return &v }
+	mistakes := []models.AgentMemory{
+		{StockSymbol: sym("THYAO"), ProfitLoss: pl(-100)},
+		{StockSymbol: sym("THYAO"), ProfitLoss: pl(-50)},
+		{StockSymbol: sym("ASELS"), ProfitLoss: pl(-25)},
+	}
+	note := ruleBasedReflection(mistakes, []float64{90, 60, 85})
+	for _, want := range []string{"3 losing trades", "-175.00 TL", "THYAO (2)", "2 of them were high-confidence"} {
+		if !strings.Contains(note, want) {
+			t.Errorf("note %q missing %q", note, want)
+		}
+	}
+	if strings.Contains(note, "ASELS (") {
+		t.Error("single losses should not be reported as repeated")
+	}
+}
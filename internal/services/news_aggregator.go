@@ -2,6 +2,9 @@ package services
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -167,6 +170,49 @@ func (na *NewsAggregator) storeArticle(ctx context.Context, article models.NewsA
 	return err
 }
 
+// InjectArticles senaryo haberlerini gerçek haberlerle aynı yoldan yayınlar:
+// market_events'e yazar, Redis'teki son haberlerin başına ekler ve
+// news_update yayınlar. Kaydedilen makaleler ID'leriyle döner; ajan karar
+// anlık görüntüsündeki news_ids ile eşleştirilebilir.
+func (na *NewsAggregator) InjectArticles(ctx context.Context, articles []models.NewsArticle) ([]models.NewsArticle, error) {
+	stored := make([]models.NewsArticle, 0, len(articles))
+	for _, a := range articles {
+		hash := md5.Sum([]byte(a.URL + a.Title + a.Description))
+		err := na.db.QueryRow(ctx, `
+			INSERT INTO market_events (
+				title, description, content, source, url, content_hash, event_type, category, related_stocks,
+				sentiment, sentiment_score, impact_level, published_at, fetched_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, NULLIF($12, ''), $13, NOW())
+			RETURNING id, fetched_at, created_at`,
+			a.Title, a.Description, a.Content, a.Source, a.URL, hex.EncodeToString(hash[:]), a.EventType, a.Category,
+			a.RelatedStocks, a.Sentiment, a.SentimentScore, a.ImpactLevel, a.PublishedAt,
+		).Scan(&a.ID, &a.FetchedAt, &a.CreatedAt)
+		if err != nil {
+			return stored, fmt.Errorf("store injected article %q: %w", a.Title, err)
+		}
+		stored = append(stored, a)
+	}
+	if len(stored) == 0 {
+		return stored, nil
+	}
+
+	// Önbellekte yoksa ajanlar veritabanından okur; orada zaten var
+	if cached, err := na.newsCache.GetLatestNews(ctx); err == nil {
+		latest := append(append([]models.NewsArticle{}, stored...), cached...)
+		if err := na.newsCache.SetLatestNews(ctx, latest); err != nil {
+			log.Warn().Err(err).Msg("Failed to cache injected news")
+		}
+	}
+
+	na.hub.BroadcastMessage("news_update", map[string]interface{}{
+		"count":     len(stored),
+		"articles":  stored[:min(len(stored), 5)],
+		"timestamp": time.Now().Unix(),
+		"injected":  true,
+	})
+	return stored, nil
+}
+
 // deduplicateByURL URL'ye göre tekrar eden makaleleri kaldırır
 func (na *NewsAggregator) deduplicateByURL(articles []models.NewsArticle) []models.NewsArticle {
 	seen := make(map[string]bool)
@@ -249,6 +295,40 @@ func (na *NewsAggregator) GetLatestNews(ctx context.Context) ([]models.NewsArtic
 	return articles, nil
 }
 
+// GetNewsAsOf at anında görülebilecek haberleri (son 24 saatte yayınlanmış,
+// en yenisi önce) getirir; geçmiş oynatmada ajanlar geleceği görmez
+func (na *NewsAggregator) GetNewsAsOf(ctx context.Context, at time.Time) ([]models.NewsArticle, error) {
+	return NewsBetween(ctx, na.db, at.Add(-24*time.Hour), at, 20)
+}
+
+// NewsBetween (from, to] aralığında yayınlanmış haberleri en yenisi önce döndürür
+func NewsBetween(ctx context.Context, db *pgxpool.Pool, from, to time.Time, limit int) ([]models.NewsArticle, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, title, COALESCE(description, ''), COALESCE(content, ''), source, COALESCE(url, ''),
+		       COALESCE(event_type, 'news'), COALESCE(category, ''), COALESCE(related_stocks, '{}'),
+		       published_at, COALESCE(fetched_at, published_at), COALESCE(created_at, published_at)
+		FROM market_events
+		WHERE published_at > $1 AND published_at <= $2
+		ORDER BY published_at DESC
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	articles := []models.NewsArticle{}
+	for rows.Next() {
+		var a models.NewsArticle
+		if err := rows.Scan(&a.ID, &a.Title, &a.Description, &a.Content, &a.Source, &a.URL,
+			&a.EventType, &a.Category, &a.RelatedStocks,
+			&a.PublishedAt, &a.FetchedAt, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		articles = append(articles, a)
+	}
+	return articles, rows.Err()
+}
+
 // min iki tam sayının en küçüğünü almak için yardımcı fonksiyon
 func min(a, b int) int {
 	if a < b {
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/websocket"
+)
+
+var (
+	// ErrProposalNotFound öneri bulunamadığında döner
+	ErrProposalNotFound = errors.New("proposal not found")
+	// ErrProposalNotPending öneri artık onay beklemiyorsa (onaylanmış, reddedilmiş, süresi dolmuş) döner
+	ErrProposalNotPending = errors.New("proposal is not pending")
+)
+
+// proposalExpiryInterval süresi dolan önerilerin kontrol sıklığı
+const proposalExpiryInterval = 30 * time.Second
+
+// ProposalService onay modundaki ajanların işlem önerilerini yönetir:
+// öneri oluşturur, onaylananları TradingEngine ile gerçekleştirir, süresi dolanları kapatır
+type ProposalService struct {
+	db            *pgxpool.Pool
+	hub           *websocket.Hub
+	tradingEngine *TradingEngine
+	riskManager   *RiskManager
+	ttl           time.Duration
+}
+
+// DefaultProposalTTL geçerlilik süresi verilmeyen önerilerin ömrü
+const DefaultProposalTTL = 15 * time.Minute
+
+// NewProposalService yeni bir öneri servisi oluşturur; pozitif olmayan ttl
+// varsayılana (15 dk) çekilir, yoksa her öneri onaylanamadan süresi dolar
+func NewProposalService(db *pgxpool.Pool, hub *websocket.Hub, tradingEngine *TradingEngine, riskManager *RiskManager, ttl time.Duration) *ProposalService {
+	if ttl <= 0 {
+		log.Warn().Dur("ttl", ttl).Dur("default", DefaultProposalTTL).Msg("PROPOSAL_TTL must be positive, using default")
+		ttl = DefaultProposalTTL
+	}
+	return &ProposalService{db: db, hub: hub, tradingEngine: tradingEngine, riskManager: riskManager, ttl: ttl}
+}
+
+// Start süresi dolan önerileri periyodik olarak kapatır
+func (ps *ProposalService) Start(ctx context.Context) {
+	ticker := time.NewTicker(proposalExpiryInterval)
+	defer ticker.Stop()
+
+	log.Info().Dur("ttl", ps.ttl).Msg("Proposal service started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Proposal service stopped")
+			return
+		case <-ticker.C:
+			ps.ExpireStale(ctx)
+		}
+	}
+}
+
+// Create bir karardan onay bekleyen öneri oluşturur ve trade_proposal olarak yayınlar
+func (ps *ProposalService) Create(ctx context.Context, agentName string, decisionID uuid.UUID, action string, orders []models.TradeRequest, mode string, confidence float64) (*models.TradeProposal, error) {
+	if len(orders) == 0 {
+		return nil, errors.New("no orders")
+	}
+	p := &models.TradeProposal{
+		ID:            uuid.New(),
+		AgentID:       orders[0].AgentID,
+		AgentName:     agentName,
+		DecisionID:    &decisionID,
+		Status:        models.ProposalPending,
+		Action:        action,
+		Orders:        orderList(orders),
+		ExecutionMode: mode,
+		Confidence:    confidence,
+		Reasoning:     orders[0].Reasoning,
+		CreatedAt:     time.Now(),
+	}
+	p.ExpiresAt = p.CreatedAt.Add(ps.ttl)
+
+	ordersJSON, err := json.Marshal(p.Orders)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ps.db.Exec(ctx, `
+		INSERT INTO trade_proposals (id, agent_id, decision_id, status, action, orders, execution_mode, confidence, reasoning, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW() + $10 * INTERVAL '1 second')
+	`, p.ID, p.AgentID, decisionID, p.Status, p.Action, ordersJSON, p.ExecutionMode, p.Confidence, p.Reasoning, ps.ttl.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert proposal: %w", err)
+	}
+
+	ps.hub.BroadcastMessage("trade_proposal", p)
+	return p, nil
+}
+
+const proposalColumns = `
+	p.id, p.agent_id, a.name, p.decision_id, p.status, p.action, p.orders, p.execution_mode,
+	COALESCE(p.confidence, 0), COALESCE(p.reasoning, ''), p.expires_at,
+	p.decided_by, p.decided_at, p.decision_note, p.results, p.error, p.created_at`
+
+func scanProposal(row pgx.Row) (*models.TradeProposal, error) {
+	var p models.TradeProposal
+	var results []byte
+	if err := row.Scan(&p.ID, &p.AgentID, &p.AgentName, &p.DecisionID, &p.Status, &p.Action, &p.Orders,
+		&p.ExecutionMode, &p.Confidence, &p.Reasoning, &p.ExpiresAt,
+		&p.DecidedBy, &p.DecidedAt, &p.DecisionNote, &results, &p.Error, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	if len(results) > 0 {
+		_ = json.Unmarshal(results, &p.Results)
+	}
+	return &p, nil
+}
+
+// Get tek bir öneriyi döndürür
+func (ps *ProposalService) Get(ctx context.Context, id uuid.UUID) (*models.TradeProposal, error) {
+	p, err := scanProposal(ps.db.QueryRow(ctx, `
+		SELECT `+proposalColumns+`
+		FROM trade_proposals p
+		JOIN agents a ON a.id = p.agent_id
+		WHERE p.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrProposalNotFound
+	}
+	return p, err
+}
+
+// List önerileri en yeniden eskiye döndürür; boş status/agentID filtre uygulamaz
+func (ps *ProposalService) List(ctx context.Context, status string, agentID *uuid.UUID, limit int) ([]models.TradeProposal, error) {
+	rows, err := ps.db.Query(ctx, `
+		SELECT `+proposalColumns+`
+		FROM trade_proposals p
+		JOIN agents a ON a.id = p.agent_id
+		WHERE ($1 = '' OR p.status = $1)
+		  AND ($2::uuid IS NULL OR p.agent_id = $2)
+		ORDER BY p.created_at DESC
+		LIMIT $3`, status, agentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	proposals := []models.TradeProposal{}
+	for rows.Next() {
+		p, err := scanProposal(rows)
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, *p)
+	}
+	return proposals, rows.Err()
+}
+
+// Approve bekleyen öneriyi onaylayanı kaydederek gerçekleştirir. Emirler onay
+// anındaki fiyat ve bakiyeyle risk kurallarından yeniden geçirilir; başarısız
+// olursa öneri failed durumuna geçer.
+func (ps *ProposalService) Approve(ctx context.Context, id uuid.UUID, approver, note string) (*models.TradeProposal, error) {
+	p, err := ps.claim(ctx, id, models.ProposalApproved, approver, note)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]models.TradeRequest, len(p.Orders))
+	for i, o := range p.Orders {
+		orders[i] = models.TradeRequest{
+			AgentID:     p.AgentID,
+			StockSymbol: o.StockSymbol,
+			TradeType:   o.Action,
+			Quantity:    o.Quantity,
+			Reasoning:   p.Reasoning,
+			DecisionID:  p.DecisionID,
+		}
+	}
+
+	var results []models.OrderResult
+	execErr := ps.riskManager.ValidateOrders(ctx, p.AgentID, orders, p.Confidence)
+	if execErr == nil {
+		results, execErr = ps.tradingEngine.ExecuteOrders(ctx, orders, p.ExecutionMode)
+	}
+
+	p.Results = results
+	var errText *string
+	if execErr != nil {
+		msg := execErr.Error()
+		errText = &msg
+		p.Status = models.ProposalFailed
+		p.Error = errText
+	}
+	resultsJSON, _ := json.Marshal(results)
+	if _, err := ps.db.Exec(ctx,
+		"UPDATE trade_proposals SET status = $1, results = $2, error = $3 WHERE id = $4",
+		p.Status, resultsJSON, errText, p.ID); err != nil {
+		log.Error().Err(err).Str("proposal_id", p.ID.String()).Msg("Failed to store proposal result")
+	}
+
+	for _, r := range results {
+		if r.Trade != nil {
+			ps.hub.BroadcastMessage("trade_executed", r.Trade)
+		}
+	}
+	ps.hub.BroadcastMessage("proposal_updated", p)
+
+	log.Info().
+		Str("proposal_id", p.ID.String()).
+		Str("agent", p.AgentName).
+		Str("approver", approver).
+		Str("status", p.Status).
+		Msg("Trade proposal approved")
+	return p, nil
+}
+
+// Reject bekleyen öneriyi reddeder
+func (ps *ProposalService) Reject(ctx context.Context, id uuid.UUID, approver, note string) (*models.TradeProposal, error) {
+	p, err := ps.claim(ctx, id, models.ProposalRejected, approver, note)
+	if err != nil {
+		return nil, err
+	}
+	ps.hub.BroadcastMessage("proposal_updated", p)
+	return p, nil
+}
+
+// claim bekleyen ve süresi dolmamış öneriyi tek bir UPDATE ile yeni duruma alır;
+// böylece aynı öneri iki kez onaylanamaz
+func (ps *ProposalService) claim(ctx context.Context, id uuid.UUID, status, approver, note string) (*models.TradeProposal, error) {
+	var notePtr *string
+	if note != "" {
+		notePtr = &note
+	}
+	tag, err := ps.db.Exec(ctx, `
+		UPDATE trade_proposals
+		SET status = $1, decided_by = $2, decided_at = NOW(), decision_note = $3
+		WHERE id = $4 AND status = 'pending' AND expires_at > NOW()
+	`, status, approver, notePtr, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := ps.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrProposalNotPending
+	}
+	return ps.Get(ctx, id)
+}
+
+// ExpireStale süresi dolan bekleyen önerileri kapatır ve yayınlar
+func (ps *ProposalService) ExpireStale(ctx context.Context) {
+	rows, err := ps.db.Query(ctx, `
+		UPDATE trade_proposals
+		SET status = 'expired', decided_at = NOW()
+		WHERE status = 'pending' AND expires_at <= NOW()
+		RETURNING id, agent_id`)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to expire proposals")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, agentID uuid.UUID
+		if err := rows.Scan(&id, &agentID); err != nil {
+			continue
+		}
+		ps.hub.BroadcastMessage("proposal_expired", map[string]interface{}{
+			"proposal_id": id,
+			"agent_id":    agentID,
+			"timestamp":   time.Now().Unix(),
+		})
+	}
+}
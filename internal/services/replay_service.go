@@ -0,0 +1,579 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/ai"
+	"github.com/1batu/market-ai/internal/models"
+)
+
+var (
+	// ErrNoDecisionAudit karar denetim kaydından (prompt) önce verilmişse döner; tekrar oynatılamaz
+	ErrNoDecisionAudit = errors.New("decision has no stored prompt")
+	// ErrReplayModelUnavailable istenen model için yapılandırılmış istemci yoksa döner
+	ErrReplayModelUnavailable = errors.New("model not available for replay")
+	// ErrUnknownPromptSet istenen prompt seti yüklü değilse döner
+	ErrUnknownPromptSet = errors.New("unknown prompt set")
+	// ErrReplayBatchNotFound bilinmeyen toplu tekrar için döner
+	ErrReplayBatchNotFound = errors.New("replay batch not found")
+)
+
+// Toplu tekrar durumları
+const (
+	ReplayBatchRunning   = "running"
+	ReplayBatchCompleted = "completed"
+	ReplayBatchFailed    = "failed"
+)
+
+// Aralık tekrarında oynatılacak en fazla karar sayısı (varsayılan / üst sınır)
+const (
+	defaultReplayLimit = 20
+	maxReplayLimit     = 500
+)
+
+// ReplayService geçmiş kararları kayıtlı promptlarıyla aynı ya da farklı bir
+// modelde yeniden çalıştırır ve yeni kararı orijinaliyle karşılaştırır.
+// Modele giden karar promptu bire bir aynıdır; istenirse yalnızca sistem promptu
+// başka bir prompt setiyle değiştirilir.
+type ReplayService struct {
+	db      *pgxpool.Pool
+	clients map[string]ai.Client // model adı -> istemci
+	prompts *ai.PromptRegistry
+
+	mu      sync.Mutex
+	base    context.Context               // arka plan toplu tekrarlarının bağlamı
+	batches map[uuid.UUID]*replayBatchJob // bu süreçte başlatılan toplu tekrarlar
+}
+
+// replayBatchJob arka planda çalışan bir toplu tekrarın durumu; sonuçlar decision_replays'tedir
+type replayBatchJob struct {
+	status    string
+	planned   int
+	model     string
+	promptSet string
+	err       string
+}
+
+// NewReplayService yeni bir tekrar servisi oluşturur
+func NewReplayService(db *pgxpool.Pool, clients map[string]ai.Client, prompts *ai.PromptRegistry) *ReplayService {
+	return &ReplayService{db: db, clients: clients, prompts: prompts, base: context.Background(), batches: map[uuid.UUID]*replayBatchJob{}}
+}
+
+// SetContext arka plan toplu tekrarlarının bağlamını verir; ctx bitince yarım kalanlar durur
+func (rs *ReplayService) SetContext(ctx context.Context) {
+	rs.mu.Lock()
+	rs.base = ctx
+	rs.mu.Unlock()
+}
+
+// Models tekrar için kullanılabilen model adlarını döndürür
+func (rs *ReplayService) Models() []string {
+	names := make([]string, 0, len(rs.clients))
+	for name := range rs.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// replaySource tekrar için yüklenen orijinal karar ve promptları
+type replaySource struct {
+	DecisionID   uuid.UUID
+	AgentID      uuid.UUID
+	AgentName    string
+	Strategy     string
+	Balance      float64
+	SystemPrompt string
+	Prompt       string
+	Model        string
+	Original     models.ReplayedDecision
+}
+
+// Replay tek bir kararı yeniden oynatır ve sonucu kaydeder
+func (rs *ReplayService) Replay(ctx context.Context, decisionID uuid.UUID, opts models.ReplayOptions) (*models.DecisionReplay, error) {
+	src, err := rs.load(ctx, decisionID)
+	if err != nil {
+		return nil, err
+	}
+	return rs.run(ctx, src, opts, nil)
+}
+
+// ReplayRange bir zaman aralığındaki denetim kayıtlı kararları sırayla yeniden
+// oynatır ve bitince raporu döndürür (komut satırı). Tek tek başarısız olan
+// tekrarlar rapora hata olarak yazılır.
+func (rs *ReplayService) ReplayRange(ctx context.Context, req models.ReplayRangeRequest) (*models.ReplayReport, error) {
+	ids, err := rs.selectRange(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.ReplayReport{
+		BatchID:   uuid.New(),
+		Model:     req.Model,
+		PromptSet: req.PromptSet,
+		Status:    ReplayBatchRunning,
+		Planned:   len(ids),
+		Results:   []models.DecisionReplay{},
+	}
+	if err := rs.replayBatch(ctx, report.BatchID, ids, req.ReplayOptions, func(r models.DecisionReplay) {
+		report.Results = append(report.Results, r)
+	}); err != nil {
+		return nil, err
+	}
+	report.Status = ReplayBatchCompleted
+	summarizeReplays(report)
+	return report, nil
+}
+
+// StartRange aralıktaki kararları seçer ve arka planda yeniden oynatır. Dönen
+// rapor yalnızca toplu tekrarın kimliğini ve planlanan sayıyı taşır; ilerleme
+// ve sonuçlar Batch ile izlenir.
+func (rs *ReplayService) StartRange(ctx context.Context, req models.ReplayRangeRequest) (*models.ReplayReport, error) {
+	ids, err := rs.selectRange(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	batchID := uuid.New()
+	job := &replayBatchJob{status: ReplayBatchRunning, planned: len(ids), model: req.Model, promptSet: req.PromptSet}
+
+	rs.mu.Lock()
+	rs.batches[batchID] = job
+	base := rs.base
+	rs.mu.Unlock()
+
+	go func() {
+		err := rs.replayBatch(base, batchID, ids, req.ReplayOptions, nil)
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		if err != nil {
+			job.status, job.err = ReplayBatchFailed, err.Error()
+			log.Error().Err(err).Str("batch_id", batchID.String()).Msg("Decision replay batch failed")
+			return
+		}
+		job.status = ReplayBatchCompleted
+	}()
+
+	return &models.ReplayReport{
+		BatchID:   batchID,
+		Model:     req.Model,
+		PromptSet: req.PromptSet,
+		Status:    ReplayBatchRunning,
+		Planned:   len(ids),
+		Results:   []models.DecisionReplay{},
+	}, nil
+}
+
+// Batch toplu tekrarın durumunu ve şimdiye kadarki sonuçlarını döndürür. Bu
+// süreçte başlatılmamış (ör. yeniden başlatma öncesi) toplu tekrarlar kayıtlı
+// sonuçlarıyla tamamlanmış sayılır.
+func (rs *ReplayService) Batch(ctx context.Context, batchID uuid.UUID) (*models.ReplayReport, error) {
+	results, err := rs.queryReplays(ctx, "r.batch_id = $1", "r.created_at ASC", batchID)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.ReplayReport{BatchID: batchID, Status: ReplayBatchCompleted, Planned: len(results), Results: results}
+
+	rs.mu.Lock()
+	job, ok := rs.batches[batchID]
+	if ok {
+		report.Status, report.Planned, report.Model, report.PromptSet, report.Error = job.status, job.planned, job.model, job.promptSet, job.err
+	}
+	rs.mu.Unlock()
+
+	if !ok {
+		if len(results) == 0 {
+			return nil, ErrReplayBatchNotFound
+		}
+		report.Model, report.PromptSet = results[0].Model, results[0].PromptSet
+	}
+	summarizeReplays(report)
+	return report, nil
+}
+
+// selectRange isteği doğrular, varsayılanları uygular ve aralıktaki denetim
+// kayıtlı kararları eskiden yeniye seçer
+func (rs *ReplayService) selectRange(ctx context.Context, req *models.ReplayRangeRequest) ([]uuid.UUID, error) {
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+	if !req.From.Before(req.To) {
+		return nil, errors.New("from must be before to")
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultReplayLimit
+	}
+	if req.Limit > maxReplayLimit {
+		req.Limit = maxReplayLimit
+	}
+	if err := rs.checkOptions(req.ReplayOptions); err != nil {
+		return nil, err
+	}
+
+	rows, err := rs.db.Query(ctx, `
+		SELECT d.id
+		FROM agent_decisions d
+		JOIN agent_decision_audits au ON au.decision_id = d.id
+		WHERE d.created_at >= $1 AND d.created_at < $2
+		  AND ($3::uuid IS NULL OR d.agent_id = $3)
+		ORDER BY d.created_at
+		LIMIT $4`, req.From, req.To, req.AgentID, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// replayBatch kararları sırayla yeniden oynatır; her sonuç kaydedilir ve
+// (nil değilse) done'a verilir
+func (rs *ReplayService) replayBatch(ctx context.Context, batchID uuid.UUID, ids []uuid.UUID, opts models.ReplayOptions, done func(models.DecisionReplay)) error {
+	var total, matching, failed int
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		src, err := rs.load(ctx, id)
+		if err != nil {
+			return err
+		}
+		r, err := rs.run(ctx, src, opts, &batchID)
+		if err != nil {
+			return err
+		}
+		total++
+		switch {
+		case r.Error != "":
+			failed++
+		case r.Diff != nil && r.Diff.SameAction:
+			matching++
+		}
+		if done != nil {
+			done(*r)
+		}
+	}
+
+	log.Info().
+		Str("batch_id", batchID.String()).
+		Str("model", opts.Model).
+		Int("total", total).
+		Int("matching", matching).
+		Int("failed", failed).
+		Msg("Decision replay finished")
+	return nil
+}
+
+// List bir kararın geçmiş tekrarlarını en yeniden eskiye döndürür
+func (rs *ReplayService) List(ctx context.Context, decisionID uuid.UUID) ([]models.DecisionReplay, error) {
+	return rs.queryReplays(ctx, "r.decision_id = $1", "r.created_at DESC", decisionID)
+}
+
+// queryReplays koşula uyan kayıtlı tekrarları verilen sırayla döndürür
+func (rs *ReplayService) queryReplays(ctx context.Context, where, orderBy string, args ...any) ([]models.DecisionReplay, error) {
+	rows, err := rs.db.Query(ctx, `
+		SELECT r.id, r.batch_id, r.decision_id, d.agent_id, r.model, COALESCE(r.provider, ''), COALESCE(r.prompt_set, ''),
+		       r.original, r.replayed, r.same_action, r.changes, COALESCE(r.latency_ms, 0),
+		       COALESCE(r.raw_response, ''), COALESCE(r.error, ''), r.created_at
+		FROM decision_replays r
+		JOIN agent_decisions d ON d.id = r.decision_id
+		WHERE `+where+`
+		ORDER BY `+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replays := []models.DecisionReplay{}
+	for rows.Next() {
+		var r models.DecisionReplay
+		var original, replayed, changes []byte
+		var same *bool
+		if err := rows.Scan(&r.ID, &r.BatchID, &r.DecisionID, &r.AgentID, &r.Model, &r.Provider, &r.PromptSet,
+			&original, &replayed, &same, &changes, &r.LatencyMs, &r.RawResponse, &r.Error, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal(original, &r.Original)
+		if len(replayed) > 0 {
+			r.Replayed = &models.ReplayedDecision{}
+			_ = json.Unmarshal(replayed, r.Replayed)
+		}
+		if same != nil {
+			r.Diff = &models.DecisionDiff{SameAction: *same}
+			_ = json.Unmarshal(changes, &r.Diff.Changes)
+		}
+		replays = append(replays, r)
+	}
+	return replays, rows.Err()
+}
+
+// checkOptions model ve prompt setinin kullanılabilir olduğunu doğrular
+func (rs *ReplayService) checkOptions(opts models.ReplayOptions) error {
+	if opts.Model != "" {
+		if _, ok := rs.clients[opts.Model]; !ok {
+			return fmt.Errorf("%w: %s", ErrReplayModelUnavailable, opts.Model)
+		}
+	}
+	if opts.PromptSet != "" {
+		if _, ok := rs.prompts.Get(opts.PromptSet); !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownPromptSet, opts.PromptSet)
+		}
+	}
+	return nil
+}
+
+// load orijinal kararı, denetim kaydındaki promptları ve modeli yükler
+func (rs *ReplayService) load(ctx context.Context, decisionID uuid.UUID) (*replaySource, error) {
+	src := &replaySource{DecisionID: decisionID}
+	var symbol *string
+	var quantity *int
+	var orders, targetWeights, marketContext []byte
+	var systemPrompt, prompt, model *string
+	err := rs.db.QueryRow(ctx, `
+		SELECT d.agent_id, a.name, COALESCE(s.strategy_type, 'balanced'),
+		       d.decision, d.stock_symbol, d.quantity, COALESCE(d.confidence_score, 0), d.reasoning_summary,
+		       d.orders, d.target_weights, d.market_context,
+		       au.system_prompt, au.prompt, au.model
+		FROM agent_decisions d
+		JOIN agents a ON a.id = d.agent_id
+		LEFT JOIN agent_strategies s ON s.agent_id = a.id AND s.is_active
+		LEFT JOIN agent_decision_audits au ON au.decision_id = d.id
+		WHERE d.id = $1
+		LIMIT 1`, decisionID).Scan(
+		&src.AgentID, &src.AgentName, &src.Strategy,
+		&src.Original.Action, &symbol, &quantity, &src.Original.Confidence, &src.Original.ReasoningSummary,
+		&orders, &targetWeights, &marketContext,
+		&systemPrompt, &prompt, &model,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDecisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if prompt == nil || systemPrompt == nil {
+		return nil, ErrNoDecisionAudit
+	}
+	src.SystemPrompt, src.Prompt, src.Model = *systemPrompt, *prompt, deref(model)
+	src.Original.StockSymbol = deref(symbol)
+	if quantity != nil {
+		src.Original.Quantity = *quantity
+	}
+	if len(orders) > 0 {
+		_ = json.Unmarshal(orders, &src.Original.Orders)
+	}
+	if len(targetWeights) > 0 {
+		_ = json.Unmarshal(targetWeights, &src.Original.TargetWeights)
+	}
+	var snap models.MarketSnapshot
+	if len(marketContext) > 0 && json.Unmarshal(marketContext, &snap) == nil {
+		src.Balance = snap.Balance
+	}
+	return src, nil
+}
+
+// run kararı seçilen modelde çalıştırır, orijinalle karşılaştırır ve kaydeder.
+// Model hatası bir tekrar sonucu olarak kaydedilir; yalnızca seçim ve kayıt hataları döner.
+func (rs *ReplayService) run(ctx context.Context, src *replaySource, opts models.ReplayOptions, batchID *uuid.UUID) (*models.DecisionReplay, error) {
+	if err := rs.checkOptions(opts); err != nil {
+		return nil, err
+	}
+	model := opts.Model
+	if model == "" {
+		model = src.Model
+	}
+	client, ok := rs.clients[model]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReplayModelUnavailable, model)
+	}
+
+	systemPrompt := src.SystemPrompt
+	if opts.PromptSet != "" {
+		ps, _ := rs.prompts.Get(opts.PromptSet)
+		rendered, err := ps.RenderSystem(&ai.DecisionRequest{
+			AgentID:        src.AgentID.String(),
+			AgentName:      src.AgentName,
+			CurrentBalance: src.Balance,
+			Strategy:       src.Strategy,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render system prompt: %w", err)
+		}
+		systemPrompt = rendered
+	}
+
+	r := &models.DecisionReplay{
+		ID:         uuid.New(),
+		BatchID:    batchID,
+		DecisionID: src.DecisionID,
+		AgentID:    src.AgentID,
+		Model:      model,
+		Provider:   ai.ProviderOf(client),
+		PromptSet:  opts.PromptSet,
+		Original:   src.Original,
+		CreatedAt:  time.Now(),
+	}
+
+	started := time.Now()
+	decision, err := client.GetTradingDecision(ctx, systemPrompt, src.Prompt)
+	r.LatencyMs = int(time.Since(started).Milliseconds())
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		replayed := replayedDecision(decision)
+		diff := diffDecisions(src.Original, replayed)
+		r.Replayed, r.Diff, r.RawResponse = &replayed, &diff, decision.RawResponse
+	}
+
+	if err := rs.store(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// store tekrar sonucunu decision_replays tablosuna yazar
+func (rs *ReplayService) store(ctx context.Context, r *models.DecisionReplay) error {
+	original, _ := json.Marshal(r.Original)
+	var replayed, changes []byte
+	var same *bool
+	if r.Replayed != nil {
+		replayed, _ = json.Marshal(r.Replayed)
+	}
+	if r.Diff != nil {
+		same = &r.Diff.SameAction
+		changes, _ = json.Marshal(r.Diff.Changes)
+	}
+	var promptSet, errText *string
+	if r.PromptSet != "" {
+		promptSet = &r.PromptSet
+	}
+	if r.Error != "" {
+		errText = &r.Error
+	}
+	_, err := rs.db.Exec(ctx, `
+		INSERT INTO decision_replays (id, batch_id, decision_id, model, provider, prompt_set, original, replayed,
+			same_action, changes, latency_ms, raw_response, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`, r.ID, r.BatchID, r.DecisionID, truncateName(r.Model, 100), r.Provider, promptSet, original, replayed,
+		same, changes, r.LatencyMs, r.RawResponse, errText)
+	if err != nil {
+		return fmt.Errorf("failed to store replay: %w", err)
+	}
+	return nil
+}
+
+// replayedDecision bir YZ kararının karşılaştırılan alanlarını çıkarır
+func replayedDecision(d *models.AIDecision) models.ReplayedDecision {
+	summary := d.ReasoningSummary
+	if summary == "" {
+		summary = d.Rationale
+	}
+	return models.ReplayedDecision{
+		Action:           strings.ToUpper(d.Action),
+		StockSymbol:      strings.ToUpper(d.StockSymbol),
+		Quantity:         d.Quantity,
+		Confidence:       d.Confidence,
+		Orders:           d.Orders,
+		TargetWeights:    d.TargetWeights,
+		ReasoningSummary: summary,
+	}
+}
+
+// diffDecisions iki kararı alan alan karşılaştırır. Aynı eylem: aynı action,
+// BUY/SELL için ayrıca aynı sembol.
+func diffDecisions(orig, replay models.ReplayedDecision) models.DecisionDiff {
+	diff := models.DecisionDiff{Changes: []string{}}
+	add := func(format string, args ...any) { diff.Changes = append(diff.Changes, fmt.Sprintf(format, args...)) }
+
+	origAction, replayAction := strings.ToUpper(orig.Action), strings.ToUpper(replay.Action)
+	origSymbol, replaySymbol := strings.ToUpper(orig.StockSymbol), strings.ToUpper(replay.StockSymbol)
+	if origAction != replayAction {
+		add("action: %s -> %s", origAction, replayAction)
+	}
+	if origSymbol != replaySymbol {
+		add("stock_symbol: %s -> %s", orDash(origSymbol), orDash(replaySymbol))
+	}
+	if orig.Quantity != replay.Quantity {
+		add("quantity: %d -> %d", orig.Quantity, replay.Quantity)
+	}
+	if delta := replay.Confidence - orig.Confidence; math.Abs(delta) >= 0.5 {
+		add("confidence: %.0f -> %.0f (%+.0f)", orig.Confidence, replay.Confidence, delta)
+	}
+	if a, b := formatOrders(orig.Orders), formatOrders(replay.Orders); a != b {
+		add("orders: %s -> %s", orDash(a), orDash(b))
+	}
+
+	symbols := map[string]bool{}
+	for s := range orig.TargetWeights {
+		symbols[s] = true
+	}
+	for s := range replay.TargetWeights {
+		symbols[s] = true
+	}
+	sorted := make([]string, 0, len(symbols))
+	for s := range symbols {
+		sorted = append(sorted, s)
+	}
+	sort.Strings(sorted)
+	for _, s := range sorted {
+		if a, b := orig.TargetWeights[s], replay.TargetWeights[s]; math.Abs(a-b) >= 0.5 {
+			add("target_weight %s: %.0f%% -> %.0f%%", s, a, b)
+		}
+	}
+
+	diff.SameAction = origAction == replayAction
+	if origAction == models.ActionBuy || origAction == models.ActionSell {
+		diff.SameAction = diff.SameAction && origSymbol == replaySymbol
+	}
+	return diff
+}
+
+// summarizeReplays rapordaki eşleşen ve başarısız tekrarları sayar
+func summarizeReplays(report *models.ReplayReport) {
+	report.Total = len(report.Results)
+	report.Matching, report.Failed = 0, 0
+	for _, r := range report.Results {
+		switch {
+		case r.Error != "":
+			report.Failed++
+		case r.Diff != nil && r.Diff.SameAction:
+			report.Matching++
+		}
+	}
+	if done := report.Total - report.Failed; done > 0 {
+		report.MatchRate = float64(report.Matching) / float64(done) * 100
+	}
+}
+
+func formatOrders(orders []models.Order) string {
+	parts := make([]string, len(orders))
+	for i, o := range orders {
+		parts[i] = fmt.Sprintf("%s %s x%d", strings.ToUpper(o.Action), strings.ToUpper(o.StockSymbol), o.Quantity)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+func TestDiffDecisions(t *testing.T) {
+	orig := models.ReplayedDecision{Action: "BUY", StockSymbol: "THYAO", Quantity: 10, Confidence: 80}
+
+	same := diffDecisions(orig, models.ReplayedDecision{Action: "buy", StockSymbol: "thyao", Quantity: 10, Confidence: 80.2})
+	if !same.SameAction || len(same.Changes) != 0 {
+		t.Errorf("identical decision diff = %+v", same)
+	}
+
+	qty := diffDecisions(orig, models.ReplayedDecision{Action: "BUY", StockSymbol: "THYAO", Quantity: 20, Confidence: 65})
+	if !qty.SameAction || strings.Join(qty.Changes, "; ") != "quantity: 10 -> 20; confidence: 80 -> 65 (-15)" {
+		t.Errorf("quantity diff = %+v", qty)
+	}
+
+	symbol := diffDecisions(orig, models.ReplayedDecision{Action: "BUY", StockSymbol: "AKBNK", Quantity: 10, Confidence: 80})
+	if symbol.SameAction {
+		t.Errorf("buying another symbol should not be the same action: %+v", symbol)
+	}
+
+	hold := diffDecisions(orig, models.ReplayedDecision{Action: "HOLD", Confidence: 80})
+	if hold.SameAction || hold.Changes[0] != "action: BUY -> HOLD" || hold.Changes[1] != "stock_symbol: THYAO -> -" {
+		t.Errorf("hold diff = %+v", hold)
+	}
+
+	rebalance := diffDecisions(
+		models.ReplayedDecision{Action: "REBALANCE", TargetWeights: map[string]float64{"THYAO": 40, "AKBNK": 20}},
+		models.ReplayedDecision{Action: "REBALANCE", TargetWeights: map[string]float64{"THYAO": 30, "AKBNK": 20, "SISE": 10}},
+	)
+	if !rebalance.SameAction || strings.Join(rebalance.Changes, "; ") != "target_weight SISE: 0% -> 10%; target_weight THYAO: 40% -> 30%" {
+		t.Errorf("rebalance diff = %+v", rebalance)
+	}
+}
+
+func TestSummarizeReplays(t *testing.T) {
+	report := &models.ReplayReport{Results: []models.DecisionReplay{
+		{Diff: &models.DecisionDiff{SameAction: true}},
+		{Diff: &models.DecisionDiff{SameAction: true}},
+		{Diff: &models.DecisionDiff{SameAction: false}},
+		{Error: "timeout"},
+	}}
+	summarizeReplays(report)
+	if report.Total != 4 || report.Matching != 2 || report.Failed != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	if report.MatchRate < 66.6 || report.MatchRate > 66.7 {
+		t.Errorf("match rate = %v, want 66.7", report.MatchRate)
+	}
+}
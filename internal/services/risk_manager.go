@@ -15,6 +15,7 @@ type RiskManager struct {
 	maxRiskPerTrade    float64 // yüzde
 	maxPortfolioRisk   float64 // yüzde
 	minConfidenceScore float64
+	ledger             Ledger
 }
 
 // NewRiskManager yeni bir risk yöneticisi oluşturur
@@ -24,23 +25,32 @@ func NewRiskManager(db *pgxpool.Pool, maxRiskPerTrade, maxPortfolioRisk, minConf
 		maxRiskPerTrade:    maxRiskPerTrade,  // 5.0 %5 için
 		maxPortfolioRisk:   maxPortfolioRisk, // 20.0 %20 için
 		minConfidenceScore: minConfidence,    // 70.0 %70 için
+		ledger:             LiveLedger,
 	}
 }
 
-// ValidateTrade bir alım-satım kararını risk kurallarına göre doğrular
-func (rm *RiskManager) ValidateTrade(ctx context.Context, agentID uuid.UUID, decision *models.AIDecision) error {
-	// Miktar kontrolü
-	if decision.Quantity <= 0 {
-		return fmt.Errorf("invalid quantity: %d (must be > 0)", decision.Quantity)
-	}
-	// Güven kontrolü
-	if decision.Confidence < rm.minConfidenceScore {
-		return fmt.Errorf("confidence too low: %.1f%% < %.1f%%", decision.Confidence, rm.minConfidenceScore)
+// On aynı kurallarla verilen defterin (canlı/gölge) bakiye ve portföyünü denetleyen kopyayı döndürür
+func (rm *RiskManager) On(l Ledger) *RiskManager {
+	c := *rm
+	c.ledger = l
+	return &c
+}
+
+// balance ajanın defterdeki nakit bakiyesini döndürür (gölge hesap yoksa açılır)
+func (rm *RiskManager) balance(ctx context.Context, agentID uuid.UUID) (float64, error) {
+	if err := rm.ledger.EnsureAccount(ctx, rm.db, agentID); err != nil {
+		return 0, err
 	}
+	var balance float64
+	err := rm.db.QueryRow(ctx, rm.ledger.balanceQuery(), agentID).Scan(&balance)
+	return balance, err
+}
 
+// ValidateTrade bir alım-satım kararını risk kurallarına göre doğrular
+// (kurallar CheckTrade'de; burada yalnızca bakiye, fiyat ve portföy değeri yüklenir)
+func (rm *RiskManager) ValidateTrade(ctx context.Context, agentID uuid.UUID, decision *models.AIDecision) error {
 	// Ajan bakiyesini al
-	var balance float64
-	err := rm.db.QueryRow(ctx, "SELECT current_balance FROM agents WHERE id = $1", agentID).Scan(&balance)
+	balance, err := rm.balance(ctx, agentID)
 	if err != nil {
 		return fmt.Errorf("failed to get agent balance: %w", err)
 	}
@@ -52,6 +62,24 @@ func (rm *RiskManager) ValidateTrade(ctx context.Context, agentID uuid.UUID, dec
 		return fmt.Errorf("stock not found: %w", err)
 	}
 
+	portfolioValue, err := rm.getPortfolioValue(ctx, agentID)
+	if err != nil {
+		return err
+	}
+
+	return rm.CheckTrade(balance, portfolioValue, stockPrice, decision)
+}
+
+// CheckTrade tek emirli kararı verilen nakit, portföy değeri ve fiyatla risk
+// kurallarına göre denetler; veritabanına dokunmaz (backtest de kullanır)
+func (rm *RiskManager) CheckTrade(balance, portfolioValue, stockPrice float64, decision *models.AIDecision) error {
+	if decision.Quantity <= 0 {
+		return fmt.Errorf("invalid quantity: %d (must be > 0)", decision.Quantity)
+	}
+	if decision.Confidence < rm.minConfidenceScore {
+		return fmt.Errorf("confidence too low: %.1f%% < %.1f%%", decision.Confidence, rm.minConfidenceScore)
+	}
+
 	tradeAmount := float64(decision.Quantity) * stockPrice
 
 	// İşlem büyüklüğünü kontrol et
@@ -74,11 +102,6 @@ func (rm *RiskManager) ValidateTrade(ctx context.Context, agentID uuid.UUID, dec
 	// For now, SELL actions are allowed without additional validation
 
 	// Portföy yoğunluğunu kontrol et
-	portfolioValue, err := rm.getPortfolioValue(ctx, agentID)
-	if err != nil {
-		return err
-	}
-
 	totalValue := balance + portfolioValue
 	if decision.Action == "BUY" {
 		newPortfolioValue := portfolioValue + tradeAmount
@@ -96,12 +119,136 @@ func (rm *RiskManager) ValidateTrade(ctx context.Context, agentID uuid.UUID, dec
 // getPortfolioValue bir ajan için toplam portföy değerini hesaplar
 func (rm *RiskManager) getPortfolioValue(ctx context.Context, agentID uuid.UUID) (float64, error) {
 	var value float64
-	query := `
+	query := fmt.Sprintf(`
 		SELECT COALESCE(SUM(p.quantity * s.current_price), 0)
-		FROM portfolio p
+		FROM %s p
 		JOIN stocks s ON p.stock_symbol = s.symbol
 		WHERE p.agent_id = $1
-	`
+	`, rm.ledger.portfolio)
 	err := rm.db.QueryRow(ctx, query, agentID).Scan(&value)
 	return value, err
 }
+
+// MaxBuyAmount tek bir alım emri için izin verilen en yüksek tutarı döndürür
+func (rm *RiskManager) MaxBuyAmount(balance float64) float64 {
+	return balance * (rm.maxRiskPerTrade / 100)
+}
+
+// ValidateOrders çoklu emir kararını, emirleri sırayla (önce satışlar) uygulayarak doğrular
+func (rm *RiskManager) ValidateOrders(ctx context.Context, agentID uuid.UUID, orders []models.TradeRequest, confidence float64) error {
+	if len(orders) == 0 {
+		return fmt.Errorf("no orders")
+	}
+	if confidence < rm.minConfidenceScore {
+		return fmt.Errorf("confidence too low: %.1f%% < %.1f%%", confidence, rm.minConfidenceScore)
+	}
+
+	balance, err := rm.balance(ctx, agentID)
+	if err != nil {
+		return fmt.Errorf("failed to get agent balance: %w", err)
+	}
+
+	holdings := make(map[string]int)
+	rows, err := rm.db.Query(ctx, fmt.Sprintf("SELECT stock_symbol, quantity FROM %s WHERE agent_id = $1", rm.ledger.portfolio), agentID)
+	if err != nil {
+		return fmt.Errorf("failed to get portfolio: %w", err)
+	}
+	for rows.Next() {
+		var sym string
+		var qty int
+		if err := rows.Scan(&sym, &qty); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to get portfolio: %w", err)
+		}
+		holdings[sym] = qty
+	}
+	rows.Close()
+
+	prices := make(map[string]float64)
+	for _, o := range orders {
+		if _, ok := prices[o.StockSymbol]; ok {
+			continue
+		}
+		var price float64
+		if err := rm.db.QueryRow(ctx, "SELECT current_price FROM stocks WHERE symbol = $1", o.StockSymbol).Scan(&price); err != nil {
+			return fmt.Errorf("stock not found: %s", o.StockSymbol)
+		}
+		prices[o.StockSymbol] = price
+	}
+
+	portfolioValue, err := rm.getPortfolioValue(ctx, agentID)
+	if err != nil {
+		return err
+	}
+
+	return rm.simulateOrders(balance, portfolioValue, holdings, prices, orders)
+}
+
+// CheckOrders çoklu emir kararını bellekteki nakit, portföy ve fiyatlarla
+// ValidateOrders ile aynı kurallara göre denetler; veritabanına dokunmaz
+func (rm *RiskManager) CheckOrders(balance, portfolioValue float64, holdings map[string]int, prices map[string]float64, orders []models.TradeRequest, confidence float64) error {
+	if len(orders) == 0 {
+		return fmt.Errorf("no orders")
+	}
+	if confidence < rm.minConfidenceScore {
+		return fmt.Errorf("confidence too low: %.1f%% < %.1f%%", confidence, rm.minConfidenceScore)
+	}
+	for _, o := range orders {
+		if _, ok := prices[o.StockSymbol]; !ok {
+			return fmt.Errorf("stock not found: %s", o.StockSymbol)
+		}
+	}
+	return rm.simulateOrders(balance, portfolioValue, holdings, prices, orders)
+}
+
+// simulateOrders emirleri bellekte uygular ve ilk kural ihlalini döndürür
+func (rm *RiskManager) simulateOrders(balance, portfolioValue float64, holdings map[string]int, prices map[string]float64, orders []models.TradeRequest) error {
+	held := make(map[string]int, len(holdings))
+	for k, v := range holdings {
+		held[k] = v
+	}
+
+	bought := false
+	for i, o := range SellsFirst(orders) {
+		if o.Quantity <= 0 {
+			return fmt.Errorf("order %d: invalid quantity: %d (must be > 0)", i+1, o.Quantity)
+		}
+		amount := float64(o.Quantity) * prices[o.StockSymbol]
+
+		switch o.TradeType {
+		case models.ActionSell:
+			if held[o.StockSymbol] < o.Quantity {
+				return fmt.Errorf("order %d: insufficient stocks: %s holds %d lots, selling %d",
+					i+1, o.StockSymbol, held[o.StockSymbol], o.Quantity)
+			}
+			held[o.StockSymbol] -= o.Quantity
+			balance += amount * (1 - CommissionRate)
+			portfolioValue -= amount
+		case models.ActionBuy:
+			if maxAmount := rm.MaxBuyAmount(balance); amount > maxAmount {
+				return fmt.Errorf("order %d: trade amount %.2f TL exceeds max %.2f TL (%.1f%% of balance)",
+					i+1, amount, maxAmount, rm.maxRiskPerTrade)
+			}
+			cost := amount * (1 + CommissionRate)
+			if cost > balance {
+				return fmt.Errorf("order %d: insufficient balance: %.2f TL < %.2f TL", i+1, balance, cost)
+			}
+			held[o.StockSymbol] += o.Quantity
+			balance -= cost
+			portfolioValue += amount
+			bought = true
+		default:
+			return fmt.Errorf("order %d: invalid action: %s", i+1, o.TradeType)
+		}
+	}
+
+	// Portföy yoğunluğu yalnızca alım varsa kontrol edilir (ValidateTrade ile aynı)
+	if bought {
+		if total := balance + portfolioValue; total > 0 {
+			if risk := portfolioValue / total * 100; risk > rm.maxPortfolioRisk {
+				return fmt.Errorf("portfolio risk %.1f%% exceeds max %.1f%%", risk, rm.maxPortfolioRisk)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,613 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
+	"github.com/1batu/market-ai/internal/websocket"
+)
+
+var (
+	// ErrScenarioNotFound senaryo dosyası ya da çalıştırması bulunamadığında döner
+	ErrScenarioNotFound = errors.New("scenario not found")
+	// ErrScenarioRunning aynı anda yalnızca bir senaryo çalışabilir
+	ErrScenarioRunning = errors.New("a scenario is already running")
+)
+
+// DefaultScenarioDir senaryo YAML dosyalarının varsayılan klasörü
+const DefaultScenarioDir = "scenarios"
+
+// ScenarioRunner stres testi senaryolarını çalıştırır: fiyat şokları ve
+// volatilite rejimleri simüle piyasanın Overlay'ine, sentetik haberler
+// NewsAggregator üzerinden gerçek haberlerle aynı yola verilir. Her
+// çalıştırma scenario_runs'a kaydedilir ve ajanların tepkisi raporlanır.
+type ScenarioRunner struct {
+	db      *pgxpool.Pool
+	hub     *websocket.Hub
+	news    *NewsAggregator
+	dir     string
+	overlay *simulation.Overlay // nil = simüle piyasa yok; yalnızca haber olayları
+	clock   simulation.Clock    // nil = duvar saati; oynatmada haber zamanı sanal saatten
+
+	mu     sync.Mutex
+	base   context.Context
+	active *activeScenario
+}
+
+type activeScenario struct {
+	id     uuid.UUID
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScenarioRunner dir klasöründeki senaryoları çalıştıran bir servis oluşturur
+func NewScenarioRunner(db *pgxpool.Pool, hub *websocket.Hub, news *NewsAggregator, dir string) *ScenarioRunner {
+	if dir == "" {
+		dir = DefaultScenarioDir
+	}
+	return &ScenarioRunner{db: db, hub: hub, news: news, dir: dir, base: context.Background()}
+}
+
+// SetOverlay şok ve rejim olaylarının uygulanacağı simüle piyasayı bağlar
+func (r *ScenarioRunner) SetOverlay(o *simulation.Overlay) { r.overlay = o }
+
+// SetClock sentetik haberlerin yayın zamanını verir (geçmiş oynatma)
+func (r *ScenarioRunner) SetClock(c simulation.Clock) { r.clock = c }
+
+// Start çalıştırmaların bağlamını ayarlar; ctx bitince çalışan senaryoyu durdurur
+func (r *ScenarioRunner) Start(ctx context.Context) {
+	r.mu.Lock()
+	r.base = ctx
+	r.mu.Unlock()
+	log.Info().Str("dir", r.dir).Msg("Scenario runner started")
+
+	<-ctx.Done()
+	r.mu.Lock()
+	active := r.active
+	r.mu.Unlock()
+	if active != nil {
+		active.cancel()
+		<-active.done
+	}
+	log.Info().Msg("Scenario runner stopped")
+}
+
+// Available senaryo klasöründeki geçerli senaryoları listeler
+func (r *ScenarioRunner) Available() ([]models.ScenarioInfo, error) {
+	entries, err := os.ReadDir(r.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []models.ScenarioInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	infos := []models.ScenarioInfo{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		sc, err := simulation.LoadScenario(filepath.Join(r.dir, e.Name()))
+		if err != nil {
+			log.Warn().Err(err).Str("file", e.Name()).Msg("Skipping invalid scenario")
+			continue
+		}
+		infos = append(infos, models.ScenarioInfo{
+			File:        strings.TrimSuffix(e.Name(), ext),
+			Name:        sc.Name,
+			Description: sc.Description,
+			Events:      len(sc.Events),
+			Length:      sc.Length().String(),
+		})
+	}
+	return infos, nil
+}
+
+// Load senaryo klasöründen name(.yaml|.yml) dosyasını okur
+func (r *ScenarioRunner) Load(name string) (*simulation.Scenario, error) {
+	if name == "" || name != filepath.Base(name) {
+		return nil, ErrScenarioNotFound
+	}
+	for _, ext := range []string{"", ".yaml", ".yml"} {
+		sc, err := simulation.LoadScenario(filepath.Join(r.dir, name+ext))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return sc, err
+	}
+	return nil, ErrScenarioNotFound
+}
+
+const scenarioRunColumns = `
+	id, name, spec, status, events, start_equity, end_equity, COALESCE(error, ''), started_at, ended_at`
+
+func scanScenarioRun(row pgx.Row) (models.ScenarioRun, error) {
+	var run models.ScenarioRun
+	err := row.Scan(&run.ID, &run.Name, &run.Scenario, &run.Status, &run.Events,
+		&run.StartEquity, &run.EndEquity, &run.Error, &run.StartedAt, &run.EndedAt)
+	return run, err
+}
+
+// StartRun senaryoyu hemen başlatır; olaylar başlangıca göre zamanlanır
+func (r *ScenarioRunner) StartRun(ctx context.Context, sc *simulation.Scenario) (*models.ScenarioRun, error) {
+	if sc.MovesPrices() && r.overlay == nil {
+		return nil, fmt.Errorf("%w: shock and regime events need the market simulator (SIMULATOR_ENABLED, no replay)", simulation.ErrInvalidScenario)
+	}
+	spec, err := json.Marshal(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active != nil {
+		return nil, ErrScenarioRunning
+	}
+
+	// Yeniden başlatmada yarım kalan çalıştırmalar
+	if _, err := r.db.Exec(ctx, `
+		UPDATE scenario_runs SET status = 'stopped', ended_at = NOW(), error = 'interrupted'
+		WHERE status = 'running'`); err != nil {
+		return nil, fmt.Errorf("close stale scenario runs: %w", err)
+	}
+	equity, err := liveEquity(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot agent equity: %w", err)
+	}
+	run, err := scanScenarioRun(r.db.QueryRow(ctx, `
+		INSERT INTO scenario_runs (name, spec, start_equity)
+		VALUES ($1, $2, $3)
+		RETURNING`+scenarioRunColumns, sc.Name, spec, equity))
+	if err != nil {
+		return nil, fmt.Errorf("create scenario run: %w", err)
+	}
+
+	runCtx, cancel := context.WithCancel(r.base)
+	active := &activeScenario{id: run.ID, cancel: cancel, done: make(chan struct{})}
+	r.active = active
+	go func() {
+		defer close(active.done)
+		r.run(runCtx, run, sc)
+		cancel()
+		r.mu.Lock()
+		r.active = nil
+		r.mu.Unlock()
+	}()
+
+	log.Info().Str("scenario", sc.Name).Str("run_id", run.ID.String()).Dur("length", sc.Length()).Msg("Scenario started")
+	r.hub.BroadcastMessage("scenario_status", map[string]interface{}{"run_id": run.ID, "name": run.Name, "status": run.Status})
+	return &run, nil
+}
+
+// StopRun çalışan senaryoyu durdurur ve bitmesini bekler
+func (r *ScenarioRunner) StopRun(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	active := r.active
+	r.mu.Unlock()
+	if active == nil || active.id != id {
+		return ErrScenarioNotFound
+	}
+	active.cancel()
+	select {
+	case <-active.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run olayları zamanı gelince uygular, senaryo süresi dolunca çalıştırmayı kapatır
+func (r *ScenarioRunner) run(ctx context.Context, run models.ScenarioRun, sc *simulation.Scenario) {
+	origin := time.Now()
+	events := []models.ScenarioEventLog{}
+	status, errMsg := models.ScenarioCompleted, ""
+
+	defer func() {
+		// Rejimler senaryoyla biter
+		if r.overlay != nil {
+			for i, e := range sc.Events {
+				if e.Type == simulation.EventRegime {
+					r.overlay.ClearRegime(scenarioRegimeID(run.ID, i))
+				}
+			}
+		}
+		r.finish(run, status, events, errMsg)
+	}()
+
+	for _, action := range sc.Schedule() {
+		if !sleepUntil(ctx, origin.Add(action.At)) {
+			status = models.ScenarioStopped
+			return
+		}
+		entry, err := r.apply(ctx, run.ID, sc, action)
+		if err != nil {
+			status, errMsg = models.ScenarioFailed, err.Error()
+			log.Error().Err(err).Str("scenario", sc.Name).Int("event", action.Index+1).Msg("Scenario event failed")
+			return
+		}
+		// Olay zamanı veritabanı saatine göre (kararların created_at'iyle karşılaştırılır)
+		entry.AppliedAt = run.StartedAt.Add(time.Since(origin))
+		events = append(events, entry)
+
+		writeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if _, err := r.db.Exec(writeCtx, `UPDATE scenario_runs SET events = $2 WHERE id = $1`, run.ID, events); err != nil {
+			log.Warn().Err(err).Msg("Failed to record scenario event")
+		}
+		cancel()
+		r.hub.BroadcastMessage("scenario_event", map[string]interface{}{"run_id": run.ID, "name": run.Name, "event": entry})
+	}
+
+	if !sleepUntil(ctx, origin.Add(sc.Length())) {
+		status = models.ScenarioStopped
+	}
+}
+
+// apply tek bir zamanlanmış adımı uygular
+func (r *ScenarioRunner) apply(ctx context.Context, runID uuid.UUID, sc *simulation.Scenario, action simulation.ScheduledAction) (models.ScenarioEventLog, error) {
+	e := sc.Events[action.Index]
+	entry := models.ScenarioEventLog{Index: action.Index, Type: e.Type, End: action.End, Symbols: e.Symbols, Sector: e.Sector}
+
+	switch e.Type {
+	case simulation.EventShock:
+		r.overlay.Shock(e.Target, e.Return)
+		entry.Detail = fmt.Sprintf("%s %+.2f%%", scenarioTargetName(e.Target), e.Return*100)
+	case simulation.EventRegime:
+		id := scenarioRegimeID(runID, action.Index)
+		if action.End {
+			r.overlay.ClearRegime(id)
+			entry.Detail = scenarioTargetName(e.Target) + " regime ended"
+			break
+		}
+		r.overlay.SetRegime(id, e.Target, simulation.Regime{VolatilityScale: e.VolatilityScale, Drift: e.Drift})
+		entry.Detail = fmt.Sprintf("%s volatility x%.2f, drift %+.2f", scenarioTargetName(e.Target), e.VolatilityScale, e.Drift)
+		if e.VolatilityScale == 0 {
+			entry.Detail = fmt.Sprintf("%s drift %+.2f", scenarioTargetName(e.Target), e.Drift)
+		}
+	case simulation.EventNews:
+		stored, err := r.news.InjectArticles(ctx, []models.NewsArticle{r.article(runID, action.Index, e.News)})
+		if err != nil {
+			return entry, err
+		}
+		entry.Detail = e.News.Title
+		if len(stored) > 0 {
+			entry.NewsID = &stored[0].ID
+		}
+	}
+	log.Info().Str("type", e.Type).Bool("end", action.End).Str("detail", entry.Detail).Msg("Scenario event applied")
+	return entry, nil
+}
+
+// article senaryo haberini makaleye çevirir; URL çalıştırma başına tekildir
+func (r *ScenarioRunner) article(runID uuid.UUID, index int, n *simulation.ScenarioNews) models.NewsArticle {
+	published := time.Now()
+	if r.clock != nil {
+		published = r.clock.Now()
+	}
+	a := models.NewsArticle{
+		Title:          n.Title,
+		Description:    n.Description,
+		Content:        n.Content,
+		Source:         n.Source,
+		URL:            fmt.Sprintf("scenario://%s/%d", runID, index),
+		EventType:      n.EventType,
+		Category:       n.Category,
+		RelatedStocks:  n.RelatedStocks,
+		Sentiment:      n.Sentiment,
+		SentimentScore: n.SentimentScore,
+		ImpactLevel:    n.ImpactLevel,
+		PublishedAt:    published,
+	}
+	if a.Source == "" {
+		a.Source = "Senaryo"
+	}
+	if a.EventType == "" {
+		a.EventType = "news"
+	}
+	if a.RelatedStocks == nil {
+		a.RelatedStocks = []string{}
+	}
+	return a
+}
+
+// finish çalıştırmayı kapatır ve bitiş varlıklarını kaydeder
+func (r *ScenarioRunner) finish(run models.ScenarioRun, status string, events []models.ScenarioEventLog, errMsg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	equity, err := liveEquity(ctx, r.db)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to snapshot agent equity")
+	}
+	if _, err := r.db.Exec(ctx, `
+		UPDATE scenario_runs
+		SET status = $2, events = $3, end_equity = $4, error = NULLIF($5, ''), ended_at = NOW()
+		WHERE id = $1`, run.ID, status, events, equity, errMsg); err != nil {
+		log.Error().Err(err).Str("run_id", run.ID.String()).Msg("Failed to close scenario run")
+	}
+	log.Info().Str("scenario", run.Name).Str("status", status).Int("events", len(events)).Msg("Scenario finished")
+	r.hub.BroadcastMessage("scenario_status", map[string]interface{}{"run_id": run.ID, "name": run.Name, "status": status})
+}
+
+// List senaryo çalıştırmalarını en yeniden eskiye döndürür
+func (r *ScenarioRunner) List(ctx context.Context) ([]models.ScenarioRun, error) {
+	rows, err := r.db.Query(ctx, `SELECT`+scenarioRunColumns+` FROM scenario_runs ORDER BY started_at DESC LIMIT 100`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.ScenarioRun{}
+	for rows.Next() {
+		run, err := scanScenarioRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Get tek bir çalıştırmayı döndürür
+func (r *ScenarioRunner) Get(ctx context.Context, id uuid.UUID) (*models.ScenarioRun, error) {
+	run, err := scanScenarioRun(r.db.QueryRow(ctx, `SELECT`+scenarioRunColumns+` FROM scenario_runs WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrScenarioNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// scenarioDecision rapor için okunan bir ajan kararı
+type scenarioDecision struct {
+	id         uuid.UUID
+	agentID    uuid.UUID
+	action     string
+	symbol     string
+	confidence float64
+	newsIDs    []uuid.UUID
+	at         time.Time
+}
+
+// scenarioTrade rapor için okunan bir canlı işlem
+type scenarioTrade struct {
+	agentID   uuid.UUID
+	tradeType string
+	amount    float64
+}
+
+// Report her ajanın çalıştırma süresince kararlarını, işlemlerini, varlık
+// değişimini ve her olaydan sonraki ilk kararını raporlar. Çalışan senaryoda
+// bitiş varlığı güncel değerdir.
+func (r *ScenarioRunner) Report(ctx context.Context, id uuid.UUID) (*models.ScenarioReport, error) {
+	run, err := r.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var until *time.Time
+	if run.EndedAt != nil {
+		until = run.EndedAt
+	}
+
+	agents := map[uuid.UUID]string{}
+	rows, err := r.db.Query(ctx, `SELECT id, name FROM agents`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var aid uuid.UUID
+		var name string
+		if err := rows.Scan(&aid, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		agents[aid] = name
+	}
+	rows.Close()
+
+	rows, err = r.db.Query(ctx, `
+		SELECT id, agent_id, decision, COALESCE(stock_symbol, ''), COALESCE(confidence_score, 0),
+		       COALESCE(market_context->'news_ids', '[]'::jsonb), created_at
+		FROM agent_decisions
+		WHERE created_at >= $1 AND ($2::timestamp IS NULL OR created_at <= $2)
+		ORDER BY created_at`, run.StartedAt, until)
+	if err != nil {
+		return nil, fmt.Errorf("load scenario decisions: %w", err)
+	}
+	var decisions []scenarioDecision
+	for rows.Next() {
+		var d scenarioDecision
+		if err := rows.Scan(&d.id, &d.agentID, &d.action, &d.symbol, &d.confidence, &d.newsIDs, &d.at); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+	rows.Close()
+
+	rows, err = r.db.Query(ctx, `
+		SELECT agent_id, trade_type, total_amount
+		FROM trades
+		WHERE created_at >= $1 AND ($2::timestamp IS NULL OR created_at <= $2)`, run.StartedAt, until)
+	if err != nil {
+		return nil, fmt.Errorf("load scenario trades: %w", err)
+	}
+	var trades []scenarioTrade
+	for rows.Next() {
+		var t scenarioTrade
+		if err := rows.Scan(&t.agentID, &t.tradeType, &t.amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		trades = append(trades, t)
+	}
+	rows.Close()
+
+	if run.EndedAt == nil {
+		if run.EndEquity, err = liveEquity(ctx, r.db); err != nil {
+			return nil, err
+		}
+	}
+	return buildScenarioReport(*run, agents, decisions, trades), nil
+}
+
+// buildScenarioReport ham verilerden raporu oluşturur
+func buildScenarioReport(run models.ScenarioRun, agents map[uuid.UUID]string, decisions []scenarioDecision, trades []scenarioTrade) *models.ScenarioReport {
+	byAgent := map[uuid.UUID]*models.ScenarioAgentReaction{}
+	get := func(id uuid.UUID) *models.ScenarioAgentReaction {
+		if a, ok := byAgent[id]; ok {
+			return a
+		}
+		a := &models.ScenarioAgentReaction{AgentID: id, AgentName: agents[id]}
+		byAgent[id] = a
+		return a
+	}
+	for id := range run.StartEquity {
+		get(id)
+	}
+
+	for _, d := range decisions {
+		a := get(d.agentID)
+		a.Decisions++
+		a.AvgConfidence += d.confidence
+		switch d.action {
+		case "BUY":
+			a.Buys++
+		case "SELL":
+			a.Sells++
+		default:
+			a.Holds++
+		}
+	}
+	for _, t := range trades {
+		a := get(t.agentID)
+		a.Trades++
+		if t.tradeType == "BUY" {
+			a.BoughtValue += t.amount
+		} else {
+			a.SoldValue += t.amount
+		}
+	}
+
+	// Olay pencereleri: olaydan bir sonraki olaya (ya da çalıştırma sonuna) kadar
+	var starts []models.ScenarioEventLog
+	for _, e := range run.Events {
+		if !e.End {
+			starts = append(starts, e)
+		}
+	}
+
+	report := &models.ScenarioReport{Run: run, Agents: []models.ScenarioAgentReaction{}, GeneratedAt: time.Now()}
+	for id, a := range byAgent {
+		if a.Decisions > 0 {
+			a.AvgConfidence /= float64(a.Decisions)
+		}
+		a.StartEquity = run.StartEquity[id]
+		a.EndEquity = run.EndEquity[id]
+		if a.StartEquity > 0 {
+			a.ReturnPercent = (a.EndEquity - a.StartEquity) / a.StartEquity * 100
+		}
+
+		a.Reactions = make([]models.ScenarioEventReaction, 0, len(starts))
+		for i, e := range starts {
+			reaction := models.ScenarioEventReaction{EventIndex: e.Index, EventType: e.Type}
+			for _, d := range decisions {
+				if d.agentID != id || d.at.Before(e.AppliedAt) {
+					continue
+				}
+				if i+1 < len(starts) && !d.at.Before(starts[i+1].AppliedAt) {
+					break
+				}
+				did := d.id
+				reaction.DecisionID = &did
+				reaction.Decision = d.action
+				reaction.Symbol = d.symbol
+				reaction.Confidence = d.confidence
+				reaction.DelaySeconds = d.at.Sub(e.AppliedAt).Seconds()
+				if e.NewsID != nil {
+					for _, n := range d.newsIDs {
+						if n == *e.NewsID {
+							reaction.SawNews = true
+						}
+					}
+				}
+				break
+			}
+			a.Reactions = append(a.Reactions, reaction)
+		}
+		report.Agents = append(report.Agents, *a)
+	}
+	sort.Slice(report.Agents, func(i, j int) bool { return report.Agents[i].AgentName < report.Agents[j].AgentName })
+	return report
+}
+
+// liveEquity her ajanın canlı defterdeki nakit + güncel fiyatlı pozisyon değeri
+func liveEquity(ctx context.Context, db *pgxpool.Pool) (map[uuid.UUID]float64, error) {
+	rows, err := db.Query(ctx, `
+		SELECT a.id, a.current_balance + COALESCE(SUM(p.quantity * s.current_price), 0)
+		FROM agents a
+		LEFT JOIN portfolio p ON p.agent_id = a.id
+		LEFT JOIN stocks s ON s.symbol = p.stock_symbol
+		GROUP BY a.id, a.current_balance`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	equity := map[uuid.UUID]float64{}
+	for rows.Next() {
+		var id uuid.UUID
+		var value float64
+		if err := rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		equity[id] = value
+	}
+	return equity, rows.Err()
+}
+
+// sleepUntil at anına kadar bekler; ctx biterse false döner
+func sleepUntil(ctx context.Context, at time.Time) bool {
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func scenarioRegimeID(runID uuid.UUID, index int) string {
+	return fmt.Sprintf("%s/%d", runID, index)
+}
+
+// scenarioTargetName olayın hedefini okunur biçimde yazar
+func scenarioTargetName(t simulation.Target) string {
+	switch {
+	case len(t.Symbols) > 0 && t.Sector != "":
+		return t.Sector + "+" + strings.Join(t.Symbols, ",")
+	case len(t.Symbols) > 0:
+		return strings.Join(t.Symbols, ",")
+	case t.Sector != "":
+		return t.Sector
+	default:
+		return "market"
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/1batu/market-ai/internal/models"
+)
+
+func TestBuildScenarioReport(t *testing.T) {
+	start := time.Date(2025, 3, 20, 14, 0, 0, 0, time.UTC)
+	alpha, beta := uuid.New(), uuid.New()
+	newsID := uuid.New()
+	run := models.ScenarioRun{
+		StartedAt: start,
+		Events: []models.ScenarioEventLog{
+			{Index: 0, Type: "news", NewsID: &newsID, AppliedAt: start},
+			{Index: 1, Type: "shock", AppliedAt: start.Add(time.Minute)},
+			{Index: 2, Type: "regime", End: true, AppliedAt: start.Add(90 * time.Second)},
+		},
+		StartEquity: map[uuid.UUID]float64{alpha: 100000, beta: 100000},
+		EndEquity:   map[uuid.UUID]float64{alpha: 95000, beta: 101000},
+	}
+	agents := map[uuid.UUID]string{alpha: "Alpha", beta: "Beta"}
+	decisions := []scenarioDecision{
+		{id: uuid.New(), agentID: alpha, action: "SELL", symbol: "AKBNK", confidence: 80, newsIDs: []uuid.UUID{newsID}, at: start.Add(20 * time.Second)},
+		{id: uuid.New(), agentID: alpha, action: "HOLD", confidence: 60, at: start.Add(40 * time.Second)},
+		{id: uuid.New(), agentID: beta, action: "BUY", symbol: "GARAN", confidence: 70, at: start.Add(2 * time.Minute)},
+	}
+	trades := []scenarioTrade{{agentID: alpha, tradeType: "SELL", amount: 5000}, {agentID: beta, tradeType: "BUY", amount: 2000}}
+
+	report := buildScenarioReport(run, agents, decisions, trades)
+	if len(report.Agents) != 2 || report.Agents[0].AgentName != "Alpha" {
+		t.Fatalf("agents = %+v", report.Agents)
+	}
+
+	a := report.Agents[0]
+	if a.Decisions != 2 || a.Sells != 1 || a.Holds != 1 || a.AvgConfidence != 70 || a.SoldValue != 5000 || a.ReturnPercent != -5 {
+		t.Errorf("alpha summary = %+v", a)
+	}
+	if len(a.Reactions) != 2 {
+		t.Fatalf("reactions should skip regime ends: %+v", a.Reactions)
+	}
+	r := a.Reactions[0]
+	if r.Decision != "SELL" || r.DelaySeconds != 20 || !r.SawNews {
+		t.Errorf("alpha reaction to news = %+v", r)
+	}
+	if a.Reactions[1].DecisionID != nil {
+		t.Errorf("alpha made no decision after the shock, got %+v", a.Reactions[1])
+	}
+
+	b := report.Agents[1]
+	if b.Reactions[0].DecisionID != nil {
+		t.Errorf("beta's decision came after the next event and must not count for the news: %+v", b.Reactions[0])
+	}
+	if r := b.Reactions[1]; r.Decision != "BUY" || r.DelaySeconds != 60 || r.SawNews {
+		t.Errorf("beta reaction to shock = %+v", r)
+	}
+}
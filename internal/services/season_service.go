@@ -0,0 +1,537 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/performance"
+	"github.com/1batu/market-ai/internal/websocket"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrSeasonNotFound sezon bulunamadığında döner
+	ErrSeasonNotFound = errors.New("season not found")
+	// ErrInvalidSeason sezon tanımı geçersiz olduğunda döner
+	ErrInvalidSeason = errors.New("invalid season")
+)
+
+// DefaultSeasonCapital başlangıç sermayesi verilmeyen sezonlar için (ajanların varsayılan bakiyesi)
+const DefaultSeasonCapital = 100000
+
+// Sezon sonu rozetleri
+const (
+	BadgeSeasonChampion = "season_champion"
+	BadgeSeasonRunnerUp = "season_runner_up"
+	BadgeSeasonThird    = "season_third_place"
+	BadgeBestWinRate    = "season_best_win_rate"
+	BadgeMostActive     = "season_most_active"
+)
+
+// SeasonService yarışma sezonlarını yönetir: zamanı gelen sezonu başlatır
+// (katılımcıların canlı defterini arşivleyip sezon sermayesine sıfırlar), süresi dolanı
+// son sıralama ve rozetlerle arşivler. Aynı anda tek sezon çalışır; yeni
+// sezon başlarken çalışan sezon kapatılır.
+type SeasonService struct {
+	db       *pgxpool.Pool
+	hub      *websocket.Hub
+	interval time.Duration
+	boards   *BoardRanker // opsiyonel; sezonlar kurallarında bir tabloyu adlandırabilir
+}
+
+func NewSeasonService(db *pgxpool.Pool, hub *websocket.Hub, interval time.Duration) *SeasonService {
+	return &SeasonService{db: db, hub: hub, interval: interval}
+}
+
+// SetBoards sezon kurallarında lider tablosu tablolarının adlandırılmasına izin verir
+func (ss *SeasonService) SetBoards(br *BoardRanker) { ss.boards = br }
+
+// Start sezon başlangıç ve bitişlerini periyodik olarak denetler
+func (ss *SeasonService) Start(ctx context.Context) {
+	ss.tick(ctx)
+	ticker := time.NewTicker(ss.interval)
+	defer ticker.Stop()
+	log.Info().Dur("interval", ss.interval).Msg("Season service started")
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Season service stopped")
+			return
+		case <-ticker.C:
+			ss.tick(ctx)
+		}
+	}
+}
+
+// tick süresi dolan sezonu kapatır, zamanı gelen ilk planlı sezonu başlatır
+func (ss *SeasonService) tick(ctx context.Context) {
+	var expired uuid.UUID
+	err := ss.db.QueryRow(ctx, `SELECT id FROM seasons WHERE status = 'active' AND ends_at <= NOW()`).Scan(&expired)
+	switch {
+	case err == nil:
+		if err := ss.End(ctx, expired); err != nil {
+			log.Error().Err(err).Str("season_id", expired.String()).Msg("Failed to end season")
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		log.Warn().Err(err).Msg("Failed to check expired seasons")
+	}
+
+	var due uuid.UUID
+	err = ss.db.QueryRow(ctx, `
+		SELECT id FROM seasons
+		WHERE status = 'scheduled' AND starts_at <= NOW() AND ends_at > NOW()
+		ORDER BY starts_at ASC LIMIT 1`).Scan(&due)
+	switch {
+	case err == nil:
+		if err := ss.activate(ctx, due); err != nil {
+			log.Error().Err(err).Str("season_id", due.String()).Msg("Failed to start season")
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		log.Warn().Err(err).Msg("Failed to check scheduled seasons")
+	}
+}
+
+const seasonColumns = `
+	id, name, status, starts_at, ends_at, starting_capital,
+	COALESCE(agent_ids::text[], '{}'), rules, started_at, completed_at, created_at`
+
+func scanSeason(row pgx.Row) (models.Season, error) {
+	var s models.Season
+	var agentIDs []string
+	if err := row.Scan(&s.ID, &s.Name, &s.Status, &s.StartsAt, &s.EndsAt, &s.StartingCapital,
+		&agentIDs, &s.Rules, &s.StartedAt, &s.CompletedAt, &s.CreatedAt); err != nil {
+		return s, err
+	}
+	s.AgentIDs = make([]uuid.UUID, 0, len(agentIDs))
+	for _, id := range agentIDs {
+		if u, err := uuid.Parse(id); err == nil {
+			s.AgentIDs = append(s.AgentIDs, u)
+		}
+	}
+	return s, nil
+}
+
+// validateSeason isteği doğrular ve varsayılanları doldurur. Sıralama
+// overall (varsayılan tablo), roi, profit ya da isBoard'un tanıdığı bir tablo olabilir.
+func validateSeason(req *models.CreateSeasonRequest, now time.Time, isBoard func(string) bool) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSeason)
+	}
+	if req.StartsAt == nil {
+		req.StartsAt = &now
+	}
+	if req.EndsAt.IsZero() || !req.EndsAt.After(*req.StartsAt) || !req.EndsAt.After(now) {
+		return fmt.Errorf("%w: ends_at must be in the future and after starts_at", ErrInvalidSeason)
+	}
+	if req.StartingCapital == 0 {
+		req.StartingCapital = DefaultSeasonCapital
+	}
+	if req.StartingCapital < 0 {
+		return fmt.Errorf("%w: starting_capital must be positive", ErrInvalidSeason)
+	}
+	if req.Rules.MinTrades < 0 {
+		return fmt.Errorf("%w: min_trades must not be negative", ErrInvalidSeason)
+	}
+	switch req.Rules.Ranking {
+	case "":
+		req.Rules.Ranking = models.SeasonRankingOverall
+	case models.SeasonRankingOverall, models.SeasonRankingROI, models.SeasonRankingProfit:
+	default:
+		if isBoard == nil || !isBoard(req.Rules.Ranking) {
+			return fmt.Errorf("%w: ranking must be %q, %q, %q or a leaderboard board id", ErrInvalidSeason,
+				models.SeasonRankingOverall, models.SeasonRankingROI, models.SeasonRankingProfit)
+		}
+	}
+	return nil
+}
+
+// Create yeni bir sezon planlar; başlangıcı geldiyse hemen başlatır
+func (ss *SeasonService) Create(ctx context.Context, req models.CreateSeasonRequest) (*models.Season, error) {
+	var isBoard func(string) bool
+	if ss.boards != nil {
+		isBoard = func(id string) bool {
+			_, ok := ss.boards.Board(id)
+			return ok
+		}
+	}
+	if err := validateSeason(&req, time.Now(), isBoard); err != nil {
+		return nil, err
+	}
+	agentIDs := make([]string, 0, len(req.AgentIDs))
+	for _, id := range req.AgentIDs {
+		agentIDs = append(agentIDs, id.String())
+	}
+
+	season, err := scanSeason(ss.db.QueryRow(ctx, `
+		INSERT INTO seasons (name, starts_at, ends_at, starting_capital, agent_ids, rules)
+		VALUES ($1, $2, $3, $4, $5::uuid[], $6)
+		RETURNING`+seasonColumns,
+		req.Name, req.StartsAt.UTC(), req.EndsAt.UTC(), req.StartingCapital, agentIDs, req.Rules))
+	if err != nil {
+		return nil, fmt.Errorf("create season: %w", err)
+	}
+	if !req.StartsAt.After(time.Now()) {
+		if err := ss.activate(ctx, season.ID); err != nil {
+			return nil, err
+		}
+		return ss.Get(ctx, season.ID)
+	}
+	return &season, nil
+}
+
+// activate çalışan sezonu kapatır, katılımcıların canlı defterini arşivleyip
+// sıfırlar (açık pozisyonlar silinir, bakiye sezon sermayesine çekilir) ve
+// sezonu başlatır. Hepsi tek işlemde yapılır: başlatma başarısız olursa
+// çalışan sezon da açık kalır.
+func (ss *SeasonService) activate(ctx context.Context, id uuid.UUID) error {
+	tx, err := ss.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var capital float64
+	var agentIDs []string
+	if err := tx.QueryRow(ctx, `
+		SELECT starting_capital, COALESCE(agent_ids::text[], '{}')
+		FROM seasons WHERE id = $1 AND status = 'scheduled' FOR UPDATE`, id).Scan(&capital, &agentIDs); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSeasonNotFound
+		}
+		return err
+	}
+
+	var running uuid.UUID
+	ended := false
+	err = tx.QueryRow(ctx, `SELECT id FROM seasons WHERE status = 'active' FOR UPDATE`).Scan(&running)
+	switch {
+	case err == nil:
+		if err := ss.endTx(ctx, tx, running); err != nil {
+			return err
+		}
+		ended = true
+	case !errors.Is(err, pgx.ErrNoRows):
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id FROM agents
+		WHERE status = 'active' AND (cardinality($1::uuid[]) = 0 OR id = ANY($1::uuid[]))`, agentIDs)
+	if err != nil {
+		return err
+	}
+	var participants []uuid.UUID
+	for rows.Next() {
+		var a uuid.UUID
+		if err := rows.Scan(&a); err != nil {
+			rows.Close()
+			return err
+		}
+		participants = append(participants, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO season_ledger_archives (season_id, agent_id, initial_balance, current_balance, ledger_reset_at, positions)
+		SELECT $1, a.id, a.initial_balance, a.current_balance, a.ledger_reset_at,
+		       COALESCE((SELECT jsonb_agg(jsonb_build_object(
+		                     'symbol', p.stock_symbol, 'quantity', p.quantity,
+		                     'avg_buy_price', p.avg_buy_price, 'total_invested', p.total_invested)
+		                     ORDER BY p.stock_symbol)
+		                 FROM portfolio p WHERE p.agent_id = a.id AND p.quantity > 0), '[]'::jsonb)
+		FROM agents a WHERE a.id = ANY($2)`, id, participants); err != nil {
+		return fmt.Errorf("archive ledgers: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM portfolio WHERE agent_id = ANY($1)`, participants); err != nil {
+		return fmt.Errorf("reset portfolios: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE agents SET initial_balance = $2, current_balance = $2, ledger_reset_at = NOW()
+		WHERE id = ANY($1)`, participants, capital); err != nil {
+		return fmt.Errorf("reset balances: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO season_participants (season_id, agent_id, starting_capital, total_value)
+		SELECT $1, unnest($2::uuid[]), $3, $3`, id, participants, capital); err != nil {
+		return fmt.Errorf("add participants: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `SELECT update_agent_metrics(a) FROM unnest($1::uuid[]) AS a`, participants); err != nil {
+		return fmt.Errorf("reset metrics: %w", err)
+	}
+	// Kazanan/kaybeden işlemler Go'da hesaplanır; sonraki lider tablosu güncellemesine kadar sıfır kalsın
+	if _, err := tx.Exec(ctx, `
+		UPDATE agent_metrics SET winning_trades = 0, losing_trades = 0, win_rate = 0
+		WHERE agent_id = ANY($1)`, participants); err != nil {
+		return fmt.Errorf("reset metrics: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE seasons SET status = 'active', started_at = NOW() WHERE id = $1`, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if ended {
+		ss.announceEnd(ctx, running)
+	}
+	season, err := ss.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	log.Info().Str("season", season.Name).Int("agents", len(participants)).Float64("capital", capital).Msg("Season started")
+	ss.hub.BroadcastMessage("season_started", season)
+	return nil
+}
+
+// End çalışan bir sezonu son sıralamayla arşivler ve rozetleri dağıtır
+func (ss *SeasonService) End(ctx context.Context, id uuid.UUID) error {
+	tx, err := ss.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := ss.endTx(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	ss.announceEnd(ctx, id)
+	return nil
+}
+
+// endTx sezonu verilen işlem içinde kapatır: son sıralamayı ve rozetleri yazar
+func (ss *SeasonService) endTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {
+	var status string
+	if err := tx.QueryRow(ctx, `SELECT status FROM seasons WHERE id = $1 FOR UPDATE`, id).Scan(&status); err != nil || status != models.SeasonActive {
+		if err == nil || errors.Is(err, pgx.ErrNoRows) {
+			return ErrSeasonNotFound
+		}
+		return err
+	}
+	if err := saveSeasonTrades(ctx, ss.db, tx, id); err != nil {
+		return fmt.Errorf("final standings: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `SELECT update_season_standings($1)`, id); err != nil {
+		return fmt.Errorf("final standings: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT agent_id, rank, eligible, win_rate, winning_trades + losing_trades, total_trades
+		FROM season_participants WHERE season_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	var standings []seasonResult
+	for rows.Next() {
+		var r seasonResult
+		if err := rows.Scan(&r.agentID, &r.rank, &r.eligible, &r.winRate, &r.closed, &r.trades); err != nil {
+			rows.Close()
+			return err
+		}
+		standings = append(standings, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for agentID, badges := range seasonBadges(standings) {
+		if _, err := tx.Exec(ctx, `
+			UPDATE season_participants SET badges = $3 WHERE season_id = $1 AND agent_id = $2`,
+			id, agentID, badges); err != nil {
+			return fmt.Errorf("award badges: %w", err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `UPDATE seasons SET status = 'completed', completed_at = NOW() WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// announceEnd biten sezonun arşivlenmiş sıralamasını yayınlar
+func (ss *SeasonService) announceEnd(ctx context.Context, id uuid.UUID) {
+	detail, err := ss.Detail(ctx, id)
+	if err != nil {
+		log.Warn().Err(err).Str("season_id", id.String()).Msg("Failed to load ended season")
+		return
+	}
+	log.Info().Str("season", detail.Season.Name).Int("agents", len(detail.Standings)).Msg("Season completed")
+	ss.hub.BroadcastMessage("season_ended", detail)
+}
+
+// saveSeasonTrades katılımcıların sezondaki kazanan/kaybeden işlemlerini ve
+// kazanma oranını season_participants'a yazar. agent_metrics ile aynı tanım
+// kullanılır (ortalama maliyetle, komisyon dahil kapanmış işlemler); sezon başında
+// defter sıfırlandığından liveFills yalnızca sezon işlemlerini döndürür.
+// update_season_standings sıralamadan önce çağrılır.
+func saveSeasonTrades(ctx context.Context, db *pgxpool.Pool, w execer, seasonID uuid.UUID) error {
+	rows, err := db.Query(ctx, `SELECT agent_id FROM season_participants WHERE season_id = $1`, seasonID)
+	if err != nil {
+		return err
+	}
+	var agents []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		agents = append(agents, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, agentID := range agents {
+		fills, err := liveFills(ctx, db, agentID)
+		if err != nil {
+			return err
+		}
+		m := performance.Compute(0, nil, performance.ClosedTrades(fills))
+		if _, err := w.Exec(ctx, `
+			UPDATE season_participants SET winning_trades = $3, losing_trades = $4, win_rate = $5
+			WHERE season_id = $1 AND agent_id = $2`,
+			seasonID, agentID, m.WinningTrades, m.LosingTrades, m.WinRate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seasonResult rozet dağıtımı için bir katılımcının son durumu
+type seasonResult struct {
+	agentID  uuid.UUID
+	rank     *int
+	eligible bool
+	winRate  float64
+	closed   int // kapanmış (satış) işlem sayısı
+	trades   int
+}
+
+// seasonBadges sezon sonu rozetlerini dağıtır. Yalnızca en az işlem kuralını
+// karşılayanlar rozet alır; eşitlikte rozet paylaşılır.
+func seasonBadges(results []seasonResult) map[uuid.UUID][]string {
+	out := map[uuid.UUID][]string{}
+	bestWinRate, mostTrades := -1.0, 0
+	for _, r := range results {
+		if !r.eligible {
+			continue
+		}
+		if r.closed > 0 && r.winRate > bestWinRate {
+			bestWinRate = r.winRate
+		}
+		if r.trades > mostTrades {
+			mostTrades = r.trades
+		}
+	}
+	podium := map[int]string{1: BadgeSeasonChampion, 2: BadgeSeasonRunnerUp, 3: BadgeSeasonThird}
+	for _, r := range results {
+		if !r.eligible {
+			continue
+		}
+		var badges []string
+		if r.rank != nil {
+			if b, ok := podium[*r.rank]; ok {
+				badges = append(badges, b)
+			}
+		}
+		if r.closed > 0 && r.winRate == bestWinRate {
+			badges = append(badges, BadgeBestWinRate)
+		}
+		if mostTrades > 0 && r.trades == mostTrades {
+			badges = append(badges, BadgeMostActive)
+		}
+		if len(badges) > 0 {
+			out[r.agentID] = badges
+		}
+	}
+	return out
+}
+
+// List tüm sezonları en yeniden eskiye döndürür
+func (ss *SeasonService) List(ctx context.Context) ([]models.Season, error) {
+	rows, err := ss.db.Query(ctx, `SELECT`+seasonColumns+` FROM seasons ORDER BY starts_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Season{}
+	for rows.Next() {
+		s, err := scanSeason(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// Get tek bir sezonu döndürür
+func (ss *SeasonService) Get(ctx context.Context, id uuid.UUID) (*models.Season, error) {
+	s, err := scanSeason(ss.db.QueryRow(ctx, `SELECT`+seasonColumns+` FROM seasons WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSeasonNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Current çalışan sezonu döndürür; yoksa ErrSeasonNotFound
+func (ss *SeasonService) Current(ctx context.Context) (*models.SeasonDetail, error) {
+	var id uuid.UUID
+	if err := ss.db.QueryRow(ctx, `SELECT id FROM seasons WHERE status = 'active'`).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSeasonNotFound
+		}
+		return nil, err
+	}
+	return ss.Detail(ctx, id)
+}
+
+// Detail sezonu sıralamasıyla döndürür. Çalışan sezonun sıralaması son
+// lider tablosu güncellemesindeki, biten sezonunki arşivlenmiş hâlidir.
+func (ss *SeasonService) Detail(ctx context.Context, id uuid.UUID) (*models.SeasonDetail, error) {
+	season, err := ss.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ss.db.Query(ctx, `
+		SELECT sp.rank, sp.agent_id, a.name, a.model, sp.starting_capital, sp.total_value,
+		       sp.profit_loss, sp.roi, sp.win_rate, sp.total_trades, sp.winning_trades, sp.losing_trades,
+		       sp.score, sp.eligible, COALESCE(sp.badges, '{}'), sp.updated_at
+		FROM season_participants sp
+		JOIN agents a ON a.id = sp.agent_id
+		WHERE sp.season_id = $1
+		ORDER BY sp.rank ASC NULLS LAST, a.name ASC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	detail := &models.SeasonDetail{Season: *season, Standings: []models.SeasonStanding{}}
+	for rows.Next() {
+		var s models.SeasonStanding
+		if err := rows.Scan(&s.Rank, &s.AgentID, &s.AgentName, &s.Model, &s.StartingCapital, &s.TotalValue,
+			&s.ProfitLoss, &s.ROI, &s.WinRate, &s.TotalTrades, &s.WinningTrades, &s.LosingTrades,
+			&s.Score, &s.Eligible, &s.Badges, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		detail.Standings = append(detail.Standings, s)
+	}
+	return detail, rows.Err()
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestValidateSeasonDefaults(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	req := models.CreateSeasonRequest{Name: "  Spring  ", EndsAt: now.AddDate(0, 1, 0)}
+	if err := validateSeason(&req, now, nil); err != nil {
+		t.Fatal(err)
+	}
+	if req.Name != "Spring" || !req.StartsAt.Equal(now) || req.StartingCapital != DefaultSeasonCapital || req.Rules.Ranking != models.SeasonRankingOverall {
+		t.Errorf("defaults = %+v", req)
+	}
+
+	bad := []models.CreateSeasonRequest{
+		{EndsAt: now.AddDate(0, 1, 0)},
+		{Name: "past", EndsAt: now.Add(-time.Hour)},
+		{Name: "negative", EndsAt: now.AddDate(0, 1, 0), StartingCapital: -1},
+		{Name: "ranking", EndsAt: now.AddDate(0, 1, 0), Rules: models.SeasonRules{Ranking: "elo"}},
+	}
+	for _, r := range bad {
+		if err := validateSeason(&r, now, nil); !errors.Is(err, ErrInvalidSeason) {
+			t.Errorf("%q: err = %v, want ErrInvalidSeason", r.Name, err)
+		}
+	}
+}
+
+func TestValidateSeasonBoardRanking(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	isBoard := func(id string) bool { return id == "risk_adjusted" }
+	req := models.CreateSeasonRequest{Name: "Risk", EndsAt: now.AddDate(0, 1, 0), Rules: models.SeasonRules{Ranking: "risk_adjusted"}}
+	if err := validateSeason(&req, now, isBoard); err != nil {
+		t.Fatal(err)
+	}
+	req.Rules.Ranking = "calibration"
+	if err := validateSeason(&req, now, isBoard); !errors.Is(err, ErrInvalidSeason) {
+		t.Errorf("unknown board: err = %v, want ErrInvalidSeason", err)
+	}
+}
+
+func TestSeasonBadges(t *testing.T) {
+	rank := func(r int) *int { return &r }
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	badges := seasonBadges([]seasonResult{
+		{agentID: a, rank: rank(1), eligible: true, winRate: 50, closed: 4, trades: 10},
+		{agentID: b, rank: rank(2), eligible: true, winRate: 75, closed: 4, trades: 12},
+		{agentID: c, rank: rank(3), eligible: true, winRate: 0, closed: 0, trades: 12},
+		// below min_trades: no badges even with the best win rate
+		{agentID: d, rank: rank(4), eligible: false, winRate: 100, closed: 1, trades: 1},
+	})
+
+	want := map[uuid.UUID][]string{
+		a: {BadgeSeasonChampion},
+		b: {BadgeSeasonRunnerUp, BadgeBestWinRate, BadgeMostActive},
+		c: {BadgeSeasonThird, BadgeMostActive},
+	}
+	if len(badges) != len(want) {
+		t.Fatalf("badges = %v", badges)
+	}
+	for id, w := range want {
+		if !slices.Equal(badges[id], w) {
+			t.Errorf("badges[%v] = %v, want %v", id, badges[id], w)
+		}
+	}
+}
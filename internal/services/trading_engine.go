@@ -4,18 +4,66 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type TradingEngine struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	ledger Ledger
+	flow   *simulation.OrderFlow // executed live volume, fed back into simulated prices
+	badges *BadgeEngine          // optional, evaluates trade badges
 }
 
 func NewTradingEngine(db *pgxpool.Pool) *TradingEngine {
-	return &TradingEngine{db: db}
+	return &TradingEngine{db: db, ledger: LiveLedger}
+}
+
+// On returns an engine that books trades into the given ledger (live or shadow)
+func (te *TradingEngine) On(l Ledger) *TradingEngine {
+	return &TradingEngine{db: te.db, ledger: l, flow: te.flow, badges: te.badges}
+}
+
+// SetOrderFlow records the net volume of every committed live trade so the
+// market simulator can move prices by it; shadow trades never reach the market
+func (te *TradingEngine) SetOrderFlow(f *simulation.OrderFlow) { te.flow = f }
+
+// SetBadgeEngine evaluates the trade badge rules after every committed live trade
+func (te *TradingEngine) SetBadgeEngine(be *BadgeEngine) { te.badges = be }
+
+// awardBadges queues the trading agents for trade badge evaluation, which runs
+// in the badge engine's own goroutine; shadow trades earn none
+func (te *TradingEngine) awardBadges(trades ...*models.Trade) {
+	if te.badges == nil || te.ledger.IsShadow() {
+		return
+	}
+	for _, t := range trades {
+		if t != nil {
+			te.badges.Queue(t.AgentID)
+		}
+	}
+}
+
+// recordFlow adds committed trades to the order flow
+func (te *TradingEngine) recordFlow(trades ...*models.Trade) {
+	if te.flow == nil || te.ledger.IsShadow() {
+		return
+	}
+	for _, t := range trades {
+		if t == nil {
+			continue
+		}
+		qty := float64(t.Quantity)
+		if t.TradeType == "SELL" {
+			qty = -qty
+		}
+		te.flow.Record(t.StockSymbol, qty)
+	}
 }
 
 const CommissionRate = 0.001
@@ -32,81 +80,84 @@ func (te *TradingEngine) ExecuteTrade(ctx context.Context, req models.TradeReque
 		}
 	}()
 
+	trade, err := te.executeTradeTx(ctx, tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	te.recordFlow(trade)
+	te.awardBadges(trade)
+
+	return trade, nil
+}
+
+// executeTradeTx applies a single trade to the engine's ledger inside an open transaction
+func (te *TradingEngine) executeTradeTx(ctx context.Context, tx pgx.Tx, req models.TradeRequest) (*models.Trade, error) {
+	l := te.ledger
 	var stockPrice float64
-	err = tx.QueryRow(ctx, "SELECT current_price FROM stocks WHERE symbol = $1", req.StockSymbol).Scan(&stockPrice)
+	err := tx.QueryRow(ctx, "SELECT current_price FROM stocks WHERE symbol = $1", req.StockSymbol).Scan(&stockPrice)
 	if err != nil {
 		return nil, fmt.Errorf("stock not found: %w", err)
 	}
 
+	if err := l.EnsureAccount(ctx, tx, req.AgentID); err != nil {
+		return nil, fmt.Errorf("failed to open %s account: %w", l.Name, err)
+	}
+
 	var agentBalance float64
-	err = tx.QueryRow(ctx, "SELECT current_balance FROM agents WHERE id = $1", req.AgentID).Scan(&agentBalance)
+	err = tx.QueryRow(ctx, l.balanceQuery(), req.AgentID).Scan(&agentBalance)
 	if err != nil {
 		return nil, fmt.Errorf("agent not found: %w", err)
 	}
 
-	totalAmount := float64(req.Quantity) * stockPrice
-	commission := totalAmount * CommissionRate
+	var currentQuantity int
+	if req.TradeType == "SELL" {
+		// no row means no position; FillOrder reports it as insufficient stocks
+		_ = tx.QueryRow(ctx,
+			fmt.Sprintf("SELECT quantity FROM %s WHERE agent_id = $1 AND stock_symbol = $2", l.portfolio),
+			req.AgentID, req.StockSymbol).Scan(&currentQuantity)
+	}
 
-	if req.TradeType == "BUY" {
-		if agentBalance < totalAmount+commission {
-			return nil, errors.New("insufficient balance")
-		}
+	fill, err := FillOrder(agentBalance, currentQuantity, req, stockPrice)
+	if err != nil {
+		return nil, err
+	}
+	totalAmount, commission := fill.Amount, fill.Commission
 
-		_, err = tx.Exec(ctx,
-			"UPDATE agents SET current_balance = current_balance - $1 WHERE id = $2",
-			totalAmount+commission, req.AgentID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to update balance: %w", err)
-		}
+	_, err = tx.Exec(ctx, l.balanceUpdate(), fill.CashDelta, req.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
 
-		_, err = tx.Exec(ctx, `
-			INSERT INTO portfolio (agent_id, stock_symbol, quantity, avg_buy_price, total_invested)
+	if req.TradeType == "BUY" {
+		_, err = tx.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO %[1]s (agent_id, stock_symbol, quantity, avg_buy_price, total_invested)
 			VALUES ($1, $2, $3, $4, $5)
 			ON CONFLICT (agent_id, stock_symbol)
 			DO UPDATE SET
-				quantity = portfolio.quantity + EXCLUDED.quantity,
-				avg_buy_price = (portfolio.total_invested + EXCLUDED.total_invested) / (portfolio.quantity + EXCLUDED.quantity),
-				total_invested = portfolio.total_invested + EXCLUDED.total_invested,
+				quantity = %[1]s.quantity + EXCLUDED.quantity,
+				avg_buy_price = (%[1]s.total_invested + EXCLUDED.total_invested) / (%[1]s.quantity + EXCLUDED.quantity),
+				total_invested = %[1]s.total_invested + EXCLUDED.total_invested,
 				updated_at = NOW()
-		`, req.AgentID, req.StockSymbol, req.Quantity, stockPrice, totalAmount)
-		if err != nil {
-			return nil, fmt.Errorf("failed to update portfolio: %w", err)
-		}
-	} else if req.TradeType == "SELL" {
-		var currentQuantity int
-		err = tx.QueryRow(ctx,
-			"SELECT quantity FROM portfolio WHERE agent_id = $1 AND stock_symbol = $2",
-			req.AgentID, req.StockSymbol).Scan(&currentQuantity)
-		if err != nil || currentQuantity < req.Quantity {
-			return nil, errors.New("insufficient stocks")
-		}
-
+		`, l.portfolio), req.AgentID, req.StockSymbol, req.Quantity, stockPrice, totalAmount)
+	} else if currentQuantity == req.Quantity {
 		_, err = tx.Exec(ctx,
-			"UPDATE agents SET current_balance = current_balance + $1 WHERE id = $2",
-			totalAmount-commission, req.AgentID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to update balance: %w", err)
-		}
-
-		if currentQuantity == req.Quantity {
-			_, err = tx.Exec(ctx,
-				"DELETE FROM portfolio WHERE agent_id = $1 AND stock_symbol = $2",
-				req.AgentID, req.StockSymbol)
-		} else {
-			_, err = tx.Exec(ctx, `
-				UPDATE portfolio
-				SET quantity = quantity - $1,
-				    total_invested = total_invested * (quantity - $1) / quantity,
-				    updated_at = NOW()
-				WHERE agent_id = $2 AND stock_symbol = $3
-			`, req.Quantity, req.AgentID, req.StockSymbol)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to update portfolio: %w", err)
-		}
+			fmt.Sprintf("DELETE FROM %s WHERE agent_id = $1 AND stock_symbol = $2", l.portfolio),
+			req.AgentID, req.StockSymbol)
 	} else {
-		// Invalid trade type
-		return nil, fmt.Errorf("invalid trade type: %s", req.TradeType)
+		_, err = tx.Exec(ctx, fmt.Sprintf(`
+			UPDATE %s
+			SET quantity = quantity - $1,
+			    total_invested = total_invested * (quantity - $1) / quantity,
+			    updated_at = NOW()
+			WHERE agent_id = $2 AND stock_symbol = $3
+		`, l.portfolio), req.Quantity, req.AgentID, req.StockSymbol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update portfolio: %w", err)
 	}
 
 	trade := &models.Trade{
@@ -119,25 +170,285 @@ func (te *TradingEngine) ExecuteTrade(ctx context.Context, req models.TradeReque
 		TotalAmount: totalAmount,
 		Commission:  commission,
 		Reasoning:   req.Reasoning,
+		DecisionID:  req.DecisionID,
 	}
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO trades (id, agent_id, stock_symbol, trade_type, quantity, price, total_amount, commission, reasoning)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, trade.ID, trade.AgentID, trade.StockSymbol, trade.TradeType, trade.Quantity,
-		trade.Price, trade.TotalAmount, trade.Commission, trade.Reasoning)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (id, agent_id, stock_symbol, trade_type, quantity, price, total_amount, commission, reasoning, decision_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, l.trades), trade.ID, trade.AgentID, trade.StockSymbol, trade.TradeType, trade.Quantity,
+		trade.Price, trade.TotalAmount, trade.Commission, trade.Reasoning, trade.DecisionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert trade: %w", err)
 	}
 
-	_, err = tx.Exec(ctx, "SELECT update_agent_metrics($1)", req.AgentID)
+	_, err = tx.Exec(ctx, fmt.Sprintf("SELECT %s($1)", l.metricsFn), req.AgentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update metrics: %w", err)
 	}
 
+	return trade, nil
+}
+
+// Fill is the effect of one order filled in full at a single price
+type Fill struct {
+	Amount     float64 // quantity * price
+	Commission float64
+	CashDelta  float64 // change in the agent's cash balance
+}
+
+// FillOrder applies the engine's fill rules to an order for an agent holding
+// cash and held lots of the stock: the order fills in full at price, pays
+// CommissionRate on the amount, and is rejected when a buy cannot cover amount
+// plus commission or a sell exceeds the position. It touches no database so
+// the backtester fills orders exactly like the live engine.
+func FillOrder(cash float64, held int, req models.TradeRequest, price float64) (Fill, error) {
+	f := Fill{Amount: float64(req.Quantity) * price}
+	f.Commission = f.Amount * CommissionRate
+
+	switch req.TradeType {
+	case "BUY":
+		if cash < f.Amount+f.Commission {
+			return Fill{}, errors.New("insufficient balance")
+		}
+		f.CashDelta = -(f.Amount + f.Commission)
+	case "SELL":
+		if held < req.Quantity {
+			return Fill{}, errors.New("insufficient stocks")
+		}
+		f.CashDelta = f.Amount - f.Commission
+	default:
+		return Fill{}, fmt.Errorf("invalid trade type: %s", req.TradeType)
+	}
+	return f, nil
+}
+
+// ExecuteOrders executes the orders of a multi-order decision, sells before buys.
+// In atomic mode all orders share one transaction and any failure rolls back
+// every order; in ordered mode each order commits on its own and failures are
+// reported per order. An error is returned only when nothing was executed.
+func (te *TradingEngine) ExecuteOrders(ctx context.Context, orders []models.TradeRequest, mode string) ([]models.OrderResult, error) {
+	if len(orders) == 0 {
+		return nil, errors.New("no orders")
+	}
+	orders = SellsFirst(orders)
+	results := make([]models.OrderResult, len(orders))
+	for i, o := range orders {
+		results[i].Order = o
+	}
+
+	if mode == models.ExecutionOrdered {
+		executed := 0
+		for i, o := range orders {
+			trade, err := te.ExecuteTrade(ctx, o)
+			if err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].Trade = trade
+			executed++
+		}
+		if executed == 0 {
+			return results, errors.New("no orders executed")
+		}
+		return results, nil
+	}
+
+	tx, err := te.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	for i, o := range orders {
+		trade, err := te.executeTradeTx(ctx, tx, o)
+		if err != nil {
+			results[i].Error = err.Error()
+			for j := range results[:i] {
+				results[j].Trade = nil
+				results[j].Error = "rolled back"
+			}
+			for j := i + 1; j < len(results); j++ {
+				results[j].Error = "skipped"
+			}
+			return results, fmt.Errorf("order %d (%s %d %s): %w", i+1, o.TradeType, o.Quantity, o.StockSymbol, err)
+		}
+		results[i].Trade = trade
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	trades := make([]*models.Trade, 0, len(results))
+	for _, r := range results {
+		te.recordFlow(r.Trade)
+		trades = append(trades, r.Trade)
+	}
+	te.awardBadges(trades...)
+	return results, nil
+}
+
+// PlanRebalance converts target weights (percent of total equity) into the
+// orders that move the agent's portfolio toward them at current prices.
+// maxBuyAmount caps each buy (0 = no cap); held stocks missing from targets are sold.
+func (te *TradingEngine) PlanRebalance(ctx context.Context, agentID uuid.UUID, targets map[string]float64, maxBuyAmount float64) ([]models.TradeRequest, error) {
+	if err := te.ledger.EnsureAccount(ctx, te.db, agentID); err != nil {
+		return nil, fmt.Errorf("failed to open %s account: %w", te.ledger.Name, err)
+	}
+	var balance float64
+	if err := te.db.QueryRow(ctx, te.ledger.balanceQuery(), agentID).Scan(&balance); err != nil {
+		return nil, fmt.Errorf("agent not found: %w", err)
+	}
 
-	return trade, nil
+	holdings := make(map[string]int)
+	prices := make(map[string]float64)
+	rows, err := te.db.Query(ctx, fmt.Sprintf(`
+		SELECT p.stock_symbol, p.quantity, s.current_price
+		FROM %s p
+		JOIN stocks s ON s.symbol = p.stock_symbol
+		WHERE p.agent_id = $1
+	`, te.ledger.portfolio), agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load portfolio: %w", err)
+	}
+	for rows.Next() {
+		var sym string
+		var qty int
+		var price float64
+		if err := rows.Scan(&sym, &qty, &price); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan portfolio: %w", err)
+		}
+		holdings[sym] = qty
+		prices[sym] = price
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load portfolio: %w", err)
+	}
+
+	for sym := range targets {
+		if _, ok := prices[sym]; ok {
+			continue
+		}
+		var price float64
+		if err := te.db.QueryRow(ctx, "SELECT current_price FROM stocks WHERE symbol = $1", sym).Scan(&price); err != nil {
+			return nil, fmt.Errorf("stock not found: %s", sym)
+		}
+		prices[sym] = price
+	}
+
+	orders, err := planRebalance(balance, holdings, prices, targets, maxBuyAmount)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].AgentID = agentID
+	}
+	return orders, nil
+}
+
+// RebalanceOrders is PlanRebalance for a portfolio held in memory: it plans
+// the orders from the given cash, holdings and prices without a database
+func RebalanceOrders(balance float64, holdings map[string]int, prices, targets map[string]float64, maxBuyAmount float64) ([]models.TradeRequest, error) {
+	return planRebalance(balance, holdings, prices, targets, maxBuyAmount)
+}
+
+// planRebalance is the pure part of PlanRebalance
+func planRebalance(balance float64, holdings map[string]int, prices, targets map[string]float64, maxBuyAmount float64) ([]models.TradeRequest, error) {
+	var totalWeight float64
+	for sym, w := range targets {
+		if w < 0 {
+			return nil, fmt.Errorf("negative target weight for %s", sym)
+		}
+		totalWeight += w
+	}
+	if totalWeight > 100.5 {
+		return nil, fmt.Errorf("target weights sum to %.1f%% (max 100%%)", totalWeight)
+	}
+
+	equity := balance
+	for sym, qty := range holdings {
+		equity += float64(qty) * prices[sym]
+	}
+
+	symbols := make([]string, 0, len(holdings)+len(targets))
+	for sym := range holdings {
+		symbols = append(symbols, sym)
+	}
+	for sym := range targets {
+		if _, ok := holdings[sym]; !ok {
+			symbols = append(symbols, sym)
+		}
+	}
+	sort.Strings(symbols)
+
+	var sells, buys []models.TradeRequest
+	cash := balance
+	type deficit struct {
+		symbol string
+		amount float64
+	}
+	var deficits []deficit
+	for _, sym := range symbols {
+		price := prices[sym]
+		if price <= 0 {
+			continue
+		}
+		current := float64(holdings[sym]) * price
+		target := equity * targets[sym] / 100
+		switch {
+		case target < current:
+			qty := int((current - target) / price)
+			if targets[sym] == 0 {
+				qty = holdings[sym]
+			}
+			if qty > 0 {
+				sells = append(sells, models.TradeRequest{StockSymbol: sym, TradeType: models.ActionSell, Quantity: qty})
+				cash += float64(qty) * price * (1 - CommissionRate)
+			}
+		case target > current:
+			deficits = append(deficits, deficit{sym, target - current})
+		}
+	}
+
+	// largest gaps first so limited cash goes where the portfolio is furthest off
+	sort.SliceStable(deficits, func(i, j int) bool { return deficits[i].amount > deficits[j].amount })
+	for _, d := range deficits {
+		price := prices[d.symbol]
+		amount := d.amount
+		if maxBuyAmount > 0 && amount > maxBuyAmount {
+			amount = maxBuyAmount
+		}
+		qty := int(amount / price)
+		if affordable := int(cash / (price * (1 + CommissionRate))); qty > affordable {
+			qty = affordable
+		}
+		if qty <= 0 {
+			continue
+		}
+		buys = append(buys, models.TradeRequest{StockSymbol: d.symbol, TradeType: models.ActionBuy, Quantity: qty})
+		cash -= float64(qty) * price * (1 + CommissionRate)
+	}
+
+	return append(sells, buys...), nil
+}
+
+// SellsFirst returns the orders with every SELL ahead of every BUY, keeping
+// the relative order within each group
+func SellsFirst(orders []models.TradeRequest) []models.TradeRequest {
+	out := make([]models.TradeRequest, 0, len(orders))
+	for _, o := range orders {
+		if o.TradeType == models.ActionSell {
+			out = append(out, o)
+		}
+	}
+	for _, o := range orders {
+		if o.TradeType != models.ActionSell {
+			out = append(out, o)
+		}
+	}
+	return out
 }
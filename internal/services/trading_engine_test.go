@@ -0,0 +1,140 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/1batu/market-ai/internal/models"
+	"github.com/1batu/market-ai/internal/simulation"
+)
+
+func TestSellsFirst(t *testing.T) {
+	orders := []models.TradeRequest{
+		{StockSymbol: "A", TradeType: "BUY"},
+		{StockSymbol: "B", TradeType: "SELL"},
+		{StockSymbol: "C", TradeType: "BUY"},
+		{StockSymbol: "D", TradeType: "SELL"},
+	}
+	var got []string
+	for _, o := range SellsFirst(orders) {
+		got = append(got, o.StockSymbol)
+	}
+	if strings.Join(got, "") != "BDAC" {
+		t.Errorf("SellsFirst() = %v, want [B D A C]", got)
+	}
+}
+
+func TestPlanRebalance(t *testing.T) {
+	holdings := map[string]int{"THYAO": 100, "AKBNK": 50}
+	prices := map[string]float64{"THYAO": 100, "AKBNK": 40, "ASELS": 50}
+	// equity = 70000 + 10000 + 2000 = 82000
+	orders, err := planRebalance(70000, holdings, prices, map[string]float64{"THYAO": 5, "ASELS": 10}, 0)
+	if err != nil {
+		t.Fatalf("planRebalance() error = %v", err)
+	}
+	want := []models.TradeRequest{
+		{StockSymbol: "AKBNK", TradeType: "SELL", Quantity: 50}, // not in targets -> sold out
+		{StockSymbol: "THYAO", TradeType: "SELL", Quantity: 59}, // 10000 -> 4100
+		{StockSymbol: "ASELS", TradeType: "BUY", Quantity: 164}, // 0 -> 8200
+	}
+	if len(orders) != len(want) {
+		t.Fatalf("planRebalance() = %+v, want %+v", orders, want)
+	}
+	for i := range want {
+		if orders[i] != want[i] {
+			t.Errorf("order %d = %+v, want %+v", i, orders[i], want[i])
+		}
+	}
+
+	capped, _ := planRebalance(70000, holdings, prices, map[string]float64{"THYAO": 5, "ASELS": 10}, 1000)
+	if last := capped[len(capped)-1]; last.StockSymbol != "ASELS" || last.Quantity != 20 {
+		t.Errorf("capped buy = %+v, want 20 ASELS", last)
+	}
+
+	if _, err := planRebalance(1000, nil, prices, map[string]float64{"THYAO": 60, "ASELS": 50}, 0); err == nil {
+		t.Error("weights above 100% should fail")
+	}
+	if _, err := planRebalance(1000, nil, prices, map[string]float64{"THYAO": -1}, 0); err == nil {
+		t.Error("negative weight should fail")
+	}
+}
+
+func TestPlanRebalanceLimitedCash(t *testing.T) {
+	prices := map[string]float64{"THYAO": 100, "ASELS": 100}
+	orders, err := planRebalance(1000, nil, prices, map[string]float64{"THYAO": 60, "ASELS": 40}, 0)
+	if err != nil {
+		t.Fatalf("planRebalance() error = %v", err)
+	}
+	// largest gap first; after commission only 3 ASELS fit
+	if len(orders) != 2 || orders[0].StockSymbol != "THYAO" || orders[0].Quantity != 6 || orders[1].Quantity != 3 {
+		t.Errorf("planRebalance() = %+v", orders)
+	}
+}
+
+func TestSimulateOrders(t *testing.T) {
+	rm := &RiskManager{maxRiskPerTrade: 5, maxPortfolioRisk: 20}
+	holdings := map[string]int{"THYAO": 10}
+	prices := map[string]float64{"THYAO": 100, "ASELS": 50}
+
+	// the rotation is listed buy-first but validated sells-first
+	ok := []models.TradeRequest{
+		{StockSymbol: "ASELS", TradeType: "BUY", Quantity: 20},
+		{StockSymbol: "THYAO", TradeType: "SELL", Quantity: 10},
+	}
+	if err := rm.simulateOrders(20000, 1000, holdings, prices, ok); err != nil {
+		t.Errorf("simulateOrders() = %v, want nil", err)
+	}
+
+	cases := map[string][]models.TradeRequest{
+		"insufficient stocks": {{StockSymbol: "THYAO", TradeType: "SELL", Quantity: 11}},
+		"exceeds max":         {{StockSymbol: "ASELS", TradeType: "BUY", Quantity: 30}},
+		"invalid action":      {{StockSymbol: "ASELS", TradeType: "HOLD", Quantity: 1}},
+		"invalid quantity":    {{StockSymbol: "ASELS", TradeType: "BUY", Quantity: 0}},
+	}
+	for want, orders := range cases {
+		if err := rm.simulateOrders(20000, 1000, holdings, prices, orders); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("simulateOrders(%s) = %v", want, err)
+		}
+	}
+
+	// each buy fits the per-trade cap but together they breach the portfolio cap
+	var many []models.TradeRequest
+	for i := 0; i < 8; i++ {
+		many = append(many, models.TradeRequest{StockSymbol: "ASELS", TradeType: "BUY", Quantity: 10})
+	}
+	if err := rm.simulateOrders(20000, 1000, holdings, prices, many); err == nil || !strings.Contains(err.Error(), "portfolio risk") {
+		t.Errorf("simulateOrders(concentration) = %v", err)
+	}
+	if holdings["THYAO"] != 10 {
+		t.Error("simulateOrders must not modify the caller's holdings")
+	}
+}
+
+func TestAIDecisionTradeRequests(t *testing.T) {
+	d := &models.AIDecision{
+		Action:    models.ActionMulti,
+		Rationale: "rotate",
+		Orders:    []models.Order{{Action: "SELL", StockSymbol: "THYAO", Quantity: 5}, {Action: "BUY", StockSymbol: "ASELS", Quantity: 7}},
+	}
+	reqs := d.TradeRequests([16]byte{1})
+	if len(reqs) != 2 || reqs[1].TradeType != "BUY" || reqs[1].Quantity != 7 || reqs[0].Reasoning != "rotate" {
+		t.Errorf("TradeRequests() = %+v", reqs)
+	}
+	if reqs := (&models.AIDecision{Action: models.ActionHold}).TradeRequests([16]byte{1}); len(reqs) != 0 {
+		t.Errorf("HOLD TradeRequests() = %+v", reqs)
+	}
+}
+
+func TestRecordFlowSkipsShadowTrades(t *testing.T) {
+	flow := simulation.NewOrderFlow()
+	te := NewTradingEngine(nil)
+	te.SetOrderFlow(flow)
+
+	te.recordFlow(&models.Trade{StockSymbol: "THYAO", TradeType: "BUY", Quantity: 100}, nil,
+		&models.Trade{StockSymbol: "THYAO", TradeType: "SELL", Quantity: 30})
+	te.On(ShadowLedger).recordFlow(&models.Trade{StockSymbol: "THYAO", TradeType: "BUY", Quantity: 1000})
+
+	if net := flow.Drain(); net["THYAO"] != 70 {
+		t.Errorf("net THYAO flow = %v, want 70 (shadow trades excluded)", net["THYAO"])
+	}
+}
@@ -0,0 +1,45 @@
+package simulation
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells services what time it is in the market they trade
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+// Now returns time.Now()
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// VirtualClock is the market time of a historical replay. It only moves when
+// the replay applies a bar, so nothing reading it can see past the last price.
+type VirtualClock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewVirtualClock creates a clock standing at start
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+// Now returns the current market time
+func (c *VirtualClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+// Set moves the clock to t; it never moves backwards
+func (c *VirtualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
@@ -0,0 +1,131 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ErrNotPositiveDefinite is returned when a correlation matrix has no
+// Cholesky factor, e.g. inconsistent pairwise correlations
+var ErrNotPositiveDefinite = errors.New("correlation matrix is not positive definite")
+
+// DefaultSectors maps the default BIST universe to sectors. Symbols missing
+// here only share the market-wide correlation.
+var DefaultSectors = map[string]string{
+	"AKBNK": "banking",
+	"GARAN": "banking",
+	"ISCTR": "banking",
+	"YKBNK": "banking",
+	"HALKB": "banking",
+	"VAKBN": "banking",
+	"KCHOL": "holding",
+	"SAHOL": "holding",
+	"THYAO": "transport",
+	"PGSUS": "transport",
+	"ASELS": "defense",
+	"BIMAS": "retail",
+	"MGROS": "retail",
+	"SISE":  "industrial",
+	"EREGL": "industrial",
+	"TUPRS": "energy",
+}
+
+// SectorCorrelation builds the correlation matrix of symbols: sectorRho for
+// two symbols of the same sector, marketRho for any other pair. With
+// 0 <= marketRho <= sectorRho < 1 the matrix is always positive definite.
+func SectorCorrelation(symbols []string, sectors map[string]string, sectorRho, marketRho float64) [][]float64 {
+	n := len(symbols)
+	c := make([][]float64, n)
+	for i := range c {
+		c[i] = make([]float64, n)
+		for j := range c[i] {
+			switch {
+			case i == j:
+				c[i][j] = 1
+			case sectors[symbols[i]] != "" && sectors[symbols[i]] == sectors[symbols[j]]:
+				c[i][j] = sectorRho
+			default:
+				c[i][j] = marketRho
+			}
+		}
+	}
+	return c
+}
+
+// Cholesky returns the lower-triangular L with L*Lᵀ = c. Multiplying L with a
+// vector of independent standard normals yields normals correlated by c.
+func Cholesky(c [][]float64) ([][]float64, error) {
+	n := len(c)
+	l := make([][]float64, n)
+	for i := range l {
+		if len(c[i]) != n {
+			return nil, fmt.Errorf("correlation matrix row %d has %d columns, want %d", i, len(c[i]), n)
+		}
+		l[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := c[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, ErrNotPositiveDefinite
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, nil
+}
+
+// correlate returns l*eps
+func correlate(l [][]float64, eps []float64) []float64 {
+	z := make([]float64, len(eps))
+	for i := range l {
+		for k := 0; k <= i; k++ {
+			z[i] += l[i][k] * eps[k]
+		}
+	}
+	return z
+}
+
+// ParseSectors parses "banking=AKBNK|GARAN;holding=KCHOL|SAHOL" into a
+// symbol -> sector map
+func ParseSectors(spec string) (map[string]string, error) {
+	sectors := map[string]string{}
+	for _, group := range strings.Split(spec, ";") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		name, list, ok := strings.Cut(group, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid sector group %q (want sector=SYM1|SYM2)", group)
+		}
+		for _, s := range strings.Split(list, "|") {
+			if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+				sectors[s] = name
+			}
+		}
+	}
+	return sectors, nil
+}
+
+// sortedKeys returns the symbols of prices in a stable order, so the same
+// seed draws the same shock for the same symbol on every run
+func sortedKeys(prices map[string]float64) []string {
+	keys := make([]string, 0, len(prices))
+	for k := range prices {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
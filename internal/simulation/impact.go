@@ -0,0 +1,85 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OrderFlow accumulates the net quantity traded per symbol between two market
+// ticks: buys are positive, sells negative. It is safe for concurrent use.
+type OrderFlow struct {
+	mu  sync.Mutex
+	net map[string]float64
+}
+
+// NewOrderFlow creates an empty order flow
+func NewOrderFlow() *OrderFlow {
+	return &OrderFlow{net: map[string]float64{}}
+}
+
+// Record adds an executed trade; qty is negative for sells
+func (f *OrderFlow) Record(symbol string, qty float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.net[symbol] += qty
+}
+
+// Drain returns the net flow since the last drain and resets it
+func (f *OrderFlow) Drain() map[string]float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	net := f.net
+	f.net = map[string]float64{}
+	return net
+}
+
+// Impact is a linear permanent price impact (Kyle's lambda on the log price):
+// a net flow of q shares moves the price by a factor exp(Coefficient*q/L),
+// where L is the symbol's liquidity in shares. Buying L shares at coefficient
+// 0.01 lifts the price about 1%; several agents buying together pay for each
+// other's impact.
+type Impact struct {
+	Coefficient float64
+	Liquidity   float64            // default liquidity (shares)
+	Symbols     map[string]float64 // per-symbol liquidity overrides
+}
+
+// Enabled reports whether trades move prices at all
+func (i Impact) Enabled() bool { return i.Coefficient > 0 }
+
+// Apply returns price moved by the net flow of symbol
+func (i Impact) Apply(symbol string, price, net float64) float64 {
+	if !i.Enabled() || net == 0 {
+		return price
+	}
+	liquidity := i.Liquidity
+	if l, ok := i.Symbols[symbol]; ok {
+		liquidity = l
+	}
+	if liquidity <= 0 {
+		return price
+	}
+	return price * math.Exp(i.Coefficient*net/liquidity)
+}
+
+// ParseLiquidity parses "THYAO=500000,AKBNK=2000000" into per-symbol liquidity
+func ParseLiquidity(spec string) (map[string]float64, error) {
+	out := map[string]float64{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		symbol, value, ok := strings.Cut(entry, "=")
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		l, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || symbol == "" || err != nil || l <= 0 {
+			return nil, fmt.Errorf("invalid liquidity %q (want SYMBOL=shares)", entry)
+		}
+		out[symbol] = l
+	}
+	return out, nil
+}
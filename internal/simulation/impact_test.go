@@ -0,0 +1,91 @@
+package simulation
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestOrderFlowNetsAndDrains(t *testing.T) {
+	f := NewOrderFlow()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.Record("THYAO", 100)
+			f.Record("AKBNK", -50)
+		}()
+	}
+	wg.Wait()
+	f.Record("THYAO", -200)
+
+	net := f.Drain()
+	if net["THYAO"] != 800 || net["AKBNK"] != -500 {
+		t.Errorf("net flow = %v, want THYAO 800, AKBNK -500", net)
+	}
+	if again := f.Drain(); len(again) != 0 {
+		t.Errorf("second drain = %v, want empty", again)
+	}
+}
+
+func TestImpact(t *testing.T) {
+	i := Impact{Coefficient: 0.01, Liquidity: 1000, Symbols: map[string]float64{"ASELS": 100}}
+
+	if got, want := i.Apply("THYAO", 100, 1000), 100*math.Exp(0.01); math.Abs(got-want) > 1e-9 {
+		t.Errorf("buying the liquidity: got %v, want %v", got, want)
+	}
+	if got := i.Apply("THYAO", 100, -1000); got >= 100 {
+		t.Errorf("selling should push the price down, got %v", got)
+	}
+	// Thinner book, same order: ten times the impact
+	if got, want := i.Apply("ASELS", 100, 1000), 100*math.Exp(0.1); math.Abs(got-want) > 1e-9 {
+		t.Errorf("per-symbol liquidity: got %v, want %v", got, want)
+	}
+	// Two agents buying together move the price more than either alone
+	one := i.Apply("THYAO", 100, 500)
+	both := i.Apply("THYAO", 100, 1000)
+	if both-100 <= one-100 {
+		t.Errorf("crowded buy %v should cost more than single buy %v", both, one)
+	}
+	if got := (Impact{}).Apply("THYAO", 100, 1e6); got != 100 {
+		t.Errorf("disabled impact moved the price to %v", got)
+	}
+}
+
+func TestMarketApplyOrderFlow(t *testing.T) {
+	cfg := testConfig()
+	cfg.Impact = Impact{Coefficient: 0.01, Liquidity: 1000}
+	m, err := NewMarket(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices := map[string]float64{"THYAO": 100, "AKBNK": 50}
+	m.ApplyOrderFlow(prices, map[string]float64{"THYAO": 1000, "XXXXX": 500})
+	if math.Abs(prices["THYAO"]-100*math.Exp(0.01)) > 1e-9 || prices["AKBNK"] != 50 {
+		t.Errorf("prices after flow = %v", prices)
+	}
+	if _, ok := prices["XXXXX"]; ok {
+		t.Error("flow for an unknown symbol added a price")
+	}
+
+	cfg.Impact = Impact{Coefficient: 0.01}
+	if _, err := NewMarket(cfg); err == nil {
+		t.Error("impact without liquidity should be rejected")
+	}
+}
+
+func TestParseLiquidity(t *testing.T) {
+	l, err := ParseLiquidity(" thyao=2000000, ASELS=500000 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l["THYAO"] != 2000000 || l["ASELS"] != 500000 {
+		t.Errorf("got %v", l)
+	}
+	for _, bad := range []string{"THYAO", "THYAO=0", "=100", "THYAO=abc"} {
+		if _, err := ParseLiquidity(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
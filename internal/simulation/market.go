@@ -0,0 +1,263 @@
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1batu/market-ai/internal/config"
+)
+
+// Price process models
+const (
+	ModelGBM           = "gbm"
+	ModelJumpDiffusion = "jump"
+	ModelMeanReversion = "meanrev"
+)
+
+// Params overrides the default process parameters of one symbol
+type Params struct {
+	Drift      float64
+	Volatility float64
+	Level      float64 // mean-reversion level; 0 = the first price seen
+}
+
+// Config describes a simulated market. Drift, volatility and intensities are
+// annualized (see TradingYear).
+type Config struct {
+	Model      string
+	Seed       int64
+	Drift      float64
+	Volatility float64
+	Symbols    map[string]Params
+
+	JumpIntensity  float64
+	JumpMean       float64
+	JumpStd        float64
+	ReversionSpeed float64
+
+	Sectors           map[string]string
+	SectorCorrelation float64
+	MarketCorrelation float64
+
+	Impact Impact // price impact of the agents' own trades
+}
+
+// ConfigFrom builds a Config from the SIM_* environment settings. A zero seed
+// is replaced by the current time; Market.Seed reports the one in use so the
+// run can be repeated.
+func ConfigFrom(c config.SimulatorConfig) (Config, error) {
+	cfg := Config{
+		Model:             strings.ToLower(strings.TrimSpace(c.Model)),
+		Seed:              c.Seed,
+		Drift:             c.Drift,
+		Volatility:        c.Volatility,
+		JumpIntensity:     c.JumpIntensity,
+		JumpMean:          c.JumpMean,
+		JumpStd:           c.JumpStd,
+		ReversionSpeed:    c.ReversionSpeed,
+		SectorCorrelation: c.SectorCorrelation,
+		MarketCorrelation: c.MarketCorrelation,
+		Sectors:           map[string]string{},
+		Impact:            Impact{Coefficient: c.ImpactCoefficient, Liquidity: c.Liquidity},
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	var err error
+	if cfg.Symbols, err = ParseParams(c.SymbolParams); err != nil {
+		return cfg, err
+	}
+	if cfg.Impact.Symbols, err = ParseLiquidity(c.SymbolLiquidity); err != nil {
+		return cfg, err
+	}
+	sectors, err := ParseSectors(c.Sectors)
+	if err != nil {
+		return cfg, err
+	}
+	for s, sector := range DefaultSectors {
+		cfg.Sectors[s] = sector
+	}
+	for s, sector := range sectors {
+		cfg.Sectors[s] = sector
+	}
+	return cfg, nil
+}
+
+// ParseParams parses "THYAO=0.12:0.45,AKBNK=0.08:0.30:42.5" (drift:volatility[:level])
+func ParseParams(spec string) (map[string]Params, error) {
+	params := map[string]Params{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		symbol, values, ok := strings.Cut(entry, "=")
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		parts := strings.Split(values, ":")
+		if !ok || symbol == "" || len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid symbol params %q (want SYMBOL=drift:volatility[:level])", entry)
+		}
+		nums := make([]float64, len(parts))
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid symbol params %q: %w", entry, err)
+			}
+			nums[i] = v
+		}
+		p := Params{Drift: nums[0], Volatility: nums[1]}
+		if len(nums) == 3 {
+			p.Level = nums[2]
+		}
+		params[symbol] = p
+	}
+	return params, nil
+}
+
+func (c Config) validate() error {
+	switch c.Model {
+	case ModelGBM, ModelJumpDiffusion, ModelMeanReversion:
+	default:
+		return fmt.Errorf("unknown price model %q (want %s, %s or %s)", c.Model, ModelGBM, ModelJumpDiffusion, ModelMeanReversion)
+	}
+	if c.Volatility < 0 || c.JumpIntensity < 0 || c.JumpStd < 0 || c.ReversionSpeed < 0 {
+		return fmt.Errorf("volatility, jump intensity, jump std and reversion speed must not be negative")
+	}
+	if c.MarketCorrelation < 0 || c.SectorCorrelation < c.MarketCorrelation || c.SectorCorrelation >= 1 {
+		return fmt.Errorf("correlations must satisfy 0 <= market (%.2f) <= sector (%.2f) < 1", c.MarketCorrelation, c.SectorCorrelation)
+	}
+	if c.Impact.Coefficient < 0 || (c.Impact.Enabled() && c.Impact.Liquidity <= 0) {
+		return fmt.Errorf("impact coefficient must not be negative and needs a positive liquidity")
+	}
+	for s, p := range c.Symbols {
+		if p.Volatility < 0 || p.Level < 0 {
+			return fmt.Errorf("%s: volatility and level must not be negative", s)
+		}
+	}
+	return nil
+}
+
+// process creates the price process of one symbol, anchoring mean reversion
+// to the first price seen unless a level is configured
+func (c Config) process(symbol string, price float64) PriceProcess {
+	p, ok := c.Symbols[symbol]
+	if !ok {
+		p = Params{Drift: c.Drift, Volatility: c.Volatility}
+	}
+	switch c.Model {
+	case ModelJumpDiffusion:
+		return JumpDiffusion{GBM: GBM{Drift: p.Drift, Volatility: p.Volatility}, Intensity: c.JumpIntensity, JumpMean: c.JumpMean, JumpStd: c.JumpStd}
+	case ModelMeanReversion:
+		if p.Level == 0 {
+			p.Level = price
+		}
+		return MeanReversion{Speed: c.ReversionSpeed, Level: p.Level, Volatility: p.Volatility}
+	default:
+		return GBM{Drift: p.Drift, Volatility: p.Volatility}
+	}
+}
+
+// Market steps the prices of a set of symbols together: one seeded random
+// source draws independent normals in symbol order, the Cholesky factor of
+// the sector correlation matrix correlates them, and each symbol's process
+// turns its shock into a new price. Two markets with the same config and
+// seed fed the same prices produce the same path. Market is not safe for
+// concurrent use; scenario interventions go through its Overlay.
+type Market struct {
+	cfg       Config
+	rng       *rand.Rand
+	processes map[string]PriceProcess
+	symbols   []string
+	chol      [][]float64
+	overlay   *Overlay
+}
+
+// NewMarket validates cfg and creates a market. Processes are created lazily
+// as symbols appear, so the universe may grow while the market runs.
+func NewMarket(cfg Config) (*Market, error) {
+	if cfg.Model == "" {
+		cfg.Model = ModelGBM
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Market{
+		cfg:       cfg,
+		rng:       rand.New(rand.NewSource(cfg.Seed)),
+		processes: map[string]PriceProcess{},
+		overlay:   NewOverlay(cfg.Sectors),
+	}, nil
+}
+
+// Seed returns the seed of the random source
+func (m *Market) Seed() int64 { return m.cfg.Seed }
+
+// Model returns the price model name
+func (m *Market) Model() string { return m.cfg.Model }
+
+// Overlay returns the shocks and regimes applied on top of the processes
+func (m *Market) Overlay() *Overlay { return m.overlay }
+
+// SetProcess replaces the process of one symbol, e.g. to plug in a custom model
+func (m *Market) SetProcess(symbol string, p PriceProcess) {
+	m.processes[symbol] = p
+}
+
+// ApplyOrderFlow moves prices by the net order flow of each symbol (see
+// Impact) in place; flow for symbols without a price is dropped
+func (m *Market) ApplyOrderFlow(prices map[string]float64, flow map[string]float64) {
+	for s, net := range flow {
+		if p, ok := prices[s]; ok {
+			prices[s] = m.cfg.Impact.Apply(s, p, net)
+		}
+	}
+}
+
+// Step advances every symbol of prices by dt years and returns the new
+// prices. Non-positive prices are skipped.
+func (m *Market) Step(prices map[string]float64, dt float64) (map[string]float64, error) {
+	live := map[string]float64{}
+	for s, p := range prices {
+		if p > 0 {
+			live[s] = p
+		}
+	}
+	symbols := sortedKeys(live)
+	if !slices.Equal(symbols, m.symbols) {
+		chol, err := Cholesky(SectorCorrelation(symbols, m.cfg.Sectors, m.cfg.SectorCorrelation, m.cfg.MarketCorrelation))
+		if err != nil {
+			return nil, err
+		}
+		m.symbols, m.chol = symbols, chol
+	}
+
+	eps := make([]float64, len(symbols))
+	for i := range eps {
+		eps[i] = m.rng.NormFloat64()
+	}
+	z := correlate(m.chol, eps)
+
+	regimes := m.overlay.activeRegimes(symbols)
+	shocks := m.overlay.drainShocks(symbols)
+
+	next := make(map[string]float64, len(symbols))
+	for i, s := range symbols {
+		proc, ok := m.processes[s]
+		if !ok {
+			proc = m.cfg.process(s, live[s])
+			m.processes[s] = proc
+		}
+		if r, ok := regimes[s]; ok {
+			proc = r.apply(proc)
+		}
+		next[s] = proc.Next(live[s], dt, z[i], m.rng)
+		if gross, ok := shocks[s]; ok {
+			next[s] *= gross
+		}
+	}
+	return next, nil
+}
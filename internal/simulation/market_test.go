@@ -0,0 +1,171 @@
+package simulation
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func testConfig() Config {
+	return Config{
+		Model:             ModelGBM,
+		Seed:              42,
+		Drift:             0.08,
+		Volatility:        0.35,
+		Sectors:           map[string]string{"AKBNK": "banking", "GARAN": "banking", "THYAO": "transport"},
+		SectorCorrelation: 0.6,
+		MarketCorrelation: 0.3,
+	}
+}
+
+func TestCholesky(t *testing.T) {
+	c := SectorCorrelation([]string{"AKBNK", "GARAN", "THYAO"}, testConfig().Sectors, 0.6, 0.3)
+	l, err := Cholesky(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range c {
+		for j := range c {
+			var v float64
+			for k := range l {
+				v += l[i][k] * l[j][k]
+			}
+			if math.Abs(v-c[i][j]) > 1e-12 {
+				t.Errorf("LLᵀ[%d][%d] = %v, want %v", i, j, v, c[i][j])
+			}
+		}
+	}
+
+	if _, err := Cholesky([][]float64{{1, 0.9, -0.9}, {0.9, 1, 0.9}, {-0.9, 0.9, 1}}); !errors.Is(err, ErrNotPositiveDefinite) {
+		t.Errorf("inconsistent correlations: got %v, want ErrNotPositiveDefinite", err)
+	}
+}
+
+func TestMarketIsReproducible(t *testing.T) {
+	run := func(seed int64) []float64 {
+		cfg := testConfig()
+		cfg.Seed = seed
+		m, err := NewMarket(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prices := map[string]float64{"AKBNK": 40, "GARAN": 100, "THYAO": 300}
+		var path []float64
+		for i := 0; i < 50; i++ {
+			if prices, err = m.Step(prices, 1.0/252); err != nil {
+				t.Fatal(err)
+			}
+			path = append(path, prices["AKBNK"], prices["GARAN"], prices["THYAO"])
+		}
+		return path
+	}
+	a, b, c := run(42), run(42), run(43)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("same seed diverged at %d: %v != %v", i, a[i], b[i])
+		}
+	}
+	if a[len(a)-1] == c[len(c)-1] {
+		t.Error("different seeds produced the same path")
+	}
+}
+
+func TestMarketSectorCorrelation(t *testing.T) {
+	m, err := NewMarket(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols := []string{"AKBNK", "GARAN", "THYAO"}
+	rets := map[string][]float64{}
+	for i := 0; i < 20000; i++ {
+		prices := map[string]float64{"AKBNK": 100, "GARAN": 100, "THYAO": 100}
+		next, err := m.Step(prices, 1.0/252)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range symbols {
+			rets[s] = append(rets[s], math.Log(next[s]/100))
+		}
+	}
+	corr := func(a, b []float64) float64 {
+		ma, sa := meanStd(a)
+		mb, sb := meanStd(b)
+		var cov float64
+		for i := range a {
+			cov += (a[i] - ma) * (b[i] - mb)
+		}
+		return cov / float64(len(a)-1) / (sa * sb)
+	}
+	if got := corr(rets["AKBNK"], rets["GARAN"]); math.Abs(got-0.6) > 0.03 {
+		t.Errorf("same-sector correlation %.3f, want 0.6", got)
+	}
+	if got := corr(rets["AKBNK"], rets["THYAO"]); math.Abs(got-0.3) > 0.03 {
+		t.Errorf("cross-sector correlation %.3f, want 0.3", got)
+	}
+}
+
+func TestMarketUniverseChanges(t *testing.T) {
+	cfg := testConfig()
+	cfg.Model = ModelMeanReversion
+	cfg.ReversionSpeed = 2
+	m, err := NewMarket(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Step(map[string]float64{"AKBNK": 40}, 1.0/252); err != nil {
+		t.Fatal(err)
+	}
+	next, err := m.Step(map[string]float64{"AKBNK": 40, "GARAN": 100, "SISE": 0}, 1.0/252)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 2 || next["GARAN"] <= 0 {
+		t.Errorf("got %v, want AKBNK and GARAN only", next)
+	}
+	if mr, ok := m.processes["GARAN"].(MeanReversion); !ok || mr.Level != 100 {
+		t.Errorf("GARAN process %+v, want mean reversion to its first price", m.processes["GARAN"])
+	}
+}
+
+func TestNewMarketValidates(t *testing.T) {
+	for name, mutate := range map[string]func(*Config){
+		"unknown model":       func(c *Config) { c.Model = "brownian" },
+		"negative volatility": func(c *Config) { c.Volatility = -0.1 },
+		"market above sector": func(c *Config) { c.MarketCorrelation = 0.8 },
+		"perfect correlation": func(c *Config) { c.SectorCorrelation = 1 },
+	} {
+		cfg := testConfig()
+		mutate(&cfg)
+		if _, err := NewMarket(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	p, err := ParseParams("thyao=0.12:0.45, AKBNK=0.08:0.30:42.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p["THYAO"] != (Params{Drift: 0.12, Volatility: 0.45}) || p["AKBNK"] != (Params{Drift: 0.08, Volatility: 0.30, Level: 42.5}) {
+		t.Errorf("got %+v", p)
+	}
+	for _, bad := range []string{"THYAO=0.1", "THYAO", "THYAO=a:b"} {
+		if _, err := ParseParams(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestParseSectors(t *testing.T) {
+	s, err := ParseSectors("banking=AKBNK|garan; aviation=THYAO|PGSUS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s["GARAN"] != "banking" || s["PGSUS"] != "aviation" || len(s) != 4 {
+		t.Errorf("got %v", s)
+	}
+	if _, err := ParseSectors("AKBNK|GARAN"); err == nil {
+		t.Error("expected an error for a group without a name")
+	}
+}
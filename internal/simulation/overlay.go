@@ -0,0 +1,154 @@
+package simulation
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+)
+
+// Target selects symbols: the listed symbols, every symbol of a sector, or
+// the whole market when both are empty
+type Target struct {
+	Symbols []string `yaml:"symbols,omitempty" json:"symbols,omitempty"`
+	Sector  string   `yaml:"sector,omitempty" json:"sector,omitempty"`
+}
+
+func (t Target) matches(symbol string, sectors map[string]string) bool {
+	if len(t.Symbols) == 0 && t.Sector == "" {
+		return true
+	}
+	return slices.Contains(t.Symbols, symbol) || (t.Sector != "" && sectors[symbol] == t.Sector)
+}
+
+// Regime changes how a symbol's process moves while it is active: volatility
+// is multiplied by VolatilityScale (0 = unchanged) and Drift is added to the
+// annualized drift
+type Regime struct {
+	VolatilityScale float64
+	Drift           float64
+}
+
+func (r Regime) scale() float64 {
+	if r.VolatilityScale <= 0 {
+		return 1
+	}
+	return r.VolatilityScale
+}
+
+// combine stacks two overlapping regimes
+func (r Regime) combine(o Regime) Regime {
+	return Regime{VolatilityScale: r.scale() * o.scale(), Drift: r.Drift + o.Drift}
+}
+
+// apply returns p running under the regime. The built-in processes get their
+// parameters changed; any other process has its shock scaled instead.
+func (r Regime) apply(p PriceProcess) PriceProcess {
+	switch v := p.(type) {
+	case GBM:
+		v.Drift += r.Drift
+		v.Volatility *= r.scale()
+		return v
+	case JumpDiffusion:
+		v.Drift += r.Drift
+		v.Volatility *= r.scale()
+		return v
+	default:
+		return regimeProcess{p, r}
+	}
+}
+
+type regimeProcess struct {
+	PriceProcess
+	regime Regime
+}
+
+func (p regimeProcess) Next(price, dt, z float64, rng *rand.Rand) float64 {
+	return p.PriceProcess.Next(price, dt, z*p.regime.scale(), rng) * math.Exp(p.regime.Drift*dt)
+}
+
+type shock struct {
+	target Target
+	ret    float64
+}
+
+type activeRegime struct {
+	target Target
+	regime Regime
+}
+
+// Overlay holds scenario interventions on top of a market: one-off price
+// shocks that wait for the next step and regimes that stay active until
+// cleared. Unlike Market it is safe for concurrent use, so a scenario runner
+// can change it while the simulator steps.
+type Overlay struct {
+	mu      sync.Mutex
+	sectors map[string]string
+	shocks  []shock
+	regimes map[string]activeRegime
+}
+
+// NewOverlay creates an empty overlay resolving sector targets with sectors
+func NewOverlay(sectors map[string]string) *Overlay {
+	return &Overlay{sectors: sectors, regimes: map[string]activeRegime{}}
+}
+
+// Shock moves the targeted prices by ret (-0.08 = -8%) on the next step
+func (o *Overlay) Shock(t Target, ret float64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.shocks = append(o.shocks, shock{t, ret})
+}
+
+// SetRegime activates (or replaces) the regime with the given id
+func (o *Overlay) SetRegime(id string, t Target, r Regime) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.regimes[id] = activeRegime{t, r}
+}
+
+// ClearRegime ends the regime with the given id
+func (o *Overlay) ClearRegime(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.regimes, id)
+}
+
+// activeRegimes returns the combined active regime of every symbol that has one
+func (o *Overlay) activeRegimes(symbols []string) map[string]Regime {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := map[string]Regime{}
+	for _, ar := range o.regimes {
+		for _, s := range symbols {
+			if ar.target.matches(s, o.sectors) {
+				if cur, ok := out[s]; ok {
+					out[s] = cur.combine(ar.regime)
+				} else {
+					out[s] = ar.regime
+				}
+			}
+		}
+	}
+	return out
+}
+
+// drainShocks returns the gross return (1+r1)(1+r2)... queued for each symbol
+// and clears the queue
+func (o *Overlay) drainShocks(symbols []string) map[string]float64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := map[string]float64{}
+	for _, sh := range o.shocks {
+		for _, s := range symbols {
+			if sh.target.matches(s, o.sectors) {
+				if _, ok := out[s]; !ok {
+					out[s] = 1
+				}
+				out[s] *= 1 + sh.ret
+			}
+		}
+	}
+	o.shocks = nil
+	return out
+}
@@ -0,0 +1,96 @@
+package simulation
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// TradingYear is the session time in one year (252 days of 8-hour BIST
+// sessions). Drift and volatility are annualized over this, so a tick of
+// simulated time d advances processes by YearFraction(d).
+const TradingYear = 252 * 8 * time.Hour
+
+// YearFraction converts a duration of session time into years
+func YearFraction(d time.Duration) float64 {
+	return float64(d) / float64(TradingYear)
+}
+
+// PriceProcess moves one symbol's price forward by dt years. z is a standard
+// normal shock supplied by the market, already correlated with the other
+// symbols' shocks; rng is for any extra randomness the process needs (jumps).
+type PriceProcess interface {
+	Next(price, dt, z float64, rng *rand.Rand) float64
+}
+
+// GBM is geometric Brownian motion with annualized drift and volatility.
+// Log returns are normal, so prices stay positive and a day's move is
+// sigma*sqrt(1/252) regardless of how often the market ticks.
+type GBM struct {
+	Drift      float64
+	Volatility float64
+}
+
+// Next applies the exact GBM transition
+func (g GBM) Next(price, dt, z float64, _ *rand.Rand) float64 {
+	return price * math.Exp(g.logReturn(dt, z))
+}
+
+func (g GBM) logReturn(dt, z float64) float64 {
+	return (g.Drift-0.5*g.Volatility*g.Volatility)*dt + g.Volatility*math.Sqrt(dt)*z
+}
+
+// JumpDiffusion is Merton's model: GBM plus Poisson-arriving jumps whose log
+// sizes are normal. The drift is compensated for the expected jump so Drift
+// stays the expected return.
+type JumpDiffusion struct {
+	GBM
+	Intensity float64 // expected jumps per year
+	JumpMean  float64 // mean log jump size
+	JumpStd   float64 // standard deviation of the log jump size
+}
+
+// Next applies the diffusion step and any jumps that arrive within dt
+func (j JumpDiffusion) Next(price, dt, z float64, rng *rand.Rand) float64 {
+	k := math.Exp(j.JumpMean+0.5*j.JumpStd*j.JumpStd) - 1
+	r := j.logReturn(dt, z) - j.Intensity*k*dt
+	for n := poisson(rng, j.Intensity*dt); n > 0; n-- {
+		r += j.JumpMean + j.JumpStd*rng.NormFloat64()
+	}
+	return price * math.Exp(r)
+}
+
+// MeanReversion is an Ornstein-Uhlenbeck process on the log price: the price
+// is pulled back towards Level with speed Speed (per year) and a half-life of
+// ln2/Speed years.
+type MeanReversion struct {
+	Speed      float64
+	Level      float64
+	Volatility float64
+}
+
+// Next applies the exact OU transition, which stays stable for any dt
+func (m MeanReversion) Next(price, dt, z float64, _ *rand.Rand) float64 {
+	if m.Speed <= 0 || m.Level <= 0 {
+		return GBM{Volatility: m.Volatility}.Next(price, dt, z, nil)
+	}
+	mu := math.Log(m.Level)
+	decay := math.Exp(-m.Speed * dt)
+	std := m.Volatility * math.Sqrt((1-decay*decay)/(2*m.Speed))
+	return math.Exp(mu + (math.Log(price)-mu)*decay + std*z)
+}
+
+// poisson draws a Poisson(lambda) count with Knuth's method; lambda per tick
+// is tiny, so this ends after a step or two
+func poisson(rng *rand.Rand, lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	limit := math.Exp(-lambda)
+	n, p := 0, rng.Float64()
+	for p > limit {
+		n++
+		p *= rng.Float64()
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package simulation
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func meanStd(xs []float64) (float64, float64) {
+	var sum, sq float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	for _, x := range xs {
+		sq += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(xs)-1))
+}
+
+func TestYearFraction(t *testing.T) {
+	if got := YearFraction(8 * time.Hour); math.Abs(got-1.0/252) > 1e-12 {
+		t.Errorf("one session = %v years, want 1/252", got)
+	}
+}
+
+func TestGBMMoments(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	g := GBM{Drift: 0.1, Volatility: 0.3}
+	dt := 1.0 / 252
+	rets := make([]float64, 50000)
+	for i := range rets {
+		rets[i] = math.Log(g.Next(100, dt, rng.NormFloat64(), rng) / 100)
+	}
+	mean, std := meanStd(rets)
+	if want := 0.3 * math.Sqrt(dt); math.Abs(std-want)/want > 0.02 {
+		t.Errorf("daily volatility %.5f, want %.5f", std, want)
+	}
+	if want := (0.1 - 0.045) * dt; math.Abs(mean-want) > 3*std/math.Sqrt(float64(len(rets))) {
+		t.Errorf("daily mean log return %.6f, want %.6f", mean, want)
+	}
+}
+
+func TestGBMTickSizeIndependent(t *testing.T) {
+	// A day of 5-second ticks must move prices like one daily step, not the
+	// compounding ±2% walk the simulator used to do
+	g := GBM{Volatility: 0.35}
+	dt := YearFraction(5 * time.Second)
+	ticks := int(8 * time.Hour / (5 * time.Second))
+	rng := rand.New(rand.NewSource(7))
+	rets := make([]float64, 400)
+	for i := range rets {
+		p := 100.0
+		for k := 0; k < ticks; k++ {
+			p = g.Next(p, dt, rng.NormFloat64(), rng)
+		}
+		rets[i] = math.Log(p / 100)
+	}
+	_, std := meanStd(rets)
+	if want := 0.35 / math.Sqrt(252); math.Abs(std-want)/want > 0.15 {
+		t.Errorf("daily volatility from 5s ticks %.4f, want ~%.4f", std, want)
+	}
+}
+
+func TestJumpDiffusionIsCompensated(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	j := JumpDiffusion{GBM: GBM{Drift: 0.05, Volatility: 0.2}, Intensity: 50, JumpMean: -0.05, JumpStd: 0.05}
+	n, jumps := 100000, 0
+	var sum float64
+	for i := 0; i < n; i++ {
+		p := j.Next(100, 0.1, rng.NormFloat64(), rng)
+		if math.Abs(math.Log(p/100)) > 0.15 {
+			jumps++
+		}
+		sum += p
+	}
+	if want := 100 * math.Exp(0.05*0.1); math.Abs(sum/float64(n)-want) > 0.3 {
+		t.Errorf("mean price %.3f, want %.3f (drift must stay the expected return)", sum/float64(n), want)
+	}
+	if jumps == 0 {
+		t.Error("expected some large jump moves")
+	}
+}
+
+func TestMeanReversionPullsTowardsLevel(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	m := MeanReversion{Speed: 4, Level: 50, Volatility: 0.2}
+	finals := make([]float64, 5000)
+	for i := range finals {
+		p := 100.0
+		for k := 0; k < 252; k++ {
+			p = m.Next(p, 1.0/252, rng.NormFloat64(), rng)
+		}
+		finals[i] = math.Log(p)
+	}
+	mean, _ := meanStd(finals)
+	// After one year log(2)*e^-4 of the initial gap remains
+	if want := math.Log(50) + math.Log(2)*math.Exp(-4); math.Abs(mean-want) > 0.01 {
+		t.Errorf("mean log price %.4f, want %.4f", mean, want)
+	}
+}
+
+func TestPoisson(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	total := 0
+	for i := 0; i < 20000; i++ {
+		total += poisson(rng, 0.5)
+	}
+	if mean := float64(total) / 20000; math.Abs(mean-0.5) > 0.02 {
+		t.Errorf("poisson mean %.3f, want 0.5", mean)
+	}
+	if poisson(rng, 0) != 0 {
+		t.Error("zero intensity should never jump")
+	}
+}
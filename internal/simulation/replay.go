@@ -0,0 +1,267 @@
+package simulation
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bar is one OHLCV bar. Time is the start of the bar.
+type Bar struct {
+	Symbol string
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume int64
+}
+
+// BarSource yields bars grouped by bar time in ascending order. Next returns
+// io.EOF after the last group.
+type BarSource interface {
+	Next(ctx context.Context) (time.Time, []Bar, error)
+}
+
+// Timeframes are the bar sizes stored in market_data
+var Timeframes = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// ParseTime accepts RFC3339, "2006-01-02 15:04:05" and "2006-01-02"; times
+// without a zone are UTC like the TIMESTAMP columns they are compared with
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// Replayer plays a bar source against a virtual clock. Bars are applied when
+// they close (bar time + interval), so at any clock time only finished bars
+// are visible. speed is market time per real time (10 = ten times faster);
+// 0 plays as fast as possible. Real waits are capped at maxGap so nights and
+// weekends do not stall a 1x replay.
+type Replayer struct {
+	source   BarSource
+	clock    *VirtualClock
+	interval time.Duration
+	speed    float64
+	maxGap   time.Duration
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// NewReplayer creates a replayer driving clock
+func NewReplayer(source BarSource, clock *VirtualClock, interval time.Duration, speed float64, maxGap time.Duration) *Replayer {
+	return &Replayer{source: source, clock: clock, interval: interval, speed: speed, maxGap: maxGap, sleep: sleepContext}
+}
+
+// Interval returns the bar size
+func (r *Replayer) Interval() time.Duration { return r.interval }
+
+// Clock returns the virtual clock the replayer drives
+func (r *Replayer) Clock() *VirtualClock { return r.clock }
+
+// Run plays every bar group, calling apply with the close time and the bars.
+// It returns nil when the source is exhausted.
+func (r *Replayer) Run(ctx context.Context, apply func(ctx context.Context, at time.Time, bars []Bar) error) error {
+	var prev time.Time
+	for {
+		start, bars, err := r.source.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		at := start.Add(r.interval)
+		if !prev.IsZero() && r.speed > 0 {
+			wait := time.Duration(float64(at.Sub(prev)) / r.speed)
+			if r.maxGap > 0 && wait > r.maxGap {
+				wait = r.maxGap
+			}
+			if err := r.sleep(ctx, wait); err != nil {
+				return err
+			}
+		}
+		r.clock.Set(at)
+		if err := apply(ctx, at, bars); err != nil {
+			return err
+		}
+		prev = at
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// SliceSource serves bars from memory
+type SliceSource struct {
+	bars []Bar
+	pos  int
+}
+
+// NewSliceSource sorts bars by time (then symbol) and serves them by group
+func NewSliceSource(bars []Bar) *SliceSource {
+	sorted := append([]Bar(nil), bars...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Time.Equal(sorted[j].Time) {
+			return sorted[i].Time.Before(sorted[j].Time)
+		}
+		return sorted[i].Symbol < sorted[j].Symbol
+	})
+	return &SliceSource{bars: sorted}
+}
+
+// Len returns the number of bars
+func (s *SliceSource) Len() int { return len(s.bars) }
+
+// Next returns the next group of bars sharing a bar time
+func (s *SliceSource) Next(ctx context.Context) (time.Time, []Bar, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, nil, err
+	}
+	if s.pos >= len(s.bars) {
+		return time.Time{}, nil, io.EOF
+	}
+	at := s.bars[s.pos].Time
+	end := s.pos
+	for end < len(s.bars) && s.bars[end].Time.Equal(at) {
+		end++
+	}
+	group := s.bars[s.pos:end]
+	s.pos = end
+	return at, group, nil
+}
+
+// LoadCSV reads bars from a CSV file or from every *.csv file of a directory.
+// The header names the columns (any order, case-insensitive): timestamp (or
+// time/date), open, high, low, close, volume and symbol. Without a symbol
+// column the file name is the symbol (THYAO.csv). Bars outside [from, to) are
+// dropped; zero bounds are open.
+func LoadCSV(path string, from, to time.Time) ([]Bar, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.csv")); err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+	var bars []Bar
+	for _, f := range files {
+		fileBars, err := loadCSVFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range fileBars {
+			if (!from.IsZero() && b.Time.Before(from)) || (!to.IsZero() && !b.Time.Before(to)) {
+				continue
+			}
+			bars = append(bars, b)
+		}
+	}
+	return bars, nil
+}
+
+func loadCSVFile(path string) ([]Bar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	bars, err := ReadCSV(f, strings.ToUpper(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return bars, nil
+}
+
+// ReadCSV parses bars from r; symbol is used when there is no symbol column
+func ReadCSV(r io.Reader, symbol string) ([]Bar, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, alias := range []string{"time", "date", "datetime"} {
+		if i, ok := col[alias]; ok {
+			if _, has := col["timestamp"]; !has {
+				col["timestamp"] = i
+			}
+		}
+	}
+	for _, required := range []string{"timestamp", "open", "high", "low", "close"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("missing %q column", required)
+		}
+	}
+
+	var bars []Bar
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return bars, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		b := Bar{Symbol: symbol}
+		if i, ok := col["symbol"]; ok {
+			b.Symbol = strings.ToUpper(strings.TrimSpace(rec[i]))
+		}
+		if b.Time, err = ParseTime(rec[col["timestamp"]]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		for name, dst := range map[string]*float64{"open": &b.Open, "high": &b.High, "low": &b.Low, "close": &b.Close} {
+			if *dst, err = strconv.ParseFloat(strings.TrimSpace(rec[col[name]]), 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %w", line, name, err)
+			}
+		}
+		if i, ok := col["volume"]; ok && strings.TrimSpace(rec[i]) != "" {
+			v, err := strconv.ParseFloat(strings.TrimSpace(rec[i]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid volume: %w", line, err)
+			}
+			b.Volume = int64(v)
+		}
+		if b.Symbol == "" || b.Close <= 0 {
+			return nil, fmt.Errorf("line %d: symbol and a positive close are required", line)
+		}
+		bars = append(bars, b)
+	}
+}
@@ -0,0 +1,117 @@
+package simulation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCSV(t *testing.T) {
+	bars, err := ReadCSV(strings.NewReader("Date,Close,Open,High,Low,Volume\n2024-03-01 10:00:00,101.5,100,102,99.5,1200\n2024-03-01 10:01:00,101,101.5,101.6,100.8,\n"), "THYAO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Bar{Symbol: "THYAO", Time: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), Open: 100, High: 102, Low: 99.5, Close: 101.5, Volume: 1200}
+	if len(bars) != 2 || bars[0] != want || bars[1].Volume != 0 {
+		t.Errorf("got %+v", bars)
+	}
+
+	withSymbol, err := ReadCSV(strings.NewReader("symbol,timestamp,open,high,low,close\nakbnk,2024-03-01T10:00:00Z,40,41,39,40.5\n"), "IGNORED")
+	if err != nil || withSymbol[0].Symbol != "AKBNK" {
+		t.Errorf("symbol column: got %+v, %v", withSymbol, err)
+	}
+
+	for name, in := range map[string]string{
+		"missing close": "timestamp,open,high,low\n2024-03-01,1,1,1\n",
+		"bad time":      "timestamp,open,high,low,close\nyesterday,1,1,1,1\n",
+		"bad price":     "timestamp,open,high,low,close\n2024-03-01,1,1,1,x\n",
+	} {
+		if _, err := ReadCSV(strings.NewReader(in), "THYAO"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadCSVDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("THYAO.csv", "timestamp,open,high,low,close\n2024-03-01,300,310,295,305\n2024-03-04,305,306,290,292\n")
+	write("akbnk.csv", "timestamp,open,high,low,close\n2024-03-01,40,41,39,40.5\n")
+	write("notes.txt", "ignored")
+
+	bars, err := LoadCSV(dir, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bars) != 2 || bars[0].Symbol != "THYAO" || bars[1].Symbol != "AKBNK" {
+		t.Errorf("got %+v, want the 1 March bars of both files", bars)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	source := NewSliceSource([]Bar{
+		{Symbol: "THYAO", Time: t0.Add(time.Minute), Close: 301},
+		{Symbol: "AKBNK", Time: t0, Close: 40},
+		{Symbol: "THYAO", Time: t0, Close: 300},
+		{Symbol: "THYAO", Time: t0.Add(18 * time.Hour), Close: 302}, // next morning
+	})
+	clock := NewVirtualClock(t0)
+	r := NewReplayer(source, clock, time.Minute, 10, 10*time.Second)
+	var waits []time.Duration
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	var applied []time.Time
+	var sizes []int
+	err := r.Run(context.Background(), func(_ context.Context, at time.Time, bars []Bar) error {
+		if !clock.Now().Equal(at) {
+			t.Errorf("clock %v while applying bars closing at %v", clock.Now(), at)
+		}
+		applied = append(applied, at)
+		sizes = append(sizes, len(bars))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 3 || !applied[0].Equal(t0.Add(time.Minute)) || sizes[0] != 2 {
+		t.Fatalf("applied %v with sizes %v; bars must be applied when they close, grouped by time", applied, sizes)
+	}
+	if len(waits) != 2 || waits[0] != 6*time.Second || waits[1] != 10*time.Second {
+		t.Errorf("waits %v, want 6s (1 minute at 10x) then the 10s gap cap", waits)
+	}
+}
+
+func TestReplayerMaxSpeedDoesNotWait(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	r := NewReplayer(NewSliceSource([]Bar{{Symbol: "A", Time: t0, Close: 1}, {Symbol: "A", Time: t0.Add(time.Hour), Close: 2}}), NewVirtualClock(t0), time.Hour, 0, 0)
+	r.sleep = func(context.Context, time.Duration) error {
+		t.Error("as-fast-as-possible replay should not sleep")
+		return nil
+	}
+	if err := r.Run(context.Background(), func(context.Context, time.Time, []Bar) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if want := t0.Add(2 * time.Hour); !r.Clock().Now().Equal(want) {
+		t.Errorf("clock %v, want %v", r.Clock().Now(), want)
+	}
+}
+
+func TestVirtualClockNeverMovesBack(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	c := NewVirtualClock(t0)
+	c.Set(t0.Add(-time.Hour))
+	if !c.Now().Equal(t0) {
+		t.Errorf("clock moved back to %v", c.Now())
+	}
+}
@@ -0,0 +1,211 @@
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Scenario event types
+const (
+	EventShock  = "shock"  // one-off move of the targeted prices
+	EventRegime = "regime" // volatility/drift change, optionally for a duration
+	EventNews   = "news"   // synthetic article injected into the news feed
+)
+
+// DefaultScenarioTail is how long a scenario without an explicit duration
+// keeps running after its last event, so the agents' reactions are recorded
+const DefaultScenarioTail = 15 * time.Minute
+
+// ErrInvalidScenario is returned for scenarios that fail validation
+var ErrInvalidScenario = errors.New("invalid scenario")
+
+// Duration is a time.Duration written as "30s", "5m" or "1h30m" in YAML/JSON
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	v, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON reads a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// Scenario is a scripted stress test: events fire at offsets from the start
+// of the run.
+//
+//	name: tcmb-surprise-hike
+//	events:
+//	  - at: 0s
+//	    type: news
+//	    news: {title: "TCMB faizi 500 baz puan artırdı", sentiment: negative}
+//	  - at: 30s
+//	    type: shock
+//	    sector: banking
+//	    return: -0.08
+//	  - at: 30s
+//	    type: regime
+//	    volatility_scale: 2.5
+//	    duration: 10m
+type Scenario struct {
+	Name        string          `yaml:"name" json:"name"`
+	Description string          `yaml:"description,omitempty" json:"description,omitempty"`
+	Duration    Duration        `yaml:"duration,omitempty" json:"duration,omitempty"` // total run length (0 = last event + DefaultScenarioTail)
+	Events      []ScenarioEvent `yaml:"events" json:"events"`
+}
+
+// ScenarioEvent is one scheduled intervention
+type ScenarioEvent struct {
+	At     Duration `yaml:"at" json:"at"`
+	Type   string   `yaml:"type" json:"type"`
+	Target `yaml:",inline"`
+
+	Return          float64  `yaml:"return,omitempty" json:"return,omitempty"`                     // shock: -0.08 = -8%
+	VolatilityScale float64  `yaml:"volatility_scale,omitempty" json:"volatility_scale,omitempty"` // regime
+	Drift           float64  `yaml:"drift,omitempty" json:"drift,omitempty"`                       // regime: added annual drift
+	Duration        Duration `yaml:"duration,omitempty" json:"duration,omitempty"`                 // regime: 0 = until the run ends
+
+	News *ScenarioNews `yaml:"news,omitempty" json:"news,omitempty"`
+}
+
+// ScenarioNews is a synthetic article
+type ScenarioNews struct {
+	Title          string   `yaml:"title" json:"title"`
+	Description    string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Content        string   `yaml:"content,omitempty" json:"content,omitempty"`
+	Source         string   `yaml:"source,omitempty" json:"source,omitempty"`
+	EventType      string   `yaml:"event_type,omitempty" json:"event_type,omitempty"` // news | economic_data | earnings | policy
+	Category       string   `yaml:"category,omitempty" json:"category,omitempty"`
+	RelatedStocks  []string `yaml:"related_stocks,omitempty" json:"related_stocks,omitempty"`
+	Sentiment      string   `yaml:"sentiment,omitempty" json:"sentiment,omitempty"` // positive | negative | neutral
+	SentimentScore float64  `yaml:"sentiment_score,omitempty" json:"sentiment_score,omitempty"`
+	ImpactLevel    string   `yaml:"impact_level,omitempty" json:"impact_level,omitempty"` // low | medium | high
+}
+
+// ParseScenario reads a YAML scenario and validates it
+func ParseScenario(data []byte) (*Scenario, error) {
+	var s Scenario
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScenario, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LoadScenario reads a scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// Validate checks the events and sorts them by offset
+func (s *Scenario) Validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidScenario)
+	}
+	if len(s.Events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidScenario)
+	}
+	for i := range s.Events {
+		e := &s.Events[i]
+		for j, sym := range e.Symbols {
+			e.Symbols[j] = strings.ToUpper(strings.TrimSpace(sym))
+		}
+		if e.At < 0 || e.Duration < 0 {
+			return fmt.Errorf("%w: event %d: offsets must not be negative", ErrInvalidScenario, i+1)
+		}
+		switch e.Type {
+		case EventShock:
+			if e.Return <= -1 || e.Return == 0 {
+				return fmt.Errorf("%w: event %d: shock return must be non-zero and above -1", ErrInvalidScenario, i+1)
+			}
+		case EventRegime:
+			if e.VolatilityScale < 0 || (e.VolatilityScale == 0 && e.Drift == 0) {
+				return fmt.Errorf("%w: event %d: regime needs a positive volatility_scale or a drift", ErrInvalidScenario, i+1)
+			}
+		case EventNews:
+			if e.News == nil || strings.TrimSpace(e.News.Title) == "" {
+				return fmt.Errorf("%w: event %d: news needs a title", ErrInvalidScenario, i+1)
+			}
+		default:
+			return fmt.Errorf("%w: event %d: unknown type %q (want shock, regime or news)", ErrInvalidScenario, i+1, e.Type)
+		}
+	}
+	sort.SliceStable(s.Events, func(i, j int) bool { return s.Events[i].At < s.Events[j].At })
+	return nil
+}
+
+// MovesPrices reports whether the scenario needs a simulated market
+func (s *Scenario) MovesPrices() bool {
+	for _, e := range s.Events {
+		if e.Type != EventNews {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduledAction is one step of a scenario run: event Index starts, or for
+// a regime with a duration, ends
+type ScheduledAction struct {
+	At    time.Duration
+	Index int
+	End   bool
+}
+
+// Schedule returns the actions of the scenario in time order
+func (s *Scenario) Schedule() []ScheduledAction {
+	actions := make([]ScheduledAction, 0, len(s.Events))
+	for i, e := range s.Events {
+		actions = append(actions, ScheduledAction{At: time.Duration(e.At), Index: i})
+		if e.Type == EventRegime && e.Duration > 0 {
+			actions = append(actions, ScheduledAction{At: time.Duration(e.At + e.Duration), Index: i, End: true})
+		}
+	}
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].At < actions[j].At })
+	return actions
+}
+
+// Length is how long a run of the scenario lasts
+func (s *Scenario) Length() time.Duration {
+	var last time.Duration
+	for _, a := range s.Schedule() {
+		last = max(last, a.At)
+	}
+	if s.Duration > 0 {
+		return max(time.Duration(s.Duration), last)
+	}
+	return last + DefaultScenarioTail
+}
@@ -0,0 +1,140 @@
+package simulation
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testScenario = `
+name: hike
+events:
+  - at: 1m
+    type: shock
+    sector: banking
+    return: -0.08
+  - at: 0s
+    type: news
+    news:
+      title: TCMB faizi artırdı
+      sentiment: negative
+  - at: 30s
+    type: regime
+    symbols: [thyao]
+    volatility_scale: 2
+    duration: 2m
+`
+
+func TestParseScenario(t *testing.T) {
+	sc, err := ParseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Events[0].Type != EventNews || sc.Events[1].Type != EventRegime || sc.Events[2].Type != EventShock {
+		t.Fatalf("events not sorted by offset: %+v", sc.Events)
+	}
+	if sc.Events[1].Symbols[0] != "THYAO" || sc.Events[2].Sector != "banking" {
+		t.Errorf("targets not parsed: %+v", sc.Events)
+	}
+	if !sc.MovesPrices() {
+		t.Error("scenario with shocks should move prices")
+	}
+
+	want := []ScheduledAction{{0, 0, false}, {30 * time.Second, 1, false}, {time.Minute, 2, false}, {150 * time.Second, 1, true}}
+	got := sc.Schedule()
+	if len(got) != len(want) {
+		t.Fatalf("schedule = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("action %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if l := sc.Length(); l != 150*time.Second+DefaultScenarioTail {
+		t.Errorf("length = %v", l)
+	}
+	sc.Duration = Duration(time.Hour)
+	if l := sc.Length(); l != time.Hour {
+		t.Errorf("explicit length = %v", l)
+	}
+}
+
+func TestParseScenarioRejectsInvalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"no name":       "events: [{at: 0s, type: shock, return: -0.1}]",
+		"no events":     "name: x",
+		"unknown type":  "name: x\nevents: [{at: 0s, type: crash}]",
+		"wipeout shock": "name: x\nevents: [{at: 0s, type: shock, return: -1}]",
+		"empty regime":  "name: x\nevents: [{at: 0s, type: regime}]",
+		"untitled news": "name: x\nevents: [{at: 0s, type: news, news: {}}]",
+		"bad offset":    "name: x\nevents: [{at: soon, type: shock, return: 0.1}]",
+		"unknown field": "name: x\nevents: [{at: 0s, type: shock, retrun: 0.1}]",
+	} {
+		if _, err := ParseScenario([]byte(doc)); !errors.Is(err, ErrInvalidScenario) {
+			t.Errorf("%s: got %v, want ErrInvalidScenario", name, err)
+		}
+	}
+}
+
+func TestBundledScenariosParse(t *testing.T) {
+	files, _ := filepath.Glob("../../scenarios/*.yaml")
+	if len(files) == 0 {
+		t.Skip("no bundled scenarios")
+	}
+	for _, f := range files {
+		if _, err := LoadScenario(f); err != nil {
+			t.Errorf("%s: %v", filepath.Base(f), err)
+		}
+	}
+	if _, err := LoadScenario("missing.yaml"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: got %v", err)
+	}
+}
+
+func TestOverlayShocksAndRegimes(t *testing.T) {
+	cfg := testConfig()
+	cfg.Volatility = 0.2
+	m, err := NewMarket(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calm, _ := NewMarket(cfg)
+	prices := map[string]float64{"AKBNK": 50, "GARAN": 100, "THYAO": 300}
+
+	m.Overlay().Shock(Target{Sector: "banking"}, -0.1)
+	shocked, _ := m.Step(prices, 0)
+	if math.Abs(shocked["AKBNK"]-45) > 1e-9 || math.Abs(shocked["GARAN"]-90) > 1e-9 || shocked["THYAO"] != 300 {
+		t.Errorf("banking shock with dt=0: %v", shocked)
+	}
+	again, _ := m.Step(prices, 0)
+	if again["AKBNK"] != 50 {
+		t.Errorf("shock applied twice: %v", again)
+	}
+
+	// Same seed and shocks: a 3x volatility regime on THYAO triples its log move
+	calm.Step(prices, 0)
+	calm.Step(prices, 0)
+	m.Overlay().SetRegime("r", Target{Symbols: []string{"THYAO"}}, Regime{VolatilityScale: 3})
+	dt := YearFraction(time.Hour)
+	a, _ := m.Step(prices, dt)
+	b, _ := calm.Step(prices, dt)
+	drift := func(vol float64) float64 { return (cfg.Drift - 0.5*vol*vol) * dt }
+	za := (math.Log(a["THYAO"]/300) - drift(0.6)) / 0.6
+	zb := (math.Log(b["THYAO"]/300) - drift(0.2)) / 0.2
+	if math.Abs(za-zb) > 1e-9 {
+		t.Errorf("regime changed the shock instead of the volatility: %v vs %v", za, zb)
+	}
+	if a["AKBNK"] != b["AKBNK"] {
+		t.Errorf("regime leaked to AKBNK: %v vs %v", a["AKBNK"], b["AKBNK"])
+	}
+
+	m.Overlay().ClearRegime("r")
+	a, _ = m.Step(prices, dt)
+	b, _ = calm.Step(prices, dt)
+	if a["THYAO"] != b["THYAO"] {
+		t.Errorf("cleared regime still active: %v vs %v", a["THYAO"], b["THYAO"])
+	}
+}
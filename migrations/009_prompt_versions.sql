@@ -0,0 +1,28 @@
+-- ============================================
+-- Market AI - Versioned Prompt Templates
+-- ============================================
+
+-- Per-agent prompt set override (NULL = strategy/default selection)
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS prompt_set VARCHAR(50);
+
+-- Prompt revision used for each decision
+ALTER TABLE agent_decisions ADD COLUMN IF NOT EXISTS prompt_version VARCHAR(50);
+ALTER TABLE agent_decisions ADD COLUMN IF NOT EXISTS prompt_hash VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_decisions_prompt_hash ON agent_decisions(prompt_hash);
+
+-- View: decision statistics per prompt revision
+CREATE OR REPLACE VIEW v_prompt_version_performance AS
+SELECT
+    d.prompt_version,
+    d.prompt_hash,
+    COUNT(*) AS decisions,
+    COUNT(*) FILTER (WHERE d.decision = 'HOLD') AS holds,
+    COUNT(*) FILTER (WHERE d.executed) AS executed,
+    AVG(d.confidence_score) AS avg_confidence,
+    COALESCE(SUM(d.actual_profit_loss), 0) AS total_profit_loss,
+    MIN(d.created_at) AS first_seen,
+    MAX(d.created_at) AS last_seen
+FROM agent_decisions d
+WHERE d.prompt_hash IS NOT NULL
+GROUP BY d.prompt_version, d.prompt_hash;
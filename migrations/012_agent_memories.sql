@@ -0,0 +1,22 @@
+-- ============================================
+-- Market AI - Agent Memory & Reflection
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS agent_memories (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    decision_id UUID REFERENCES agent_decisions(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('lesson', 'reflection')),
+    stock_symbol VARCHAR(10),
+    situation TEXT,
+    content TEXT NOT NULL,
+    profit_loss DECIMAL(15,2),
+    importance DECIMAL(6,2) DEFAULT 0,
+    source VARCHAR(20) DEFAULT 'rule' CHECK (source IN ('rule', 'agent')),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_memories_agent_kind ON agent_memories(agent_id, kind, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_memories_symbol ON agent_memories(agent_id, stock_symbol);
+-- One lesson per decision
+CREATE UNIQUE INDEX IF NOT EXISTS idx_memories_decision_lesson ON agent_memories(decision_id) WHERE kind = 'lesson';
@@ -0,0 +1,34 @@
+-- ============================================
+-- Market AI - Human-in-the-loop Trade Approval
+-- ============================================
+
+-- Agents in approval mode turn non-HOLD decisions into proposals
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS approval_mode BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS trade_proposals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    decision_id UUID REFERENCES agent_decisions(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected', 'expired', 'failed')),
+    action VARCHAR(10) NOT NULL,
+    orders JSONB NOT NULL,
+    execution_mode VARCHAR(10) NOT NULL DEFAULT 'atomic',
+    confidence DECIMAL(5,2),
+    reasoning TEXT,
+    expires_at TIMESTAMP NOT NULL,
+
+    -- Approval
+    decided_by VARCHAR(100),
+    decided_at TIMESTAMP,
+    decision_note TEXT,
+
+    -- Execution result
+    results JSONB,
+    error TEXT,
+
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_proposals_status ON trade_proposals(status, expires_at);
+CREATE INDEX IF NOT EXISTS idx_proposals_agent ON trade_proposals(agent_id, created_at DESC);
@@ -0,0 +1,20 @@
+-- ============================================
+-- Market AI - Decision Audit Trail
+-- ============================================
+-- Exact prompts, raw model answer, latency and provider of every decision.
+-- Kept apart from agent_decisions so the large texts don't bloat it; the
+-- market snapshot the decision saw lives in agent_decisions.market_context.
+CREATE TABLE IF NOT EXISTS agent_decision_audits (
+    decision_id UUID PRIMARY KEY REFERENCES agent_decisions(id) ON DELETE CASCADE,
+    system_prompt TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    raw_response TEXT,
+    latency_ms INTEGER,
+    provider VARCHAR(30),
+    model VARCHAR(100),
+    model_version VARCHAR(100),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Trades of a decision, looked up by the audit endpoint
+CREATE INDEX IF NOT EXISTS idx_shadow_trades_decision ON shadow_trades(decision_id);
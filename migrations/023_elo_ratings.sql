@@ -0,0 +1,39 @@
+-- ============================================
+-- Market AI - Elo Ratings
+-- ============================================
+-- A daily head-to-head job plays every pair of agents with a row in
+-- agent_daily_stats: the higher daily return wins. agent_matchups keeps the
+-- pair's record (agent1_id < agent2_id), agent_ratings the Elo rating.
+
+-- Each pair is stored once, in id order
+CREATE UNIQUE INDEX IF NOT EXISTS idx_matchups_pair ON agent_matchups(LEAST(agent1_id, agent2_id), GREATEST(agent1_id, agent2_id));
+
+CREATE TABLE IF NOT EXISTS agent_ratings (
+    agent_id UUID PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    rating DECIMAL(8,2) NOT NULL DEFAULT 1500,
+    peak_rating DECIMAL(8,2) NOT NULL DEFAULT 1500,
+    games INTEGER NOT NULL DEFAULT 0,
+    wins INTEGER NOT NULL DEFAULT 0,
+    losses INTEGER NOT NULL DEFAULT 0,
+    draws INTEGER NOT NULL DEFAULT 0,
+    last_played DATE,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ratings_rating ON agent_ratings(rating DESC);
+
+-- Rating after each played day
+CREATE TABLE IF NOT EXISTS agent_rating_history (
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    stat_date DATE NOT NULL,
+    rating DECIMAL(8,2) NOT NULL,
+    change DECIMAL(8,2) NOT NULL,
+    PRIMARY KEY (agent_id, stat_date)
+);
+
+-- Days already played, so every day counts once
+CREATE TABLE IF NOT EXISTS matchup_days (
+    stat_date DATE PRIMARY KEY,
+    agents INTEGER NOT NULL,
+    processed_at TIMESTAMP DEFAULT NOW()
+);
@@ -0,0 +1,154 @@
+-- ============================================
+-- Market AI - Configurable Leaderboard Boards
+-- ============================================
+-- Agents are scored in Go on named boards (weighted Sharpe, ROI, drawdown,
+-- calibration and cost efficiency, with minimum-activity eligibility). Every
+-- board's standings are stored here; the default board's rank is written back
+-- to leaderboard_rankings.rank_overall. Seasons rank on the default board or on
+-- the board their rules name.
+
+CREATE TABLE IF NOT EXISTS leaderboard_board_rankings (
+    board VARCHAR(50) NOT NULL,
+    agent_id UUID NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    rank INTEGER NOT NULL,
+    score DECIMAL(10,4) NOT NULL,     -- 0..100
+    eligible BOOLEAN NOT NULL,        -- met the board's minimum activity
+    components JSONB NOT NULL DEFAULT '{}', -- each metric scaled to 0..1
+    is_default BOOLEAN NOT NULL DEFAULT FALSE, -- the first configured board
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (board, agent_id)
+);
+
+ALTER TABLE leaderboard_board_rankings ADD COLUMN IF NOT EXISTS is_default BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Shadow agents are ranked among themselves on the default board, on their shadow ledgers
+ALTER TABLE shadow_metrics ADD COLUMN IF NOT EXISTS rank INTEGER;
+ALTER TABLE shadow_metrics ADD COLUMN IF NOT EXISTS score DECIMAL(10,4);
+
+CREATE INDEX IF NOT EXISTS idx_board_rankings_rank ON leaderboard_board_rankings(board, rank);
+
+-- Season standings score on a board instead of the fixed ROI/win rate/P&L
+-- formula: 'overall' is the default board, any other name but 'roi' and
+-- 'profit' is a board id. Until the boards are ranked the season ranks on ROI.
+CREATE OR REPLACE FUNCTION update_season_standings(p_season_id UUID)
+RETURNS VOID AS $$
+DECLARE
+    v_started TIMESTAMP;
+    v_min_trades INTEGER;
+    v_ranking TEXT;
+BEGIN
+    SELECT started_at, COALESCE((rules->>'min_trades')::INTEGER, 0), COALESCE(rules->>'ranking', 'overall')
+    INTO v_started, v_min_trades, v_ranking
+    FROM seasons WHERE id = p_season_id AND status = 'active';
+    IF NOT FOUND THEN
+        RETURN;
+    END IF;
+    IF v_ranking NOT IN ('roi', 'profit') AND NOT EXISTS (
+        SELECT 1 FROM leaderboard_board_rankings
+        WHERE board = v_ranking OR (v_ranking = 'overall' AND is_default)
+    ) THEN
+        v_ranking := 'roi';
+    END IF;
+
+    WITH stats AS (
+        SELECT sp.agent_id,
+               sp.starting_capital,
+               a.current_balance + calculate_portfolio_value(a.id) AS total_value,
+               (SELECT COUNT(*) FROM trades tr
+                WHERE tr.agent_id = sp.agent_id AND tr.created_at >= v_started) AS total_trades
+        FROM season_participants sp
+        JOIN agents a ON a.id = sp.agent_id
+        WHERE sp.season_id = p_season_id
+    ), scored AS (
+        SELECT s.*,
+               s.total_value - s.starting_capital AS pl,
+               (s.total_value - s.starting_capital) / s.starting_capital * 100 AS roi,
+               b.score AS board_score
+        FROM stats s
+        LEFT JOIN leaderboard_board_rankings b
+               ON b.agent_id = s.agent_id AND (b.board = v_ranking OR (v_ranking = 'overall' AND b.is_default))
+    )
+    UPDATE season_participants sp SET
+        total_value = sc.total_value,
+        profit_loss = sc.pl,
+        roi = sc.roi,
+        total_trades = sc.total_trades,
+        score = CASE v_ranking
+                    WHEN 'roi' THEN sc.roi
+                    WHEN 'profit' THEN sc.pl
+                    ELSE COALESCE(sc.board_score, 0)
+                END,
+        eligible = sc.total_trades >= v_min_trades,
+        updated_at = NOW()
+    FROM scored sc
+    WHERE sp.season_id = p_season_id AND sp.agent_id = sc.agent_id;
+
+    UPDATE season_participants sp SET rank = r.rank
+    FROM (
+        SELECT agent_id, RANK() OVER (ORDER BY eligible DESC, score DESC) AS rank
+        FROM season_participants WHERE season_id = p_season_id
+    ) r
+    WHERE sp.season_id = p_season_id AND sp.agent_id = r.agent_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The fixed ROI/win rate/P&L formula is gone: rank_overall starts out as the
+-- ROI rank (the season rank during a season) and is replaced from the boards
+-- after they are ranked.
+CREATE OR REPLACE FUNCTION update_leaderboard_rankings()
+RETURNS void AS $$
+DECLARE
+    v_season UUID;
+BEGIN
+    -- Clear old rankings
+    TRUNCATE leaderboard_rankings;
+
+    SELECT id INTO v_season FROM seasons WHERE status = 'active';
+    IF v_season IS NOT NULL THEN
+        PERFORM update_season_standings(v_season);
+
+        INSERT INTO leaderboard_rankings (
+            agent_id, rank_overall, rank_by_roi, rank_by_winrate, rank_by_profit,
+            current_roi, current_profit_loss, current_win_rate, total_trades, updated_at
+        )
+        SELECT
+            sp.agent_id,
+            sp.rank,
+            RANK() OVER (ORDER BY sp.roi DESC),
+            RANK() OVER (ORDER BY sp.win_rate DESC),
+            RANK() OVER (ORDER BY sp.profit_loss DESC),
+            sp.roi, sp.profit_loss, sp.win_rate, sp.total_trades, NOW()
+        FROM season_participants sp
+        JOIN agents a ON a.id = sp.agent_id
+        JOIN agent_metrics am ON am.agent_id = sp.agent_id
+        WHERE sp.season_id = v_season AND a.status = 'active';
+        RETURN;
+    END IF;
+
+    -- Calculate new rankings from agent_metrics
+    WITH agent_stats AS (
+        SELECT
+            a.id AS agent_id,
+            am.roi,
+            am.total_profit_loss,
+            am.win_rate,
+            am.total_trades,
+            RANK() OVER (ORDER BY am.roi DESC) AS rank_roi,
+            RANK() OVER (ORDER BY am.total_profit_loss DESC) AS rank_profit,
+            RANK() OVER (ORDER BY am.win_rate DESC) AS rank_winrate
+        FROM agents a
+        JOIN agent_metrics am ON a.id = am.agent_id
+        WHERE a.status = 'active'
+    )
+    INSERT INTO leaderboard_rankings (
+        agent_id, rank_overall, rank_by_roi, rank_by_winrate, rank_by_profit,
+        current_roi, current_profit_loss, current_win_rate, total_trades, updated_at
+    )
+    SELECT
+        agent_id, rank_roi, rank_roi, rank_winrate, rank_profit,
+        roi, total_profit_loss, win_rate, total_trades, NOW()
+    FROM agent_stats;
+
+    RAISE NOTICE 'Leaderboard rankings updated';
+END;
+$$ LANGUAGE plpgsql;
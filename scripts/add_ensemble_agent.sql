@@ -0,0 +1,17 @@
+-- ============================================
+-- Add Ensemble (Committee) Agent
+-- Members are configured with ENSEMBLE_MEMBERS / ENSEMBLE_JUDGE
+-- ============================================
+
+-- Insert ensemble agent
+INSERT INTO agents (name, model, status, initial_balance, current_balance) VALUES
+('Ensemble Committee', 'ensemble', 'active', 100000.00, 100000.00)
+ON CONFLICT DO NOTHING;
+
+-- Initialize agent metrics for ensemble agent
+INSERT INTO agent_metrics (agent_id)
+SELECT id FROM agents WHERE name = 'Ensemble Committee'
+ON CONFLICT (agent_id) DO NOTHING;
+
+-- Verify agent was created
+SELECT id, name, model, status, current_balance FROM agents WHERE name = 'Ensemble Committee';